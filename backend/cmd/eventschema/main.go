@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"assetsentinel/internal/events"
+)
+
+func main() {
+	out := flag.String("out", "", "output file; defaults to stdout")
+	flag.Parse()
+
+	schema, err := json.MarshalIndent(events.Schema(), "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to encode schema: %v", err)
+	}
+	schema = append(schema, '\n')
+
+	if *out == "" {
+		os.Stdout.Write(schema)
+		return
+	}
+	if err := os.WriteFile(*out, schema, 0644); err != nil {
+		log.Fatalf("Failed to write schema: %v", err)
+	}
+}
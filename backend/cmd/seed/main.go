@@ -43,6 +43,20 @@ func main() {
 		log.Printf("Created admin user: %s", admin.Email)
 	}
 
+	superHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+	superAdmin := &repository.User{
+		OrganizationID: org.ID,
+		Email:          "platform@assetsentinel.local",
+		PasswordHash:   string(superHash),
+		FullName:       "Platform Admin",
+		Role:           "super_admin",
+	}
+	if err := repo.CreateUser(superAdmin); err != nil {
+		log.Printf("Platform admin already exists or error: %v", err)
+	} else {
+		log.Printf("Created platform admin user: %s", superAdmin.Email)
+	}
+
 	techHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
 	tech := &repository.User{
 		OrganizationID: org.ID,
@@ -2,22 +2,37 @@ package main
 
 import (
 	"assetsentinel/internal/config"
+	"assetsentinel/internal/events"
 	"assetsentinel/internal/handlers"
+	"assetsentinel/internal/jwtkeys"
+	"assetsentinel/internal/mailer"
 	"assetsentinel/internal/middleware"
+	"assetsentinel/internal/rbac"
 	"assetsentinel/internal/repository"
 	"assetsentinel/internal/services"
 	"assetsentinel/internal/websocket"
 	"assetsentinel/internal/worker"
+	"context"
+	"errors"
 	"log"
-	"os"
+	"net/http"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 	log.Printf("Starting server on port %s", cfg.Port)
 	log.Printf("Database path: %s", cfg.DBPath)
 
@@ -31,121 +46,357 @@ func main() {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
-	wsHub := websocket.NewHub()
-	go wsHub.Run()
+	signingKeys, err := loadSigningKeys(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
 
 	repo := repository.NewRepository(db)
-	authService := services.NewAuthService(repo, cfg.JWTSecret)
+	roleService := services.NewRoleService(repo)
+
+	backplane, err := newBackplane(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to backplane: %v", err)
+	}
+	if backplane != nil {
+		defer backplane.Close()
+	}
+
+	wsHub := websocket.NewHub(services.NewTopicService(repo, roleService), repo, websocket.HubOptions{
+		EventRetention:     cfg.EventLogRetention,
+		SlowConsumerPolicy: cfg.SlowConsumerPolicy,
+		Backplane:          backplane,
+	})
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	var background sync.WaitGroup
+	runInBackground(&background, func() { wsHub.Run(hubCtx) })
+
+	passwordPolicy, err := services.NewPasswordPolicy(cfg.PasswordMinLength, cfg.PasswordHistorySize, cfg.PasswordBlocklistFile)
+	if err != nil {
+		log.Fatalf("Failed to load password policy: %v", err)
+	}
+
+	var mail mailer.Mailer = mailer.NewLogMailer()
+	if cfg.SMTPHost != "" {
+		mail = mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+	}
+
+	loginGuard := services.NewLoginGuard(repo, cfg.LoginMaxAccountFailures, cfg.LoginAccountFreeFailures, cfg.LoginMaxIPFailures, time.Duration(cfg.LoginLockoutMinutes)*time.Minute)
+	authService := services.NewAuthService(repo, signingKeys, loginGuard, passwordPolicy)
+	passwordResetService := services.NewPasswordResetService(repo, passwordPolicy, mail, loginGuard, cfg.AppBaseURL, time.Duration(cfg.PasswordResetTTL)*time.Minute)
+	connectionTickets := services.NewConnectionTicketService(repo)
 	assetService := services.NewAssetService(repo)
-	maintenanceService := services.NewMaintenanceService(repo, wsHub)
-	workOrderService := services.NewWorkOrderService(repo, wsHub)
-	inventoryService := services.NewInventoryService(repo, wsHub)
+	webhookService := services.NewWebhookService(repo)
+	maintenanceService := services.NewMaintenanceService(repo)
+	notificationService := services.NewNotificationService(repo, wsHub, map[string]services.NotificationChannel{
+		services.ChannelEmail: services.NewEmailChannel(mail),
+		services.ChannelChat:  services.NewChatChannel(),
+	}, cfg.NotificationDigestHour)
+	publisher := events.NewPublisher(
+		events.Subscription{Name: "realtime", Consumer: wsHub},
+		events.Subscription{Name: "webhooks", Consumer: webhookService},
+		events.Subscription{Name: "notifications", Consumer: notificationService},
+		events.Subscription{Name: "audit", Consumer: services.NewAuditTrail(repo)},
+	)
+	dispatchService := services.NewDispatchService(repo, roleService)
+	workOrderService := services.NewWorkOrderService(repo, notificationService, dispatchService)
+	inventoryService := services.NewInventoryService(repo)
 	depreciationService := services.NewDepreciationService(repo)
+	jobService := services.NewJobService(repo)
+	calendarService := services.NewCalendarService(repo)
+	procedureService := services.NewProcedureService(repo)
+	calendarFeedService := services.NewCalendarFeedService(repo, roleService)
 
 	authHandler := handlers.NewAuthHandler(authService)
+	passwordResetHandler := handlers.NewPasswordResetHandler(passwordResetService)
 	assetHandler := handlers.NewAssetHandler(assetService)
 	maintenanceHandler := handlers.NewMaintenanceHandler(maintenanceService)
 	workOrderHandler := handlers.NewWorkOrderHandler(workOrderService)
 	inventoryHandler := handlers.NewInventoryHandler(inventoryService)
 	depreciationHandler := handlers.NewDepreciationHandler(depreciationService)
+	roleHandler := handlers.NewRoleHandler(roleService)
+	notificationHandler := handlers.NewNotificationHandler(notificationService)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	jobHandler := handlers.NewJobHandler(jobService)
+	calendarHandler := handlers.NewCalendarHandler(calendarService)
+	procedureHandler := handlers.NewProcedureHandler(procedureService)
+	calendarFeedHandler := handlers.NewCalendarFeedHandler(calendarFeedService, cfg.PublicURL)
+	dispatchHandler := handlers.NewDispatchHandler(dispatchService)
+
+	scheduler := worker.NewScheduler(repo, notificationService, workOrderService)
+	runInBackground(&background, func() { scheduler.Start(ctx) })
+
+	outboxRelay := worker.NewOutboxRelay(repo, publisher, time.Second)
+	runInBackground(&background, func() { outboxRelay.Start(ctx) })
+
+	webhookDispatcher := worker.NewDeliveryWorker(webhookService, 5*time.Second)
+	runInBackground(&background, func() { webhookDispatcher.Start(ctx) })
 
-	scheduler := worker.NewScheduler(repo, wsHub)
-	go scheduler.Start()
-	defer scheduler.Stop()
+	notificationDispatcher := worker.NewDeliveryWorker(notificationService, 10*time.Second)
+	runInBackground(&background, func() { notificationDispatcher.Start(ctx) })
 
 	r := gin.Default()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
 
 	r.Use(middleware.CORS())
 
-	r.GET("/ws", wsHub.HandleWebSocket)
+	r.GET("/ws", middleware.ConnectionTicket(connectionTickets, middleware.AuthMiddleware(signingKeys)), wsHub.HandleWebSocket)
+	r.GET("/events", middleware.ConnectionTicket(connectionTickets, middleware.AuthMiddleware(signingKeys)), wsHub.HandleSSE)
+	r.GET("/.well-known/jwks.json", handlers.GetJWKS(signingKeys))
+	r.GET("/feeds/:token", calendarFeedHandler.Feed)
 
 	auth := r.Group("/api/auth")
 	{
 		auth.POST("/login", authHandler.Login)
 		auth.POST("/register", authHandler.Register)
+		auth.POST("/password/forgot", passwordResetHandler.Forgot)
+		auth.POST("/password/reset", passwordResetHandler.Reset)
 	}
 
 	api := r.Group("/api")
-	api.Use(middleware.AuthMiddleware(cfg.JWTSecret))
+	api.Use(middleware.AuthMiddleware(signingKeys), middleware.LoadPermissions(roleService))
 	{
-		api.GET("/dashboard", handlers.GetDashboard(repo))
+		api.GET("/dashboard", middleware.RequirePermission(rbac.DashboardRead), handlers.GetDashboard(repo))
+
+		api.GET("/me/permissions", handlers.GetMyPermissions())
+		api.GET("/permissions", handlers.ListPermissions())
+		api.GET("/events/schema", handlers.GetEventSchema())
+
+		api.POST("/realtime/tickets", handlers.CreateConnectionTicket(connectionTickets))
+		api.GET("/realtime/metrics", middleware.RequirePermission(rbac.PlatformManage), handlers.GetRealtimeMetrics(wsHub))
+
+		notifications := api.Group("/notifications")
+		{
+			notifications.GET("", notificationHandler.List)
+			notifications.GET("/unread-count", notificationHandler.UnreadCount)
+			notifications.POST("/read-all", notificationHandler.MarkAllRead)
+			notifications.POST("/:id/read", notificationHandler.MarkRead)
+			notifications.GET("/preferences", notificationHandler.Preferences)
+			notifications.PUT("/preferences", notificationHandler.UpdatePreferences)
+			notifications.GET("/preferences/organization", middleware.RequirePermission(rbac.NotificationsManage), notificationHandler.OrganizationPreferences)
+			notifications.PUT("/preferences/organization", middleware.RequirePermission(rbac.NotificationsManage), notificationHandler.UpdateOrganizationPreferences)
+		}
 
 		assets := api.Group("/assets")
 		{
-			assets.GET("", assetHandler.List)
-			assets.POST("", middleware.RequireRole("admin", "maintenance_manager"), assetHandler.Create)
-			assets.GET("/:id", assetHandler.Get)
-			assets.PUT("/:id", middleware.RequireRole("admin", "maintenance_manager"), assetHandler.Update)
-			assets.DELETE("/:id", middleware.RequireRole("admin"), assetHandler.Delete)
+			assets.GET("", middleware.RequirePermission(rbac.AssetsRead), assetHandler.List)
+			assets.POST("", middleware.RequirePermission(rbac.AssetsWrite), assetHandler.Create)
+			assets.GET("/:id", middleware.RequirePermission(rbac.AssetsRead), assetHandler.Get)
+			assets.PUT("/:id", middleware.RequirePermission(rbac.AssetsWrite), assetHandler.Update)
+			assets.DELETE("/:id", middleware.RequirePermission(rbac.AssetsDelete), assetHandler.Delete)
 		}
 
 		maintenance := api.Group("/maintenance-plans")
 		{
-			maintenance.GET("", maintenanceHandler.List)
-			maintenance.POST("", middleware.RequireRole("admin", "maintenance_manager"), maintenanceHandler.Create)
-			maintenance.GET("/:id", maintenanceHandler.Get)
-			maintenance.PUT("/:id", middleware.RequireRole("admin", "maintenance_manager"), maintenanceHandler.Update)
-			maintenance.DELETE("/:id", middleware.RequireRole("admin"), maintenanceHandler.Delete)
+			maintenance.GET("", middleware.RequirePermission(rbac.MaintenanceRead), maintenanceHandler.List)
+			maintenance.POST("", middleware.RequirePermission(rbac.MaintenanceWrite), maintenanceHandler.Create)
+			maintenance.POST("/preview", middleware.RequirePermission(rbac.MaintenanceRead), maintenanceHandler.Preview)
+			maintenance.GET("/forecast", middleware.RequirePermission(rbac.MaintenanceRead), maintenanceHandler.Forecast)
+			maintenance.GET("/:id", middleware.RequirePermission(rbac.MaintenanceRead), maintenanceHandler.Get)
+			maintenance.GET("/:id/occurrences", middleware.RequirePermission(rbac.MaintenanceRead), maintenanceHandler.Occurrences)
+			maintenance.PUT("/:id", middleware.RequirePermission(rbac.MaintenanceWrite), maintenanceHandler.Update)
+			maintenance.DELETE("/:id", middleware.RequirePermission(rbac.MaintenanceDelete), maintenanceHandler.Delete)
+		}
+
+		maintenanceTasks := api.Group("/maintenance-tasks")
+		{
+			maintenanceTasks.GET("/:id", middleware.RequirePermission(rbac.MaintenanceRead), maintenanceHandler.GetTask)
+			maintenanceTasks.POST("/:id/complete", middleware.RequirePermission(rbac.MaintenanceWrite), maintenanceHandler.CompleteTask)
 		}
 
 		workOrders := api.Group("/work-orders")
 		{
-			workOrders.GET("", workOrderHandler.List)
-			workOrders.POST("", middleware.RequireRole("admin", "maintenance_manager"), workOrderHandler.Create)
-			workOrders.GET("/:id", workOrderHandler.Get)
-			workOrders.PUT("/:id", workOrderHandler.Update)
-			workOrders.DELETE("/:id", middleware.RequireRole("admin"), workOrderHandler.Delete)
+			workOrders.GET("", middleware.RequirePermission(rbac.WorkOrdersRead), workOrderHandler.List)
+			workOrders.POST("", middleware.RequirePermission(rbac.WorkOrdersCreate), workOrderHandler.Create)
+			workOrders.GET("/:id", middleware.RequirePermission(rbac.WorkOrdersRead), workOrderHandler.Get)
+			workOrders.PUT("/:id", middleware.RequirePermission(rbac.WorkOrdersUpdate), workOrderHandler.Update)
+			workOrders.DELETE("/:id", middleware.RequirePermission(rbac.WorkOrdersDelete), workOrderHandler.Delete)
+			workOrders.GET("/:id/procedure", middleware.RequirePermission(rbac.WorkOrdersRead), procedureHandler.WorkOrderProcedure)
+			workOrders.POST("/:id/procedure/results", middleware.RequirePermission(rbac.WorkOrdersUpdate), procedureHandler.SubmitResults)
+		}
+
+		dispatch := api.Group("/dispatch")
+		dispatch.Use(middleware.RequirePermission(rbac.WorkOrdersRead))
+		{
+			dispatch.GET("/schedule", dispatchHandler.Schedule)
+			dispatch.POST("/suggestions", dispatchHandler.Suggest)
+		}
+
+		procedures := api.Group("/procedures")
+		{
+			procedures.GET("", middleware.RequirePermission(rbac.MaintenanceRead), procedureHandler.List)
+			procedures.POST("", middleware.RequirePermission(rbac.MaintenanceWrite), procedureHandler.Create)
+			procedures.GET("/:id", middleware.RequirePermission(rbac.MaintenanceRead), procedureHandler.Get)
+			procedures.PUT("/:id", middleware.RequirePermission(rbac.MaintenanceWrite), procedureHandler.Update)
+			procedures.DELETE("/:id", middleware.RequirePermission(rbac.MaintenanceDelete), procedureHandler.Delete)
 		}
 
 		inventory := api.Group("/inventory")
 		{
-			inventory.GET("", inventoryHandler.List)
-			inventory.POST("", middleware.RequireRole("admin", "maintenance_manager"), inventoryHandler.Create)
-			inventory.GET("/:id", inventoryHandler.Get)
-			inventory.PUT("/:id", middleware.RequireRole("admin", "maintenance_manager"), inventoryHandler.Update)
-			inventory.DELETE("/:id", middleware.RequireRole("admin"), inventoryHandler.Delete)
+			inventory.GET("", middleware.RequirePermission(rbac.InventoryRead), inventoryHandler.List)
+			inventory.POST("", middleware.RequirePermission(rbac.InventoryWrite), inventoryHandler.Create)
+			inventory.GET("/:id", middleware.RequirePermission(rbac.InventoryRead), inventoryHandler.Get)
+			inventory.PUT("/:id", middleware.RequirePermission(rbac.InventoryWrite), inventoryHandler.Update)
+			inventory.DELETE("/:id", middleware.RequirePermission(rbac.InventoryDelete), inventoryHandler.Delete)
 		}
 
 		reports := api.Group("/reports")
+		reports.Use(middleware.RequirePermission(rbac.ReportsRead))
 		{
 			reports.GET("/depreciation/:asset_id", depreciationHandler.GetAssetDepreciation)
 			reports.GET("/costs/:asset_id", depreciationHandler.GetAssetCosts)
 			reports.GET("/costs", depreciationHandler.GetAllCosts)
 		}
 
+		businessCalendar := api.Group("/calendar")
+		{
+			businessCalendar.GET("", middleware.RequirePermission(rbac.MaintenanceRead), calendarHandler.Get)
+			businessCalendar.PUT("", middleware.RequirePermission(rbac.OrganizationsManage), calendarHandler.Update)
+			businessCalendar.GET("/holidays", middleware.RequirePermission(rbac.MaintenanceRead), calendarHandler.Holidays)
+			businessCalendar.POST("/holidays", middleware.RequirePermission(rbac.OrganizationsManage), calendarHandler.AddHoliday)
+			businessCalendar.DELETE("/holidays/:id", middleware.RequirePermission(rbac.OrganizationsManage), calendarHandler.DeleteHoliday)
+		}
+
+		calendarFeeds := api.Group("/calendar-feeds")
+		{
+			calendarFeeds.GET("", calendarFeedHandler.List)
+			calendarFeeds.POST("", calendarFeedHandler.Create)
+			calendarFeeds.DELETE("/:id", calendarFeedHandler.Revoke)
+		}
+
 		audit := api.Group("/audit")
+		audit.Use(middleware.RequirePermission(rbac.AuditRead))
 		{
 			audit.GET("", handlers.GetAuditLogs(repo))
 		}
 
 		orgs := api.Group("/organizations")
-		orgs.Use(middleware.RequireRole("admin"))
+		orgs.Use(middleware.RequirePermission(rbac.OrganizationsManage))
 		{
 			orgs.GET("", handlers.ListOrganizations(repo))
-			orgs.POST("", handlers.CreateOrganization(repo))
+			orgs.POST("", middleware.RequirePermission(rbac.PlatformManage), handlers.CreateOrganization(repo))
 			orgs.GET("/:id", handlers.GetOrganization(repo))
 			orgs.PUT("/:id", handlers.UpdateOrganization(repo))
 		}
 
 		users := api.Group("/users")
-		users.Use(middleware.RequireRole("admin"))
+		users.Use(middleware.RequirePermission(rbac.UsersManage))
 		{
 			users.GET("", handlers.ListUsers(repo))
-			users.POST("", handlers.CreateUser(repo))
+			users.POST("", handlers.CreateUser(authService, roleService))
 			users.GET("/:id", handlers.GetUser(repo))
-			users.PUT("/:id", handlers.UpdateUser(repo))
+			users.PUT("/:id", handlers.UpdateUser(repo, roleService))
 			users.DELETE("/:id", handlers.DeleteUser(repo))
+			users.POST("/:id/unlock", handlers.UnlockUser(repo, loginGuard))
+		}
+
+		security := api.Group("/security")
+		security.Use(middleware.RequirePermission(rbac.SecurityRead))
+		{
+			security.GET("/events", handlers.ListSecurityEvents(loginGuard))
+		}
+
+		roles := api.Group("/roles")
+		roles.Use(middleware.RequirePermission(rbac.RolesManage))
+		{
+			roles.GET("", roleHandler.List)
+			roles.POST("", roleHandler.Create)
+			roles.GET("/:id", roleHandler.Get)
+			roles.PUT("/:id", roleHandler.Update)
+			roles.DELETE("/:id", roleHandler.Delete)
 		}
-	}
 
-	log.Printf("Server starting on http://localhost:%s", cfg.Port)
-	if err := r.Run(":" + cfg.Port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+		webhooks := api.Group("/webhooks")
+		webhooks.Use(middleware.RequirePermission(rbac.WebhooksManage))
+		{
+			webhooks.GET("", webhookHandler.List)
+			webhooks.POST("", webhookHandler.Create)
+			webhooks.GET("/:id", webhookHandler.Get)
+			webhooks.PUT("/:id", webhookHandler.Update)
+			webhooks.DELETE("/:id", webhookHandler.Delete)
+			webhooks.GET("/:id/deliveries", webhookHandler.Deliveries)
+			webhooks.POST("/deliveries/:id/redeliver", webhookHandler.Redeliver)
+		}
+
+		jobs := api.Group("/jobs")
+		jobs.Use(middleware.RequirePermission(rbac.PlatformManage))
+		{
+			jobs.GET("", jobHandler.List)
+			jobs.GET("/:name", jobHandler.Get)
+			jobs.PUT("/:name", jobHandler.Update)
+			jobs.GET("/:name/runs", jobHandler.Runs)
+			jobs.POST("/:name/run", jobHandler.Trigger)
+		}
 	}
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	srv := &http.Server{Addr: ":" + cfg.Port, Handler: r}
+	go func() {
+		log.Printf("Server starting on http://localhost:%s", cfg.Port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
 
 	log.Println("Shutting down server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout)*time.Second)
+	defer cancel()
+
+	stopHub()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP server did not shut down cleanly: %v", err)
+	}
+	if err := waitFor(shutdownCtx, &background); err != nil {
+		log.Printf("Background workers did not finish before the shutdown timeout: %v", err)
+		return
+	}
+	log.Println("Server stopped")
+}
+
+func runInBackground(wg *sync.WaitGroup, fn func()) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		fn()
+	}()
+}
+
+func waitFor(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func loadSigningKeys(cfg *config.Config) (*jwtkeys.KeySet, error) {
+	if cfg.JWTKeysDir == "" {
+		return jwtkeys.NewHMAC(cfg.JWTSecret), nil
+	}
+
+	keys, err := jwtkeys.LoadDir(cfg.JWTKeysDir, cfg.JWTActiveKID)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.JWTSecret != config.DefaultJWTSecret {
+		keys.AllowLegacyHMAC(cfg.JWTSecret)
+	}
+	return keys, nil
+}
+
+func newBackplane(cfg *config.Config) (websocket.Backplane, error) {
+	if cfg.Backplane == "redis" {
+		return websocket.NewRedisBackplane(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisChannel)
+	}
+	return nil, nil
 }
@@ -0,0 +1,126 @@
+package calendar
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+const (
+	DateLayout = "2006-01-02"
+
+	ShiftNone     = "none"
+	ShiftNext     = "next"
+	ShiftPrevious = "previous"
+
+	MaxShiftDays = 31
+
+	DefaultDailyHours = 8.0
+
+	// WorkdayStartHour is when the working day starts in the calendar's time
+	// zone.
+	WorkdayStartHour = 8
+)
+
+var ErrInvalidTimeZone = errors.New("invalid time zone")
+
+var DefaultWorkingDays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+
+type Calendar struct {
+	Location    *time.Location
+	WorkingDays []time.Weekday
+	Holidays    map[string]string
+	DailyHours  float64
+}
+
+func New(location *time.Location, workingDays []time.Weekday, holidays map[string]string) *Calendar {
+	if location == nil {
+		location = time.UTC
+	}
+	if holidays == nil {
+		holidays = map[string]string{}
+	}
+	days := append([]time.Weekday{}, workingDays...)
+	sort.Slice(days, func(i, j int) bool { return days[i] < days[j] })
+	return &Calendar{Location: location, WorkingDays: days, Holidays: holidays, DailyHours: DefaultDailyHours}
+}
+
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, ErrInvalidTimeZone
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, ErrInvalidTimeZone
+	}
+	return location, nil
+}
+
+func ValidShift(policy string) bool {
+	return policy == ShiftNone || policy == ShiftNext || policy == ShiftPrevious
+}
+
+func Date(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func (c *Calendar) Today(now time.Time) time.Time {
+	return Date(now.In(c.Location))
+}
+
+func (c *Calendar) IsWorkingDay(day time.Time) bool {
+	if _, holiday := c.Holidays[day.Format(DateLayout)]; holiday {
+		return false
+	}
+	for _, weekday := range c.WorkingDays {
+		if day.Weekday() == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Calendar) Shift(day time.Time, policy string) time.Time {
+	day = Date(day)
+	step := 0
+	switch policy {
+	case ShiftNext:
+		step = 1
+	case ShiftPrevious:
+		step = -1
+	default:
+		return day
+	}
+	for i := 0; i <= MaxShiftDays; i++ {
+		candidate := day.AddDate(0, 0, i*step)
+		if c.IsWorkingDay(candidate) {
+			return candidate
+		}
+	}
+	return day
+}
+
+// WorkingHours is the number of working hours one person has on the days from
+// start up to but not including end.
+func (c *Calendar) WorkingHours(start, end time.Time) float64 {
+	hours := 0.0
+	for day := Date(start); day.Before(Date(end)); day = day.AddDate(0, 0, 1) {
+		if c.IsWorkingDay(day) {
+			hours += c.DailyHours
+		}
+	}
+	return hours
+}
+
+// WorkdayWindow returns when the working hours of day start and end, in the
+// calendar's time zone.
+func (c *Calendar) WorkdayWindow(day time.Time) (time.Time, time.Time) {
+	year, month, date := day.Date()
+	start := time.Date(year, month, date, WorkdayStartHour, 0, 0, 0, c.Location)
+	return start, start.Add(time.Duration(c.DailyHours * float64(time.Hour)))
+}
+
+func (c *Calendar) OverdueCutoff(now time.Time) time.Time {
+	return c.Shift(c.Today(now), ShiftPrevious)
+}
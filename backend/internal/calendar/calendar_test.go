@@ -0,0 +1,129 @@
+package calendar
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	location, err := LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location
+}
+
+func day(value string) time.Time {
+	parsed, err := time.Parse(DateLayout, value)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+func TestTodayUsesTheCalendarTimeZone(t *testing.T) {
+	newYork := New(mustLoad(t, "America/New_York"), DefaultWorkingDays, nil)
+	auckland := New(mustLoad(t, "Pacific/Auckland"), DefaultWorkingDays, nil)
+
+	tests := []struct {
+		cal  *Calendar
+		now  time.Time
+		want string
+	}{
+		// 01:30 EST, half an hour before clocks go forward.
+		{newYork, time.Date(2026, 3, 8, 6, 30, 0, 0, time.UTC), "2026-03-08"},
+		{newYork, time.Date(2026, 3, 8, 4, 30, 0, 0, time.UTC), "2026-03-07"},
+		// 01:30 EDT the second time round, after clocks go back.
+		{newYork, time.Date(2026, 11, 1, 6, 30, 0, 0, time.UTC), "2026-11-01"},
+		{auckland, time.Date(2026, 4, 4, 13, 30, 0, 0, time.UTC), "2026-04-05"},
+	}
+	for _, tt := range tests {
+		got := tt.cal.Today(tt.now)
+		if got.Format(DateLayout) != tt.want || got.Location() != time.UTC || got.Hour() != 0 {
+			t.Errorf("Today(%v) in %s = %v, want midnight of %s", tt.now, tt.cal.Location, got, tt.want)
+		}
+	}
+}
+
+func TestShift(t *testing.T) {
+	cal := New(mustLoad(t, "America/New_York"), DefaultWorkingDays, map[string]string{
+		"2026-12-25": "Christmas Day",
+		"2026-12-28": "Boxing Day (observed)",
+	})
+
+	tests := []struct {
+		from, policy, want string
+	}{
+		{"2026-03-07", ShiftNext, "2026-03-09"},
+		{"2026-03-08", ShiftPrevious, "2026-03-06"},
+		{"2026-10-31", ShiftNext, "2026-11-02"},
+		{"2026-12-25", ShiftNext, "2026-12-29"},
+		{"2026-12-27", ShiftPrevious, "2026-12-24"},
+		{"2026-12-25", ShiftNone, "2026-12-25"},
+		{"2026-12-24", ShiftNext, "2026-12-24"},
+	}
+	for _, tt := range tests {
+		got := cal.Shift(day(tt.from), tt.policy)
+		if got.Format(DateLayout) != tt.want || !got.Equal(Date(got)) {
+			t.Errorf("Shift(%s, %s) = %v, want %s", tt.from, tt.policy, got, tt.want)
+		}
+	}
+
+	closed := New(time.UTC, nil, nil)
+	if got := closed.Shift(day("2026-06-01"), ShiftNext); got.Format(DateLayout) != "2026-06-01" {
+		t.Errorf("Shift without working days = %v, want the original date", got)
+	}
+}
+
+func TestOverdueCutoff(t *testing.T) {
+	holidays := map[string]string{"2026-12-25": "Christmas Day"}
+	newYork := New(mustLoad(t, "America/New_York"), DefaultWorkingDays, holidays)
+	berlin := New(mustLoad(t, "Europe/Berlin"), DefaultWorkingDays, holidays)
+
+	tests := []struct {
+		name string
+		cal  *Calendar
+		now  time.Time
+		want string
+	}{
+		{"Sunday evening in New York skips the weekend and the holiday", newYork, time.Date(2026, 12, 28, 3, 0, 0, 0, time.UTC), "2026-12-24"},
+		{"the same instant is Monday in Berlin", berlin, time.Date(2026, 12, 28, 3, 0, 0, 0, time.UTC), "2026-12-28"},
+		{"the night clocks go forward in Berlin", berlin, time.Date(2026, 3, 29, 0, 30, 0, 0, time.UTC), "2026-03-27"},
+		{"the night clocks go back in Berlin", berlin, time.Date(2026, 10, 25, 1, 30, 0, 0, time.UTC), "2026-10-23"},
+		{"a working day is its own cutoff", newYork, time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC), "2026-03-10"},
+	}
+	for _, tt := range tests {
+		if got := tt.cal.OverdueCutoff(tt.now); got.Format(DateLayout) != tt.want {
+			t.Errorf("%s: OverdueCutoff = %s, want %s", tt.name, got.Format(DateLayout), tt.want)
+		}
+	}
+}
+
+func TestWorkingHours(t *testing.T) {
+	cal := New(mustLoad(t, "America/New_York"), DefaultWorkingDays, map[string]string{"2026-03-11": "Founders' Day"})
+	// The week after clocks go forward has a 23-hour Sunday but five working days.
+	if got := cal.WorkingHours(day("2026-03-08"), day("2026-03-15")); got != 32 {
+		t.Errorf("WorkingHours = %v, want 4 days of 8 hours", got)
+	}
+	cal.DailyHours = 7.5
+	if got := cal.WorkingHours(day("2026-03-16"), day("2026-03-23")); got != 37.5 {
+		t.Errorf("WorkingHours = %v, want 5 days of 7.5 hours", got)
+	}
+	if got := cal.WorkingHours(day("2026-03-16"), day("2026-03-16")); got != 0 {
+		t.Errorf("WorkingHours of an empty range = %v", got)
+	}
+}
+
+func TestNewAndLoadLocation(t *testing.T) {
+	cal := New(nil, []time.Weekday{time.Friday, time.Monday}, nil)
+	if cal.Location != time.UTC || cal.WorkingDays[0] != time.Monday || cal.Holidays == nil {
+		t.Errorf("New = %+v, want UTC, sorted days and an empty holiday map", cal)
+	}
+	for _, name := range []string{"", "Local", "Mars/Olympus_Mons"} {
+		if _, err := LoadLocation(name); !errors.Is(err, ErrInvalidTimeZone) {
+			t.Errorf("LoadLocation(%q) = %v, want ErrInvalidTimeZone", name, err)
+		}
+	}
+}
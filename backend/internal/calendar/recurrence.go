@@ -0,0 +1,77 @@
+package calendar
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+var ErrInvalidRecurrence = errors.New("invalid recurrence rule")
+
+type Recurrence struct {
+	rule *rrule.RRule
+}
+
+func ParseRecurrence(rule string, start time.Time) (*Recurrence, error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if strings.ContainsAny(rule, "\r\n") {
+		return nil, fmt.Errorf("%w: only a single RRULE line is supported", ErrInvalidRecurrence)
+	}
+	options, err := rrule.StrToROption(rule)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRecurrence, err)
+	}
+	if options.Freq > rrule.DAILY {
+		return nil, fmt.Errorf("%w: FREQ must be YEARLY, MONTHLY, WEEKLY or DAILY", ErrInvalidRecurrence)
+	}
+	if len(options.Byhour) > 0 || len(options.Byminute) > 0 || len(options.Bysecond) > 0 {
+		return nil, fmt.Errorf("%w: BYHOUR, BYMINUTE and BYSECOND are not supported", ErrInvalidRecurrence)
+	}
+	return newRecurrence(*options, start)
+}
+
+func IntervalRecurrence(days int, start time.Time) (*Recurrence, error) {
+	if days < 1 {
+		return nil, fmt.Errorf("%w: frequency_days must be at least 1", ErrInvalidRecurrence)
+	}
+	return newRecurrence(rrule.ROption{Freq: rrule.DAILY, Interval: days}, start)
+}
+
+func newRecurrence(options rrule.ROption, start time.Time) (*Recurrence, error) {
+	options.Dtstart = Date(start)
+	if !options.Until.IsZero() {
+		options.Until = Date(options.Until)
+	}
+	rule, err := rrule.NewRRule(options)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRecurrence, err)
+	}
+	return &Recurrence{rule: rule}, nil
+}
+
+func (r *Recurrence) String() string {
+	return r.rule.OrigOptions.RRuleString()
+}
+
+func (r *Recurrence) From(day time.Time) (time.Time, bool) {
+	next := r.rule.After(Date(day), true)
+	return next, !next.IsZero()
+}
+
+func (r *Recurrence) After(day time.Time) (time.Time, bool) {
+	next := r.rule.After(Date(day), false)
+	return next, !next.IsZero()
+}
+
+func (r *Recurrence) Next(from time.Time, count int) []time.Time {
+	occurrences := make([]time.Time, 0, count)
+	next, ok := r.From(from)
+	for ok && len(occurrences) < count {
+		occurrences = append(occurrences, next)
+		next, ok = r.After(next)
+	}
+	return occurrences
+}
@@ -0,0 +1,92 @@
+package calendar
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func dates(times []time.Time) string {
+	formatted := make([]string, len(times))
+	for i, t := range times {
+		formatted[i] = t.Format(DateLayout)
+	}
+	return strings.Join(formatted, " ")
+}
+
+func TestParseRecurrence(t *testing.T) {
+	start := day("2026-01-05")
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{"FREQ=WEEKLY;BYDAY=MO,TH", "2026-01-05 2026-01-08 2026-01-12 2026-01-15"},
+		{"RRULE:FREQ=MONTHLY;BYMONTHDAY=-1", "2026-01-31 2026-02-28 2026-03-31 2026-04-30"},
+		{"FREQ=MONTHLY;BYDAY=1MO", "2026-01-05 2026-02-02 2026-03-02 2026-04-06"},
+		{"FREQ=YEARLY;BYMONTH=3,9;BYMONTHDAY=15", "2026-03-15 2026-09-15 2027-03-15 2027-09-15"},
+		{"FREQ=DAILY;INTERVAL=10;COUNT=3", "2026-01-05 2026-01-15 2026-01-25"},
+		{"FREQ=WEEKLY;UNTIL=20260120T000000Z", "2026-01-05 2026-01-12 2026-01-19"},
+		{"  FREQ=WEEKLY;INTERVAL=2  ", "2026-01-05 2026-01-19 2026-02-02 2026-02-16"},
+	}
+	for _, tt := range tests {
+		recurrence, err := ParseRecurrence(tt.rule, start)
+		if err != nil {
+			t.Errorf("ParseRecurrence(%q): %v", tt.rule, err)
+			continue
+		}
+		if got := dates(recurrence.Next(start, 4)); got != tt.want {
+			t.Errorf("%q occurs on %s, want %s", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestParseRecurrenceRejections(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"every monday",
+		"FREQ=FORTNIGHTLY",
+		"FREQ=HOURLY",
+		"FREQ=MINUTELY;INTERVAL=30",
+		"FREQ=DAILY;BYHOUR=9",
+		"FREQ=WEEKLY;BYMINUTE=30",
+		"FREQ=WEEKLY\r\nEXDATE:20260112",
+		"FREQ=WEEKLY;BYDAY=XX",
+	} {
+		if _, err := ParseRecurrence(rule, day("2026-01-05")); !errors.Is(err, ErrInvalidRecurrence) {
+			t.Errorf("ParseRecurrence(%q) = %v, want ErrInvalidRecurrence", rule, err)
+		}
+	}
+	if _, err := IntervalRecurrence(0, day("2026-01-05")); !errors.Is(err, ErrInvalidRecurrence) {
+		t.Errorf("IntervalRecurrence(0) = %v, want ErrInvalidRecurrence", err)
+	}
+}
+
+func TestRecurrenceFromAndAfter(t *testing.T) {
+	recurrence, err := ParseRecurrence("FREQ=WEEKLY;BYDAY=MO;COUNT=2", day("2026-01-05"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next, ok := recurrence.From(day("2026-01-05")); !ok || next.Format(DateLayout) != "2026-01-05" {
+		t.Errorf("From includes the day itself: got %v, %v", next, ok)
+	}
+	if next, ok := recurrence.After(day("2026-01-05")); !ok || next.Format(DateLayout) != "2026-01-12" {
+		t.Errorf("After excludes the day itself: got %v, %v", next, ok)
+	}
+	if _, ok := recurrence.After(day("2026-01-12")); ok {
+		t.Error("a rule with COUNT=2 has a third occurrence")
+	}
+
+	// Times of day and time zones are ignored; occurrences are dates.
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	interval, err := IntervalRecurrence(7, time.Date(2026, 3, 22, 23, 30, 0, 0, berlin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := dates(interval.Next(day("2026-03-22"), 3)); got != "2026-03-22 2026-03-29 2026-04-05" {
+		t.Errorf("weekly interval across the DST change = %s", got)
+	}
+	if interval.String() != "FREQ=DAILY;INTERVAL=7" {
+		t.Errorf("String() = %q", interval.String())
+	}
+}
@@ -1,21 +1,125 @@
 package config
 
 import (
+	"errors"
 	"os"
+	"strconv"
+	"strings"
 )
 
+const DefaultJWTSecret = "default_secret_key_change_me"
+
 type Config struct {
 	Port      string
+	AppEnv    string
 	JWTSecret string
 	DBPath    string
+
+	JWTKeysDir   string
+	JWTActiveKID string
+
+	LoginMaxAccountFailures  int
+	LoginAccountFreeFailures int
+	LoginMaxIPFailures       int
+	LoginLockoutMinutes      int
+	TrustedProxies           []string
+
+	AppBaseURL   string
+	PublicURL    string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	PasswordMinLength     int
+	PasswordBlocklistFile string
+	PasswordHistorySize   int
+	PasswordResetTTL      int
+
+	EventLogRetention  int
+	SlowConsumerPolicy string
+
+	NotificationDigestHour int
+
+	ShutdownTimeout int
+
+	Backplane     string
+	RedisAddr     string
+	RedisPassword string
+	RedisChannel  string
 }
 
 func Load() *Config {
 	return &Config{
 		Port:      getEnv("PORT", "8080"),
-		JWTSecret: getEnv("JWT_SECRET", "default_secret_key_change_me"),
+		AppEnv:    getEnv("APP_ENV", "production"),
+		JWTSecret: getEnv("JWT_SECRET", DefaultJWTSecret),
 		DBPath:    getEnv("DB_PATH", "./data/assetsentinel.db"),
+
+		JWTKeysDir:   getEnv("JWT_KEYS_DIR", ""),
+		JWTActiveKID: getEnv("JWT_ACTIVE_KID", ""),
+
+		LoginMaxAccountFailures:  getEnvInt("LOGIN_MAX_ACCOUNT_FAILURES", 5),
+		LoginAccountFreeFailures: getEnvInt("LOGIN_ACCOUNT_FREE_FAILURES", 2),
+		LoginMaxIPFailures:       getEnvInt("LOGIN_MAX_IP_FAILURES", 20),
+		LoginLockoutMinutes:      getEnvInt("LOGIN_LOCKOUT_MINUTES", 15),
+		TrustedProxies:           getEnvList("TRUSTED_PROXIES"),
+
+		AppBaseURL:   getEnv("APP_BASE_URL", "http://localhost:5173"),
+		PublicURL:    strings.TrimSuffix(getEnv("PUBLIC_URL", ""), "/"),
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnvInt("SMTP_PORT", 1025),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", "AssetSentinel <no-reply@assetsentinel.local>"),
+
+		PasswordMinLength:     getEnvInt("PASSWORD_MIN_LENGTH", 10),
+		PasswordBlocklistFile: getEnv("PASSWORD_BLOCKLIST_FILE", ""),
+		PasswordHistorySize:   getEnvInt("PASSWORD_HISTORY_SIZE", 5),
+		PasswordResetTTL:      getEnvInt("PASSWORD_RESET_TTL_MINUTES", 60),
+
+		EventLogRetention:  getEnvInt("EVENT_LOG_RETENTION", 1000),
+		SlowConsumerPolicy: getEnv("WS_SLOW_CONSUMER_POLICY", "coalesce"),
+
+		NotificationDigestHour: getEnvInt("NOTIFICATION_DIGEST_HOUR", 8),
+
+		ShutdownTimeout: getEnvInt("SHUTDOWN_TIMEOUT_SECONDS", 30),
+
+		Backplane:     getEnv("BACKPLANE", ""),
+		RedisAddr:     getEnv("REDIS_ADDR", "localhost:6379"),
+		RedisPassword: getEnv("REDIS_PASSWORD", ""),
+		RedisChannel:  getEnv("REDIS_CHANNEL", "assetsentinel:events"),
+	}
+}
+
+func (c *Config) DevMode() bool {
+	return c.AppEnv == "development"
+}
+
+func (c *Config) Validate() error {
+	if c.JWTKeysDir == "" && c.JWTSecret == DefaultJWTSecret && !c.DevMode() {
+		return errors.New("JWT_SECRET is set to the default value; configure JWT_KEYS_DIR or JWT_SECRET, or set APP_ENV=development")
+	}
+	if c.JWTKeysDir != "" && c.JWTActiveKID == "" {
+		return errors.New("JWT_ACTIVE_KID is required when JWT_KEYS_DIR is set")
+	}
+	if c.SlowConsumerPolicy != "coalesce" && c.SlowConsumerPolicy != "disconnect" {
+		return errors.New("WS_SLOW_CONSUMER_POLICY must be coalesce or disconnect")
+	}
+	if c.NotificationDigestHour < 0 || c.NotificationDigestHour > 23 {
+		return errors.New("NOTIFICATION_DIGEST_HOUR must be between 0 and 23")
+	}
+	if c.ShutdownTimeout < 1 {
+		return errors.New("SHUTDOWN_TIMEOUT_SECONDS must be at least 1")
+	}
+	if c.PublicURL != "" && !strings.HasPrefix(c.PublicURL, "http://") && !strings.HasPrefix(c.PublicURL, "https://") {
+		return errors.New("PUBLIC_URL must start with http:// or https://")
 	}
+	if c.Backplane != "" && c.Backplane != "redis" {
+		return errors.New("BACKPLANE must be empty or redis")
+	}
+	return nil
 }
 
 func getEnv(key, defaultValue string) string {
@@ -24,3 +128,22 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,234 @@
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"assetsentinel/internal/repository"
+)
+
+const (
+	TypeWorkOrderCreated       = "work_order_created"
+	TypeWorkOrderStatusChanged = "work_order_status_change"
+	TypeLowInventory           = "low_inventory"
+	TypeMaintenanceDue         = "maintenance_due"
+	TypeMaintenanceOverdue     = "maintenance_overdue"
+)
+
+const (
+	ActorUser   = "user"
+	ActorSystem = "system"
+)
+
+type Payload interface {
+	EventType() string
+	EventVersion() int
+	Subject() (table string, id uint)
+}
+
+type Actor struct {
+	Type   string `json:"type"`
+	UserID *uint  `json:"user_id,omitempty"`
+}
+
+func User(userID uint) Actor {
+	return Actor{Type: ActorUser, UserID: &userID}
+}
+
+func System() Actor {
+	return Actor{Type: ActorSystem}
+}
+
+type Envelope struct {
+	ID             string    `json:"id"`
+	Type           string    `json:"type"`
+	Version        int       `json:"version"`
+	OccurredAt     time.Time `json:"occurred_at"`
+	OrganizationID uint      `json:"organization_id"`
+	Actor          Actor     `json:"actor"`
+	Data           Payload   `json:"data"`
+}
+
+func New(orgID uint, actor Actor, data Payload) *Envelope {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return &Envelope{
+		ID:             "evt_" + hex.EncodeToString(id),
+		Type:           data.EventType(),
+		Version:        data.EventVersion(),
+		OccurredAt:     time.Now().UTC(),
+		OrganizationID: orgID,
+		Actor:          actor,
+		Data:           data,
+	}
+}
+
+func (e *Envelope) Message() map[string]interface{} {
+	return map[string]interface{}{
+		"id":              e.ID,
+		"type":            e.Type,
+		"version":         e.Version,
+		"occurred_at":     e.OccurredAt,
+		"organization_id": e.OrganizationID,
+		"actor":           e.Actor,
+		"data":            e.Data,
+	}
+}
+
+// WorkOrder is a work order as published in events. It is kept apart from
+// repository.WorkOrder so that changing the table does not change the event
+// contract.
+type WorkOrder struct {
+	ID                     uint       `json:"id"`
+	OrganizationID         uint       `json:"organization_id"`
+	AssetID                uint       `json:"asset_id"`
+	TechnicianID           *uint      `json:"technician_id"`
+	MaintenanceTaskID      *uint      `json:"maintenance_task_id"`
+	ProcedureID            *uint      `json:"procedure_id"`
+	SourceWorkOrderID      *uint      `json:"source_work_order_id"`
+	Title                  string     `json:"title"`
+	Description            *string    `json:"description"`
+	Status                 string     `json:"status"`
+	Priority               string     `json:"priority"`
+	EstimatedDurationHours *float64   `json:"estimated_duration_hours"`
+	ScheduledStart         *time.Time `json:"scheduled_start"`
+	ScheduledEnd           *time.Time `json:"scheduled_end"`
+	ActualStart            *time.Time `json:"actual_start"`
+	ActualEnd              *time.Time `json:"actual_end"`
+	TotalCost              float64    `json:"total_cost"`
+	Notes                  *string    `json:"notes"`
+	CreatedBy              *uint      `json:"created_by"`
+	CreatedAt              time.Time  `json:"created_at"`
+	UpdatedAt              time.Time  `json:"updated_at"`
+}
+
+func NewWorkOrder(wo *repository.WorkOrder) WorkOrder {
+	return WorkOrder{
+		ID:                     wo.ID,
+		OrganizationID:         wo.OrganizationID,
+		AssetID:                wo.AssetID,
+		TechnicianID:           wo.TechnicianID,
+		MaintenanceTaskID:      wo.MaintenanceTaskID,
+		ProcedureID:            wo.ProcedureID,
+		SourceWorkOrderID:      wo.SourceWorkOrderID,
+		Title:                  wo.Title,
+		Description:            wo.Description,
+		Status:                 wo.Status,
+		Priority:               wo.Priority,
+		EstimatedDurationHours: wo.EstimatedDurationHours,
+		ScheduledStart:         wo.ScheduledStart,
+		ScheduledEnd:           wo.ScheduledEnd,
+		ActualStart:            wo.ActualStart,
+		ActualEnd:              wo.ActualEnd,
+		TotalCost:              wo.TotalCost,
+		Notes:                  wo.Notes,
+		CreatedBy:              wo.CreatedBy,
+		CreatedAt:              wo.CreatedAt,
+		UpdatedAt:              wo.UpdatedAt,
+	}
+}
+
+// InventoryPart is an inventory part as published in events.
+type InventoryPart struct {
+	ID             uint      `json:"id"`
+	OrganizationID uint      `json:"organization_id"`
+	Name           string    `json:"name"`
+	SKU            string    `json:"sku"`
+	Quantity       int       `json:"quantity"`
+	MinThreshold   int       `json:"min_threshold"`
+	CostPerUnit    float64   `json:"cost_per_unit"`
+	Location       *string   `json:"location"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func NewInventoryPart(part *repository.InventoryPart) InventoryPart {
+	return InventoryPart{
+		ID:             part.ID,
+		OrganizationID: part.OrganizationID,
+		Name:           part.Name,
+		SKU:            part.SKU,
+		Quantity:       part.Quantity,
+		MinThreshold:   part.MinThreshold,
+		CostPerUnit:    part.CostPerUnit,
+		Location:       part.Location,
+		CreatedAt:      part.CreatedAt,
+		UpdatedAt:      part.UpdatedAt,
+	}
+}
+
+type WorkOrderCreated struct {
+	WorkOrder WorkOrder `json:"work_order"`
+}
+
+func (WorkOrderCreated) EventType() string { return TypeWorkOrderCreated }
+func (WorkOrderCreated) EventVersion() int { return 1 }
+func (e WorkOrderCreated) Subject() (string, uint) {
+	return "work_orders", e.WorkOrder.ID
+}
+
+type WorkOrderStatusChanged struct {
+	WorkOrder WorkOrder `json:"work_order"`
+	OldStatus string    `json:"old_status"`
+	NewStatus string    `json:"new_status"`
+}
+
+func (WorkOrderStatusChanged) EventType() string { return TypeWorkOrderStatusChanged }
+func (WorkOrderStatusChanged) EventVersion() int { return 1 }
+func (e WorkOrderStatusChanged) Subject() (string, uint) {
+	return "work_orders", e.WorkOrder.ID
+}
+
+type LowInventory struct {
+	Part InventoryPart `json:"part"`
+}
+
+func (LowInventory) EventType() string { return TypeLowInventory }
+func (LowInventory) EventVersion() int { return 1 }
+func (e LowInventory) Subject() (string, uint) {
+	return "inventory_parts", e.Part.ID
+}
+
+type MaintenanceDue struct {
+	MaintenancePlanID uint      `json:"maintenance_plan_id"`
+	MaintenanceTaskID uint      `json:"maintenance_task_id"`
+	AssetID           uint      `json:"asset_id"`
+	ScheduledDate     time.Time `json:"scheduled_date"`
+}
+
+func (MaintenanceDue) EventType() string { return TypeMaintenanceDue }
+func (MaintenanceDue) EventVersion() int { return 1 }
+func (e MaintenanceDue) Subject() (string, uint) {
+	return "maintenance_tasks", e.MaintenanceTaskID
+}
+
+type MaintenanceOverdue struct {
+	MaintenancePlanID uint      `json:"maintenance_plan_id"`
+	MaintenanceTaskID uint      `json:"maintenance_task_id"`
+	AssetID           uint      `json:"asset_id"`
+	ScheduledDate     time.Time `json:"scheduled_date"`
+}
+
+func (MaintenanceOverdue) EventType() string { return TypeMaintenanceOverdue }
+func (MaintenanceOverdue) EventVersion() int { return 1 }
+func (e MaintenanceOverdue) Subject() (string, uint) {
+	return "maintenance_tasks", e.MaintenanceTaskID
+}
+
+var catalog = []Payload{
+	LowInventory{},
+	MaintenanceDue{},
+	MaintenanceOverdue{},
+	WorkOrderCreated{},
+	WorkOrderStatusChanged{},
+}
+
+func IsType(eventType string) bool {
+	for _, payload := range catalog {
+		if payload.EventType() == eventType {
+			return true
+		}
+	}
+	return false
+}
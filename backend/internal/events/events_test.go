@@ -0,0 +1,81 @@
+package events
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"assetsentinel/internal/repository"
+)
+
+// TestPayloadsDoNotEmbedModels keeps database models out of the event
+// contract: a new column must not silently change a published payload.
+func TestPayloadsDoNotEmbedModels(t *testing.T) {
+	repositoryPkg := reflect.TypeOf(repository.WorkOrder{}).PkgPath()
+	seen := map[reflect.Type]bool{}
+	var check func(t *testing.T, typ reflect.Type, path string)
+	check = func(t *testing.T, typ reflect.Type, path string) {
+		for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Map {
+			typ = typ.Elem()
+		}
+		if typ.PkgPath() == repositoryPkg {
+			t.Errorf("%s is %s", path, typ)
+		}
+		if typ.Kind() != reflect.Struct || seen[typ] {
+			return
+		}
+		seen[typ] = true
+		for i := 0; i < typ.NumField(); i++ {
+			check(t, typ.Field(i).Type, path+"."+typ.Field(i).Name)
+		}
+	}
+	for _, payload := range catalog {
+		check(t, reflect.TypeOf(payload), reflect.TypeOf(payload).Name())
+	}
+}
+
+func TestWorkOrderPayloadFields(t *testing.T) {
+	data, err := json.Marshal(NewWorkOrder(&repository.WorkOrder{ID: 4, AssetID: 2, Title: "Replace seal"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	json.Unmarshal(data, &fields)
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	want := "actual_end actual_start asset_id created_at created_by description estimated_duration_hours id maintenance_task_id notes " +
+		"organization_id priority procedure_id scheduled_end scheduled_start source_work_order_id status technician_id title total_cost updated_at"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("work order payload fields changed:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestDecodeRoundTrip(t *testing.T) {
+	location := "Building A"
+	event := New(1, User(3), LowInventory{Part: NewInventoryPart(&repository.InventoryPart{ID: 9, Name: "Seal", SKU: "S-1", Quantity: 1, MinThreshold: 5, Location: &location})})
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := Decode(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	low, ok := decoded.Data.(LowInventory)
+	if !ok {
+		t.Fatalf("decoded %T, want LowInventory", decoded.Data)
+	}
+	if low.Part.ID != 9 || low.Part.SKU != "S-1" || low.Part.Location == nil || *low.Part.Location != location {
+		t.Errorf("decoded part %+v", low.Part)
+	}
+	if decoded.ID != event.ID || decoded.Type != TypeLowInventory || decoded.Version != 1 {
+		t.Errorf("decoded envelope %+v", decoded)
+	}
+}
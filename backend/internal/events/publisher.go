@@ -0,0 +1,95 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"assetsentinel/internal/repository"
+)
+
+type Consumer interface {
+	Consume(event *Envelope) error
+}
+
+// Subscription names a consumer. Deliveries are recorded by name, so names
+// must stay the same across releases.
+type Subscription struct {
+	Name     string
+	Consumer Consumer
+}
+
+// DeliveryLog records which consumers have received an event, so that a retry
+// after one consumer fails does not deliver it to the others again.
+type DeliveryLog interface {
+	Delivered(consumer string) bool
+	RecordDelivery(consumer string) error
+}
+
+type Publisher struct {
+	subscriptions []Subscription
+}
+
+func NewPublisher(subscriptions ...Subscription) *Publisher {
+	return &Publisher{subscriptions: subscriptions}
+}
+
+// Dispatch delivers event to each consumer that deliveries does not list yet
+// and returns the first error.
+func (p *Publisher) Dispatch(event *Envelope, deliveries DeliveryLog) error {
+	var firstErr error
+	for _, subscription := range p.subscriptions {
+		if deliveries.Delivered(subscription.Name) {
+			continue
+		}
+		err := subscription.Consumer.Consume(event)
+		if err == nil {
+			err = deliveries.RecordDelivery(subscription.Name)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", subscription.Name, err)
+		}
+	}
+	return firstErr
+}
+
+func Enqueue(tx *repository.Repository, orgID uint, actor Actor, data Payload) error {
+	event := New(orgID, actor, data)
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return tx.CreateOutboxEvent(&repository.OutboxEvent{
+		OrganizationID: orgID,
+		EventID:        event.ID,
+		EventType:      event.Type,
+		Payload:        payload,
+	})
+}
+
+func Decode(payload []byte) (*Envelope, error) {
+	var raw struct {
+		Envelope
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, err
+	}
+
+	for _, proto := range catalog {
+		if proto.EventType() != raw.Type {
+			continue
+		}
+		if proto.EventVersion() != raw.Version {
+			return nil, fmt.Errorf("unsupported version %d of event %s", raw.Version, raw.Type)
+		}
+		data := reflect.New(reflect.TypeOf(proto))
+		if err := json.Unmarshal(raw.Data, data.Interface()); err != nil {
+			return nil, err
+		}
+		event := raw.Envelope
+		event.Data = data.Elem().Interface().(Payload)
+		return &event, nil
+	}
+	return nil, fmt.Errorf("unknown event type %q", raw.Type)
+}
@@ -0,0 +1,52 @@
+package events
+
+import (
+	"errors"
+	"testing"
+)
+
+type countingConsumer struct {
+	calls int
+	err   error
+}
+
+func (c *countingConsumer) Consume(event *Envelope) error {
+	c.calls++
+	return c.err
+}
+
+type memoryDeliveries map[string]bool
+
+func (d memoryDeliveries) Delivered(consumer string) bool { return d[consumer] }
+
+func (d memoryDeliveries) RecordDelivery(consumer string) error {
+	d[consumer] = true
+	return nil
+}
+
+func TestDispatchRetriesOnlyFailedConsumers(t *testing.T) {
+	realtime, webhooks, audit := &countingConsumer{}, &countingConsumer{err: errors.New("webhook store unavailable")}, &countingConsumer{}
+	publisher := NewPublisher(
+		Subscription{Name: "realtime", Consumer: realtime},
+		Subscription{Name: "webhooks", Consumer: webhooks},
+		Subscription{Name: "audit", Consumer: audit},
+	)
+	event := New(1, System(), MaintenanceDue{MaintenancePlanID: 1})
+	deliveries := memoryDeliveries{}
+
+	err := publisher.Dispatch(event, deliveries)
+	if !errors.Is(err, webhooks.err) {
+		t.Fatalf("Dispatch = %v, want the webhook error", err)
+	}
+	if !deliveries["realtime"] || deliveries["webhooks"] || !deliveries["audit"] {
+		t.Errorf("recorded deliveries %v, want realtime and audit", deliveries)
+	}
+
+	webhooks.err = nil
+	if err := publisher.Dispatch(event, deliveries); err != nil {
+		t.Fatal(err)
+	}
+	if realtime.calls != 1 || webhooks.calls != 2 || audit.calls != 1 {
+		t.Errorf("calls realtime=%d webhooks=%d audit=%d, want 1, 2 and 1", realtime.calls, webhooks.calls, audit.calls)
+	}
+}
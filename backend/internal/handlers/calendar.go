@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"assetsentinel/internal/middleware"
+	"assetsentinel/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CalendarHandler struct {
+	calendarService interface {
+		Get(orgID uint) (*repository.BusinessCalendar, error)
+		Update(orgID uint, workingDays []int, dailyHours *float64) (*repository.BusinessCalendar, error)
+		Holidays(orgID uint) ([]repository.Holiday, error)
+		AddHoliday(holiday *repository.Holiday) error
+		DeleteHoliday(id, orgID uint) error
+	}
+}
+
+func NewCalendarHandler(calendarService interface {
+	Get(orgID uint) (*repository.BusinessCalendar, error)
+	Update(orgID uint, workingDays []int, dailyHours *float64) (*repository.BusinessCalendar, error)
+	Holidays(orgID uint) ([]repository.Holiday, error)
+	AddHoliday(holiday *repository.Holiday) error
+	DeleteHoliday(id, orgID uint) error
+}) *CalendarHandler {
+	return &CalendarHandler{calendarService: calendarService}
+}
+
+func (h *CalendarHandler) Get(c *gin.Context) {
+	settings, err := h.calendarService.Get(middleware.GetOrganizationID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func (h *CalendarHandler) Update(c *gin.Context) {
+	var req struct {
+		WorkingDays []int    `json:"working_days" binding:"required"`
+		DailyHours  *float64 `json:"daily_hours"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := h.calendarService.Update(middleware.GetOrganizationID(c), req.WorkingDays, req.DailyHours)
+	if err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func (h *CalendarHandler) Holidays(c *gin.Context) {
+	holidays, err := h.calendarService.Holidays(middleware.GetOrganizationID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, holidays)
+}
+
+func (h *CalendarHandler) AddHoliday(c *gin.Context) {
+	var holiday repository.Holiday
+	if err := c.ShouldBindJSON(&holiday); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	holiday.OrganizationID = middleware.GetOrganizationID(c)
+
+	if err := h.calendarService.AddHoliday(&holiday); err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, holiday)
+}
+
+func (h *CalendarHandler) DeleteHoliday(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	if err := h.calendarService.DeleteHoliday(uint(id), middleware.GetOrganizationID(c)); err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Holiday deleted"})
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"assetsentinel/internal/ical"
+	"assetsentinel/internal/middleware"
+	"assetsentinel/internal/repository"
+	"assetsentinel/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CalendarFeedHandler struct {
+	feedService interface {
+		List(orgID, userID uint) ([]repository.CalendarFeed, error)
+		Create(feed *repository.CalendarFeed, role string) error
+		Revoke(id, orgID, userID uint) error
+		Render(token string) (*ical.Calendar, error)
+	}
+	publicURL string
+}
+
+// NewCalendarFeedHandler serves calendar feeds. Subscription URLs start with
+// publicURL, the address clients reach the API at; when it is empty they are
+// built from the request.
+func NewCalendarFeedHandler(feedService interface {
+	List(orgID, userID uint) ([]repository.CalendarFeed, error)
+	Create(feed *repository.CalendarFeed, role string) error
+	Revoke(id, orgID, userID uint) error
+	Render(token string) (*ical.Calendar, error)
+}, publicURL string) *CalendarFeedHandler {
+	return &CalendarFeedHandler{feedService: feedService, publicURL: publicURL}
+}
+
+func (h *CalendarFeedHandler) List(c *gin.Context) {
+	feeds, err := h.feedService.List(middleware.GetOrganizationID(c), middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, feeds)
+}
+
+func (h *CalendarFeedHandler) Create(c *gin.Context) {
+	var req struct {
+		Scope string  `json:"scope"`
+		Name  *string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	feed := repository.CalendarFeed{
+		OrganizationID: middleware.GetOrganizationID(c),
+		UserID:         middleware.GetUserID(c),
+		Scope:          req.Scope,
+		Name:           req.Name,
+	}
+	if err := h.feedService.Create(&feed, middleware.GetRole(c)); err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, struct {
+		repository.CalendarFeed
+		Token string `json:"token"`
+		URL   string `json:"url"`
+	}{feed, feed.Token, h.feedURL(c, feed.Token)})
+}
+
+func (h *CalendarFeedHandler) Revoke(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	if err := h.feedService.Revoke(uint(id), middleware.GetOrganizationID(c), middleware.GetUserID(c)); err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar feed revoked"})
+}
+
+// Feed serves the iCalendar document of a feed. It is public: calendar apps
+// cannot send credentials, so the token in the URL is the only credential.
+func (h *CalendarFeedHandler) Feed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	calendar, err := h.feedService.Render(token)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, services.ErrCalendarFeedForbidden) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar feed not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "text/calendar; charset=utf-8")
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Status(http.StatusOK)
+	calendar.Write(c.Writer, time.Now())
+}
+
+// feedURL returns the subscription URL of a feed. Forwarding headers are not
+// trusted, so behind a proxy that terminates TLS the public URL must be set.
+func (h *CalendarFeedHandler) feedURL(c *gin.Context, token string) string {
+	base := h.publicURL
+	if base == "" {
+		scheme := "http"
+		if c.Request.TLS != nil {
+			scheme = "https"
+		}
+		base = scheme + "://" + c.Request.Host
+	}
+	return fmt.Sprintf("%s/feeds/%s.ics", base, token)
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"assetsentinel/internal/jwtkeys"
+	"assetsentinel/internal/middleware"
+	"assetsentinel/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCalendarFeedURL(t *testing.T) {
+	repo := newTestRepository(t)
+	keys := jwtkeys.NewHMAC("test-secret")
+	acme := seedTenant(t, repo, "acme")
+	roleService := services.NewRoleService(repo)
+
+	for _, tt := range []struct {
+		publicURL string
+		want      string
+	}{
+		{"", "http://example.com/feeds/"},
+		{"https://maintenance.acme.test", "https://maintenance.acme.test/feeds/"},
+	} {
+		gin.SetMode(gin.TestMode)
+		handler := NewCalendarFeedHandler(services.NewCalendarFeedService(repo, roleService), tt.publicURL)
+		r := gin.New()
+		r.GET("/feeds/:token", handler.Feed)
+		api := r.Group("/api")
+		api.Use(middleware.AuthMiddleware(keys), middleware.LoadPermissions(roleService))
+		api.POST("/calendar-feeds", handler.Create)
+
+		// A client cannot make the URL claim https by sending the header a
+		// proxy would set.
+		req := httptest.NewRequest(http.MethodPost, "/api/calendar-feeds", strings.NewReader(`{"scope": "user"}`))
+		req.Header.Set("Authorization", bearer(t, keys, acme.technician))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-Proto", "https")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("creating a feed = %d %s", w.Code, w.Body)
+		}
+		var created struct {
+			URL string `json:"url"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(created.URL, tt.want) || !strings.HasSuffix(created.URL, ".ics") {
+			t.Errorf("public URL %q: feed url = %s, want it under %s", tt.publicURL, created.URL, tt.want)
+		}
+
+		path := created.URL[strings.Index(created.URL, "/feeds/"):]
+		w = request(r, "", http.MethodGet, path, nil)
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/calendar") ||
+			!strings.HasPrefix(w.Body.String(), "BEGIN:VCALENDAR\r\n") {
+			t.Errorf("GET %s = %d %s\n%s", path, w.Code, w.Header().Get("Content-Type"), w.Body)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"assetsentinel/internal/middleware"
+	"assetsentinel/internal/repository"
+	"assetsentinel/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DispatchHandler struct {
+	dispatchService interface {
+		Schedule(orgID uint, from, to *time.Time, technicianID *uint) (*services.Schedule, error)
+		Suggest(wo *repository.WorkOrder, role string) (*services.Suggestions, error)
+	}
+}
+
+func NewDispatchHandler(dispatchService interface {
+	Schedule(orgID uint, from, to *time.Time, technicianID *uint) (*services.Schedule, error)
+	Suggest(wo *repository.WorkOrder, role string) (*services.Suggestions, error)
+}) *DispatchHandler {
+	return &DispatchHandler{dispatchService: dispatchService}
+}
+
+func (h *DispatchHandler) Schedule(c *gin.Context) {
+	from, to, ok := dateRangeQuery(c)
+	if !ok {
+		return
+	}
+	var technicianID *uint
+	if value := c.Query("technician_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "technician_id must be a user id"})
+			return
+		}
+		technicianID = func() *uint { v := uint(id); return &v }()
+	}
+
+	schedule, err := h.dispatchService.Schedule(middleware.GetOrganizationID(c), from, to, technicianID)
+	if err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+func (h *DispatchHandler) Suggest(c *gin.Context) {
+	var req struct {
+		WorkOrderID            uint       `json:"work_order_id"`
+		AssetID                uint       `json:"asset_id"`
+		ScheduledStart         *time.Time `json:"scheduled_start"`
+		ScheduledEnd           *time.Time `json:"scheduled_end"`
+		EstimatedDurationHours *float64   `json:"estimated_duration_hours"`
+		Role                   string     `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	wo := repository.WorkOrder{
+		ID:                     req.WorkOrderID,
+		OrganizationID:         middleware.GetOrganizationID(c),
+		AssetID:                req.AssetID,
+		ScheduledStart:         req.ScheduledStart,
+		ScheduledEnd:           req.ScheduledEnd,
+		EstimatedDurationHours: req.EstimatedDurationHours,
+	}
+	suggestions, err := h.dispatchService.Suggest(&wo, req.Role)
+	if err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, suggestions)
+}
+
+// workOrderError responds to a failed work order save, listing the work orders
+// a schedule conflict is with.
+func workOrderError(c *gin.Context, err error) {
+	var conflict *services.ScheduleConflictError
+	if errors.As(err, &conflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "technician_id": conflict.TechnicianID, "conflicts": conflict.Conflicts})
+		return
+	}
+	c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+}
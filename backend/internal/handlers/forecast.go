@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"assetsentinel/internal/calendar"
+	"assetsentinel/internal/middleware"
+	"assetsentinel/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *MaintenanceHandler) Forecast(c *gin.Context) {
+	from, to, ok := dateRangeQuery(c)
+	if !ok {
+		return
+	}
+
+	forecast, err := h.maintenanceService.Forecast(middleware.GetOrganizationID(c), from, to)
+	if err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		writeForecastCSV(c, forecast)
+		return
+	}
+	c.JSON(http.StatusOK, forecast)
+}
+
+// dateRangeQuery parses the optional from and to query parameters. It responds
+// with 400 and returns false when either is not a date.
+func dateRangeQuery(c *gin.Context) (from, to *time.Time, ok bool) {
+	for _, param := range []struct {
+		name string
+		dest **time.Time
+	}{{"from", &from}, {"to", &to}} {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		date, err := time.Parse(calendar.DateLayout, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be a date in YYYY-MM-DD format", param.name)})
+			return nil, nil, false
+		}
+		*param.dest = &date
+	}
+	return from, to, true
+}
+
+// writeForecastCSV writes one row per role and week followed by the week's
+// total, so over-allocated roles and weeks can be filtered in a spreadsheet.
+func writeForecastCSV(c *gin.Context, forecast *services.Forecast) {
+	filename := fmt.Sprintf("maintenance-forecast-%s-%s.csv", forecast.From.Format(calendar.DateLayout), forecast.To.Format(calendar.DateLayout))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	hours := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"week_start", "week_end", "role", "technicians", "occurrences", "hours", "capacity_hours", "over_allocated"})
+	for _, week := range forecast.Weeks {
+		start, end := week.WeekStart.Format(calendar.DateLayout), week.WeekEnd.Format(calendar.DateLayout)
+		for _, role := range week.Roles {
+			w.Write([]string{start, end, role.Role, strconv.Itoa(role.Technicians), strconv.Itoa(role.Occurrences),
+				hours(role.Hours), hours(role.CapacityHours), strconv.FormatBool(role.OverAllocated)})
+		}
+		w.Write([]string{start, end, "total", "", strconv.Itoa(week.Occurrences),
+			hours(week.Hours), hours(week.CapacityHours), strconv.FormatBool(week.OverAllocated)})
+	}
+	w.Flush()
+}
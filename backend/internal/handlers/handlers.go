@@ -1,24 +1,31 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
+	"assetsentinel/internal/calendar"
 	"assetsentinel/internal/middleware"
+	"assetsentinel/internal/rbac"
 	"assetsentinel/internal/repository"
+	"assetsentinel/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AuthHandler struct {
 	authService interface {
-		Login(email, password string) (string, *repository.User, error)
+		Login(email, password, ip string) (string, *repository.User, error)
 		Register(email, password, fullName, role string, orgID uint) (*repository.User, error)
 	}
 }
 
 func NewAuthHandler(authService interface {
-	Login(email, password string) (string, *repository.User, error)
+	Login(email, password, ip string) (string, *repository.User, error)
 	Register(email, password, fullName, role string, orgID uint) (*repository.User, error)
 }) *AuthHandler {
 	return &AuthHandler{authService: authService}
@@ -35,8 +42,11 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	token, user, err := h.authService.Login(req.Email, req.Password)
+	token, user, err := h.authService.Login(req.Email, req.Password, c.ClientIP())
 	if err != nil {
+		if throttled(c, err) {
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
@@ -56,7 +66,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req struct {
 		Email    string `json:"email" binding:"required,email"`
-		Password string `json:"password" binding:"required,min=6"`
+		Password string `json:"password" binding:"required"`
 		FullName string `json:"full_name" binding:"required"`
 		Role     string `json:"role" binding:"required"`
 		OrgID    uint   `json:"organization_id" binding:"required"`
@@ -69,7 +79,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 	user, err := h.authService.Register(req.Email, req.Password, req.FullName, req.Role, req.OrgID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -167,7 +177,7 @@ func (h *AssetHandler) Update(c *gin.Context) {
 	asset.OrganizationID = orgID
 
 	if err := h.assetService.Update(&asset); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -179,7 +189,7 @@ func (h *AssetHandler) Delete(c *gin.Context) {
 	orgID := middleware.GetOrganizationID(c)
 
 	if err := h.assetService.Delete(uint(id), orgID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -193,6 +203,11 @@ type MaintenanceHandler struct {
 		List(orgID uint, page, pageSize int) ([]repository.MaintenancePlan, int, error)
 		Update(plan *repository.MaintenancePlan) error
 		Delete(id, orgID uint) error
+		Occurrences(id, orgID uint, count int) ([]services.Occurrence, error)
+		Preview(plan *repository.MaintenancePlan, count int) ([]services.Occurrence, error)
+		GetTask(id, orgID uint) (*repository.MaintenanceTask, error)
+		CompleteTask(id, orgID, actorID uint, notes *string) (*repository.MaintenanceTask, error)
+		Forecast(orgID uint, from, to *time.Time) (*services.Forecast, error)
 	}
 }
 
@@ -202,6 +217,11 @@ func NewMaintenanceHandler(maintenanceService interface {
 	List(orgID uint, page, pageSize int) ([]repository.MaintenancePlan, int, error)
 	Update(plan *repository.MaintenancePlan) error
 	Delete(id, orgID uint) error
+	Occurrences(id, orgID uint, count int) ([]services.Occurrence, error)
+	Preview(plan *repository.MaintenancePlan, count int) ([]services.Occurrence, error)
+	GetTask(id, orgID uint) (*repository.MaintenanceTask, error)
+	CompleteTask(id, orgID, actorID uint, notes *string) (*repository.MaintenanceTask, error)
+	Forecast(orgID uint, from, to *time.Time) (*services.Forecast, error)
 }) *MaintenanceHandler {
 	return &MaintenanceHandler{maintenanceService: maintenanceService}
 }
@@ -216,7 +236,7 @@ func (h *MaintenanceHandler) Create(c *gin.Context) {
 	plan.OrganizationID = middleware.GetOrganizationID(c)
 
 	if err := h.maintenanceService.Create(&plan); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -269,7 +289,7 @@ func (h *MaintenanceHandler) Update(c *gin.Context) {
 	plan.OrganizationID = orgID
 
 	if err := h.maintenanceService.Update(&plan); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -288,12 +308,89 @@ func (h *MaintenanceHandler) Delete(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Maintenance plan deleted"})
 }
 
+func (h *MaintenanceHandler) Occurrences(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	orgID := middleware.GetOrganizationID(c)
+
+	occurrences, err := h.maintenanceService.Occurrences(uint(id), orgID, occurrenceCount(c))
+	if err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, occurrences)
+}
+
+func (h *MaintenanceHandler) Preview(c *gin.Context) {
+	var plan repository.MaintenancePlan
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan.OrganizationID = middleware.GetOrganizationID(c)
+
+	occurrences, err := h.maintenanceService.Preview(&plan, occurrenceCount(c))
+	if err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recurrence_rule":       plan.RecurrenceRule,
+		"next_maintenance_date": plan.NextMaintenanceDate,
+		"occurrences":           occurrences,
+	})
+}
+
+func (h *MaintenanceHandler) GetTask(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	orgID := middleware.GetOrganizationID(c)
+
+	task, err := h.maintenanceService.GetTask(uint(id), orgID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Maintenance task not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+func (h *MaintenanceHandler) CompleteTask(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	orgID := middleware.GetOrganizationID(c)
+
+	var req struct {
+		Notes *string `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.maintenanceService.CompleteTask(uint(id), orgID, middleware.GetUserID(c), req.Notes)
+	if err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+func occurrenceCount(c *gin.Context) int {
+	count, _ := strconv.Atoi(c.DefaultQuery("count", "10"))
+	if count < 1 || count > services.MaxOccurrencePreview {
+		count = 10
+	}
+	return count
+}
+
 type WorkOrderHandler struct {
 	workOrderService interface {
 		Create(wo *repository.WorkOrder) error
 		Get(id, orgID uint) (*repository.WorkOrder, error)
 		List(orgID uint, page, pageSize int, status string) ([]repository.WorkOrder, int, error)
-		Update(wo *repository.WorkOrder, oldStatus string) error
+		Update(wo *repository.WorkOrder, oldStatus string, actorID uint) error
 		Delete(id, orgID uint) error
 	}
 }
@@ -302,7 +399,7 @@ func NewWorkOrderHandler(workOrderService interface {
 	Create(wo *repository.WorkOrder) error
 	Get(id, orgID uint) (*repository.WorkOrder, error)
 	List(orgID uint, page, pageSize int, status string) ([]repository.WorkOrder, int, error)
-	Update(wo *repository.WorkOrder, oldStatus string) error
+	Update(wo *repository.WorkOrder, oldStatus string, actorID uint) error
 	Delete(id, orgID uint) error
 }) *WorkOrderHandler {
 	return &WorkOrderHandler{workOrderService: workOrderService}
@@ -319,7 +416,7 @@ func (h *WorkOrderHandler) Create(c *gin.Context) {
 	wo.CreatedBy = func() *uint { id := middleware.GetUserID(c); return &id }()
 
 	if err := h.workOrderService.Create(&wo); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		workOrderError(c, err)
 		return
 	}
 
@@ -378,8 +475,8 @@ func (h *WorkOrderHandler) Update(c *gin.Context) {
 	wo.ID = uint(id)
 	wo.OrganizationID = orgID
 
-	if err := h.workOrderService.Update(&wo, oldStatus); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.workOrderService.Update(&wo, oldStatus, middleware.GetUserID(c)); err != nil {
+		workOrderError(c, err)
 		return
 	}
 
@@ -391,7 +488,7 @@ func (h *WorkOrderHandler) Delete(c *gin.Context) {
 	orgID := middleware.GetOrganizationID(c)
 
 	if err := h.workOrderService.Delete(uint(id), orgID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -404,7 +501,7 @@ type InventoryHandler struct {
 		Get(id, orgID uint) (*repository.InventoryPart, error)
 		List(orgID uint, page, pageSize int) ([]repository.InventoryPart, int, error)
 		GetLowStock(orgID uint) ([]repository.InventoryPart, error)
-		Update(part *repository.InventoryPart) error
+		Update(part *repository.InventoryPart, actorID uint) error
 		Deduct(partID, orgID, quantity int) (int, error)
 		Delete(id, orgID uint) error
 	}
@@ -415,7 +512,7 @@ func NewInventoryHandler(inventoryService interface {
 	Get(id, orgID uint) (*repository.InventoryPart, error)
 	List(orgID uint, page, pageSize int) ([]repository.InventoryPart, int, error)
 	GetLowStock(orgID uint) ([]repository.InventoryPart, error)
-	Update(part *repository.InventoryPart) error
+	Update(part *repository.InventoryPart, actorID uint) error
 	Deduct(partID, orgID, quantity int) (int, error)
 	Delete(id, orgID uint) error
 }) *InventoryHandler {
@@ -484,7 +581,7 @@ func (h *InventoryHandler) Update(c *gin.Context) {
 	part.ID = uint(id)
 	part.OrganizationID = orgID
 
-	if err := h.inventoryService.Update(&part); err != nil {
+	if err := h.inventoryService.Update(&part, middleware.GetUserID(c)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -608,6 +705,16 @@ func GetAuditLogs(repo *repository.Repository) gin.HandlerFunc {
 
 func ListOrganizations(repo *repository.Repository) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if !middleware.HasPermission(c, rbac.PlatformManage) {
+			org, err := repo.GetOrganization(middleware.GetOrganizationID(c))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, []repository.Organization{*org})
+			return
+		}
+
 		orgs, err := repo.ListOrganizations()
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -625,6 +732,14 @@ func CreateOrganization(repo *repository.Repository) gin.HandlerFunc {
 			return
 		}
 
+		if org.TimeZone == "" {
+			org.TimeZone = "UTC"
+		}
+		if _, err := calendar.LoadLocation(org.TimeZone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		if err := repo.CreateOrganization(&org); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -638,6 +753,11 @@ func GetOrganization(repo *repository.Repository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
 
+		if !canAccessOrganization(c, uint(id)) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return
+		}
+
 		org, err := repo.GetOrganization(uint(id))
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
@@ -652,15 +772,38 @@ func UpdateOrganization(repo *repository.Repository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
 
-		var org repository.Organization
-		if err := c.ShouldBindJSON(&org); err != nil {
+		if !canAccessOrganization(c, uint(id)) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return
+		}
+
+		org, err := repo.GetOrganization(uint(id))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return
+		}
+
+		var req struct {
+			Name     *string `json:"name"`
+			TimeZone *string `json:"timezone"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		org.ID = uint(id)
+		if req.Name != nil {
+			org.Name = *req.Name
+		}
+		if req.TimeZone != nil {
+			if _, err := calendar.LoadLocation(*req.TimeZone); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			org.TimeZone = *req.TimeZone
+		}
 
-		if err := repo.UpdateOrganization(&org); err != nil {
+		if err := repo.UpdateOrganization(org); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -683,20 +826,44 @@ func ListUsers(repo *repository.Repository) gin.HandlerFunc {
 	}
 }
 
-func CreateUser(repo *repository.Repository) gin.HandlerFunc {
+func CreateUser(authService interface {
+	CreateUser(user *repository.User, password string) error
+}, roleService interface {
+	ValidateRole(orgID uint, role string) error
+}) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		orgID := middleware.GetOrganizationID(c)
 
-		var user repository.User
-		if err := c.ShouldBindJSON(&user); err != nil {
+		var req struct {
+			Email    string `json:"email" binding:"required,email"`
+			Password string `json:"password" binding:"required"`
+			FullName string `json:"full_name" binding:"required"`
+			Role     string `json:"role" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		user.OrganizationID = orgID
+		user := repository.User{
+			OrganizationID: orgID,
+			Email:          req.Email,
+			FullName:       req.FullName,
+			Role:           req.Role,
+		}
 
-		if err := repo.CreateUser(&user); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if !canAssignRole(c, user.Role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to assign role"})
+			return
+		}
+
+		if err := roleService.ValidateRole(orgID, user.Role); err != nil {
+			c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := authService.CreateUser(&user, req.Password); err != nil {
+			c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
 
@@ -707,8 +874,9 @@ func CreateUser(repo *repository.Repository) gin.HandlerFunc {
 func GetUser(repo *repository.Repository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+		orgID := middleware.GetOrganizationID(c)
 
-		user, err := repo.GetUser(uint(id))
+		user, err := repo.GetUser(uint(id), orgID)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 			return
@@ -718,9 +886,12 @@ func GetUser(repo *repository.Repository) gin.HandlerFunc {
 	}
 }
 
-func UpdateUser(repo *repository.Repository) gin.HandlerFunc {
+func UpdateUser(repo *repository.Repository, roleService interface {
+	ValidateRole(orgID uint, role string) error
+}) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+		orgID := middleware.GetOrganizationID(c)
 
 		var user repository.User
 		if err := c.ShouldBindJSON(&user); err != nil {
@@ -729,8 +900,23 @@ func UpdateUser(repo *repository.Repository) gin.HandlerFunc {
 		}
 
 		user.ID = uint(id)
+		user.OrganizationID = orgID
+
+		if !canAssignRole(c, user.Role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to assign role"})
+			return
+		}
+
+		if err := roleService.ValidateRole(orgID, user.Role); err != nil {
+			c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
 
 		if err := repo.UpdateUser(&user); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -742,8 +928,13 @@ func UpdateUser(repo *repository.Repository) gin.HandlerFunc {
 func DeleteUser(repo *repository.Repository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+		orgID := middleware.GetOrganizationID(c)
 
-		if err := repo.DeleteUser(uint(id)); err != nil {
+		if err := repo.DeleteUser(uint(id), orgID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -751,3 +942,52 @@ func DeleteUser(repo *repository.Repository) gin.HandlerFunc {
 		c.JSON(http.StatusOK, gin.H{"message": "User deleted"})
 	}
 }
+
+func canAccessOrganization(c *gin.Context, orgID uint) bool {
+	return orgID == middleware.GetOrganizationID(c) || middleware.HasPermission(c, rbac.PlatformManage)
+}
+
+func canAssignRole(c *gin.Context, role string) bool {
+	return !rbac.IsPlatformRole(role) || middleware.HasPermission(c, rbac.PlatformManage)
+}
+
+func serviceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrUnknownRole),
+		errors.Is(err, services.ErrInvalidPermission),
+		errors.Is(err, services.ErrReservedRoleName),
+		errors.Is(err, services.ErrUnknownOrganization),
+		errors.Is(err, services.ErrForeignReference),
+		errors.Is(err, services.ErrWeakPassword),
+		errors.Is(err, services.ErrInvalidResetToken),
+		errors.Is(err, services.ErrInvalidWebhookURL),
+		errors.Is(err, services.ErrPrivateAddress),
+		errors.Is(err, services.ErrUnknownEventType),
+		errors.Is(err, services.ErrInvalidNotificationPreference),
+		errors.Is(err, services.ErrInvalidJobSchedule),
+		errors.Is(err, services.ErrInvalidCalendar),
+		errors.Is(err, services.ErrInvalidWorkOrderSettings),
+		errors.Is(err, services.ErrInvalidProcedure),
+		errors.Is(err, services.ErrInvalidStepResults),
+		errors.Is(err, services.ErrInvalidForecastRange),
+		errors.Is(err, services.ErrInvalidCalendarFeed),
+		errors.Is(err, services.ErrIneligibleAssignee),
+		errors.Is(err, services.ErrInvalidSchedule),
+		errors.Is(err, calendar.ErrInvalidTimeZone),
+		errors.Is(err, calendar.ErrInvalidRecurrence):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrRoleNotAssignable),
+		errors.Is(err, services.ErrCalendarFeedForbidden),
+		errors.Is(err, services.ErrRegistrationClosed):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrRoleInUse),
+		errors.Is(err, services.ErrWorkOrderCompleted),
+		errors.Is(err, services.ErrProcedureResultsRequired),
+		errors.Is(err, services.ErrScheduleConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
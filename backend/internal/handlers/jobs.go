@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"assetsentinel/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JobHandler struct {
+	jobService interface {
+		List() ([]repository.Job, error)
+		Get(name string) (*repository.Job, error)
+		Runs(name string, page, pageSize int) ([]repository.JobRun, int, error)
+		Update(name string, schedule *string, enabled *bool) (*repository.Job, error)
+		Trigger(name string) (*repository.Job, error)
+	}
+}
+
+func NewJobHandler(jobService interface {
+	List() ([]repository.Job, error)
+	Get(name string) (*repository.Job, error)
+	Runs(name string, page, pageSize int) ([]repository.JobRun, int, error)
+	Update(name string, schedule *string, enabled *bool) (*repository.Job, error)
+	Trigger(name string) (*repository.Job, error)
+}) *JobHandler {
+	return &JobHandler{jobService: jobService}
+}
+
+func (h *JobHandler) List(c *gin.Context) {
+	jobs, err := h.jobService.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, jobs)
+}
+
+func (h *JobHandler) Get(c *gin.Context) {
+	job, err := h.jobService.Get(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+func (h *JobHandler) Update(c *gin.Context) {
+	var req struct {
+		Schedule *string `json:"schedule"`
+		Enabled  *bool   `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := h.jobService.Update(c.Param("name"), req.Schedule, req.Enabled)
+	if err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+func (h *JobHandler) Runs(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	runs, total, err := h.jobService.Runs(c.Param("name"), page, pageSize)
+	if err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      runs,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+func (h *JobHandler) Trigger(c *gin.Context) {
+	job, err := h.jobService.Trigger(c.Param("name"))
+	if err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"assetsentinel/internal/middleware"
+	"assetsentinel/internal/rbac"
+	"assetsentinel/internal/repository"
+	"assetsentinel/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type notificationPreferencesRequest struct {
+	QuietHoursStart *string                             `json:"quiet_hours_start"`
+	QuietHoursEnd   *string                             `json:"quiet_hours_end"`
+	TimeZone        *string                             `json:"timezone"`
+	ChatWebhookURL  *string                             `json:"chat_webhook_url"`
+	ChatFormat      *string                             `json:"chat_format"`
+	Preferences     []repository.NotificationPreference `json:"preferences"`
+}
+
+type NotificationHandler struct {
+	notificationService interface {
+		List(userID, orgID uint, page, pageSize int, unreadOnly bool) ([]repository.Notification, int, error)
+		UnreadCount(userID, orgID uint) (int, error)
+		MarkRead(id, userID, orgID uint) error
+		MarkAllRead(userID, orgID uint) (int64, error)
+		Preferences(orgID, userID uint) (*services.NotificationPreferences, error)
+		UpdatePreferences(settings *repository.NotificationSettings, preferences []repository.NotificationPreference) error
+	}
+}
+
+func NewNotificationHandler(notificationService interface {
+	List(userID, orgID uint, page, pageSize int, unreadOnly bool) ([]repository.Notification, int, error)
+	UnreadCount(userID, orgID uint) (int, error)
+	MarkRead(id, userID, orgID uint) error
+	MarkAllRead(userID, orgID uint) (int64, error)
+	Preferences(orgID, userID uint) (*services.NotificationPreferences, error)
+	UpdatePreferences(settings *repository.NotificationSettings, preferences []repository.NotificationPreference) error
+}) *NotificationHandler {
+	return &NotificationHandler{notificationService: notificationService}
+}
+
+func (h *NotificationHandler) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	unreadOnly := c.Query("unread") == "true"
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	notifications, total, err := h.notificationService.List(middleware.GetUserID(c), middleware.GetOrganizationID(c), page, pageSize, unreadOnly)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      notifications,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+func (h *NotificationHandler) UnreadCount(c *gin.Context) {
+	count, err := h.notificationService.UnreadCount(middleware.GetUserID(c), middleware.GetOrganizationID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread": count})
+}
+
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	err := h.notificationService.MarkRead(uint(id), middleware.GetUserID(c), middleware.GetOrganizationID(c))
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+}
+
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	updated, err := h.notificationService.MarkAllRead(middleware.GetUserID(c), middleware.GetOrganizationID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": updated})
+}
+
+func (h *NotificationHandler) Preferences(c *gin.Context) {
+	h.getPreferences(c, middleware.GetUserID(c))
+}
+
+func (h *NotificationHandler) UpdatePreferences(c *gin.Context) {
+	h.updatePreferences(c, middleware.GetUserID(c))
+}
+
+func (h *NotificationHandler) OrganizationPreferences(c *gin.Context) {
+	if !middleware.HasPermission(c, rbac.NotificationsManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+	h.getPreferences(c, 0)
+}
+
+func (h *NotificationHandler) UpdateOrganizationPreferences(c *gin.Context) {
+	if !middleware.HasPermission(c, rbac.NotificationsManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+	h.updatePreferences(c, 0)
+}
+
+func (h *NotificationHandler) getPreferences(c *gin.Context, userID uint) {
+	preferences, err := h.notificationService.Preferences(middleware.GetOrganizationID(c), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
+
+func (h *NotificationHandler) updatePreferences(c *gin.Context, userID uint) {
+	var req notificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings := &repository.NotificationSettings{
+		OrganizationID:  middleware.GetOrganizationID(c),
+		UserID:          userID,
+		QuietHoursStart: req.QuietHoursStart,
+		QuietHoursEnd:   req.QuietHoursEnd,
+		TimeZone:        req.TimeZone,
+		ChatWebhookURL:  req.ChatWebhookURL,
+		ChatFormat:      req.ChatFormat,
+	}
+	if err := h.notificationService.UpdatePreferences(settings, req.Preferences); err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	h.getPreferences(c, userID)
+}
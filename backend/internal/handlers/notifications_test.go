@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"assetsentinel/internal/jwtkeys"
+	"assetsentinel/internal/middleware"
+	"assetsentinel/internal/rbac"
+	"assetsentinel/internal/repository"
+	"assetsentinel/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newNotificationRouter(repo *repository.Repository, keys *jwtkeys.KeySet) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	roleService := services.NewRoleService(repo)
+	notificationHandler := NewNotificationHandler(services.NewNotificationService(repo, nil, map[string]services.NotificationChannel{
+		services.ChannelChat: services.NewChatChannel(),
+	}, 8))
+
+	r := gin.New()
+	notifications := r.Group("/api/notifications")
+	notifications.Use(middleware.AuthMiddleware(keys), middleware.LoadPermissions(roleService))
+	{
+		notifications.PUT("/preferences", notificationHandler.UpdatePreferences)
+		notifications.GET("/preferences/organization", middleware.RequirePermission(rbac.NotificationsManage), notificationHandler.OrganizationPreferences)
+		notifications.PUT("/preferences/organization", middleware.RequirePermission(rbac.NotificationsManage), notificationHandler.UpdateOrganizationPreferences)
+	}
+	return r
+}
+
+func TestOrganizationNotificationSettingsRequireManagePermission(t *testing.T) {
+	repo := newTestRepository(t)
+	keys := jwtkeys.NewHMAC("test-secret")
+	acme := seedTenant(t, repo, "acme")
+	r := newNotificationRouter(repo, keys)
+	tech := bearer(t, keys, acme.technician)
+	admin := bearer(t, keys, acme.admin)
+	settings := map[string]interface{}{"chat_webhook_url": "https://93.184.216.34/hook", "chat_format": "slack"}
+
+	if w := request(r, tech, http.MethodGet, "/api/notifications/preferences/organization", nil); w.Code != http.StatusForbidden {
+		t.Errorf("technician reading organization settings got %d, want 403", w.Code)
+	}
+	if w := request(r, tech, http.MethodPut, "/api/notifications/preferences/organization", settings); w.Code != http.StatusForbidden {
+		t.Errorf("technician changing organization settings got %d, want 403", w.Code)
+	}
+	if w := request(r, admin, http.MethodPut, "/api/notifications/preferences/organization", settings); w.Code != http.StatusOK {
+		t.Errorf("admin changing organization settings got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestChatWebhookMustBePublic(t *testing.T) {
+	repo := newTestRepository(t)
+	keys := jwtkeys.NewHMAC("test-secret")
+	acme := seedTenant(t, repo, "acme")
+	r := newNotificationRouter(repo, keys)
+
+	for _, url := range []string{"http://169.254.169.254/latest/meta-data/", "http://127.0.0.1:9000/hook", "http://10.0.0.8/hook"} {
+		body := map[string]interface{}{"chat_webhook_url": url}
+		if w := request(r, bearer(t, keys, acme.admin), http.MethodPut, "/api/notifications/preferences/organization", body); w.Code != http.StatusBadRequest {
+			t.Errorf("organization chat webhook %s got %d, want 400", url, w.Code)
+		}
+		if w := request(r, bearer(t, keys, acme.technician), http.MethodPut, "/api/notifications/preferences", body); w.Code != http.StatusBadRequest {
+			t.Errorf("personal chat webhook %s got %d, want 400", url, w.Code)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PasswordResetHandler struct {
+	resetService interface {
+		RequestReset(email, ip string) error
+		Reset(token, password, ip string) error
+	}
+}
+
+func NewPasswordResetHandler(resetService interface {
+	RequestReset(email, ip string) error
+	Reset(token, password, ip string) error
+}) *PasswordResetHandler {
+	return &PasswordResetHandler{resetService: resetService}
+}
+
+func (h *PasswordResetHandler) Forgot(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.resetService.RequestReset(req.Email, c.ClientIP()); err != nil {
+		if throttled(c, err) {
+			return
+		}
+		log.Printf("Error requesting password reset: %v", err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account exists, a password reset email has been sent"})
+}
+
+func (h *PasswordResetHandler) Reset(c *gin.Context) {
+	var req struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.resetService.Reset(req.Token, req.Password, c.ClientIP()); err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
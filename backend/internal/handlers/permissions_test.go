@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"assetsentinel/internal/jwtkeys"
+	"assetsentinel/internal/rbac"
+	"assetsentinel/internal/repository"
+	"assetsentinel/internal/services"
+)
+
+func TestCustomRolePermissionsAreEnforced(t *testing.T) {
+	repo := newTestRepository(t)
+	keys := jwtkeys.NewHMAC("test-secret")
+	acme := seedTenant(t, repo, "acme")
+	r := newTenancyRouter(repo, keys)
+
+	if err := services.NewRoleService(repo).Create(&repository.Role{OrganizationID: acme.org.ID, Name: "inspector",
+		Permissions: []string{rbac.AssetsRead, rbac.WorkOrdersRead}}); err != nil {
+		t.Fatal(err)
+	}
+	inspector := repository.User{OrganizationID: acme.org.ID, Email: "inspector@acme.test", PasswordHash: "x", FullName: "Inspector", Role: "inspector"}
+	if err := repo.CreateUser(&inspector); err != nil {
+		t.Fatal(err)
+	}
+	auth := bearer(t, keys, inspector)
+
+	tests := []struct {
+		method, path string
+		body         interface{}
+		want         int
+	}{
+		{http.MethodGet, fmt.Sprintf("/api/assets/%d", acme.asset.ID), nil, http.StatusOK},
+		{http.MethodGet, fmt.Sprintf("/api/work-orders/%d", acme.workOrder.ID), nil, http.StatusOK},
+		{http.MethodPut, fmt.Sprintf("/api/assets/%d", acme.asset.ID), map[string]interface{}{"name": "Renamed", "category": "pump", "status": "active"}, http.StatusForbidden},
+		{http.MethodPut, fmt.Sprintf("/api/work-orders/%d", acme.workOrder.ID), map[string]interface{}{"status": "completed"}, http.StatusForbidden},
+		{http.MethodGet, "/api/users", nil, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if w := request(r, auth, tt.method, tt.path, tt.body); w.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d: %s", tt.method, tt.path, w.Code, tt.want, w.Body)
+		}
+	}
+
+	orphan := repository.User{OrganizationID: acme.org.ID, Email: "orphan@acme.test", PasswordHash: "x", FullName: "Orphan", Role: "deleted_role"}
+	if err := repo.CreateUser(&orphan); err != nil {
+		t.Fatal(err)
+	}
+	if w := request(r, bearer(t, keys, orphan), http.MethodGet, fmt.Sprintf("/api/assets/%d", acme.asset.ID), nil); w.Code != http.StatusForbidden {
+		t.Errorf("unknown role got %d, want 403", w.Code)
+	}
+}
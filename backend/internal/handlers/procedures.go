@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"assetsentinel/internal/middleware"
+	"assetsentinel/internal/repository"
+	"assetsentinel/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProcedureHandler struct {
+	procedureService interface {
+		List(orgID uint) ([]repository.Procedure, error)
+		Get(id, orgID uint) (*repository.Procedure, error)
+		Create(procedure *repository.Procedure) error
+		Update(procedure *repository.Procedure) error
+		Delete(id, orgID uint) error
+		WorkOrderProcedure(workOrderID, orgID uint) (*repository.Procedure, []repository.StepResult, error)
+		SubmitResults(workOrderID, orgID, actorID uint, submissions []services.StepSubmission) (*services.ProcedureOutcome, error)
+	}
+}
+
+func NewProcedureHandler(procedureService interface {
+	List(orgID uint) ([]repository.Procedure, error)
+	Get(id, orgID uint) (*repository.Procedure, error)
+	Create(procedure *repository.Procedure) error
+	Update(procedure *repository.Procedure) error
+	Delete(id, orgID uint) error
+	WorkOrderProcedure(workOrderID, orgID uint) (*repository.Procedure, []repository.StepResult, error)
+	SubmitResults(workOrderID, orgID, actorID uint, submissions []services.StepSubmission) (*services.ProcedureOutcome, error)
+}) *ProcedureHandler {
+	return &ProcedureHandler{procedureService: procedureService}
+}
+
+func (h *ProcedureHandler) List(c *gin.Context) {
+	procedures, err := h.procedureService.List(middleware.GetOrganizationID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, procedures)
+}
+
+func (h *ProcedureHandler) Get(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	orgID := middleware.GetOrganizationID(c)
+
+	procedure, err := h.procedureService.Get(uint(id), orgID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Procedure not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, procedure)
+}
+
+func (h *ProcedureHandler) Create(c *gin.Context) {
+	var procedure repository.Procedure
+	if err := c.ShouldBindJSON(&procedure); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	procedure.OrganizationID = middleware.GetOrganizationID(c)
+
+	if err := h.procedureService.Create(&procedure); err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, procedure)
+}
+
+func (h *ProcedureHandler) Update(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	orgID := middleware.GetOrganizationID(c)
+
+	var procedure repository.Procedure
+	if err := c.ShouldBindJSON(&procedure); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	procedure.ID = uint(id)
+	procedure.OrganizationID = orgID
+
+	if err := h.procedureService.Update(&procedure); err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, procedure)
+}
+
+func (h *ProcedureHandler) Delete(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	orgID := middleware.GetOrganizationID(c)
+
+	if err := h.procedureService.Delete(uint(id), orgID); err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Procedure deleted"})
+}
+
+func (h *ProcedureHandler) WorkOrderProcedure(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	orgID := middleware.GetOrganizationID(c)
+
+	procedure, results, err := h.procedureService.WorkOrderProcedure(uint(id), orgID)
+	if err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"procedure": procedure,
+		"results":   results,
+	})
+}
+
+func (h *ProcedureHandler) SubmitResults(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	orgID := middleware.GetOrganizationID(c)
+
+	var req struct {
+		Results []services.StepSubmission `json:"results" binding:"required,dive"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	outcome, err := h.procedureService.SubmitResults(uint(id), orgID, middleware.GetUserID(c), req.Results)
+	if err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, outcome)
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"assetsentinel/internal/middleware"
+	"assetsentinel/internal/websocket"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetRealtimeMetrics(hub interface {
+	Metrics() websocket.Metrics
+}) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, hub.Metrics())
+	}
+}
+
+func CreateConnectionTicket(tickets interface {
+	Issue(userID, orgID uint, role string) (string, time.Time, error)
+}) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ticket, expiresAt, err := tickets.Issue(middleware.GetUserID(c), middleware.GetOrganizationID(c), middleware.GetRole(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue connection ticket"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"ticket": ticket, "expires_at": expiresAt})
+	}
+}
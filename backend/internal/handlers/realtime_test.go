@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"assetsentinel/internal/jwtkeys"
+	"assetsentinel/internal/middleware"
+	"assetsentinel/internal/repository"
+	"assetsentinel/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTicketRouter(repo *repository.Repository, keys *jwtkeys.KeySet) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	tickets := services.NewConnectionTicketService(repo)
+
+	r := gin.New()
+	r.GET("/ws", middleware.ConnectionTicket(tickets, middleware.AuthMiddleware(keys)), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": middleware.GetUserID(c), "organization_id": middleware.GetOrganizationID(c), "role": middleware.GetRole(c)})
+	})
+	r.POST("/api/realtime/tickets", middleware.AuthMiddleware(keys), CreateConnectionTicket(tickets))
+	return r
+}
+
+func issueTicket(t *testing.T, r http.Handler, auth string) string {
+	t.Helper()
+	w := request(r, auth, http.MethodPost, "/api/realtime/tickets", nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("issuing a ticket returned %d: %s", w.Code, w.Body)
+	}
+	var body struct {
+		Ticket string `json:"ticket"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Ticket == "" {
+		t.Fatalf("no ticket in %s", w.Body)
+	}
+	return body.Ticket
+}
+
+func TestConnectionTicketsAreSingleUse(t *testing.T) {
+	repo := newTestRepository(t)
+	keys := jwtkeys.NewHMAC("test-secret")
+	acme := seedTenant(t, repo, "acme")
+	r := newTicketRouter(repo, keys)
+
+	ticket := issueTicket(t, r, bearer(t, keys, acme.technician))
+
+	w := request(r, "", http.MethodGet, "/ws?ticket="+ticket, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("first use returned %d: %s", w.Code, w.Body)
+	}
+	var identity struct {
+		UserID         uint   `json:"user_id"`
+		OrganizationID uint   `json:"organization_id"`
+		Role           string `json:"role"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &identity)
+	if identity.UserID != acme.technician.ID || identity.OrganizationID != acme.org.ID || identity.Role != acme.technician.Role {
+		t.Errorf("ticket authenticated as %+v, want the technician", identity)
+	}
+
+	if w := request(r, "", http.MethodGet, "/ws?ticket="+ticket, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("second use returned %d, want 401", w.Code)
+	}
+}
+
+func TestConnectionTicketsExpire(t *testing.T) {
+	repo := newTestRepository(t)
+	keys := jwtkeys.NewHMAC("test-secret")
+	acme := seedTenant(t, repo, "acme")
+	r := newTicketRouter(repo, keys)
+
+	sum := sha256.Sum256([]byte("expired"))
+	if err := repo.CreateConnectionTicket(&repository.ConnectionTicket{
+		UserID:         acme.admin.ID,
+		OrganizationID: acme.org.ID,
+		Role:           acme.admin.Role,
+		ExpiresAt:      time.Now().UTC().Add(-time.Second),
+	}, hex.EncodeToString(sum[:])); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := request(r, "", http.MethodGet, "/ws?ticket=expired", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("expired ticket returned %d, want 401", w.Code)
+	}
+}
+
+func TestRealtimeRejectsTokenInQuery(t *testing.T) {
+	repo := newTestRepository(t)
+	keys := jwtkeys.NewHMAC("test-secret")
+	acme := seedTenant(t, repo, "acme")
+	r := newTicketRouter(repo, keys)
+	auth := bearer(t, keys, acme.admin)
+
+	if w := request(r, "", http.MethodGet, "/ws?token="+strings.TrimPrefix(auth, "Bearer "), nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("JWT in the query returned %d, want 401", w.Code)
+	}
+	if w := request(r, auth, http.MethodGet, "/ws", nil); w.Code != http.StatusOK {
+		t.Errorf("JWT in the Authorization header returned %d, want 200", w.Code)
+	}
+	if w := request(r, "", http.MethodPost, "/api/realtime/tickets", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("issuing a ticket without a login returned %d, want 401", w.Code)
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"assetsentinel/internal/middleware"
+	"assetsentinel/internal/rbac"
+	"assetsentinel/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoleHandler struct {
+	roleService interface {
+		List(orgID uint) ([]repository.Role, error)
+		Get(id, orgID uint) (*repository.Role, error)
+		Create(role *repository.Role) error
+		Update(role *repository.Role) error
+		Delete(id, orgID uint) error
+	}
+}
+
+func NewRoleHandler(roleService interface {
+	List(orgID uint) ([]repository.Role, error)
+	Get(id, orgID uint) (*repository.Role, error)
+	Create(role *repository.Role) error
+	Update(role *repository.Role) error
+	Delete(id, orgID uint) error
+}) *RoleHandler {
+	return &RoleHandler{roleService: roleService}
+}
+
+func (h *RoleHandler) List(c *gin.Context) {
+	orgID := middleware.GetOrganizationID(c)
+
+	custom, err := h.roleService.List(orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	builtin := []gin.H{}
+	for _, name := range rbac.BuiltinRoles() {
+		builtin = append(builtin, gin.H{
+			"name":        name,
+			"permissions": rbac.BuiltinPermissions(name),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"builtin": builtin,
+		"custom":  custom,
+	})
+}
+
+func (h *RoleHandler) Get(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	orgID := middleware.GetOrganizationID(c)
+
+	role, err := h.roleService.Get(uint(id), orgID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+func (h *RoleHandler) Create(c *gin.Context) {
+	var role repository.Role
+	if err := c.ShouldBindJSON(&role); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role.OrganizationID = middleware.GetOrganizationID(c)
+
+	if err := h.roleService.Create(&role); err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, role)
+}
+
+func (h *RoleHandler) Update(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	orgID := middleware.GetOrganizationID(c)
+
+	var role repository.Role
+	if err := c.ShouldBindJSON(&role); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role.ID = uint(id)
+	role.OrganizationID = orgID
+
+	if err := h.roleService.Update(&role); err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+func (h *RoleHandler) Delete(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	orgID := middleware.GetOrganizationID(c)
+
+	if err := h.roleService.Delete(uint(id), orgID); err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted"})
+}
+
+func ListPermissions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, rbac.AllPermissions())
+	}
+}
+
+func GetMyPermissions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"role":        middleware.GetRole(c),
+			"permissions": middleware.GetPermissions(c),
+		})
+	}
+}
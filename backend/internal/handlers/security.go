@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"assetsentinel/internal/middleware"
+	"assetsentinel/internal/rbac"
+	"assetsentinel/internal/repository"
+	"assetsentinel/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func UnlockUser(repo *repository.Repository, loginGuard interface {
+	Unlock(user *repository.User, actorID uint) error
+}) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+		orgID := middleware.GetOrganizationID(c)
+
+		user, err := repo.GetUser(uint(id), orgID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		if err := loginGuard.Unlock(user, middleware.GetUserID(c)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "User unlocked"})
+	}
+}
+
+func ListSecurityEvents(loginGuard interface {
+	ListEvents(orgID uint, page, pageSize int, eventType string) ([]repository.SecurityEvent, int, error)
+}) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID := middleware.GetOrganizationID(c)
+		if middleware.HasPermission(c, rbac.PlatformManage) && c.Query("scope") == "platform" {
+			orgID = 0
+		}
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+		eventType := c.Query("type")
+
+		events, total, err := loginGuard.ListEvents(orgID, page, pageSize, eventType)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data":      events,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		})
+	}
+}
+
+func GetJWKS(keys interface {
+	JWKS() map[string]interface{}
+}) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, keys.JWKS())
+	}
+}
+
+// throttled responds with 429 and Retry-After if err is a ThrottleError.
+func throttled(c *gin.Context, err error) bool {
+	var throttle *services.ThrottleError
+	if !errors.As(err, &throttle) {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttle.RetryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	return true
+}
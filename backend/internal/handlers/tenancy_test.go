@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"assetsentinel/internal/jwtkeys"
+	"assetsentinel/internal/middleware"
+	"assetsentinel/internal/rbac"
+	"assetsentinel/internal/repository"
+	"assetsentinel/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type tenant struct {
+	org        repository.Organization
+	admin      repository.User
+	technician repository.User
+	asset      repository.Asset
+	workOrder  repository.WorkOrder
+}
+
+func newTestRepository(t *testing.T) *repository.Repository {
+	t.Helper()
+	db, err := repository.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := repository.RunMigrations(db); err != nil {
+		t.Fatal(err)
+	}
+	return repository.NewRepository(db)
+}
+
+func seedTenant(t *testing.T, repo *repository.Repository, name string) *tenant {
+	t.Helper()
+	tn := &tenant{org: repository.Organization{Name: name}}
+	if err := repo.CreateOrganization(&tn.org); err != nil {
+		t.Fatal(err)
+	}
+	tn.admin = repository.User{OrganizationID: tn.org.ID, Email: "admin@" + name + ".test", PasswordHash: "x", FullName: name + " Admin", Role: rbac.RoleAdmin}
+	tn.technician = repository.User{OrganizationID: tn.org.ID, Email: "tech@" + name + ".test", PasswordHash: "x", FullName: name + " Technician", Role: rbac.RoleTechnician}
+	for _, user := range []*repository.User{&tn.admin, &tn.technician} {
+		if err := repo.CreateUser(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tn.asset = repository.Asset{OrganizationID: tn.org.ID, Name: name + " Pump", Category: "pump", Status: "active"}
+	if err := repo.CreateAsset(&tn.asset); err != nil {
+		t.Fatal(err)
+	}
+	tn.workOrder = repository.WorkOrder{OrganizationID: tn.org.ID, AssetID: tn.asset.ID, TechnicianID: &tn.technician.ID, Title: name + " repair", Status: "pending", Priority: "medium"}
+	if err := repo.CreateWorkOrder(&tn.workOrder); err != nil {
+		t.Fatal(err)
+	}
+	return tn
+}
+
+func newTenancyRouter(repo *repository.Repository, keys *jwtkeys.KeySet) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	roleService := services.NewRoleService(repo)
+	assetHandler := NewAssetHandler(services.NewAssetService(repo))
+	workOrderHandler := NewWorkOrderHandler(services.NewWorkOrderService(repo, nil, services.NewDispatchService(repo, roleService)))
+
+	r := gin.New()
+	api := r.Group("/api")
+	api.Use(middleware.AuthMiddleware(keys), middleware.LoadPermissions(roleService))
+	{
+		api.GET("/assets/:id", middleware.RequirePermission(rbac.AssetsRead), assetHandler.Get)
+		api.PUT("/assets/:id", middleware.RequirePermission(rbac.AssetsWrite), assetHandler.Update)
+		api.DELETE("/assets/:id", middleware.RequirePermission(rbac.AssetsDelete), assetHandler.Delete)
+
+		api.GET("/work-orders/:id", middleware.RequirePermission(rbac.WorkOrdersRead), workOrderHandler.Get)
+		api.PUT("/work-orders/:id", middleware.RequirePermission(rbac.WorkOrdersUpdate), workOrderHandler.Update)
+		api.DELETE("/work-orders/:id", middleware.RequirePermission(rbac.WorkOrdersDelete), workOrderHandler.Delete)
+
+		orgs := api.Group("/organizations")
+		orgs.Use(middleware.RequirePermission(rbac.OrganizationsManage))
+		orgs.GET("", ListOrganizations(repo))
+		orgs.GET("/:id", GetOrganization(repo))
+		orgs.PUT("/:id", UpdateOrganization(repo))
+
+		users := api.Group("/users")
+		users.Use(middleware.RequirePermission(rbac.UsersManage))
+		users.GET("", ListUsers(repo))
+		users.GET("/:id", GetUser(repo))
+		users.PUT("/:id", UpdateUser(repo, roleService))
+		users.DELETE("/:id", DeleteUser(repo))
+	}
+	return r
+}
+
+func bearer(t *testing.T, keys *jwtkeys.KeySet, user repository.User) string {
+	t.Helper()
+	token, err := keys.Sign(&services.Claims{
+		UserID:         user.ID,
+		OrganizationID: user.OrganizationID,
+		Role:           user.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "Bearer " + token
+}
+
+func request(r http.Handler, auth, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var payload bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCrossTenantAccessIsRejected(t *testing.T) {
+	repo := newTestRepository(t)
+	keys := jwtkeys.NewHMAC("test-secret")
+	acme := seedTenant(t, repo, "acme")
+	globex := seedTenant(t, repo, "globex")
+	r := newTenancyRouter(repo, keys)
+	auth := bearer(t, keys, acme.admin)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		want   int
+	}{
+		{"get user", http.MethodGet, fmt.Sprintf("/api/users/%d", globex.technician.ID), nil, http.StatusNotFound},
+		{"update user", http.MethodPut, fmt.Sprintf("/api/users/%d", globex.technician.ID),
+			gin.H{"email": "owned@acme.test", "full_name": "Owned", "role": rbac.RoleTechnician}, http.StatusNotFound},
+		{"delete user", http.MethodDelete, fmt.Sprintf("/api/users/%d", globex.technician.ID), nil, http.StatusNotFound},
+		{"get asset", http.MethodGet, fmt.Sprintf("/api/assets/%d", globex.asset.ID), nil, http.StatusNotFound},
+		{"update asset", http.MethodPut, fmt.Sprintf("/api/assets/%d", globex.asset.ID),
+			gin.H{"name": "Owned", "category": "pump", "status": "retired"}, http.StatusNotFound},
+		{"delete asset", http.MethodDelete, fmt.Sprintf("/api/assets/%d", globex.asset.ID), nil, http.StatusNotFound},
+		{"get work order", http.MethodGet, fmt.Sprintf("/api/work-orders/%d", globex.workOrder.ID), nil, http.StatusNotFound},
+		{"update work order", http.MethodPut, fmt.Sprintf("/api/work-orders/%d", globex.workOrder.ID),
+			gin.H{"asset_id": acme.asset.ID, "title": "Owned", "status": "closed", "priority": "low"}, http.StatusNotFound},
+		{"delete work order", http.MethodDelete, fmt.Sprintf("/api/work-orders/%d", globex.workOrder.ID), nil, http.StatusNotFound},
+		{"get organization", http.MethodGet, fmt.Sprintf("/api/organizations/%d", globex.org.ID), nil, http.StatusNotFound},
+		{"update organization", http.MethodPut, fmt.Sprintf("/api/organizations/%d", globex.org.ID), gin.H{"name": "Owned"}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := request(r, auth, tt.method, tt.path, tt.body)
+			if w.Code != tt.want {
+				t.Fatalf("%s %s = %d, want %d: %s", tt.method, tt.path, w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+
+	if user, err := repo.GetUser(globex.technician.ID, globex.org.ID); err != nil || user.Email != globex.technician.Email || user.Role != rbac.RoleTechnician {
+		t.Errorf("globex technician was modified: %+v, %v", user, err)
+	}
+	if asset, err := repo.GetAsset(globex.asset.ID, globex.org.ID); err != nil || asset.Name != globex.asset.Name {
+		t.Errorf("globex asset was modified or deleted: %+v, %v", asset, err)
+	}
+	if wo, err := repo.GetWorkOrder(globex.workOrder.ID, globex.org.ID); err != nil || wo.Title != globex.workOrder.Title || wo.Status != "pending" {
+		t.Errorf("globex work order was modified or deleted: %+v, %v", wo, err)
+	}
+	if org, err := repo.GetOrganization(globex.org.ID); err != nil || org.Name != "globex" {
+		t.Errorf("globex organization was modified: %+v, %v", org, err)
+	}
+}
+
+func TestListsAreScopedToTheCallersOrganization(t *testing.T) {
+	repo := newTestRepository(t)
+	keys := jwtkeys.NewHMAC("test-secret")
+	acme := seedTenant(t, repo, "acme")
+	seedTenant(t, repo, "globex")
+	r := newTenancyRouter(repo, keys)
+	auth := bearer(t, keys, acme.admin)
+
+	w := request(r, auth, http.MethodGet, "/api/organizations", nil)
+	var orgs []repository.Organization
+	if err := json.Unmarshal(w.Body.Bytes(), &orgs); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("list organizations = %d: %s", w.Code, w.Body.String())
+	}
+	if len(orgs) != 1 || orgs[0].ID != acme.org.ID {
+		t.Errorf("list organizations returned %+v, want only %d", orgs, acme.org.ID)
+	}
+
+	w = request(r, auth, http.MethodGet, "/api/users", nil)
+	var users []repository.User
+	if err := json.Unmarshal(w.Body.Bytes(), &users); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("list users = %d: %s", w.Code, w.Body.String())
+	}
+	for _, user := range users {
+		if user.OrganizationID != acme.org.ID {
+			t.Errorf("list users returned user %d of organization %d", user.ID, user.OrganizationID)
+		}
+	}
+}
+
+func TestOrganizationsRequireManagePermission(t *testing.T) {
+	repo := newTestRepository(t)
+	keys := jwtkeys.NewHMAC("test-secret")
+	acme := seedTenant(t, repo, "acme")
+	r := newTenancyRouter(repo, keys)
+	auth := bearer(t, keys, acme.technician)
+
+	for _, path := range []string{"/api/organizations", fmt.Sprintf("/api/organizations/%d", acme.org.ID), fmt.Sprintf("/api/users/%d", acme.admin.ID)} {
+		if w := request(r, auth, http.MethodGet, path, nil); w.Code != http.StatusForbidden {
+			t.Errorf("GET %s as technician = %d, want %d", path, w.Code, http.StatusForbidden)
+		}
+	}
+	if w := request(r, auth, http.MethodPut, fmt.Sprintf("/api/organizations/%d", acme.org.ID), gin.H{"name": "Renamed"}); w.Code != http.StatusForbidden {
+		t.Errorf("PUT organization as technician = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestSuperAdminCanReachOtherOrganizations(t *testing.T) {
+	repo := newTestRepository(t)
+	keys := jwtkeys.NewHMAC("test-secret")
+	acme := seedTenant(t, repo, "acme")
+	globex := seedTenant(t, repo, "globex")
+	platform := repository.User{OrganizationID: acme.org.ID, Email: "platform@acme.test", PasswordHash: "x", FullName: "Platform", Role: rbac.RoleSuperAdmin}
+	if err := repo.CreateUser(&platform); err != nil {
+		t.Fatal(err)
+	}
+	r := newTenancyRouter(repo, keys)
+	auth := bearer(t, keys, platform)
+
+	if w := request(r, auth, http.MethodGet, fmt.Sprintf("/api/organizations/%d", globex.org.ID), nil); w.Code != http.StatusOK {
+		t.Errorf("GET other organization as super admin = %d, want %d", w.Code, http.StatusOK)
+	}
+	w := request(r, auth, http.MethodGet, "/api/organizations", nil)
+	var orgs []repository.Organization
+	if err := json.Unmarshal(w.Body.Bytes(), &orgs); err != nil || len(orgs) != 2 {
+		t.Errorf("list organizations as super admin = %d %s, want both organizations", w.Code, w.Body.String())
+	}
+}
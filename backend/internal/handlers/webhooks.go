@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"assetsentinel/internal/events"
+	"assetsentinel/internal/middleware"
+	"assetsentinel/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookHandler struct {
+	webhookService interface {
+		List(orgID uint) ([]repository.Webhook, error)
+		Get(id, orgID uint) (*repository.Webhook, error)
+		Create(webhook *repository.Webhook) error
+		Update(webhook *repository.Webhook) error
+		Delete(id, orgID uint) error
+		Deliveries(webhookID, orgID uint, page, pageSize int) ([]repository.WebhookDelivery, int, error)
+		Redeliver(deliveryID, orgID uint) (*repository.WebhookDelivery, error)
+	}
+}
+
+func NewWebhookHandler(webhookService interface {
+	List(orgID uint) ([]repository.Webhook, error)
+	Get(id, orgID uint) (*repository.Webhook, error)
+	Create(webhook *repository.Webhook) error
+	Update(webhook *repository.Webhook) error
+	Delete(id, orgID uint) error
+	Deliveries(webhookID, orgID uint, page, pageSize int) ([]repository.WebhookDelivery, int, error)
+	Redeliver(deliveryID, orgID uint) (*repository.WebhookDelivery, error)
+}) *WebhookHandler {
+	return &WebhookHandler{webhookService: webhookService}
+}
+
+type webhookRequest struct {
+	URL         string   `json:"url" binding:"required"`
+	EventTypes  []string `json:"event_types"`
+	Description *string  `json:"description"`
+	Active      *bool    `json:"active"`
+}
+
+func (r webhookRequest) apply(webhook *repository.Webhook) {
+	webhook.URL = r.URL
+	webhook.EventTypes = r.EventTypes
+	webhook.Description = r.Description
+	webhook.Active = r.Active == nil || *r.Active
+}
+
+func (h *WebhookHandler) List(c *gin.Context) {
+	webhooks, err := h.webhookService.List(middleware.GetOrganizationID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+func (h *WebhookHandler) Get(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	webhook, err := h.webhookService.Get(uint(id), middleware.GetOrganizationID(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (h *WebhookHandler) Create(c *gin.Context) {
+	var req webhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	webhook := repository.Webhook{OrganizationID: middleware.GetOrganizationID(c)}
+	req.apply(&webhook)
+
+	if err := h.webhookService.Create(&webhook); err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, struct {
+		repository.Webhook
+		Secret string `json:"secret"`
+	}{webhook, webhook.Secret})
+}
+
+func (h *WebhookHandler) Update(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	orgID := middleware.GetOrganizationID(c)
+
+	var req webhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	webhook := repository.Webhook{ID: uint(id), OrganizationID: orgID}
+	req.apply(&webhook)
+
+	if err := h.webhookService.Update(&webhook); err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := h.webhookService.Get(uint(id), orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func (h *WebhookHandler) Delete(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	if err := h.webhookService.Delete(uint(id), middleware.GetOrganizationID(c)); err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+}
+
+func (h *WebhookHandler) Deliveries(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	deliveries, total, err := h.webhookService.Deliveries(uint(id), middleware.GetOrganizationID(c), page, pageSize)
+	if err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      deliveries,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	delivery, err := h.webhookService.Redeliver(uint(id), middleware.GetOrganizationID(c))
+	if err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+func GetEventSchema() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, events.Schema())
+	}
+}
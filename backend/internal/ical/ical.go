@@ -0,0 +1,126 @@
+// Package ical writes iCalendar (RFC 5545) calendars for subscription feeds.
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	StatusTentative = "TENTATIVE"
+	StatusConfirmed = "CONFIRMED"
+	StatusCancelled = "CANCELLED"
+
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405Z"
+	maxLineOctets  = 75
+)
+
+// Event is a VEVENT. All-day events use the dates of Start and End, with End
+// being the day after the last day of the event.
+type Event struct {
+	UID          string
+	Sequence     int
+	Summary      string
+	Description  string
+	Location     string
+	Start        time.Time
+	End          time.Time
+	AllDay       bool
+	Status       string
+	LastModified time.Time
+}
+
+type Calendar struct {
+	Name        string
+	ProductID   string
+	RefreshRate time.Duration
+	Events      []Event
+}
+
+// Write encodes the calendar with CRLF line endings and lines folded at 75
+// octets. Stamp is written as the DTSTAMP of every event.
+func (c *Calendar) Write(w io.Writer, stamp time.Time) error {
+	out := bufio.NewWriter(w)
+	line := func(name, value string) { writeLine(out, name+":"+value) }
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", c.ProductID)
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		line("X-WR-CALNAME", escape(c.Name))
+	}
+	if c.RefreshRate > 0 {
+		line("REFRESH-INTERVAL;VALUE=DURATION", duration(c.RefreshRate))
+		line("X-PUBLISHED-TTL", duration(c.RefreshRate))
+	}
+	for _, event := range c.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", event.UID)
+		line("DTSTAMP", stamp.UTC().Format(dateTimeLayout))
+		if !event.LastModified.IsZero() {
+			line("LAST-MODIFIED", event.LastModified.UTC().Format(dateTimeLayout))
+		}
+		line("SEQUENCE", strconv.Itoa(event.Sequence))
+		if event.AllDay {
+			line("DTSTART;VALUE=DATE", event.Start.Format(dateLayout))
+			line("DTEND;VALUE=DATE", event.End.Format(dateLayout))
+		} else {
+			line("DTSTART", event.Start.UTC().Format(dateTimeLayout))
+			line("DTEND", event.End.UTC().Format(dateTimeLayout))
+		}
+		line("SUMMARY", escape(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION", escape(event.Description))
+		}
+		if event.Location != "" {
+			line("LOCATION", escape(event.Location))
+		}
+		if event.Status != "" {
+			line("STATUS", event.Status)
+		}
+		line("TRANSP", "OPAQUE")
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return out.Flush()
+}
+
+func escape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(text)
+}
+
+// writeLine folds content lines longer than 75 octets without splitting a
+// UTF-8 sequence; continuation lines start with a space.
+func writeLine(w *bufio.Writer, text string) {
+	limit := maxLineOctets
+	for len(text) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		w.WriteString(text[:cut])
+		w.WriteString("\r\n ")
+		text = text[cut:]
+		limit = maxLineOctets - 1
+	}
+	w.WriteString(text)
+	w.WriteString("\r\n")
+}
+
+func duration(d time.Duration) string {
+	minutes := int(d.Minutes())
+	if minutes < 1 {
+		minutes = 1
+	}
+	if minutes%60 == 0 {
+		return "PT" + strconv.Itoa(minutes/60) + "H"
+	}
+	return "PT" + strconv.Itoa(minutes) + "M"
+}
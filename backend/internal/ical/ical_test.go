@@ -0,0 +1,84 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	stamp := time.Date(2026, 3, 30, 12, 0, 0, 0, time.UTC)
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	cal := &Calendar{Name: "Acme, Inc. maintenance", ProductID: "-//Test//EN", RefreshRate: time.Hour, Events: []Event{
+		{
+			UID:         "work-order-1@test",
+			Sequence:    3,
+			Summary:     "Replace seal; check pump",
+			Description: "Line one\nLine two",
+			Start:       time.Date(2026, 3, 30, 8, 0, 0, 0, berlin),
+			End:         time.Date(2026, 3, 30, 10, 0, 0, 0, berlin),
+			Status:      StatusConfirmed,
+		},
+		{
+			UID:     "maintenance-2-20260331@test",
+			Summary: "Maintenance: Pump",
+			Start:   time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC),
+			End:     time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC),
+			AllDay:  true,
+			Status:  StatusCancelled,
+		},
+	}}
+
+	var out strings.Builder
+	if err := cal.Write(&out, stamp); err != nil {
+		t.Fatal(err)
+	}
+	text := out.String()
+	if !strings.HasSuffix(text, "END:VCALENDAR\r\n") || strings.Contains(strings.ReplaceAll(text, "\r\n", ""), "\n") {
+		t.Error("lines are not terminated by CRLF")
+	}
+	for _, line := range []string{
+		`X-WR-CALNAME:Acme\, Inc. maintenance`,
+		"REFRESH-INTERVAL;VALUE=DURATION:PT1H",
+		"UID:work-order-1@test",
+		"DTSTAMP:20260330T120000Z",
+		"SEQUENCE:3",
+		"DTSTART:20260330T060000Z",
+		"DTEND:20260330T080000Z",
+		`SUMMARY:Replace seal\; check pump`,
+		`DESCRIPTION:Line one\nLine two`,
+		"STATUS:CONFIRMED",
+		"DTSTART;VALUE=DATE:20260331",
+		"DTEND;VALUE=DATE:20260401",
+		"SEQUENCE:0",
+		"STATUS:CANCELLED",
+	} {
+		if !strings.Contains(text, "\r\n"+line+"\r\n") {
+			t.Errorf("calendar is missing %q:\n%s", line, text)
+		}
+	}
+}
+
+func TestWriteFoldsLongLines(t *testing.T) {
+	summary := strings.Repeat("Überprüfung ", 20)
+	cal := &Calendar{ProductID: "-//Test//EN", Events: []Event{{UID: "1@test", Summary: summary, Start: time.Now(), End: time.Now()}}}
+	var out strings.Builder
+	if err := cal.Write(&out, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	var unfolded strings.Builder
+	for i, line := range strings.Split(strings.TrimSuffix(out.String(), "\r\n"), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Errorf("line %d has %d octets", i, len(line))
+		}
+		if strings.HasPrefix(line, " ") {
+			unfolded.WriteString(line[1:])
+			continue
+		}
+		unfolded.WriteString("\n" + line)
+	}
+	if !strings.Contains(unfolded.String(), "\nSUMMARY:"+summary+"\n") {
+		t.Error("unfolding does not restore the summary; a UTF-8 sequence was split")
+	}
+}
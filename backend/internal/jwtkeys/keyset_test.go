@@ -0,0 +1,217 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func writePEM(t *testing.T, dir, kid, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeRSAKey(t *testing.T, dir, kid string) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, kid, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))
+	return key
+}
+
+func writeEdKey(t *testing.T, dir, kid string) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, kid, "PRIVATE KEY", der)
+	return key
+}
+
+func claims() jwt.MapClaims {
+	return jwt.MapClaims{"sub": "1", "exp": time.Now().Add(time.Hour).Unix()}
+}
+
+func load(t *testing.T, dir, active string) *KeySet {
+	t.Helper()
+	ks, err := LoadDir(dir, active)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ks
+}
+
+func TestSignAndParse(t *testing.T) {
+	dir := t.TempDir()
+	writeRSAKey(t, dir, "2024-rsa")
+	writeEdKey(t, dir, "2025-ed")
+
+	for kid, alg := range map[string]string{"2024-rsa": AlgRS256, "2025-ed": AlgEdDSA} {
+		ks := load(t, dir, kid)
+		signed, err := ks.Sign(claims())
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := ks.Parse(signed, jwt.MapClaims{})
+		if err != nil {
+			t.Fatalf("%s: %v", kid, err)
+		}
+		if token.Header["kid"] != kid || token.Method.Alg() != alg {
+			t.Errorf("%s token header = %v", kid, token.Header)
+		}
+	}
+}
+
+func TestRotation(t *testing.T) {
+	dir := t.TempDir()
+	writeRSAKey(t, dir, "old")
+	old, err := load(t, dir, "old").Sign(claims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeEdKey(t, dir, "new")
+	rotated := load(t, dir, "new")
+	if _, err := rotated.Parse(old, jwt.MapClaims{}); err != nil {
+		t.Errorf("token signed before rotation: %v", err)
+	}
+	current, err := rotated.Sign(claims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token, _ := rotated.Parse(current, jwt.MapClaims{}); token == nil || token.Header["kid"] != "new" {
+		t.Error("new tokens are not signed with the active key")
+	}
+
+	if err := os.Remove(filepath.Join(dir, "old.pem")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := load(t, dir, "new").Parse(old, jwt.MapClaims{}); err == nil {
+		t.Error("token signed by a retired key was accepted")
+	}
+}
+
+func TestAlgorithmPinning(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey := writeRSAKey(t, dir, "rsa")
+	writeEdKey(t, dir, "ed")
+	ks := load(t, dir, "rsa")
+	ks.AllowLegacyHMAC("legacy-secret")
+
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)})
+	sign := func(method jwt.SigningMethod, kid string, key interface{}) string {
+		token := jwt.NewWithClaims(method, claims())
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		accept bool
+	}{
+		{"legacy HMAC token", sign(jwt.SigningMethodHS256, "", []byte("legacy-secret")), true},
+		{"HMAC keyed with the RSA public key", sign(jwt.SigningMethodHS256, "rsa", publicPEM), false},
+		{"HMAC claiming the legacy key with another secret", sign(jwt.SigningMethodHS256, "", []byte("guess")), false},
+		{"RS256 under the EdDSA key ID", sign(jwt.SigningMethodRS256, "ed", rsaKey), false},
+		{"unsigned", sign(jwt.SigningMethodNone, "rsa", jwt.UnsafeAllowNoneSignatureType), false},
+		{"unknown key ID", sign(jwt.SigningMethodRS256, "other", rsaKey), false},
+	}
+	for _, tt := range tests {
+		_, err := ks.Parse(tt.token, jwt.MapClaims{})
+		if (err == nil) != tt.accept {
+			t.Errorf("%s: accepted = %v, want %v (%v)", tt.name, err == nil, tt.accept, err)
+		}
+	}
+
+	if _, err := load(t, dir, "rsa").Parse(sign(jwt.SigningMethodHS256, "", []byte("legacy-secret")), jwt.MapClaims{}); err == nil {
+		t.Error("HMAC token accepted without AllowLegacyHMAC")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey := writeRSAKey(t, dir, "rsa")
+	edKey := writeEdKey(t, dir, "ed")
+	ks := load(t, dir, "rsa")
+	ks.AllowLegacyHMAC("legacy-secret")
+
+	keys := ks.JWKS()["keys"].([]map[string]string)
+	if len(keys) != 2 {
+		t.Fatalf("JWKS has %d keys, want the RSA and Ed25519 public keys only", len(keys))
+	}
+	for _, jwk := range keys {
+		if _, ok := jwk["d"]; ok {
+			t.Errorf("key %s exposes private material", jwk["kid"])
+		}
+	}
+
+	ed, rsaJWK := keys[0], keys[1]
+	if ed["kid"] != "ed" || ed["kty"] != "OKP" || ed["alg"] != AlgEdDSA || ed["x"] != base64.RawURLEncoding.EncodeToString(edKey.Public().(ed25519.PublicKey)) {
+		t.Errorf("Ed25519 JWK = %v", ed)
+	}
+	n, _ := base64.RawURLEncoding.DecodeString(rsaJWK["n"])
+	e, _ := base64.RawURLEncoding.DecodeString(rsaJWK["e"])
+	if rsaJWK["kid"] != "rsa" || rsaJWK["alg"] != AlgRS256 || new(big.Int).SetBytes(n).Cmp(rsaKey.N) != 0 || new(big.Int).SetBytes(e).Int64() != int64(rsaKey.E) {
+		t.Errorf("RSA JWK = %v", rsaJWK)
+	}
+}
+
+func TestLoadDirErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := LoadDir(dir, "any"); err == nil {
+		t.Error("loaded an empty directory")
+	}
+
+	key := writeRSAKey(t, dir, "signing")
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, "verify-only", "PUBLIC KEY", der)
+
+	for _, active := range []string{"", "missing", "verify-only"} {
+		if _, err := LoadDir(dir, active); err == nil {
+			t.Errorf("LoadDir with active key %q succeeded", active)
+		}
+	}
+	ks := load(t, dir, "signing")
+	if _, err := ks.Parse(mustSign(t, ks), jwt.MapClaims{}); err != nil {
+		t.Errorf("a verify-only key in the directory broke signing: %v", err)
+	}
+}
+
+func mustSign(t *testing.T, ks *KeySet) string {
+	t.Helper()
+	signed, err := ks.Sign(claims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
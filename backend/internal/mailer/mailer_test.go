@@ -0,0 +1,162 @@
+package mailer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"net"
+	"strings"
+	"testing"
+)
+
+// smtpSink is a minimal SMTP server that records the transactions it accepts.
+type smtpSink struct {
+	listener net.Listener
+	auth     chan string
+	messages chan sinkMessage
+}
+
+type sinkMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+func newSMTPSink(t *testing.T) *smtpSink {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	sink := &smtpSink{listener: listener, auth: make(chan string, 1), messages: make(chan sinkMessage, 1)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go sink.serve(conn)
+		}
+	}()
+	return sink
+}
+
+func (s *smtpSink) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpSink) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 sink ESMTP")
+	var msg sinkMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			reply("250-sink")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			_, credentials, _ := strings.Cut(arg, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(credentials)
+			s.auth <- string(decoded)
+			reply("235 authenticated")
+		case "MAIL":
+			msg.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			reply("250 ok")
+		case "RCPT":
+			msg.to = append(msg.to, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			msg.data = data.String()
+			s.messages <- msg
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestSMTPMailerSendsMessage(t *testing.T) {
+	sink := newSMTPSink(t)
+	m := NewSMTPMailer("127.0.0.1", sink.port(), "", "", "noreply@assetsentinel.test")
+
+	err := m.Send(Message{
+		To:      []string{"tech@acme.test", "admin@acme.test"},
+		Subject: "Reset your AssetSentinel password",
+		Body:    "Hello,\nUse the link below.\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-sink.messages
+	if msg.from != "noreply@assetsentinel.test" {
+		t.Errorf("MAIL FROM %q", msg.from)
+	}
+	if strings.Join(msg.to, ",") != "tech@acme.test,admin@acme.test" {
+		t.Errorf("RCPT TO %v", msg.to)
+	}
+	for _, want := range []string{
+		"From: noreply@assetsentinel.test\r\n",
+		"To: tech@acme.test, admin@acme.test\r\n",
+		"Subject: Reset your AssetSentinel password\r\n",
+		"Content-Type: text/plain; charset=\"utf-8\"\r\n",
+		"\r\n\r\nHello,\r\nUse the link below.\r\n",
+	} {
+		if !strings.Contains(msg.data, want) {
+			t.Errorf("message is missing %q:\n%s", want, msg.data)
+		}
+	}
+}
+
+func TestSMTPMailerAuthenticates(t *testing.T) {
+	sink := newSMTPSink(t)
+	m := NewSMTPMailer("127.0.0.1", sink.port(), "mailer", "s3cret", "noreply@assetsentinel.test")
+
+	if err := m.Send(Message{To: []string{"tech@acme.test"}, Subject: "Test", Body: "Hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if auth := <-sink.auth; auth != "\x00mailer\x00s3cret" {
+		t.Errorf("AUTH PLAIN %q", auth)
+	}
+	<-sink.messages
+}
+
+func TestSMTPMailerReportsUnreachableServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	m := NewSMTPMailer("127.0.0.1", port, "", "", "noreply@assetsentinel.test")
+	err = m.Send(Message{To: []string{"tech@acme.test"}, Subject: "Test", Body: "Hello"})
+	if err == nil || !strings.Contains(err.Error(), "failed to send mail") {
+		t.Fatalf("got %v, want a send error", err)
+	}
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"net/http"
+	"sort"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -23,7 +24,37 @@ func CORS() gin.HandlerFunc {
 	}
 }
 
-func AuthMiddleware(secret string) gin.HandlerFunc {
+// ConnectionTicket authenticates a request with the single-use ticket in the
+// ticket query parameter, so browsers can open real-time connections without
+// putting a JWT in the URL. Requests without a ticket go through fallback.
+func ConnectionTicket(tickets interface {
+	Redeem(ticket string) (userID, orgID uint, role string, err error)
+}, fallback gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ticket := c.Query("ticket")
+		if ticket == "" {
+			fallback(c)
+			return
+		}
+
+		userID, orgID, role, err := tickets.Redeem(ticket)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid connection ticket"})
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", float64(userID))
+		c.Set("organization_id", float64(orgID))
+		c.Set("role", role)
+
+		c.Next()
+	}
+}
+
+func AuthMiddleware(keys interface {
+	Parse(tokenString string, claims jwt.Claims) (*jwt.Token, error)
+}) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -39,9 +70,7 @@ func AuthMiddleware(secret string) gin.HandlerFunc {
 			return
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(secret), nil
-		})
+		token, err := keys.Parse(tokenString, jwt.MapClaims{})
 
 		if err != nil || !token.Valid {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
@@ -64,32 +93,64 @@ func AuthMiddleware(secret string) gin.HandlerFunc {
 	}
 }
 
-func RequireRole(roles ...string) gin.HandlerFunc {
+func LoadPermissions(resolver interface {
+	ResolvePermissions(orgID uint, role string) ([]string, error)
+}) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userRole, exists := c.Get("role")
-		if !exists {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Role not found"})
+		role, _ := c.Get("role")
+		roleStr, _ := role.(string)
+
+		permissions, err := resolver.ResolvePermissions(GetOrganizationID(c), roleStr)
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Unable to resolve permissions"})
 			c.Abort()
 			return
 		}
 
-		roleStr, ok := userRole.(string)
-		if !ok {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid role"})
+		granted := make(map[string]bool, len(permissions))
+		for _, permission := range permissions {
+			granted[permission] = true
+		}
+		c.Set("permissions", granted)
+
+		c.Next()
+	}
+}
+
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasPermission(c, permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
 			c.Abort()
 			return
 		}
 
-		for _, role := range roles {
-			if roleStr == role {
-				c.Next()
-				return
-			}
-		}
+		c.Next()
+	}
+}
+
+func HasPermission(c *gin.Context, permission string) bool {
+	granted, _ := c.Get("permissions")
+	permissions, _ := granted.(map[string]bool)
+	return permissions[permission]
+}
 
-		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
-		c.Abort()
+func GetPermissions(c *gin.Context) []string {
+	granted, _ := c.Get("permissions")
+	permissions, _ := granted.(map[string]bool)
+
+	result := make([]string, 0, len(permissions))
+	for permission := range permissions {
+		result = append(result, permission)
 	}
+	sort.Strings(result)
+	return result
+}
+
+func GetRole(c *gin.Context) string {
+	role, _ := c.Get("role")
+	roleStr, _ := role.(string)
+	return roleStr
 }
 
 func GetOrganizationID(c *gin.Context) uint {
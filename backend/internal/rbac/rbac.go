@@ -0,0 +1,127 @@
+package rbac
+
+import "sort"
+
+const (
+	DashboardRead = "dashboard:read"
+
+	AssetsRead   = "assets:read"
+	AssetsWrite  = "assets:write"
+	AssetsDelete = "assets:delete"
+
+	MaintenanceRead   = "maintenance:read"
+	MaintenanceWrite  = "maintenance:write"
+	MaintenanceDelete = "maintenance:delete"
+
+	WorkOrdersRead   = "work_orders:read"
+	WorkOrdersCreate = "work_orders:create"
+	WorkOrdersUpdate = "work_orders:update"
+	WorkOrdersDelete = "work_orders:delete"
+
+	InventoryRead   = "inventory:read"
+	InventoryWrite  = "inventory:write"
+	InventoryDelete = "inventory:delete"
+
+	ReportsRead  = "reports:read"
+	AuditRead    = "audit:read"
+	SecurityRead = "security:read"
+
+	OrganizationsManage = "organizations:manage"
+	UsersManage         = "users:manage"
+	RolesManage         = "roles:manage"
+	WebhooksManage      = "webhooks:manage"
+	NotificationsManage = "notifications:manage"
+
+	PlatformManage = "platform:manage"
+)
+
+const (
+	RoleSuperAdmin         = "super_admin"
+	RoleAdmin              = "admin"
+	RoleMaintenanceManager = "maintenance_manager"
+	RoleTechnician         = "technician"
+	RoleViewer             = "viewer"
+)
+
+var readPermissions = []string{
+	DashboardRead,
+	AssetsRead,
+	MaintenanceRead,
+	WorkOrdersRead,
+	InventoryRead,
+	ReportsRead,
+	AuditRead,
+}
+
+var allPermissions = []string{
+	DashboardRead,
+	AssetsRead, AssetsWrite, AssetsDelete,
+	MaintenanceRead, MaintenanceWrite, MaintenanceDelete,
+	WorkOrdersRead, WorkOrdersCreate, WorkOrdersUpdate, WorkOrdersDelete,
+	InventoryRead, InventoryWrite, InventoryDelete,
+	ReportsRead,
+	AuditRead,
+	SecurityRead,
+	OrganizationsManage,
+	UsersManage,
+	RolesManage,
+	WebhooksManage,
+	NotificationsManage,
+}
+
+var builtinRoles = map[string][]string{
+	RoleSuperAdmin: append(append([]string{}, allPermissions...),
+		PlatformManage,
+	),
+	RoleAdmin: allPermissions,
+	RoleMaintenanceManager: append(append([]string{}, readPermissions...),
+		AssetsWrite,
+		MaintenanceWrite,
+		WorkOrdersCreate, WorkOrdersUpdate,
+		InventoryWrite,
+	),
+	RoleTechnician: append(append([]string{}, readPermissions...),
+		WorkOrdersUpdate,
+	),
+	RoleViewer: readPermissions,
+}
+
+func AllPermissions() []string {
+	return append([]string{}, allPermissions...)
+}
+
+func IsPermission(name string) bool {
+	for _, p := range allPermissions {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+func IsPlatformRole(role string) bool {
+	for _, p := range builtinRoles[role] {
+		if p == PlatformManage {
+			return true
+		}
+	}
+	return false
+}
+
+func IsBuiltinRole(role string) bool {
+	_, ok := builtinRoles[role]
+	return ok
+}
+
+func BuiltinRoles() []string {
+	roles := make([]string, 0, len(builtinRoles))
+	for role := range builtinRoles {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+func BuiltinPermissions(role string) []string {
+	return append([]string{}, builtinRoles[role]...)
+}
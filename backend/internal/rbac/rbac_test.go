@@ -0,0 +1,62 @@
+package rbac
+
+import "testing"
+
+func has(permissions []string, permission string) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBuiltinRolePermissions(t *testing.T) {
+	tests := []struct {
+		role    string
+		granted []string
+		denied  []string
+	}{
+		{RoleSuperAdmin, []string{PlatformManage, UsersManage, AssetsDelete}, nil},
+		{RoleAdmin, []string{UsersManage, RolesManage, WorkOrdersDelete, SecurityRead}, []string{PlatformManage}},
+		{RoleMaintenanceManager, []string{AssetsWrite, MaintenanceWrite, WorkOrdersCreate, WorkOrdersUpdate}, []string{AssetsDelete, WorkOrdersDelete, UsersManage, SecurityRead}},
+		{RoleTechnician, []string{WorkOrdersRead, WorkOrdersUpdate}, []string{WorkOrdersCreate, AssetsWrite, MaintenanceWrite, UsersManage}},
+		{RoleViewer, []string{DashboardRead, AssetsRead, ReportsRead}, []string{AssetsWrite, WorkOrdersUpdate, InventoryWrite}},
+	}
+	for _, tt := range tests {
+		permissions := BuiltinPermissions(tt.role)
+		for _, p := range tt.granted {
+			if !has(permissions, p) {
+				t.Errorf("%s lacks %s", tt.role, p)
+			}
+		}
+		for _, p := range tt.denied {
+			if has(permissions, p) {
+				t.Errorf("%s has %s", tt.role, p)
+			}
+		}
+	}
+}
+
+func TestPlatformPermissionIsNotGrantable(t *testing.T) {
+	if IsPermission(PlatformManage) {
+		t.Error("platform:manage can be granted to a custom role")
+	}
+	if has(AllPermissions(), PlatformManage) {
+		t.Error("AllPermissions lists platform:manage")
+	}
+	if !IsPermission(WorkOrdersUpdate) || IsPermission("work_orders:approve") {
+		t.Error("IsPermission does not match the permission list")
+	}
+	if !IsPlatformRole(RoleSuperAdmin) || IsPlatformRole(RoleAdmin) || IsPlatformRole("inspector") {
+		t.Error("only super_admin is a platform role")
+	}
+}
+
+func TestBuiltinPermissionsReturnsCopies(t *testing.T) {
+	BuiltinPermissions(RoleViewer)[0] = PlatformManage
+	AllPermissions()[0] = PlatformManage
+	if has(BuiltinPermissions(RoleViewer), PlatformManage) || has(AllPermissions(), PlatformManage) {
+		t.Error("callers can modify the built-in permission lists")
+	}
+}
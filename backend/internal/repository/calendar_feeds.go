@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"time"
+
+	"assetsentinel/internal/calendar"
+)
+
+const calendarFeedColumns = `id, organization_id, user_id, scope, name, last_used_at, revoked_at, created_at`
+
+func scanCalendarFeed(row rowScanner) (*CalendarFeed, error) {
+	var feed CalendarFeed
+	if err := row.Scan(&feed.ID, &feed.OrganizationID, &feed.UserID, &feed.Scope, &feed.Name, &feed.LastUsedAt, &feed.RevokedAt, &feed.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
+
+func (r *Repository) CreateCalendarFeed(feed *CalendarFeed, tokenHash string) error {
+	result, err := r.Exec(`INSERT INTO calendar_feeds (organization_id, user_id, scope, name, token_hash) VALUES (?, ?, ?, ?, ?)`,
+		feed.OrganizationID, feed.UserID, feed.Scope, feed.Name, tokenHash)
+	if err != nil {
+		return err
+	}
+	id, _ := result.LastInsertId()
+	feed.ID = uint(id)
+	feed.CreatedAt = time.Now().UTC()
+	return nil
+}
+
+func (r *Repository) ListCalendarFeeds(orgID, userID uint) ([]CalendarFeed, error) {
+	rows, err := r.Query(`SELECT `+calendarFeedColumns+` FROM calendar_feeds WHERE organization_id = ? AND user_id = ? ORDER BY id DESC`, orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feeds := []CalendarFeed{}
+	for rows.Next() {
+		feed, err := scanCalendarFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, *feed)
+	}
+	return feeds, rows.Err()
+}
+
+func (r *Repository) GetCalendarFeedByTokenHash(tokenHash string) (*CalendarFeed, error) {
+	return scanCalendarFeed(r.QueryRow(`SELECT `+calendarFeedColumns+` FROM calendar_feeds WHERE token_hash = ? AND revoked_at IS NULL`, tokenHash))
+}
+
+func (r *Repository) RevokeCalendarFeed(id, orgID, userID uint) error {
+	result, err := r.Exec(`UPDATE calendar_feeds SET revoked_at = ? WHERE id = ? AND organization_id = ? AND user_id = ? AND revoked_at IS NULL`,
+		time.Now().UTC(), id, orgID, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (r *Repository) TouchCalendarFeed(id uint, usedAt time.Time) error {
+	_, err := r.Exec(`UPDATE calendar_feeds SET last_used_at = ? WHERE id = ?`, usedAt, id)
+	return err
+}
+
+// Work orders appear in feeds when they are scheduled or belong to a
+// maintenance task; tasks appear only while no work order carries them.
+const (
+	workOrderEntryQuery = `SELECT wo.id, wo.maintenance_task_id, mt.maintenance_plan_id, wo.technician_id, wo.title, wo.description, a.name, a.location,
+		wo.status, wo.priority, wo.scheduled_start, wo.scheduled_end, mt.scheduled_date, wo.estimated_duration_hours, wo.calendar_sequence, wo.updated_at
+		FROM work_orders wo
+		JOIN assets a ON a.id = wo.asset_id
+		LEFT JOIN maintenance_tasks mt ON mt.id = wo.maintenance_task_id
+		WHERE wo.organization_id = ? AND (wo.scheduled_start IS NOT NULL OR mt.id IS NOT NULL)`
+
+	taskEntryQuery = `SELECT mt.id, mt.maintenance_plan_id, a.name, a.location, mt.status, mt.scheduled_date, p.estimated_duration_hours, mt.calendar_sequence, mt.updated_at
+		FROM maintenance_tasks mt
+		JOIN assets a ON a.id = mt.asset_id
+		JOIN maintenance_plans p ON p.id = mt.maintenance_plan_id
+		WHERE mt.organization_id = ? AND NOT EXISTS (SELECT 1 FROM work_orders wo WHERE wo.maintenance_task_id = mt.id)`
+)
+
+func scanWorkOrderEntry(row rowScanner) (*CalendarEntry, error) {
+	var entry CalendarEntry
+	if err := row.Scan(&entry.WorkOrderID, &entry.MaintenanceTaskID, &entry.MaintenancePlanID, &entry.TechnicianID, &entry.Title, &entry.Description, &entry.AssetName, &entry.AssetLocation,
+		&entry.Status, &entry.Priority, &entry.ScheduledStart, &entry.ScheduledEnd, &entry.ScheduledDate, &entry.EstimatedDurationHours, &entry.Sequence, &entry.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func scanTaskEntry(row rowScanner) (*CalendarEntry, error) {
+	var entry CalendarEntry
+	if err := row.Scan(&entry.MaintenanceTaskID, &entry.MaintenancePlanID, &entry.AssetName, &entry.AssetLocation, &entry.Status, &entry.ScheduledDate,
+		&entry.EstimatedDurationHours, &entry.Sequence, &entry.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (r *Repository) queryCalendarEntries(scan func(rowScanner) (*CalendarEntry, error), query string, args ...interface{}) ([]CalendarEntry, error) {
+	rows, err := r.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []CalendarEntry{}
+	for rows.Next() {
+		entry, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, rows.Err()
+}
+
+// ListWorkOrderCalendarEntries returns the organization's work orders
+// scheduled on or after since, or only those of technicianID when it is set.
+func (r *Repository) ListWorkOrderCalendarEntries(orgID uint, technicianID *uint, since time.Time) ([]CalendarEntry, error) {
+	query := workOrderEntryQuery + ` AND COALESCE(wo.scheduled_start, mt.scheduled_date) >= ?`
+	args := []interface{}{orgID, since.Format(calendar.DateLayout)}
+	if technicianID != nil {
+		query += ` AND wo.technician_id = ?`
+		args = append(args, *technicianID)
+	}
+	return r.queryCalendarEntries(scanWorkOrderEntry, query+` ORDER BY wo.id`, args...)
+}
+
+func (r *Repository) GetWorkOrderCalendarEntry(id, orgID uint) (*CalendarEntry, error) {
+	return scanWorkOrderEntry(r.QueryRow(workOrderEntryQuery+` AND wo.id = ?`, orgID, id))
+}
+
+// ListTaskCalendarEntries returns the organization's maintenance tasks
+// scheduled on or after since, or only those of plans assigned to role when it
+// is set.
+func (r *Repository) ListTaskCalendarEntries(orgID uint, role *string, since time.Time) ([]CalendarEntry, error) {
+	query := taskEntryQuery + ` AND mt.scheduled_date >= ?`
+	args := []interface{}{orgID, since.Format(calendar.DateLayout)}
+	if role != nil {
+		query += ` AND p.assigned_role = ?`
+		args = append(args, *role)
+	}
+	return r.queryCalendarEntries(scanTaskEntry, query+` ORDER BY mt.id`, args...)
+}
+
+// ListPlanCalendarEntries returns the entries that disappear from the feeds
+// when the plan is deleted: its tasks, and the unscheduled work orders that
+// only have a date through one of them.
+func (r *Repository) ListPlanCalendarEntries(planID, orgID uint, since time.Time) ([]CalendarEntry, error) {
+	tasks, err := r.queryCalendarEntries(scanTaskEntry, taskEntryQuery+` AND mt.maintenance_plan_id = ? AND mt.scheduled_date >= ?`,
+		orgID, planID, since.Format(calendar.DateLayout))
+	if err != nil {
+		return nil, err
+	}
+	workOrders, err := r.queryCalendarEntries(scanWorkOrderEntry, workOrderEntryQuery+` AND wo.scheduled_start IS NULL AND mt.maintenance_plan_id = ? AND mt.scheduled_date >= ?`,
+		orgID, planID, since.Format(calendar.DateLayout))
+	if err != nil {
+		return nil, err
+	}
+	return append(tasks, workOrders...), nil
+}
+
+func (r *Repository) CreateCalendarCancellation(cancellation *CalendarCancellation) error {
+	result, err := r.Exec(`INSERT INTO calendar_cancellations (organization_id, user_id, uid, summary, starts_at, ends_at, all_day, sequence, cancelled_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		cancellation.OrganizationID, cancellation.UserID, cancellation.UID, cancellation.Summary, cancellation.StartsAt, cancellation.EndsAt,
+		cancellation.AllDay, cancellation.Sequence, cancellation.CancelledAt)
+	if err != nil {
+		return err
+	}
+	id, _ := result.LastInsertId()
+	cancellation.ID = uint(id)
+	return nil
+}
+
+// ListCalendarCancellations returns the cancellations recorded since the given
+// time for a user's feed, or for the organization feeds when userID is nil.
+func (r *Repository) ListCalendarCancellations(orgID uint, userID *uint, since time.Time) ([]CalendarCancellation, error) {
+	query := `SELECT id, organization_id, user_id, uid, summary, starts_at, ends_at, all_day, sequence, cancelled_at
+		FROM calendar_cancellations WHERE organization_id = ? AND cancelled_at >= ?`
+	args := []interface{}{orgID, since}
+	if userID != nil {
+		query += ` AND user_id = ?`
+		args = append(args, *userID)
+	} else {
+		query += ` AND user_id IS NULL`
+	}
+	rows, err := r.Query(query+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cancellations := []CalendarCancellation{}
+	for rows.Next() {
+		var c CalendarCancellation
+		if err := rows.Scan(&c.ID, &c.OrganizationID, &c.UserID, &c.UID, &c.Summary, &c.StartsAt, &c.EndsAt, &c.AllDay, &c.Sequence, &c.CancelledAt); err != nil {
+			return nil, err
+		}
+		cancellations = append(cancellations, c)
+	}
+	return cancellations, rows.Err()
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"assetsentinel/internal/calendar"
+)
+
+func (r *Repository) GetBusinessCalendarSettings(orgID uint) (*BusinessCalendar, error) {
+	settings := &BusinessCalendar{OrganizationID: orgID, DailyHours: calendar.DefaultDailyHours}
+	if err := r.QueryRow(`SELECT timezone FROM organizations WHERE id = ?`, orgID).Scan(&settings.TimeZone); err != nil {
+		return nil, err
+	}
+
+	var workingDays string
+	err := r.QueryRow(`SELECT working_days, daily_hours, updated_at FROM business_calendars WHERE organization_id = ?`, orgID).
+		Scan(&workingDays, &settings.DailyHours, &settings.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		for _, day := range calendar.DefaultWorkingDays {
+			settings.WorkingDays = append(settings.WorkingDays, int(day))
+		}
+		return settings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(workingDays), &settings.WorkingDays); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func (r *Repository) SaveBusinessCalendar(orgID uint, workingDays []int, dailyHours float64) error {
+	days, _ := json.Marshal(workingDays)
+	_, err := r.Exec(`INSERT INTO business_calendars (organization_id, working_days, daily_hours, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(organization_id) DO UPDATE SET working_days = excluded.working_days, daily_hours = excluded.daily_hours, updated_at = excluded.updated_at`,
+		orgID, string(days), dailyHours, time.Now().UTC())
+	return err
+}
+
+func (r *Repository) ListHolidays(orgID uint) ([]Holiday, error) {
+	rows, err := r.Query(`SELECT id, organization_id, date, name, created_at FROM holidays WHERE organization_id = ? ORDER BY date`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holidays := []Holiday{}
+	for rows.Next() {
+		var holiday Holiday
+		if err := rows.Scan(&holiday.ID, &holiday.OrganizationID, &holiday.Date, &holiday.Name, &holiday.CreatedAt); err != nil {
+			return nil, err
+		}
+		holidays = append(holidays, holiday)
+	}
+	return holidays, nil
+}
+
+func (r *Repository) SaveHoliday(holiday *Holiday) error {
+	holiday.CreatedAt = time.Now().UTC()
+	_, err := r.Exec(`INSERT INTO holidays (organization_id, date, name, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(organization_id, date) DO UPDATE SET name = excluded.name`,
+		holiday.OrganizationID, holiday.Date, holiday.Name, holiday.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return r.QueryRow(`SELECT id, created_at FROM holidays WHERE organization_id = ? AND date = ?`, holiday.OrganizationID, holiday.Date).
+		Scan(&holiday.ID, &holiday.CreatedAt)
+}
+
+func (r *Repository) DeleteHoliday(id, orgID uint) error {
+	result, err := r.Exec(`DELETE FROM holidays WHERE id = ? AND organization_id = ?`, id, orgID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (r *Repository) GetBusinessCalendar(orgID uint) (*calendar.Calendar, error) {
+	settings, err := r.GetBusinessCalendarSettings(orgID)
+	if err != nil {
+		return nil, err
+	}
+	location, err := calendar.LoadLocation(settings.TimeZone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	holidays, err := r.ListHolidays(orgID)
+	if err != nil {
+		return nil, err
+	}
+	dates := make(map[string]string, len(holidays))
+	for _, holiday := range holidays {
+		dates[holiday.Date] = holiday.Name
+	}
+
+	workingDays := make([]time.Weekday, 0, len(settings.WorkingDays))
+	for _, day := range settings.WorkingDays {
+		workingDays = append(workingDays, time.Weekday(day))
+	}
+	cal := calendar.New(location, workingDays, dates)
+	cal.DailyHours = settings.DailyHours
+	return cal, nil
+}
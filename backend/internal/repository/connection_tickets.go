@@ -0,0 +1,25 @@
+package repository
+
+import "time"
+
+func (r *Repository) CreateConnectionTicket(ticket *ConnectionTicket, tokenHash string) error {
+	if _, err := r.Exec(`DELETE FROM connection_tickets WHERE expires_at <= ?`, time.Now().UTC()); err != nil {
+		return err
+	}
+	_, err := r.Exec(`INSERT INTO connection_tickets (token_hash, user_id, organization_id, role, expires_at) VALUES (?, ?, ?, ?, ?)`,
+		tokenHash, ticket.UserID, ticket.OrganizationID, ticket.Role, ticket.ExpiresAt)
+	return err
+}
+
+// RedeemConnectionTicket deletes the ticket and returns it, or sql.ErrNoRows
+// if it does not exist or expired before now.
+func (r *Repository) RedeemConnectionTicket(tokenHash string, now time.Time) (*ConnectionTicket, error) {
+	var ticket ConnectionTicket
+	err := r.QueryRow(`DELETE FROM connection_tickets WHERE token_hash = ? AND expires_at > ?
+		RETURNING user_id, organization_id, role, expires_at`, tokenHash, now).
+		Scan(&ticket.UserID, &ticket.OrganizationID, &ticket.Role, &ticket.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
@@ -1,8 +1,10 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -31,20 +33,11 @@ func RunMigrations(db *DB) error {
 		`CREATE TABLE IF NOT EXISTS organizations (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			name TEXT NOT NULL,
+			timezone TEXT NOT NULL DEFAULT 'UTC',
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)`,
-		`CREATE TABLE IF NOT EXISTS users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			organization_id INTEGER NOT NULL,
-			email TEXT UNIQUE NOT NULL,
-			password_hash TEXT NOT NULL,
-			full_name TEXT NOT NULL,
-			role TEXT NOT NULL CHECK(role IN ('admin', 'maintenance_manager', 'technician', 'viewer')),
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE
-		)`,
+		fmt.Sprintf(createUsersTable, "users"),
 		`CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)`,
 		`CREATE INDEX IF NOT EXISTS idx_users_org ON users(organization_id)`,
 
@@ -73,14 +66,25 @@ func RunMigrations(db *DB) error {
 			organization_id INTEGER NOT NULL,
 			asset_id INTEGER NOT NULL,
 			frequency_days INTEGER NOT NULL,
+			recurrence_rule TEXT,
+			recurrence_start DATE,
 			estimated_duration_hours REAL,
 			assigned_role TEXT CHECK(assigned_role IN ('technician', 'maintenance_manager')),
 			last_maintenance_date DATE,
 			next_maintenance_date DATE NOT NULL,
+			non_working_day_shift TEXT NOT NULL DEFAULT 'none' CHECK(non_working_day_shift IN ('none', 'next', 'previous')),
+			auto_work_order BOOLEAN NOT NULL DEFAULT 0,
+			work_order_title TEXT,
+			work_order_description TEXT,
+			work_order_priority TEXT NOT NULL DEFAULT 'medium' CHECK(work_order_priority IN ('low', 'medium', 'high', 'critical')),
+			assignment_strategy TEXT NOT NULL DEFAULT 'round_robin' CHECK(assignment_strategy IN ('round_robin', 'least_loaded')),
+			last_assignee_id INTEGER,
+			procedure_id INTEGER,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
-			FOREIGN KEY (asset_id) REFERENCES assets(id) ON DELETE CASCADE
+			FOREIGN KEY (asset_id) REFERENCES assets(id) ON DELETE CASCADE,
+			FOREIGN KEY (procedure_id) REFERENCES procedures(id) ON DELETE SET NULL
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_mp_org ON maintenance_plans(organization_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_mp_next_date ON maintenance_plans(next_maintenance_date)`,
@@ -94,6 +98,7 @@ func RunMigrations(db *DB) error {
 			status TEXT DEFAULT 'pending' CHECK(status IN ('pending', 'in_progress', 'completed', 'overdue')),
 			completed_date DATE,
 			notes TEXT,
+			calendar_sequence INTEGER NOT NULL DEFAULT 0,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
@@ -109,27 +114,85 @@ func RunMigrations(db *DB) error {
 			organization_id INTEGER NOT NULL,
 			asset_id INTEGER NOT NULL,
 			technician_id INTEGER,
+			maintenance_task_id INTEGER,
+			procedure_id INTEGER,
+			source_work_order_id INTEGER,
 			title TEXT NOT NULL,
 			description TEXT,
 			status TEXT DEFAULT 'pending' CHECK(status IN ('pending', 'in_progress', 'completed', 'closed')),
 			priority TEXT DEFAULT 'medium' CHECK(priority IN ('low', 'medium', 'high', 'critical')),
+			estimated_duration_hours REAL,
 			scheduled_start DATETIME,
 			scheduled_end DATETIME,
 			actual_start DATETIME,
 			actual_end DATETIME,
 			total_cost REAL DEFAULT 0,
 			notes TEXT,
+			calendar_sequence INTEGER NOT NULL DEFAULT 0,
 			created_by INTEGER,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
 			FOREIGN KEY (asset_id) REFERENCES assets(id) ON DELETE CASCADE,
 			FOREIGN KEY (technician_id) REFERENCES users(id) ON DELETE SET NULL,
+			FOREIGN KEY (maintenance_task_id) REFERENCES maintenance_tasks(id) ON DELETE SET NULL,
+			FOREIGN KEY (procedure_id) REFERENCES procedures(id) ON DELETE SET NULL,
+			FOREIGN KEY (source_work_order_id) REFERENCES work_orders(id) ON DELETE SET NULL,
 			FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_wo_org ON work_orders(organization_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_wo_status ON work_orders(status)`,
 		`CREATE INDEX IF NOT EXISTS idx_wo_asset ON work_orders(asset_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_wo_technician ON work_orders(technician_id)`,
+
+		`CREATE TABLE IF NOT EXISTS procedures (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			organization_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			description TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_procedures_org ON procedures(organization_id)`,
+
+		`CREATE TABLE IF NOT EXISTS procedure_steps (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			procedure_id INTEGER NOT NULL,
+			position INTEGER NOT NULL,
+			type TEXT NOT NULL CHECK(type IN ('checkbox', 'numeric', 'pass_fail', 'text', 'photo')),
+			instruction TEXT NOT NULL,
+			optional BOOLEAN NOT NULL DEFAULT 0,
+			min_value REAL,
+			max_value REAL,
+			unit TEXT,
+			FOREIGN KEY (procedure_id) REFERENCES procedures(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_procedure_steps_procedure ON procedure_steps(procedure_id, position)`,
+
+		`CREATE TABLE IF NOT EXISTS work_order_step_results (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			work_order_id INTEGER NOT NULL,
+			step_id INTEGER,
+			position INTEGER NOT NULL,
+			type TEXT NOT NULL,
+			instruction TEXT NOT NULL,
+			min_value REAL,
+			max_value REAL,
+			unit TEXT,
+			checked BOOLEAN,
+			reading REAL,
+			passed BOOLEAN,
+			text TEXT,
+			photo_url TEXT,
+			out_of_limit BOOLEAN NOT NULL DEFAULT 0,
+			recorded_by INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (work_order_id) REFERENCES work_orders(id) ON DELETE CASCADE,
+			FOREIGN KEY (step_id) REFERENCES procedure_steps(id) ON DELETE SET NULL,
+			FOREIGN KEY (recorded_by) REFERENCES users(id) ON DELETE SET NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_step_results_wo ON work_order_step_results(work_order_id)`,
 
 		`CREATE TABLE IF NOT EXISTS work_order_parts (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -188,6 +251,266 @@ func RunMigrations(db *DB) error {
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_audit_org ON audit_logs(organization_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_audit_table ON audit_logs(table_name)`,
+
+		`CREATE TABLE IF NOT EXISTS roles (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			organization_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			description TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+			UNIQUE(organization_id, name)
+		)`,
+		`CREATE TABLE IF NOT EXISTS role_permissions (
+			role_id INTEGER NOT NULL,
+			permission TEXT NOT NULL,
+			PRIMARY KEY (role_id, permission),
+			FOREIGN KEY (role_id) REFERENCES roles(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS login_throttles (
+			key TEXT PRIMARY KEY,
+			failures INTEGER NOT NULL DEFAULT 0,
+			last_failure_at DATETIME,
+			locked_until DATETIME
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS security_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			organization_id INTEGER,
+			user_id INTEGER,
+			email TEXT,
+			event_type TEXT NOT NULL,
+			ip_address TEXT,
+			details TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_security_org ON security_events(organization_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_security_user_ip ON security_events(user_id, ip_address)`,
+
+		`CREATE TABLE IF NOT EXISTS password_reset_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			token_hash TEXT UNIQUE NOT NULL,
+			expires_at DATETIME NOT NULL,
+			used_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS password_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			password_hash TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_password_history_user ON password_history(user_id)`,
+
+		`CREATE TABLE IF NOT EXISTS notifications (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			organization_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			type TEXT NOT NULL,
+			title TEXT NOT NULL,
+			message TEXT,
+			data TEXT,
+			read_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (organization_id) REFERENCES organizations(id),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id, read_at)`,
+		`CREATE TABLE IF NOT EXISTS notification_settings (
+			organization_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL DEFAULT 0,
+			quiet_hours_start TEXT,
+			quiet_hours_end TEXT,
+			timezone TEXT,
+			chat_webhook_url TEXT,
+			chat_format TEXT,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (organization_id, user_id),
+			FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS notification_preferences (
+			organization_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL DEFAULT 0,
+			notification_type TEXT NOT NULL,
+			channels TEXT NOT NULL,
+			digest BOOLEAN NOT NULL DEFAULT 0,
+			PRIMARY KEY (organization_id, user_id, notification_type),
+			FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS notification_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			organization_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			channel TEXT NOT NULL,
+			notification_type TEXT NOT NULL,
+			title TEXT NOT NULL,
+			message TEXT,
+			digest BOOLEAN NOT NULL DEFAULT 0,
+			status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'sent', 'failed')),
+			attempts INTEGER DEFAULT 0,
+			deliver_after DATETIME NOT NULL,
+			sent_at DATETIME,
+			error TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_notification_deliveries_due ON notification_deliveries(status, deliver_after)`,
+
+		`CREATE TABLE IF NOT EXISTS events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			organization_id INTEGER NOT NULL,
+			seq INTEGER NOT NULL,
+			user_id INTEGER,
+			roles TEXT,
+			topics TEXT,
+			payload TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(organization_id, seq)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS webhooks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			organization_id INTEGER NOT NULL,
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			event_types TEXT,
+			description TEXT,
+			active BOOLEAN DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (organization_id) REFERENCES organizations(id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhooks_org ON webhooks(organization_id)`,
+
+		`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			webhook_id INTEGER NOT NULL,
+			organization_id INTEGER NOT NULL,
+			event_type TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'succeeded', 'failed')),
+			attempts INTEGER DEFAULT 0,
+			next_attempt_at DATETIME,
+			last_attempt_at DATETIME,
+			response_code INTEGER,
+			response_body TEXT,
+			error TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id)`,
+		`CREATE TABLE IF NOT EXISTS outbox_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			organization_id INTEGER NOT NULL,
+			event_id TEXT NOT NULL UNIQUE,
+			event_type TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'delivered', 'failed')),
+			attempts INTEGER DEFAULT 0,
+			next_attempt_at DATETIME,
+			last_error TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			delivered_at DATETIME
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_outbox_events_due ON outbox_events(status, next_attempt_at)`,
+		`CREATE TABLE IF NOT EXISTS outbox_deliveries (
+			outbox_event_id INTEGER NOT NULL,
+			consumer TEXT NOT NULL,
+			delivered_at DATETIME NOT NULL,
+			PRIMARY KEY (outbox_event_id, consumer),
+			FOREIGN KEY (outbox_event_id) REFERENCES outbox_events(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_maintenance_tasks_plan ON maintenance_tasks(maintenance_plan_id, scheduled_date)`,
+		`CREATE TABLE IF NOT EXISTS jobs (
+			name TEXT PRIMARY KEY,
+			schedule TEXT NOT NULL,
+			enabled BOOLEAN DEFAULT 1,
+			next_run_at DATETIME NOT NULL,
+			run_requested BOOLEAN DEFAULT 0,
+			attempts INTEGER DEFAULT 0,
+			lease_owner TEXT,
+			lease_until DATETIME,
+			last_run_at DATETIME,
+			last_status TEXT,
+			last_error TEXT,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS job_runs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			job_name TEXT NOT NULL,
+			trigger TEXT NOT NULL CHECK(trigger IN ('schedule', 'retry', 'manual', 'recovery')),
+			status TEXT NOT NULL DEFAULT 'running' CHECK(status IN ('running', 'succeeded', 'failed')),
+			attempt INTEGER NOT NULL DEFAULT 1,
+			owner TEXT NOT NULL,
+			started_at DATETIME NOT NULL,
+			finished_at DATETIME,
+			duration_ms INTEGER,
+			error TEXT,
+			FOREIGN KEY (job_name) REFERENCES jobs(name) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_job_runs_job ON job_runs(job_name, id)`,
+		`CREATE TABLE IF NOT EXISTS business_calendars (
+			organization_id INTEGER PRIMARY KEY,
+			working_days TEXT NOT NULL,
+			daily_hours REAL NOT NULL DEFAULT 8,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS holidays (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			organization_id INTEGER NOT NULL,
+			date TEXT NOT NULL,
+			name TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(organization_id, date),
+			FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS calendar_feeds (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			organization_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			scope TEXT NOT NULL CHECK(scope IN ('user', 'organization')),
+			name TEXT,
+			token_hash TEXT UNIQUE NOT NULL,
+			last_used_at DATETIME,
+			revoked_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_calendar_feeds_user ON calendar_feeds(user_id)`,
+		`CREATE TABLE IF NOT EXISTS calendar_cancellations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			organization_id INTEGER NOT NULL,
+			user_id INTEGER,
+			uid TEXT NOT NULL,
+			summary TEXT NOT NULL,
+			starts_at DATETIME NOT NULL,
+			ends_at DATETIME NOT NULL,
+			all_day BOOLEAN NOT NULL DEFAULT 0,
+			sequence INTEGER NOT NULL DEFAULT 0,
+			cancelled_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_calendar_cancellations_org ON calendar_cancellations(organization_id, cancelled_at)`,
+		`CREATE TABLE IF NOT EXISTS connection_tickets (
+			token_hash TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			organization_id INTEGER NOT NULL,
+			role TEXT NOT NULL,
+			expires_at DATETIME NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
 	}
 
 	for _, migration := range migrations {
@@ -196,5 +519,125 @@ func RunMigrations(db *DB) error {
 		}
 	}
 
+	if err := dropUserRoleCheck(db); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+
+	columns := []struct{ table, column, definition string }{
+		{"organizations", "timezone", `TEXT NOT NULL DEFAULT 'UTC'`},
+		{"maintenance_plans", "recurrence_rule", `TEXT`},
+		{"maintenance_plans", "recurrence_start", `DATE`},
+		{"maintenance_plans", "non_working_day_shift", `TEXT NOT NULL DEFAULT 'none' CHECK(non_working_day_shift IN ('none', 'next', 'previous'))`},
+		{"maintenance_plans", "auto_work_order", `BOOLEAN NOT NULL DEFAULT 0`},
+		{"maintenance_plans", "work_order_title", `TEXT`},
+		{"maintenance_plans", "work_order_description", `TEXT`},
+		{"maintenance_plans", "work_order_priority", `TEXT NOT NULL DEFAULT 'medium' CHECK(work_order_priority IN ('low', 'medium', 'high', 'critical'))`},
+		{"maintenance_plans", "assignment_strategy", `TEXT NOT NULL DEFAULT 'round_robin' CHECK(assignment_strategy IN ('round_robin', 'least_loaded'))`},
+		{"maintenance_plans", "last_assignee_id", `INTEGER`},
+		{"work_orders", "maintenance_task_id", `INTEGER REFERENCES maintenance_tasks(id) ON DELETE SET NULL`},
+		{"work_orders", "estimated_duration_hours", `REAL`},
+		{"business_calendars", "daily_hours", `REAL NOT NULL DEFAULT 8`},
+		{"maintenance_plans", "procedure_id", `INTEGER REFERENCES procedures(id) ON DELETE SET NULL`},
+		{"work_orders", "procedure_id", `INTEGER REFERENCES procedures(id) ON DELETE SET NULL`},
+		{"work_orders", "source_work_order_id", `INTEGER REFERENCES work_orders(id) ON DELETE SET NULL`},
+		{"maintenance_tasks", "calendar_sequence", `INTEGER NOT NULL DEFAULT 0`},
+		{"work_orders", "calendar_sequence", `INTEGER NOT NULL DEFAULT 0`},
+	}
+	for _, c := range columns {
+		if err := addColumn(db, c.table, c.column, c.definition); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
+	// Scheduled times used to keep the offset they were submitted with;
+	// convert them to UTC as they are now written.
+	for _, column := range []string{"scheduled_start", "scheduled_end"} {
+		if _, err := db.Exec(fmt.Sprintf(`UPDATE work_orders SET %[1]s = datetime(%[1]s) || '+00:00'
+			WHERE %[1]s NOT LIKE '%%+00:00' AND datetime(%[1]s) IS NOT NULL`, column)); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
 	return nil
 }
+
+const createUsersTable = `CREATE TABLE IF NOT EXISTS %s (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			organization_id INTEGER NOT NULL,
+			email TEXT UNIQUE NOT NULL,
+			password_hash TEXT NOT NULL,
+			full_name TEXT NOT NULL,
+			role TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE
+		)`
+
+func addColumn(db *DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	rows.Close()
+
+	_, err = db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition))
+	return err
+}
+
+func dropUserRoleCheck(db *DB) error {
+	var schema string
+	if err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'users'`).Scan(&schema); err != nil {
+		return err
+	}
+	if !strings.Contains(schema, "CHECK(role IN") {
+		return nil
+	}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		fmt.Sprintf(createUsersTable, "users_new"),
+		`INSERT INTO users_new (id, organization_id, email, password_hash, full_name, role, created_at, updated_at)
+			SELECT id, organization_id, email, password_hash, full_name, role, created_at, updated_at FROM users`,
+		`DROP TABLE users`,
+		`ALTER TABLE users_new RENAME TO users`,
+		`CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)`,
+		`CREATE INDEX IF NOT EXISTS idx_users_org ON users(organization_id)`,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"strings"
+	"time"
+)
+
+// Scheduled times are stored in UTC, so they compare as text in the same
+// order as in time.
+var scheduledWorkOrderColumns = "wo." + strings.ReplaceAll(workOrderColumns, ", ", ", wo.")
+
+// ListScheduledWorkOrders returns the open, assigned work orders of the
+// organization scheduled between from and to, or only those of technicianID
+// when it is set. Work orders without a scheduled end are included when they
+// start before to; callers work out how long they take.
+func (r *Repository) ListScheduledWorkOrders(orgID uint, technicianID *uint, from, to time.Time) ([]ScheduledWorkOrder, error) {
+	query := `SELECT ` + scheduledWorkOrderColumns + `, a.name, a.location
+		FROM work_orders wo
+		JOIN assets a ON a.id = wo.asset_id
+		WHERE wo.organization_id = ? AND wo.technician_id IS NOT NULL AND wo.scheduled_start IS NOT NULL
+		AND wo.status IN ('pending', 'in_progress') AND wo.scheduled_start < ?
+		AND (wo.scheduled_end IS NULL OR wo.scheduled_end > ?)`
+	args := []interface{}{orgID, to.UTC(), from.UTC()}
+	if technicianID != nil {
+		query += ` AND wo.technician_id = ?`
+		args = append(args, *technicianID)
+	}
+
+	rows, err := r.Query(query+` ORDER BY wo.scheduled_start, wo.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []ScheduledWorkOrder{}
+	for rows.Next() {
+		var order ScheduledWorkOrder
+		wo := &order.WorkOrder
+		if err := rows.Scan(&wo.ID, &wo.OrganizationID, &wo.AssetID, &wo.TechnicianID, &wo.MaintenanceTaskID, &wo.ProcedureID, &wo.SourceWorkOrderID, &wo.Title, &wo.Description, &wo.Status, &wo.Priority, &wo.EstimatedDurationHours,
+			&wo.ScheduledStart, &wo.ScheduledEnd, &wo.ActualStart, &wo.ActualEnd, &wo.TotalCost, &wo.Notes, &wo.CreatedBy, &wo.CreatedAt, &wo.UpdatedAt,
+			&order.AssetName, &order.AssetLocation); err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, rows.Err()
+}
+
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	converted := t.UTC()
+	return &converted
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduledTimesAreStoredInUTC(t *testing.T) {
+	repo := newTestRepository(t)
+	org := Organization{Name: "Acme"}
+	if err := repo.CreateOrganization(&org); err != nil {
+		t.Fatal(err)
+	}
+	user := User{OrganizationID: org.ID, Email: "tech@acme.test", PasswordHash: "x", FullName: "Tech", Role: "technician"}
+	if err := repo.CreateUser(&user); err != nil {
+		t.Fatal(err)
+	}
+	asset := Asset{OrganizationID: org.ID, Name: "Pump", Category: "pump", Status: "active"}
+	if err := repo.CreateAsset(&asset); err != nil {
+		t.Fatal(err)
+	}
+
+	// 09:00 to 11:00 in UTC+2 is 07:00 to 09:00 UTC, which compares as text
+	// after 08:30 UTC only once both are in UTC.
+	plusTwo := time.FixedZone("", 2*60*60)
+	start, end := time.Date(2026, 3, 30, 9, 0, 0, 0, plusTwo), time.Date(2026, 3, 30, 11, 0, 0, 0, plusTwo)
+	wo := WorkOrder{OrganizationID: org.ID, AssetID: asset.ID, TechnicianID: &user.ID, Title: "Inspection", Status: "pending", Priority: "medium",
+		ScheduledStart: &start, ScheduledEnd: &end}
+	if err := repo.CreateWorkOrder(&wo); err != nil {
+		t.Fatal(err)
+	}
+	var stored string
+	if err := repo.QueryRow(`SELECT scheduled_start || '' FROM work_orders WHERE id = ?`, wo.ID).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored != "2026-03-30 07:00:00+00:00" {
+		t.Errorf("scheduled_start stored as %q, want UTC", stored)
+	}
+
+	at := func(hour, minute int) time.Time { return time.Date(2026, 3, 30, hour, minute, 0, 0, time.UTC) }
+	for _, tt := range []struct {
+		from, to time.Time
+		found    bool
+	}{
+		{at(8, 30), at(10, 0), true},
+		{at(6, 0), at(7, 30), true},
+		{at(9, 0), at(10, 0), false},
+		{at(5, 0), at(7, 0), false},
+	} {
+		orders, err := repo.ListScheduledWorkOrders(org.ID, nil, tt.from, tt.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found := len(orders) == 1; found != tt.found {
+			t.Errorf("%s to %s: found %v, want %v", tt.from.Format("15:04"), tt.to.Format("15:04"), found, tt.found)
+		}
+	}
+
+	// Rows written before times were converted are normalized by the
+	// migrations.
+	if _, err := repo.Exec(`UPDATE work_orders SET scheduled_start = '2026-03-30 09:00:00+02:00', scheduled_end = '2026-03-30T11:00:00Z' WHERE id = ?`, wo.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := RunMigrations(repo.DB); err != nil {
+		t.Fatal(err)
+	}
+	var storedEnd string
+	if err := repo.QueryRow(`SELECT scheduled_start || '', scheduled_end || '' FROM work_orders WHERE id = ?`, wo.ID).Scan(&stored, &storedEnd); err != nil {
+		t.Fatal(err)
+	}
+	if stored != "2026-03-30 07:00:00+00:00" || storedEnd != "2026-03-30 11:00:00+00:00" {
+		t.Errorf("migrated schedule = %q to %q", stored, storedEnd)
+	}
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"encoding/json"
+	"time"
+)
+
+func (r *Repository) LatestEventSeq(orgID uint) (uint64, error) {
+	var seq uint64
+	err := r.QueryRow(`SELECT COALESCE(MAX(seq), 0) FROM events WHERE organization_id = ?`, orgID).Scan(&seq)
+	return seq, err
+}
+
+func (r *Repository) OldestEventSeq(orgID uint) (uint64, error) {
+	var seq uint64
+	err := r.QueryRow(`SELECT COALESCE(MIN(seq), 0) FROM events WHERE organization_id = ?`, orgID).Scan(&seq)
+	return seq, err
+}
+
+// AppendEvent stores event with the next sequence of its organization. The
+// sequence is computed inside the INSERT, which SQLite runs under the database
+// write lock, so hubs sharing the database never assign the same sequence;
+// UNIQUE(organization_id, seq) rejects a duplicate if that ever changes.
+func (r *Repository) AppendEvent(event *Event, retain int) error {
+	roles, err := json.Marshal(event.Roles)
+	if err != nil {
+		return err
+	}
+	topics, err := json.Marshal(event.Topics)
+	if err != nil {
+		return err
+	}
+
+	event.CreatedAt = time.Now().UTC()
+	err = r.QueryRow(`INSERT INTO events (organization_id, seq, user_id, roles, topics, payload, created_at)
+		VALUES (?, (SELECT COALESCE(MAX(seq), 0) + 1 FROM events WHERE organization_id = ?), ?, ?, ?, ?, ?)
+		RETURNING id, seq`,
+		event.OrganizationID, event.OrganizationID, event.UserID, string(roles), string(topics), string(event.Payload), event.CreatedAt).
+		Scan(&event.ID, &event.Seq)
+	if err != nil {
+		return err
+	}
+
+	if retain > 0 && event.Seq > uint64(retain) {
+		_, err = r.Exec(`DELETE FROM events WHERE organization_id = ? AND seq <= ?`, event.OrganizationID, event.Seq-uint64(retain))
+	}
+	return err
+}
+
+func (r *Repository) ListEventsSince(orgID uint, seq uint64, limit int) ([]Event, error) {
+	rows, err := r.Query(`SELECT id, organization_id, seq, user_id, roles, topics, payload, created_at FROM events
+		WHERE organization_id = ? AND seq > ? ORDER BY seq LIMIT ?`, orgID, seq, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var event Event
+		var roles, topics, payload string
+		if err := rows.Scan(&event.ID, &event.OrganizationID, &event.Seq, &event.UserID, &roles, &topics, &payload, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(roles), &event.Roles)
+		json.Unmarshal([]byte(topics), &event.Topics)
+		event.Payload = json.RawMessage(payload)
+		events = append(events, event)
+	}
+	return events, nil
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func openTestDB(t *testing.T, path string) *Repository {
+	t.Helper()
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := RunMigrations(db); err != nil {
+		t.Fatal(err)
+	}
+	return NewRepository(db)
+}
+
+func newTestRepository(t *testing.T) *Repository {
+	t.Helper()
+	return openTestDB(t, filepath.Join(t.TempDir(), "test.db"))
+}
+
+// TestAppendEventSequencesAcrossConnections appends from several database
+// handles at once, as hubs on separate replicas do, and checks that each
+// organization gets gapless, unique sequences.
+func TestAppendEventSequencesAcrossConnections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	repos := []*Repository{openTestDB(t, path), openTestDB(t, path), openTestDB(t, path)}
+
+	const perRepo = 20
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seqs = map[uint]map[uint64]bool{1: {}, 2: {}}
+	)
+	for _, repo := range repos {
+		for _, orgID := range []uint{1, 2} {
+			wg.Add(1)
+			go func(repo *Repository, orgID uint) {
+				defer wg.Done()
+				for i := 0; i < perRepo; i++ {
+					event := &Event{OrganizationID: orgID, Payload: json.RawMessage(`{}`)}
+					if err := repo.AppendEvent(event, 0); err != nil {
+						t.Error(err)
+						return
+					}
+					mu.Lock()
+					if seqs[orgID][event.Seq] {
+						t.Errorf("org %d sequence %d assigned twice", orgID, event.Seq)
+					}
+					seqs[orgID][event.Seq] = true
+					mu.Unlock()
+				}
+			}(repo, orgID)
+		}
+	}
+	wg.Wait()
+
+	for orgID, seen := range seqs {
+		for seq := uint64(1); seq <= uint64(len(repos)*perRepo); seq++ {
+			if !seen[seq] {
+				t.Errorf("org %d is missing sequence %d", orgID, seq)
+			}
+		}
+	}
+}
+
+func TestAppendEventRetention(t *testing.T) {
+	repo := newTestRepository(t)
+	for i := 0; i < 5; i++ {
+		if err := repo.AppendEvent(&Event{OrganizationID: 1, Payload: json.RawMessage(`{}`)}, 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldest, _ := repo.OldestEventSeq(1)
+	latest, _ := repo.LatestEventSeq(1)
+	if oldest != 3 || latest != 5 {
+		t.Errorf("kept sequences %d to %d, want 3 to 5", oldest, latest)
+	}
+	events, err := repo.ListEventsSince(1, 3, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Seq != 4 || events[1].Seq != 5 {
+		t.Errorf("events after 3 = %+v", events)
+	}
+}
@@ -0,0 +1,160 @@
+package repository
+
+import "time"
+
+const jobColumns = `name, schedule, enabled, next_run_at, run_requested, attempts, lease_owner, lease_until, last_run_at, last_status, last_error, updated_at`
+
+const jobRunColumns = `id, job_name, trigger, status, attempt, owner, started_at, finished_at, duration_ms, error`
+
+func scanJob(row rowScanner) (*Job, error) {
+	var job Job
+	if err := row.Scan(&job.Name, &job.Schedule, &job.Enabled, &job.NextRunAt, &job.RunRequested, &job.Attempts, &job.LeaseOwner,
+		&job.LeaseUntil, &job.LastRunAt, &job.LastStatus, &job.LastError, &job.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *Repository) RegisterJob(name, schedule string, nextRunAt time.Time) error {
+	_, err := r.Exec(`INSERT INTO jobs (name, schedule, next_run_at, updated_at) VALUES (?, ?, ?, ?) ON CONFLICT(name) DO NOTHING`,
+		name, schedule, nextRunAt.UTC().Truncate(time.Second), time.Now().UTC())
+	return err
+}
+
+func (r *Repository) ListJobs() ([]Job, error) {
+	rows, err := r.Query(`SELECT ` + jobColumns + ` FROM jobs ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, nil
+}
+
+func (r *Repository) GetJob(name string) (*Job, error) {
+	return scanJob(r.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE name = ?`, name))
+}
+
+func (r *Repository) UpdateJobSchedule(job *Job) error {
+	job.NextRunAt = job.NextRunAt.UTC().Truncate(time.Second)
+	job.UpdatedAt = time.Now().UTC()
+	result, err := r.Exec(`UPDATE jobs SET schedule = ?, enabled = ?, next_run_at = CASE WHEN run_requested = 1 THEN next_run_at ELSE ? END, updated_at = ?
+		WHERE name = ?`, job.Schedule, job.Enabled, job.NextRunAt, job.UpdatedAt, job.Name)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (r *Repository) RequestJobRun(name string, now time.Time) error {
+	result, err := r.Exec(`UPDATE jobs SET run_requested = 1, next_run_at = ?, updated_at = ? WHERE name = ?`,
+		now.UTC().Truncate(time.Second), time.Now().UTC(), name)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// ClaimJob leases a due job to owner and returns it as it was when claimed,
+// or sql.ErrNoRows if it is not due or another owner holds the lease.
+func (r *Repository) ClaimJob(name, owner string, now, leaseUntil time.Time) (*Job, error) {
+	var job *Job
+	err := r.WithTx(func(tx *Repository) error {
+		var err error
+		job, err = scanJob(tx.QueryRow(`UPDATE jobs SET lease_owner = ?, lease_until = ?
+			WHERE name = ? AND (enabled = 1 OR run_requested = 1) AND next_run_at <= ? AND (lease_until IS NULL OR lease_until <= ?)
+			RETURNING `+jobColumns, owner, leaseUntil, name, now, now))
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE jobs SET run_requested = 0 WHERE name = ?`, name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (r *Repository) RenewJobLease(name, owner string, leaseUntil time.Time) (bool, error) {
+	result, err := r.Exec(`UPDATE jobs SET lease_until = ? WHERE name = ? AND lease_owner = ?`, leaseUntil, name, owner)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+func (r *Repository) ReleaseJob(job *Job, owner string) error {
+	job.NextRunAt = job.NextRunAt.UTC().Truncate(time.Second)
+	job.UpdatedAt = time.Now().UTC()
+	_, err := r.Exec(`UPDATE jobs SET next_run_at = CASE WHEN run_requested = 1 THEN next_run_at ELSE ? END, attempts = ?,
+		lease_owner = NULL, lease_until = NULL, last_run_at = ?, last_status = ?, last_error = ?, updated_at = ?
+		WHERE name = ? AND lease_owner = ?`,
+		job.NextRunAt, job.Attempts, job.LastRunAt, job.LastStatus, job.LastError, job.UpdatedAt, job.Name, owner)
+	return err
+}
+
+func (r *Repository) AbandonJobRuns(jobName string, finishedAt time.Time) (int, error) {
+	result, err := r.Exec(`UPDATE job_runs SET status = 'failed', finished_at = ?, error = 'lease expired before the run finished'
+		WHERE job_name = ? AND status = 'running'`, finishedAt, jobName)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
+
+func (r *Repository) CreateJobRun(run *JobRun) error {
+	run.Status = "running"
+	run.StartedAt = time.Now().UTC()
+	result, err := r.Exec(`INSERT INTO job_runs (job_name, trigger, status, attempt, owner, started_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		run.JobName, run.Trigger, run.Status, run.Attempt, run.Owner, run.StartedAt)
+	if err != nil {
+		return err
+	}
+	id, _ := result.LastInsertId()
+	run.ID = uint(id)
+	return nil
+}
+
+func (r *Repository) FinishJobRun(run *JobRun) error {
+	_, err := r.Exec(`UPDATE job_runs SET status = ?, finished_at = ?, duration_ms = ?, error = ? WHERE id = ?`,
+		run.Status, run.FinishedAt, run.DurationMS, run.Error, run.ID)
+	return err
+}
+
+func (r *Repository) ListJobRuns(jobName string, page, pageSize int) ([]JobRun, int, error) {
+	offset := (page - 1) * pageSize
+
+	var count int
+	if err := r.QueryRow(`SELECT COUNT(*) FROM job_runs WHERE job_name = ?`, jobName).Scan(&count); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.Query(`SELECT `+jobRunColumns+` FROM job_runs WHERE job_name = ? ORDER BY id DESC LIMIT ? OFFSET ?`,
+		jobName, pageSize, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	runs := []JobRun{}
+	for rows.Next() {
+		var run JobRun
+		if err := rows.Scan(&run.ID, &run.JobName, &run.Trigger, &run.Status, &run.Attempt, &run.Owner, &run.StartedAt,
+			&run.FinishedAt, &run.DurationMS, &run.Error); err != nil {
+			return nil, 0, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, count, nil
+}
@@ -1,12 +1,15 @@
 package repository
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
 )
 
 type Organization struct {
 	ID        uint      `json:"id"`
 	Name      string    `json:"name"`
+	TimeZone  string    `json:"timezone"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -22,6 +25,16 @@ type User struct {
 	UpdatedAt      time.Time `json:"updated_at"`
 }
 
+type Role struct {
+	ID             uint      `json:"id"`
+	OrganizationID uint      `json:"organization_id"`
+	Name           string    `json:"name"`
+	Description    *string   `json:"description"`
+	Permissions    []string  `json:"permissions"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
 type Asset struct {
 	ID               uint       `json:"id"`
 	OrganizationID   uint       `json:"organization_id"`
@@ -43,10 +56,20 @@ type MaintenancePlan struct {
 	OrganizationID         uint       `json:"organization_id"`
 	AssetID                uint       `json:"asset_id"`
 	FrequencyDays          int        `json:"frequency_days"`
+	RecurrenceRule         *string    `json:"recurrence_rule"`
+	RecurrenceStart        *time.Time `json:"recurrence_start"`
 	EstimatedDurationHours *float64   `json:"estimated_duration_hours"`
 	AssignedRole           *string    `json:"assigned_role"`
 	LastMaintenanceDate    *time.Time `json:"last_maintenance_date"`
 	NextMaintenanceDate    time.Time  `json:"next_maintenance_date"`
+	NonWorkingDayShift     string     `json:"non_working_day_shift"`
+	AutoWorkOrder          bool       `json:"auto_work_order"`
+	WorkOrderTitle         *string    `json:"work_order_title"`
+	WorkOrderDescription   *string    `json:"work_order_description"`
+	WorkOrderPriority      string     `json:"work_order_priority"`
+	AssignmentStrategy     string     `json:"assignment_strategy"`
+	LastAssigneeID         *uint      `json:"last_assignee_id"`
+	ProcedureID            *uint      `json:"procedure_id"`
 	CreatedAt              time.Time  `json:"created_at"`
 	UpdatedAt              time.Time  `json:"updated_at"`
 }
@@ -65,23 +88,69 @@ type MaintenanceTask struct {
 }
 
 type WorkOrder struct {
-	ID             uint       `json:"id"`
-	OrganizationID uint       `json:"organization_id"`
-	AssetID        uint       `json:"asset_id"`
-	TechnicianID   *uint      `json:"technician_id"`
-	Title          string     `json:"title"`
-	Description    *string    `json:"description"`
-	Status         string     `json:"status"`
-	Priority       string     `json:"priority"`
-	ScheduledStart *time.Time `json:"scheduled_start"`
-	ScheduledEnd   *time.Time `json:"scheduled_end"`
-	ActualStart    *time.Time `json:"actual_start"`
-	ActualEnd      *time.Time `json:"actual_end"`
-	TotalCost      float64    `json:"total_cost"`
-	Notes          *string    `json:"notes"`
-	CreatedBy      *uint      `json:"created_by"`
-	CreatedAt      time.Time  `json:"created_at"`
-	UpdatedAt      time.Time  `json:"updated_at"`
+	ID                     uint       `json:"id"`
+	OrganizationID         uint       `json:"organization_id"`
+	AssetID                uint       `json:"asset_id"`
+	TechnicianID           *uint      `json:"technician_id"`
+	MaintenanceTaskID      *uint      `json:"maintenance_task_id"`
+	ProcedureID            *uint      `json:"procedure_id"`
+	SourceWorkOrderID      *uint      `json:"source_work_order_id"`
+	Title                  string     `json:"title"`
+	Description            *string    `json:"description"`
+	Status                 string     `json:"status"`
+	Priority               string     `json:"priority"`
+	EstimatedDurationHours *float64   `json:"estimated_duration_hours"`
+	ScheduledStart         *time.Time `json:"scheduled_start"`
+	ScheduledEnd           *time.Time `json:"scheduled_end"`
+	ActualStart            *time.Time `json:"actual_start"`
+	ActualEnd              *time.Time `json:"actual_end"`
+	TotalCost              float64    `json:"total_cost"`
+	Notes                  *string    `json:"notes"`
+	CreatedBy              *uint      `json:"created_by"`
+	CreatedAt              time.Time  `json:"created_at"`
+	UpdatedAt              time.Time  `json:"updated_at"`
+}
+
+type Procedure struct {
+	ID             uint            `json:"id"`
+	OrganizationID uint            `json:"organization_id"`
+	Name           string          `json:"name" binding:"required"`
+	Description    *string         `json:"description"`
+	Steps          []ProcedureStep `json:"steps"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+type ProcedureStep struct {
+	ID          uint     `json:"id"`
+	ProcedureID uint     `json:"procedure_id"`
+	Position    int      `json:"position"`
+	Type        string   `json:"type"`
+	Instruction string   `json:"instruction"`
+	Optional    bool     `json:"optional"`
+	MinValue    *float64 `json:"min_value"`
+	MaxValue    *float64 `json:"max_value"`
+	Unit        *string  `json:"unit"`
+}
+
+type StepResult struct {
+	ID          uint      `json:"id"`
+	WorkOrderID uint      `json:"work_order_id"`
+	StepID      *uint     `json:"step_id"`
+	Position    int       `json:"position"`
+	Type        string    `json:"type"`
+	Instruction string    `json:"instruction"`
+	MinValue    *float64  `json:"min_value"`
+	MaxValue    *float64  `json:"max_value"`
+	Unit        *string   `json:"unit"`
+	Checked     *bool     `json:"checked"`
+	Reading     *float64  `json:"reading"`
+	Passed      *bool     `json:"passed"`
+	Text        *string   `json:"text"`
+	PhotoURL    *string   `json:"photo_url"`
+	OutOfLimit  bool      `json:"out_of_limit"`
+	RecordedBy  *uint     `json:"recorded_by"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
 type WorkOrderPart struct {
@@ -129,8 +198,246 @@ type AuditLog struct {
 	CreatedAt      time.Time `json:"created_at"`
 }
 
+type LoginThrottle struct {
+	Key           string     `json:"key"`
+	Failures      int        `json:"failures"`
+	LastFailureAt *time.Time `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+}
+
+type SecurityEvent struct {
+	ID             uint      `json:"id"`
+	OrganizationID *uint     `json:"organization_id"`
+	UserID         *uint     `json:"user_id"`
+	Email          *string   `json:"email"`
+	EventType      string    `json:"event_type"`
+	IPAddress      *string   `json:"ip_address"`
+	Details        *string   `json:"details"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type PasswordResetToken struct {
+	ID        uint       `json:"id"`
+	UserID    uint       `json:"user_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type Notification struct {
+	ID             uint            `json:"id"`
+	OrganizationID uint            `json:"organization_id"`
+	UserID         uint            `json:"user_id"`
+	Type           string          `json:"type"`
+	Title          string          `json:"title"`
+	Message        *string         `json:"message"`
+	Data           json.RawMessage `json:"data,omitempty"`
+	ReadAt         *time.Time      `json:"read_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type Event struct {
+	ID             uint            `json:"id"`
+	OrganizationID uint            `json:"organization_id"`
+	Seq            uint64          `json:"seq"`
+	UserID         *uint           `json:"user_id"`
+	Roles          []string        `json:"roles"`
+	Topics         []string        `json:"topics"`
+	Payload        json.RawMessage `json:"payload"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type Webhook struct {
+	ID             uint      `json:"id"`
+	OrganizationID uint      `json:"organization_id"`
+	URL            string    `json:"url"`
+	Secret         string    `json:"-"`
+	EventTypes     []string  `json:"event_types"`
+	Description    *string   `json:"description"`
+	Active         bool      `json:"active"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             uint            `json:"id"`
+	WebhookID      uint            `json:"webhook_id"`
+	OrganizationID uint            `json:"organization_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at"`
+	ResponseCode   *int            `json:"response_code"`
+	Error          *string         `json:"error"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type NotificationSettings struct {
+	OrganizationID  uint      `json:"organization_id"`
+	UserID          uint      `json:"user_id"`
+	QuietHoursStart *string   `json:"quiet_hours_start"`
+	QuietHoursEnd   *string   `json:"quiet_hours_end"`
+	TimeZone        *string   `json:"timezone"`
+	ChatWebhookURL  *string   `json:"chat_webhook_url"`
+	ChatFormat      *string   `json:"chat_format"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type NotificationPreference struct {
+	NotificationType string   `json:"notification_type"`
+	Channels         []string `json:"channels"`
+	Digest           bool     `json:"digest"`
+}
+
+type NotificationDelivery struct {
+	ID               uint       `json:"id"`
+	OrganizationID   uint       `json:"organization_id"`
+	UserID           uint       `json:"user_id"`
+	Channel          string     `json:"channel"`
+	NotificationType string     `json:"notification_type"`
+	Title            string     `json:"title"`
+	Message          *string    `json:"message"`
+	Digest           bool       `json:"digest"`
+	Status           string     `json:"status"`
+	Attempts         int        `json:"attempts"`
+	DeliverAfter     time.Time  `json:"deliver_after"`
+	SentAt           *time.Time `json:"sent_at"`
+	Error            *string    `json:"error"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+type OutboxEvent struct {
+	ID             uint            `json:"id"`
+	OrganizationID uint            `json:"organization_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastError      *string         `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+type BusinessCalendar struct {
+	OrganizationID uint       `json:"organization_id"`
+	TimeZone       string     `json:"timezone"`
+	WorkingDays    []int      `json:"working_days"`
+	DailyHours     float64    `json:"daily_hours"`
+	UpdatedAt      *time.Time `json:"updated_at"`
+}
+
+type Holiday struct {
+	ID             uint      `json:"id"`
+	OrganizationID uint      `json:"organization_id"`
+	Date           string    `json:"date" binding:"required"`
+	Name           string    `json:"name" binding:"required"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type CalendarFeed struct {
+	ID             uint       `json:"id"`
+	OrganizationID uint       `json:"organization_id"`
+	UserID         uint       `json:"user_id"`
+	Scope          string     `json:"scope"`
+	Name           *string    `json:"name"`
+	Token          string     `json:"-"`
+	LastUsedAt     *time.Time `json:"last_used_at"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// ConnectionTicket authenticates a single real-time connection in place of a
+// JWT in the URL.
+type ConnectionTicket struct {
+	UserID         uint      `json:"user_id"`
+	OrganizationID uint      `json:"organization_id"`
+	Role           string    `json:"role"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+// CalendarEntry is a work order or maintenance task as published in a
+// calendar feed. Work orders without their own schedule take the date of
+// their maintenance task.
+type CalendarEntry struct {
+	WorkOrderID            *uint
+	MaintenanceTaskID      *uint
+	MaintenancePlanID      *uint
+	TechnicianID           *uint
+	Title                  string
+	Description            *string
+	AssetName              string
+	AssetLocation          *string
+	Status                 string
+	Priority               string
+	ScheduledStart         *time.Time
+	ScheduledEnd           *time.Time
+	ScheduledDate          *time.Time
+	EstimatedDurationHours *float64
+	Sequence               int
+	UpdatedAt              time.Time
+}
+
+// CalendarCancellation keeps a cancelled event in a feed so subscribed
+// calendars remove it. A nil UserID targets the organization feeds.
+type CalendarCancellation struct {
+	ID             uint
+	OrganizationID uint
+	UserID         *uint
+	UID            string
+	Summary        string
+	StartsAt       time.Time
+	EndsAt         time.Time
+	AllDay         bool
+	Sequence       int
+	CancelledAt    time.Time
+}
+
+// ScheduledWorkOrder is an open work order that is assigned and scheduled,
+// with the asset it is carried out on.
+type ScheduledWorkOrder struct {
+	WorkOrder
+	AssetName     string  `json:"asset_name"`
+	AssetLocation *string `json:"asset_location"`
+}
+
+type Job struct {
+	Name         string     `json:"name"`
+	Schedule     string     `json:"schedule"`
+	Enabled      bool       `json:"enabled"`
+	NextRunAt    time.Time  `json:"next_run_at"`
+	RunRequested bool       `json:"run_requested"`
+	Attempts     int        `json:"attempts"`
+	LeaseOwner   *string    `json:"lease_owner"`
+	LeaseUntil   *time.Time `json:"lease_until"`
+	LastRunAt    *time.Time `json:"last_run_at"`
+	LastStatus   *string    `json:"last_status"`
+	LastError    *string    `json:"last_error"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+type JobRun struct {
+	ID         uint       `json:"id"`
+	JobName    string     `json:"job_name"`
+	Trigger    string     `json:"trigger"`
+	Status     string     `json:"status"`
+	Attempt    int        `json:"attempt"`
+	Owner      string     `json:"owner"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	DurationMS *int64     `json:"duration_ms"`
+	Error      *string    `json:"error"`
+}
+
 func (db *DB) CreateOrganization(org *Organization) error {
-	result, err := db.Exec(`INSERT INTO organizations (name) VALUES (?)`, org.Name)
+	if org.TimeZone == "" {
+		org.TimeZone = "UTC"
+	}
+	result, err := db.Exec(`INSERT INTO organizations (name, timezone) VALUES (?, ?)`, org.Name, org.TimeZone)
 	if err != nil {
 		return err
 	}
@@ -141,13 +448,13 @@ func (db *DB) CreateOrganization(org *Organization) error {
 
 func (db *DB) GetOrganization(id uint) (*Organization, error) {
 	org := &Organization{}
-	err := db.QueryRow(`SELECT id, name, created_at, updated_at FROM organizations WHERE id = ?`, id).
-		Scan(&org.ID, &org.Name, &org.CreatedAt, &org.UpdatedAt)
+	err := db.QueryRow(`SELECT id, name, timezone, created_at, updated_at FROM organizations WHERE id = ?`, id).
+		Scan(&org.ID, &org.Name, &org.TimeZone, &org.CreatedAt, &org.UpdatedAt)
 	return org, err
 }
 
 func (db *DB) ListOrganizations() ([]Organization, error) {
-	rows, err := db.Query(`SELECT id, name, created_at, updated_at FROM organizations`)
+	rows, err := db.Query(`SELECT id, name, timezone, created_at, updated_at FROM organizations`)
 	if err != nil {
 		return nil, err
 	}
@@ -156,7 +463,7 @@ func (db *DB) ListOrganizations() ([]Organization, error) {
 	var orgs []Organization
 	for rows.Next() {
 		var org Organization
-		if err := rows.Scan(&org.ID, &org.Name, &org.CreatedAt, &org.UpdatedAt); err != nil {
+		if err := rows.Scan(&org.ID, &org.Name, &org.TimeZone, &org.CreatedAt, &org.UpdatedAt); err != nil {
 			return nil, err
 		}
 		orgs = append(orgs, org)
@@ -165,7 +472,7 @@ func (db *DB) ListOrganizations() ([]Organization, error) {
 }
 
 func (db *DB) UpdateOrganization(org *Organization) error {
-	_, err := db.Exec(`UPDATE organizations SET name = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, org.Name, org.ID)
+	_, err := db.Exec(`UPDATE organizations SET name = ?, timezone = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, org.Name, org.TimeZone, org.ID)
 	return err
 }
 
@@ -187,9 +494,9 @@ func (db *DB) GetUserByEmail(email string) (*User, error) {
 	return user, err
 }
 
-func (db *DB) GetUser(id uint) (*User, error) {
+func (db *DB) GetUser(id, orgID uint) (*User, error) {
 	user := &User{}
-	err := db.QueryRow(`SELECT id, organization_id, email, password_hash, full_name, role, created_at, updated_at FROM users WHERE id = ?`, id).
+	err := db.QueryRow(`SELECT id, organization_id, email, password_hash, full_name, role, created_at, updated_at FROM users WHERE id = ? AND organization_id = ?`, id, orgID).
 		Scan(&user.ID, &user.OrganizationID, &user.Email, &user.PasswordHash, &user.FullName, &user.Role, &user.CreatedAt, &user.UpdatedAt)
 	return user, err
 }
@@ -213,12 +520,35 @@ func (db *DB) ListUsers(orgID uint) ([]User, error) {
 }
 
 func (db *DB) UpdateUser(user *User) error {
-	_, err := db.Exec(`UPDATE users SET email = ?, full_name = ?, role = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
-		user.Email, user.FullName, user.Role, user.ID)
-	return err
+	result, err := db.Exec(`UPDATE users SET email = ?, full_name = ?, role = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND organization_id = ?`,
+		user.Email, user.FullName, user.Role, user.ID, user.OrganizationID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
 }
 
-func (db *DB) DeleteUser(id uint) error {
-	_, err := db.Exec(`DELETE FROM users WHERE id = ?`, id)
-	return err
+func (db *DB) DeleteUser(id, orgID uint) error {
+	result, err := db.Exec(`DELETE FROM users WHERE id = ? AND organization_id = ?`, id, orgID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (db *DB) CountUsers(orgID uint) (int, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM users WHERE organization_id = ?`, orgID).Scan(&count)
+	return count, err
+}
+
+func requireAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
 }
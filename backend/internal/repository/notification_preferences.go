@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const notificationDeliveryColumns = `id, organization_id, user_id, channel, notification_type, title, message, digest, status, attempts,
+	deliver_after, sent_at, error, created_at`
+
+func (r *Repository) GetNotificationSettings(orgID, userID uint) (*NotificationSettings, error) {
+	settings := &NotificationSettings{OrganizationID: orgID, UserID: userID}
+	err := r.QueryRow(`SELECT quiet_hours_start, quiet_hours_end, timezone, chat_webhook_url, chat_format, updated_at
+		FROM notification_settings WHERE organization_id = ? AND user_id = ?`, orgID, userID).
+		Scan(&settings.QuietHoursStart, &settings.QuietHoursEnd, &settings.TimeZone, &settings.ChatWebhookURL, &settings.ChatFormat, &settings.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return settings, nil
+	}
+	return settings, err
+}
+
+func (r *Repository) SaveNotificationSettings(settings *NotificationSettings) error {
+	settings.UpdatedAt = time.Now().UTC()
+	_, err := r.Exec(`INSERT INTO notification_settings (organization_id, user_id, quiet_hours_start, quiet_hours_end, timezone, chat_webhook_url, chat_format, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(organization_id, user_id) DO UPDATE SET quiet_hours_start = excluded.quiet_hours_start, quiet_hours_end = excluded.quiet_hours_end,
+			timezone = excluded.timezone, chat_webhook_url = excluded.chat_webhook_url, chat_format = excluded.chat_format, updated_at = excluded.updated_at`,
+		settings.OrganizationID, settings.UserID, settings.QuietHoursStart, settings.QuietHoursEnd, settings.TimeZone,
+		settings.ChatWebhookURL, settings.ChatFormat, settings.UpdatedAt)
+	return err
+}
+
+func (r *Repository) ListNotificationPreferences(orgID, userID uint) ([]NotificationPreference, error) {
+	rows, err := r.Query(`SELECT notification_type, channels, digest FROM notification_preferences
+		WHERE organization_id = ? AND user_id = ? ORDER BY notification_type`, orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	preferences := []NotificationPreference{}
+	for rows.Next() {
+		var preference NotificationPreference
+		var channels string
+		if err := rows.Scan(&preference.NotificationType, &channels, &preference.Digest); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(channels), &preference.Channels); err != nil {
+			return nil, err
+		}
+		preferences = append(preferences, preference)
+	}
+	return preferences, nil
+}
+
+func (r *Repository) ReplaceNotificationPreferences(orgID, userID uint, preferences []NotificationPreference) error {
+	return r.WithTx(func(tx *Repository) error {
+		if _, err := tx.Exec(`DELETE FROM notification_preferences WHERE organization_id = ? AND user_id = ?`, orgID, userID); err != nil {
+			return err
+		}
+		for _, preference := range preferences {
+			channels, _ := json.Marshal(preference.Channels)
+			if _, err := tx.Exec(`INSERT INTO notification_preferences (organization_id, user_id, notification_type, channels, digest) VALUES (?, ?, ?, ?, ?)`,
+				orgID, userID, preference.NotificationType, string(channels), preference.Digest); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *Repository) CreateNotificationDelivery(delivery *NotificationDelivery) error {
+	now := time.Now().UTC()
+	delivery.Status = "pending"
+	delivery.DeliverAfter = delivery.DeliverAfter.UTC().Truncate(time.Second)
+	result, err := r.Exec(`INSERT INTO notification_deliveries (organization_id, user_id, channel, notification_type, title, message, digest, status, deliver_after, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		delivery.OrganizationID, delivery.UserID, delivery.Channel, delivery.NotificationType, delivery.Title, delivery.Message,
+		delivery.Digest, delivery.Status, delivery.DeliverAfter, now)
+	if err != nil {
+		return err
+	}
+	id, _ := result.LastInsertId()
+	delivery.ID = uint(id)
+	delivery.CreatedAt = now
+	return nil
+}
+
+func (r *Repository) ListDueNotificationDeliveries(now time.Time, limit int) ([]NotificationDelivery, error) {
+	rows, err := r.Query(`SELECT `+notificationDeliveryColumns+` FROM notification_deliveries
+		WHERE status = 'pending' AND deliver_after <= ? ORDER BY user_id, channel, id LIMIT ?`, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []NotificationDelivery
+	for rows.Next() {
+		var d NotificationDelivery
+		if err := rows.Scan(&d.ID, &d.OrganizationID, &d.UserID, &d.Channel, &d.NotificationType, &d.Title, &d.Message, &d.Digest,
+			&d.Status, &d.Attempts, &d.DeliverAfter, &d.SentAt, &d.Error, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+func (r *Repository) ClaimNotificationDelivery(id uint, now, leaseUntil time.Time) (bool, error) {
+	result, err := r.Exec(`UPDATE notification_deliveries SET deliver_after = ? WHERE id = ? AND status = 'pending' AND deliver_after <= ?`,
+		leaseUntil, id, now)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+func (r *Repository) RecordNotificationDelivery(delivery *NotificationDelivery) error {
+	_, err := r.Exec(`UPDATE notification_deliveries SET status = ?, attempts = ?, deliver_after = ?, sent_at = ?, error = ? WHERE id = ?`,
+		delivery.Status, delivery.Attempts, delivery.DeliverAfter, delivery.SentAt, delivery.Error, delivery.ID)
+	return err
+}
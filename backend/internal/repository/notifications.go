@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+const notificationColumns = `id, organization_id, user_id, type, title, message, data, read_at, created_at`
+
+func (r *Repository) CreateNotification(n *Notification) error {
+	var data *string
+	if len(n.Data) > 0 {
+		s := string(n.Data)
+		data = &s
+	}
+
+	result, err := r.Exec(`INSERT INTO notifications (organization_id, user_id, type, title, message, data, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		n.OrganizationID, n.UserID, n.Type, n.Title, n.Message, data, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	id, _ := result.LastInsertId()
+	n.ID = uint(id)
+	return r.QueryRow(`SELECT created_at FROM notifications WHERE id = ?`, n.ID).Scan(&n.CreatedAt)
+}
+
+func (r *Repository) ListNotifications(userID, orgID uint, page, pageSize int, unreadOnly bool) ([]Notification, int, error) {
+	offset := (page - 1) * pageSize
+
+	where := ` WHERE user_id = ? AND organization_id = ?`
+	if unreadOnly {
+		where += ` AND read_at IS NULL`
+	}
+
+	var count int
+	if err := r.QueryRow(`SELECT COUNT(*) FROM notifications`+where, userID, orgID).Scan(&count); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.Query(`SELECT `+notificationColumns+` FROM notifications`+where+` ORDER BY id DESC LIMIT ? OFFSET ?`,
+		userID, orgID, pageSize, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var notifications []Notification
+	for rows.Next() {
+		var n Notification
+		var data *string
+		if err := rows.Scan(&n.ID, &n.OrganizationID, &n.UserID, &n.Type, &n.Title, &n.Message, &data, &n.ReadAt, &n.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		if data != nil {
+			n.Data = json.RawMessage(*data)
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, count, nil
+}
+
+func (r *Repository) CountUnreadNotifications(userID, orgID uint) (int, error) {
+	var count int
+	err := r.QueryRow(`SELECT COUNT(*) FROM notifications WHERE user_id = ? AND organization_id = ? AND read_at IS NULL`, userID, orgID).Scan(&count)
+	return count, err
+}
+
+func (r *Repository) MarkNotificationRead(id, userID, orgID uint) error {
+	result, err := r.Exec(`UPDATE notifications SET read_at = COALESCE(read_at, ?) WHERE id = ? AND user_id = ? AND organization_id = ?`,
+		time.Now().UTC(), id, userID, orgID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (r *Repository) MarkAllNotificationsRead(userID, orgID uint) (int64, error) {
+	result, err := r.Exec(`UPDATE notifications SET read_at = ? WHERE user_id = ? AND organization_id = ? AND read_at IS NULL`,
+		time.Now().UTC(), userID, orgID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *Repository) ListUsersWithRoles(orgID uint, roles []string) ([]User, error) {
+	if len(roles) == 0 {
+		return nil, nil
+	}
+
+	args := []interface{}{orgID}
+	for _, role := range roles {
+		args = append(args, role)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(roles)), ", ")
+
+	rows, err := r.Query(`SELECT id, organization_id, email, full_name, role, created_at, updated_at FROM users WHERE organization_id = ? AND role IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.OrganizationID, &user.Email, &user.FullName, &user.Role, &user.CreatedAt, &user.UpdatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
@@ -0,0 +1,99 @@
+package repository
+
+import "time"
+
+const outboxColumns = `id, organization_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_error, created_at, delivered_at`
+
+func (r *Repository) CreateOutboxEvent(event *OutboxEvent) error {
+	now := time.Now().UTC().Truncate(time.Second)
+	event.Status = "pending"
+	event.NextAttemptAt = &now
+	result, err := r.Exec(`INSERT INTO outbox_events (organization_id, event_id, event_type, payload, status, next_attempt_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		event.OrganizationID, event.EventID, event.EventType, string(event.Payload), event.Status, now, now)
+	if err != nil {
+		return err
+	}
+	id, _ := result.LastInsertId()
+	event.ID = uint(id)
+	event.CreatedAt = now
+	return nil
+}
+
+func (r *Repository) ListDueOutboxEvents(now time.Time, limit int) ([]OutboxEvent, error) {
+	rows, err := r.Query(`SELECT `+outboxColumns+` FROM outbox_events WHERE status = 'pending' AND next_attempt_at <= ? ORDER BY id LIMIT ?`,
+		now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []OutboxEvent
+	for rows.Next() {
+		var event OutboxEvent
+		var payload string
+		if err := rows.Scan(&event.ID, &event.OrganizationID, &event.EventID, &event.EventType, &payload, &event.Status, &event.Attempts,
+			&event.NextAttemptAt, &event.LastError, &event.CreatedAt, &event.DeliveredAt); err != nil {
+			return nil, err
+		}
+		event.Payload = []byte(payload)
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (r *Repository) ClaimOutboxEvent(id uint, now, leaseUntil time.Time) (bool, error) {
+	result, err := r.Exec(`UPDATE outbox_events SET next_attempt_at = ? WHERE id = ? AND status = 'pending' AND next_attempt_at <= ?`,
+		leaseUntil, id, now)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+func (r *Repository) MarkOutboxEventDelivered(id uint) error {
+	_, err := r.Exec(`UPDATE outbox_events SET status = 'delivered', attempts = attempts + 1, last_error = NULL, delivered_at = ? WHERE id = ?`,
+		time.Now().UTC(), id)
+	return err
+}
+
+func (r *Repository) RecordOutboxFailure(event *OutboxEvent) error {
+	_, err := r.Exec(`UPDATE outbox_events SET status = ?, attempts = ?, next_attempt_at = ?, last_error = ? WHERE id = ?`,
+		event.Status, event.Attempts, event.NextAttemptAt, event.LastError, event.ID)
+	return err
+}
+
+func (r *Repository) ListOutboxDeliveries(outboxEventID uint) ([]string, error) {
+	rows, err := r.Query(`SELECT consumer FROM outbox_deliveries WHERE outbox_event_id = ? ORDER BY consumer`, outboxEventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	consumers := []string{}
+	for rows.Next() {
+		var consumer string
+		if err := rows.Scan(&consumer); err != nil {
+			return nil, err
+		}
+		consumers = append(consumers, consumer)
+	}
+	return consumers, rows.Err()
+}
+
+func (r *Repository) RecordOutboxDelivery(outboxEventID uint, consumer string) error {
+	_, err := r.Exec(`INSERT INTO outbox_deliveries (outbox_event_id, consumer, delivered_at) VALUES (?, ?, ?)
+		ON CONFLICT(outbox_event_id, consumer) DO NOTHING`, outboxEventID, consumer, time.Now().UTC())
+	return err
+}
+
+func (r *Repository) DeleteDeliveredOutboxEvents(before time.Time) error {
+	return r.WithTx(func(tx *Repository) error {
+		if _, err := tx.Exec(`DELETE FROM outbox_deliveries WHERE outbox_event_id IN
+			(SELECT id FROM outbox_events WHERE status = 'delivered' AND delivered_at < ?)`, before); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM outbox_events WHERE status = 'delivered' AND delivered_at < ?`, before)
+		return err
+	})
+}
@@ -0,0 +1,88 @@
+package repository
+
+import "time"
+
+func (r *Repository) GetUserByID(id uint) (*User, error) {
+	user := &User{}
+	err := r.QueryRow(`SELECT id, organization_id, email, password_hash, full_name, role, created_at, updated_at FROM users WHERE id = ?`, id).
+		Scan(&user.ID, &user.OrganizationID, &user.Email, &user.PasswordHash, &user.FullName, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	return user, err
+}
+
+func (r *Repository) AddPasswordHistory(userID uint, passwordHash string) error {
+	_, err := r.Exec(`INSERT INTO password_history (user_id, password_hash) VALUES (?, ?)`, userID, passwordHash)
+	return err
+}
+
+func (r *Repository) GetPasswordHistory(userID uint, limit int) ([]string, error) {
+	rows, err := r.Query(`SELECT password_hash FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
+
+func (r *Repository) CreatePasswordResetToken(token *PasswordResetToken) error {
+	tx, err := r.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE password_reset_tokens SET used_at = ? WHERE user_id = ? AND used_at IS NULL`, time.Now().UTC(), token.UserID); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`INSERT INTO password_reset_tokens (user_id, token_hash, expires_at) VALUES (?, ?, ?)`,
+		token.UserID, token.TokenHash, token.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	id, _ := result.LastInsertId()
+	token.ID = uint(id)
+	return nil
+}
+
+func (r *Repository) GetPasswordResetToken(tokenHash string) (*PasswordResetToken, error) {
+	token := &PasswordResetToken{}
+	err := r.QueryRow(`SELECT id, user_id, token_hash, expires_at, used_at, created_at FROM password_reset_tokens WHERE token_hash = ?`, tokenHash).
+		Scan(&token.ID, &token.UserID, &token.TokenHash, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt)
+	return token, err
+}
+
+func (r *Repository) ResetPasswordWithToken(tokenID, userID uint, passwordHash string) error {
+	tx, err := r.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE password_reset_tokens SET used_at = ? WHERE id = ? AND user_id = ? AND used_at IS NULL`, time.Now().UTC(), tokenID, userID)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE users SET password_hash = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, passwordHash, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO password_history (user_id, password_hash) VALUES (?, ?)`, userID, passwordHash); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"strings"
+	"time"
+)
+
+func (r *Repository) CreateProcedure(procedure *Procedure) error {
+	return r.WithTx(func(tx *Repository) error {
+		result, err := tx.Exec(`INSERT INTO procedures (organization_id, name, description) VALUES (?, ?, ?)`,
+			procedure.OrganizationID, procedure.Name, procedure.Description)
+		if err != nil {
+			return err
+		}
+		id, _ := result.LastInsertId()
+		procedure.ID = uint(id)
+		return tx.insertProcedureSteps(procedure)
+	})
+}
+
+func (r *Repository) GetProcedure(id, orgID uint) (*Procedure, error) {
+	procedure := &Procedure{}
+	err := r.QueryRow(`SELECT id, organization_id, name, description, created_at, updated_at FROM procedures WHERE id = ? AND organization_id = ?`, id, orgID).
+		Scan(&procedure.ID, &procedure.OrganizationID, &procedure.Name, &procedure.Description, &procedure.CreatedAt, &procedure.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	procedure.Steps, err = r.GetProcedureSteps(procedure.ID)
+	return procedure, err
+}
+
+func (r *Repository) ListProcedures(orgID uint) ([]Procedure, error) {
+	rows, err := r.Query(`SELECT id, organization_id, name, description, created_at, updated_at FROM procedures WHERE organization_id = ? ORDER BY name ASC`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	procedures := []Procedure{}
+	for rows.Next() {
+		var procedure Procedure
+		if err := rows.Scan(&procedure.ID, &procedure.OrganizationID, &procedure.Name, &procedure.Description, &procedure.CreatedAt, &procedure.UpdatedAt); err != nil {
+			return nil, err
+		}
+		procedures = append(procedures, procedure)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range procedures {
+		if procedures[i].Steps, err = r.GetProcedureSteps(procedures[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return procedures, nil
+}
+
+// UpdateProcedure saves the procedure and its steps in order. Steps with an ID
+// are updated in place, so results and open forms that refer to them stay
+// valid; steps without one are added and steps left out are removed.
+func (r *Repository) UpdateProcedure(procedure *Procedure) error {
+	return r.WithTx(func(tx *Repository) error {
+		result, err := tx.Exec(`UPDATE procedures SET name = ?, description = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND organization_id = ?`,
+			procedure.Name, procedure.Description, procedure.ID, procedure.OrganizationID)
+		if err != nil {
+			return err
+		}
+		if err := requireAffected(result); err != nil {
+			return err
+		}
+
+		kept := []interface{}{procedure.ID}
+		placeholders := []string{}
+		for _, step := range procedure.Steps {
+			if step.ID != 0 {
+				kept = append(kept, step.ID)
+				placeholders = append(placeholders, "?")
+			}
+		}
+		query := `DELETE FROM procedure_steps WHERE procedure_id = ?`
+		if len(placeholders) > 0 {
+			query += ` AND id NOT IN (` + strings.Join(placeholders, ", ") + `)`
+		}
+		if _, err := tx.Exec(query, kept...); err != nil {
+			return err
+		}
+
+		for i := range procedure.Steps {
+			step := &procedure.Steps[i]
+			if step.ID == 0 {
+				continue
+			}
+			step.ProcedureID = procedure.ID
+			step.Position = i + 1
+			result, err := tx.Exec(`UPDATE procedure_steps SET position = ?, type = ?, instruction = ?, optional = ?, min_value = ?, max_value = ?, unit = ?
+				WHERE id = ? AND procedure_id = ?`,
+				step.Position, step.Type, step.Instruction, step.Optional, step.MinValue, step.MaxValue, step.Unit, step.ID, step.ProcedureID)
+			if err != nil {
+				return err
+			}
+			if err := requireAffected(result); err != nil {
+				return err
+			}
+		}
+		return tx.insertProcedureSteps(procedure)
+	})
+}
+
+func (r *Repository) DeleteProcedure(id, orgID uint) error {
+	result, err := r.Exec(`DELETE FROM procedures WHERE id = ? AND organization_id = ?`, id, orgID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// insertProcedureSteps inserts the steps of the procedure that have no ID yet.
+func (r *Repository) insertProcedureSteps(procedure *Procedure) error {
+	for i := range procedure.Steps {
+		step := &procedure.Steps[i]
+		if step.ID != 0 {
+			continue
+		}
+		step.ProcedureID = procedure.ID
+		step.Position = i + 1
+		result, err := r.Exec(`INSERT INTO procedure_steps (procedure_id, position, type, instruction, optional, min_value, max_value, unit) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			step.ProcedureID, step.Position, step.Type, step.Instruction, step.Optional, step.MinValue, step.MaxValue, step.Unit)
+		if err != nil {
+			return err
+		}
+		id, _ := result.LastInsertId()
+		step.ID = uint(id)
+	}
+	return nil
+}
+
+func (r *Repository) GetProcedureSteps(procedureID uint) ([]ProcedureStep, error) {
+	rows, err := r.Query(`SELECT id, procedure_id, position, type, instruction, optional, min_value, max_value, unit FROM procedure_steps WHERE procedure_id = ? ORDER BY position`, procedureID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	steps := []ProcedureStep{}
+	for rows.Next() {
+		var step ProcedureStep
+		if err := rows.Scan(&step.ID, &step.ProcedureID, &step.Position, &step.Type, &step.Instruction, &step.Optional, &step.MinValue, &step.MaxValue, &step.Unit); err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	return steps, rows.Err()
+}
+
+func (r *Repository) CreateStepResult(result *StepResult) error {
+	res, err := r.Exec(`INSERT INTO work_order_step_results (work_order_id, step_id, position, type, instruction, min_value, max_value, unit, checked, reading, passed, text, photo_url, out_of_limit, recorded_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		result.WorkOrderID, result.StepID, result.Position, result.Type, result.Instruction, result.MinValue, result.MaxValue, result.Unit,
+		result.Checked, result.Reading, result.Passed, result.Text, result.PhotoURL, result.OutOfLimit, result.RecordedBy)
+	if err != nil {
+		return err
+	}
+	id, _ := res.LastInsertId()
+	result.ID = uint(id)
+	return nil
+}
+
+func (r *Repository) CountStepResults(workOrderID uint) (int, error) {
+	var count int
+	err := r.QueryRow(`SELECT COUNT(*) FROM work_order_step_results WHERE work_order_id = ?`, workOrderID).Scan(&count)
+	return count, err
+}
+
+// CompleteWorkOrder marks an open work order completed, ending it at `at`
+// unless it already has an actual end. It reports false when the work order
+// was already completed or closed.
+func (r *Repository) CompleteWorkOrder(id, orgID uint, at time.Time) (bool, error) {
+	result, err := r.Exec(`UPDATE work_orders SET status = 'completed', actual_end = COALESCE(actual_end, ?), calendar_sequence = calendar_sequence + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND organization_id = ? AND status NOT IN ('completed', 'closed')`, at, id, orgID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (r *Repository) ListStepResults(workOrderID, orgID uint) ([]StepResult, error) {
+	rows, err := r.Query(`SELECT sr.id, sr.work_order_id, sr.step_id, sr.position, sr.type, sr.instruction, sr.min_value, sr.max_value, sr.unit,
+		sr.checked, sr.reading, sr.passed, sr.text, sr.photo_url, sr.out_of_limit, sr.recorded_by, sr.created_at
+		FROM work_order_step_results sr
+		JOIN work_orders wo ON sr.work_order_id = wo.id
+		WHERE sr.work_order_id = ? AND wo.organization_id = ? ORDER BY sr.position`, workOrderID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []StepResult{}
+	for rows.Next() {
+		var result StepResult
+		if err := rows.Scan(&result.ID, &result.WorkOrderID, &result.StepID, &result.Position, &result.Type, &result.Instruction, &result.MinValue, &result.MaxValue, &result.Unit,
+			&result.Checked, &result.Reading, &result.Passed, &result.Text, &result.PhotoURL, &result.OutOfLimit, &result.RecordedBy, &result.CreatedAt); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
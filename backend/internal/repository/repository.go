@@ -1,17 +1,59 @@
 package repository
 
 import (
+	"database/sql"
 	"fmt"
 	"strings"
 	"time"
+
+	"assetsentinel/internal/calendar"
 )
 
 type Repository struct {
 	*DB
+	tx *sql.Tx
 }
 
 func NewRepository(db *DB) *Repository {
-	return &Repository{db}
+	return &Repository{DB: db}
+}
+
+func (r *Repository) WithTx(fn func(tx *Repository) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&Repository{DB: r.DB, tx: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Repository) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if r.tx != nil {
+		return r.tx.Exec(query, args...)
+	}
+	return r.DB.Exec(query, args...)
+}
+
+func (r *Repository) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	if r.tx != nil {
+		return r.tx.Query(query, args...)
+	}
+	return r.DB.Query(query, args...)
+}
+
+func (r *Repository) QueryRow(query string, args ...interface{}) *sql.Row {
+	if r.tx != nil {
+		return r.tx.QueryRow(query, args...)
+	}
+	return r.DB.QueryRow(query, args...)
 }
 
 func (r *Repository) CreateAsset(asset *Asset) error {
@@ -91,20 +133,41 @@ func (r *Repository) ListAssets(orgID uint, page, pageSize int, status, category
 }
 
 func (r *Repository) UpdateAsset(asset *Asset) error {
-	_, err := r.Exec(`UPDATE assets SET name = ?, category = ?, serial_number = ?, installation_date = ?, location = ?, purchase_cost = ?, warranty_expiry = ?, status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND organization_id = ?`,
+	result, err := r.Exec(`UPDATE assets SET name = ?, category = ?, serial_number = ?, installation_date = ?, location = ?, purchase_cost = ?, warranty_expiry = ?, status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND organization_id = ?`,
 		asset.Name, asset.Category, asset.SerialNumber, asset.InstallationDate, asset.Location, asset.PurchaseCost, asset.WarrantyExpiry, asset.Status, asset.ID, asset.OrganizationID)
-	return err
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
 }
 
 func (r *Repository) SoftDeleteAsset(id, orgID uint) error {
-	_, err := r.Exec(`UPDATE assets SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND organization_id = ?`, id, orgID)
-	return err
+	result, err := r.Exec(`UPDATE assets SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND organization_id = ?`, id, orgID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+const maintenancePlanColumns = `id, organization_id, asset_id, frequency_days, recurrence_rule, recurrence_start, estimated_duration_hours, assigned_role, last_maintenance_date, next_maintenance_date, non_working_day_shift,
+	auto_work_order, work_order_title, work_order_description, work_order_priority, assignment_strategy, last_assignee_id, procedure_id, created_at, updated_at`
+
+func scanMaintenancePlan(row rowScanner) (*MaintenancePlan, error) {
+	var plan MaintenancePlan
+	if err := row.Scan(&plan.ID, &plan.OrganizationID, &plan.AssetID, &plan.FrequencyDays, &plan.RecurrenceRule, &plan.RecurrenceStart, &plan.EstimatedDurationHours,
+		&plan.AssignedRole, &plan.LastMaintenanceDate, &plan.NextMaintenanceDate, &plan.NonWorkingDayShift, &plan.AutoWorkOrder, &plan.WorkOrderTitle, &plan.WorkOrderDescription,
+		&plan.WorkOrderPriority, &plan.AssignmentStrategy, &plan.LastAssigneeID, &plan.ProcedureID, &plan.CreatedAt, &plan.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &plan, nil
 }
 
 func (r *Repository) CreateMaintenancePlan(plan *MaintenancePlan) error {
-	result, err := r.Exec(`INSERT INTO maintenance_plans (organization_id, asset_id, frequency_days, estimated_duration_hours, assigned_role, last_maintenance_date, next_maintenance_date) 
-		VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		plan.OrganizationID, plan.AssetID, plan.FrequencyDays, plan.EstimatedDurationHours, plan.AssignedRole, plan.LastMaintenanceDate, plan.NextMaintenanceDate)
+	result, err := r.Exec(`INSERT INTO maintenance_plans (organization_id, asset_id, frequency_days, recurrence_rule, recurrence_start, estimated_duration_hours, assigned_role, last_maintenance_date, next_maintenance_date, non_working_day_shift,
+		auto_work_order, work_order_title, work_order_description, work_order_priority, assignment_strategy, procedure_id) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		plan.OrganizationID, plan.AssetID, plan.FrequencyDays, plan.RecurrenceRule, plan.RecurrenceStart, plan.EstimatedDurationHours, plan.AssignedRole, plan.LastMaintenanceDate, plan.NextMaintenanceDate, plan.NonWorkingDayShift,
+		plan.AutoWorkOrder, plan.WorkOrderTitle, plan.WorkOrderDescription, plan.WorkOrderPriority, plan.AssignmentStrategy, plan.ProcedureID)
 	if err != nil {
 		return err
 	}
@@ -114,11 +177,7 @@ func (r *Repository) CreateMaintenancePlan(plan *MaintenancePlan) error {
 }
 
 func (r *Repository) GetMaintenancePlan(id, orgID uint) (*MaintenancePlan, error) {
-	plan := &MaintenancePlan{}
-	err := r.QueryRow(`SELECT id, organization_id, asset_id, frequency_days, estimated_duration_hours, assigned_role, last_maintenance_date, next_maintenance_date, created_at, updated_at 
-		FROM maintenance_plans WHERE id = ? AND organization_id = ?`, id, orgID).
-		Scan(&plan.ID, &plan.OrganizationID, &plan.AssetID, &plan.FrequencyDays, &plan.EstimatedDurationHours, &plan.AssignedRole, &plan.LastMaintenanceDate, &plan.NextMaintenanceDate, &plan.CreatedAt, &plan.UpdatedAt)
-	return plan, err
+	return scanMaintenancePlan(r.QueryRow(`SELECT `+maintenancePlanColumns+` FROM maintenance_plans WHERE id = ? AND organization_id = ?`, id, orgID))
 }
 
 func (r *Repository) ListMaintenancePlans(orgID uint, page, pageSize int) ([]MaintenancePlan, int, error) {
@@ -130,7 +189,7 @@ func (r *Repository) ListMaintenancePlans(orgID uint, page, pageSize int) ([]Mai
 		return nil, 0, err
 	}
 
-	rows, err := r.Query(`SELECT id, organization_id, asset_id, frequency_days, estimated_duration_hours, assigned_role, last_maintenance_date, next_maintenance_date, created_at, updated_at 
+	rows, err := r.Query(`SELECT `+maintenancePlanColumns+`
 		FROM maintenance_plans WHERE organization_id = ? ORDER BY next_maintenance_date ASC LIMIT ? OFFSET ?`, orgID, pageSize, offset)
 	if err != nil {
 		return nil, 0, err
@@ -139,18 +198,25 @@ func (r *Repository) ListMaintenancePlans(orgID uint, page, pageSize int) ([]Mai
 
 	var plans []MaintenancePlan
 	for rows.Next() {
-		var plan MaintenancePlan
-		if err := rows.Scan(&plan.ID, &plan.OrganizationID, &plan.AssetID, &plan.FrequencyDays, &plan.EstimatedDurationHours, &plan.AssignedRole, &plan.LastMaintenanceDate, &plan.NextMaintenanceDate, &plan.CreatedAt, &plan.UpdatedAt); err != nil {
+		plan, err := scanMaintenancePlan(rows)
+		if err != nil {
 			return nil, 0, err
 		}
-		plans = append(plans, plan)
+		plans = append(plans, *plan)
 	}
 	return plans, count, nil
 }
 
 func (r *Repository) UpdateMaintenancePlan(plan *MaintenancePlan) error {
-	_, err := r.Exec(`UPDATE maintenance_plans SET asset_id = ?, frequency_days = ?, estimated_duration_hours = ?, assigned_role = ?, last_maintenance_date = ?, next_maintenance_date = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND organization_id = ?`,
-		plan.AssetID, plan.FrequencyDays, plan.EstimatedDurationHours, plan.AssignedRole, plan.LastMaintenanceDate, plan.NextMaintenanceDate, plan.ID, plan.OrganizationID)
+	_, err := r.Exec(`UPDATE maintenance_plans SET asset_id = ?, frequency_days = ?, recurrence_rule = ?, recurrence_start = ?, estimated_duration_hours = ?, assigned_role = ?, last_maintenance_date = ?, next_maintenance_date = ?, non_working_day_shift = ?,
+		auto_work_order = ?, work_order_title = ?, work_order_description = ?, work_order_priority = ?, assignment_strategy = ?, procedure_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND organization_id = ?`,
+		plan.AssetID, plan.FrequencyDays, plan.RecurrenceRule, plan.RecurrenceStart, plan.EstimatedDurationHours, plan.AssignedRole, plan.LastMaintenanceDate, plan.NextMaintenanceDate, plan.NonWorkingDayShift,
+		plan.AutoWorkOrder, plan.WorkOrderTitle, plan.WorkOrderDescription, plan.WorkOrderPriority, plan.AssignmentStrategy, plan.ProcedureID, plan.ID, plan.OrganizationID)
+	return err
+}
+
+func (r *Repository) SetMaintenancePlanLastAssignee(planID, orgID, userID uint) error {
+	_, err := r.Exec(`UPDATE maintenance_plans SET last_assignee_id = ? WHERE id = ? AND organization_id = ?`, userID, planID, orgID)
 	return err
 }
 
@@ -160,11 +226,15 @@ func (r *Repository) DeleteMaintenancePlan(id, orgID uint) error {
 }
 
 func (r *Repository) GetUpcomingMaintenanceTasks(orgID uint, daysAhead int) ([]MaintenanceTask, error) {
-	futureDate := time.Now().AddDate(0, 0, daysAhead)
+	cal, err := r.GetBusinessCalendar(orgID)
+	if err != nil {
+		return nil, err
+	}
+	futureDate := cal.Today(time.Now()).AddDate(0, 0, daysAhead)
 	rows, err := r.Query(`SELECT mt.id, mt.organization_id, mt.maintenance_plan_id, mt.asset_id, mt.scheduled_date, mt.status, mt.completed_date, mt.notes, mt.created_at, mt.updated_at 
 		FROM maintenance_tasks mt 
 		JOIN maintenance_plans mp ON mt.maintenance_plan_id = mp.id 
-		WHERE mp.organization_id = ? AND mt.scheduled_date <= ? AND mt.status = 'pending'`, orgID, futureDate.Format("2006-01-02"))
+		WHERE mt.organization_id = ? AND mp.organization_id = mt.organization_id AND mt.scheduled_date < ? AND mt.status = 'pending'`, orgID, futureDate.AddDate(0, 0, 1).Format(calendar.DateLayout))
 	if err != nil {
 		return nil, err
 	}
@@ -182,11 +252,14 @@ func (r *Repository) GetUpcomingMaintenanceTasks(orgID uint, daysAhead int) ([]M
 }
 
 func (r *Repository) GetOverdueMaintenanceTasks(orgID uint) ([]MaintenanceTask, error) {
-	today := time.Now().Format("2006-01-02")
+	cutoff, err := r.overdueCutoff(orgID)
+	if err != nil {
+		return nil, err
+	}
 	rows, err := r.Query(`SELECT mt.id, mt.organization_id, mt.maintenance_plan_id, mt.asset_id, mt.scheduled_date, mt.status, mt.completed_date, mt.notes, mt.created_at, mt.updated_at 
 		FROM maintenance_tasks mt 
 		JOIN maintenance_plans mp ON mt.maintenance_plan_id = mp.id 
-		WHERE mp.organization_id = ? AND mt.scheduled_date < ? AND mt.status IN ('pending', 'in_progress')`, orgID, today)
+		WHERE mt.organization_id = ? AND mp.organization_id = mt.organization_id AND mt.scheduled_date < ? AND mt.status IN ('pending', 'in_progress')`, orgID, cutoff)
 	if err != nil {
 		return nil, err
 	}
@@ -214,16 +287,70 @@ func (r *Repository) CreateMaintenanceTask(task *MaintenanceTask) error {
 	return nil
 }
 
+func (r *Repository) CreateMaintenanceTaskOnce(task *MaintenanceTask) (bool, error) {
+	result, err := r.Exec(`INSERT INTO maintenance_tasks (organization_id, maintenance_plan_id, asset_id, scheduled_date, status, notes)
+		SELECT ?, ?, ?, ?, ?, ? WHERE NOT EXISTS (SELECT 1 FROM maintenance_tasks WHERE maintenance_plan_id = ? AND scheduled_date = ?)`,
+		task.OrganizationID, task.MaintenancePlanID, task.AssetID, task.ScheduledDate, task.Status, task.Notes, task.MaintenancePlanID, task.ScheduledDate)
+	if err != nil {
+		return false, err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return false, err
+	}
+	id, _ := result.LastInsertId()
+	task.ID = uint(id)
+	return true, nil
+}
+
+func (r *Repository) MarkMaintenanceTaskOverdue(task *MaintenanceTask) (bool, error) {
+	result, err := r.Exec(`UPDATE maintenance_tasks SET status = 'overdue', calendar_sequence = calendar_sequence + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND organization_id = ? AND status IN ('pending', 'in_progress')`, task.ID, task.OrganizationID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if affected == 1 {
+		task.Status = "overdue"
+	}
+	return affected == 1, err
+}
+
+func (r *Repository) GetMaintenanceTask(id, orgID uint) (*MaintenanceTask, error) {
+	task := &MaintenanceTask{}
+	err := r.QueryRow(`SELECT id, organization_id, maintenance_plan_id, asset_id, scheduled_date, status, completed_date, notes, created_at, updated_at 
+		FROM maintenance_tasks WHERE id = ? AND organization_id = ?`, id, orgID).
+		Scan(&task.ID, &task.OrganizationID, &task.MaintenancePlanID, &task.AssetID, &task.ScheduledDate, &task.Status, &task.CompletedDate, &task.Notes, &task.CreatedAt, &task.UpdatedAt)
+	return task, err
+}
+
+func (r *Repository) CompleteMaintenanceTask(task *MaintenanceTask, completed time.Time) (bool, error) {
+	result, err := r.Exec(`UPDATE maintenance_tasks SET status = 'completed', completed_date = ?, notes = COALESCE(?, notes), calendar_sequence = calendar_sequence + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND organization_id = ? AND status != 'completed'`, completed, task.Notes, task.ID, task.OrganizationID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if affected == 1 {
+		task.Status = "completed"
+		task.CompletedDate = &completed
+	}
+	if err != nil || affected == 0 {
+		return false, err
+	}
+	_, err = r.Exec(`UPDATE maintenance_plans SET last_maintenance_date = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND organization_id = ? AND (last_maintenance_date IS NULL OR last_maintenance_date < ?)`, completed, task.MaintenancePlanID, task.OrganizationID, completed)
+	return true, err
+}
+
 func (r *Repository) UpdateMaintenanceTask(task *MaintenanceTask) error {
-	_, err := r.Exec(`UPDATE maintenance_tasks SET status = ?, completed_date = ?, notes = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
-		task.Status, task.CompletedDate, task.Notes, task.ID)
+	_, err := r.Exec(`UPDATE maintenance_tasks SET status = ?, completed_date = ?, notes = ?, calendar_sequence = calendar_sequence + 1, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND organization_id = ?`,
+		task.Status, task.CompletedDate, task.Notes, task.ID, task.OrganizationID)
 	return err
 }
 
-func (r *Repository) GetMaintenancePlansDue(orgID uint) ([]MaintenancePlan, error) {
-	today := time.Now().Format("2006-01-02")
-	rows, err := r.Query(`SELECT id, organization_id, asset_id, frequency_days, estimated_duration_hours, assigned_role, last_maintenance_date, next_maintenance_date, created_at, updated_at 
-		FROM maintenance_plans WHERE organization_id = ? AND next_maintenance_date <= ?`, orgID, today)
+func (r *Repository) GetMaintenancePlansDue(orgID uint, through time.Time) ([]MaintenancePlan, error) {
+	rows, err := r.Query(`SELECT `+maintenancePlanColumns+`
+		FROM maintenance_plans WHERE organization_id = ? AND next_maintenance_date < ?`, orgID, through.AddDate(0, 0, 1).Format(calendar.DateLayout))
 	if err != nil {
 		return nil, err
 	}
@@ -231,24 +358,48 @@ func (r *Repository) GetMaintenancePlansDue(orgID uint) ([]MaintenancePlan, erro
 
 	var plans []MaintenancePlan
 	for rows.Next() {
-		var plan MaintenancePlan
-		if err := rows.Scan(&plan.ID, &plan.OrganizationID, &plan.AssetID, &plan.FrequencyDays, &plan.EstimatedDurationHours, &plan.AssignedRole, &plan.LastMaintenanceDate, &plan.NextMaintenanceDate, &plan.CreatedAt, &plan.UpdatedAt); err != nil {
+		plan, err := scanMaintenancePlan(rows)
+		if err != nil {
 			return nil, err
 		}
-		plans = append(plans, plan)
+		plans = append(plans, *plan)
 	}
 	return plans, nil
 }
 
-func (r *Repository) UpdateMaintenancePlanNextDate(planID uint, lastDate, nextDate time.Time) error {
-	_, err := r.Exec(`UPDATE maintenance_plans SET last_maintenance_date = ?, next_maintenance_date = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, lastDate, nextDate, planID)
+func (r *Repository) AdvanceMaintenancePlan(plan *MaintenancePlan, next time.Time) (bool, error) {
+	result, err := r.Exec(`UPDATE maintenance_plans SET next_maintenance_date = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND organization_id = ? AND date(next_maintenance_date) = ?`, next, plan.ID, plan.OrganizationID, plan.NextMaintenanceDate.UTC().Format(calendar.DateLayout))
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if affected == 1 {
+		plan.NextMaintenanceDate = next
+	}
+	return affected == 1, err
+}
+
+func (r *Repository) UpdateMaintenancePlanNextDate(planID, orgID uint, lastDate, nextDate time.Time) error {
+	_, err := r.Exec(`UPDATE maintenance_plans SET last_maintenance_date = ?, next_maintenance_date = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND organization_id = ?`, lastDate, nextDate, planID, orgID)
 	return err
 }
 
+const workOrderColumns = `id, organization_id, asset_id, technician_id, maintenance_task_id, procedure_id, source_work_order_id, title, description, status, priority, estimated_duration_hours, scheduled_start, scheduled_end, actual_start, actual_end, total_cost, notes, created_by, created_at, updated_at`
+
+func scanWorkOrder(row rowScanner) (*WorkOrder, error) {
+	var wo WorkOrder
+	if err := row.Scan(&wo.ID, &wo.OrganizationID, &wo.AssetID, &wo.TechnicianID, &wo.MaintenanceTaskID, &wo.ProcedureID, &wo.SourceWorkOrderID, &wo.Title, &wo.Description, &wo.Status, &wo.Priority, &wo.EstimatedDurationHours,
+		&wo.ScheduledStart, &wo.ScheduledEnd, &wo.ActualStart, &wo.ActualEnd, &wo.TotalCost, &wo.Notes, &wo.CreatedBy, &wo.CreatedAt, &wo.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &wo, nil
+}
+
 func (r *Repository) CreateWorkOrder(wo *WorkOrder) error {
-	result, err := r.Exec(`INSERT INTO work_orders (organization_id, asset_id, technician_id, title, description, status, priority, scheduled_start, scheduled_end, notes, created_by) 
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		wo.OrganizationID, wo.AssetID, wo.TechnicianID, wo.Title, wo.Description, wo.Status, wo.Priority, wo.ScheduledStart, wo.ScheduledEnd, wo.Notes, wo.CreatedBy)
+	result, err := r.Exec(`INSERT INTO work_orders (organization_id, asset_id, technician_id, maintenance_task_id, procedure_id, source_work_order_id, title, description, status, priority, estimated_duration_hours, scheduled_start, scheduled_end, notes, created_by) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		wo.OrganizationID, wo.AssetID, wo.TechnicianID, wo.MaintenanceTaskID, wo.ProcedureID, wo.SourceWorkOrderID, wo.Title, wo.Description, wo.Status, wo.Priority, wo.EstimatedDurationHours, utc(wo.ScheduledStart), utc(wo.ScheduledEnd), wo.Notes, wo.CreatedBy)
 	if err != nil {
 		return err
 	}
@@ -258,11 +409,12 @@ func (r *Repository) CreateWorkOrder(wo *WorkOrder) error {
 }
 
 func (r *Repository) GetWorkOrder(id, orgID uint) (*WorkOrder, error) {
-	wo := &WorkOrder{}
-	err := r.QueryRow(`SELECT id, organization_id, asset_id, technician_id, title, description, status, priority, scheduled_start, scheduled_end, actual_start, actual_end, total_cost, notes, created_by, created_at, updated_at 
-		FROM work_orders WHERE id = ? AND organization_id = ?`, id, orgID).
-		Scan(&wo.ID, &wo.OrganizationID, &wo.AssetID, &wo.TechnicianID, &wo.Title, &wo.Description, &wo.Status, &wo.Priority, &wo.ScheduledStart, &wo.ScheduledEnd, &wo.ActualStart, &wo.ActualEnd, &wo.TotalCost, &wo.Notes, &wo.CreatedBy, &wo.CreatedAt, &wo.UpdatedAt)
-	return wo, err
+	return scanWorkOrder(r.QueryRow(`SELECT `+workOrderColumns+` FROM work_orders WHERE id = ? AND organization_id = ?`, id, orgID))
+}
+
+func (r *Repository) GetOpenWorkOrderForTask(taskID, orgID uint) (*WorkOrder, error) {
+	return scanWorkOrder(r.QueryRow(`SELECT `+workOrderColumns+` FROM work_orders
+		WHERE maintenance_task_id = ? AND organization_id = ? AND status NOT IN ('completed', 'closed') ORDER BY id LIMIT 1`, taskID, orgID))
 }
 
 func (r *Repository) ListWorkOrders(orgID uint, page, pageSize int, status string) ([]WorkOrder, int, error) {
@@ -280,8 +432,7 @@ func (r *Repository) ListWorkOrders(orgID uint, page, pageSize int, status strin
 		return nil, 0, err
 	}
 
-	query = `SELECT id, organization_id, asset_id, technician_id, title, description, status, priority, scheduled_start, scheduled_end, actual_start, actual_end, total_cost, notes, created_by, created_at, updated_at 
-		FROM work_orders WHERE organization_id = ?`
+	query = `SELECT ` + workOrderColumns + ` FROM work_orders WHERE organization_id = ?`
 	args = []interface{}{orgID}
 	if status != "" {
 		query += ` AND status = ?`
@@ -298,24 +449,30 @@ func (r *Repository) ListWorkOrders(orgID uint, page, pageSize int, status strin
 
 	var orders []WorkOrder
 	for rows.Next() {
-		var wo WorkOrder
-		if err := rows.Scan(&wo.ID, &wo.OrganizationID, &wo.AssetID, &wo.TechnicianID, &wo.Title, &wo.Description, &wo.Status, &wo.Priority, &wo.ScheduledStart, &wo.ScheduledEnd, &wo.ActualStart, &wo.ActualEnd, &wo.TotalCost, &wo.Notes, &wo.CreatedBy, &wo.CreatedAt, &wo.UpdatedAt); err != nil {
+		wo, err := scanWorkOrder(rows)
+		if err != nil {
 			return nil, 0, err
 		}
-		orders = append(orders, wo)
+		orders = append(orders, *wo)
 	}
 	return orders, count, nil
 }
 
 func (r *Repository) UpdateWorkOrder(wo *WorkOrder) error {
-	_, err := r.Exec(`UPDATE work_orders SET asset_id = ?, technician_id = ?, title = ?, description = ?, status = ?, priority = ?, scheduled_start = ?, scheduled_end = ?, actual_start = ?, actual_end = ?, total_cost = ?, notes = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND organization_id = ?`,
-		wo.AssetID, wo.TechnicianID, wo.Title, wo.Description, wo.Status, wo.Priority, wo.ScheduledStart, wo.ScheduledEnd, wo.ActualStart, wo.ActualEnd, wo.TotalCost, wo.Notes, wo.ID, wo.OrganizationID)
-	return err
+	result, err := r.Exec(`UPDATE work_orders SET asset_id = ?, technician_id = ?, procedure_id = ?, title = ?, description = ?, status = ?, priority = ?, estimated_duration_hours = ?, scheduled_start = ?, scheduled_end = ?, actual_start = ?, actual_end = ?, total_cost = ?, notes = ?, calendar_sequence = calendar_sequence + 1, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND organization_id = ?`,
+		wo.AssetID, wo.TechnicianID, wo.ProcedureID, wo.Title, wo.Description, wo.Status, wo.Priority, wo.EstimatedDurationHours, utc(wo.ScheduledStart), utc(wo.ScheduledEnd), wo.ActualStart, wo.ActualEnd, wo.TotalCost, wo.Notes, wo.ID, wo.OrganizationID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
 }
 
 func (r *Repository) DeleteWorkOrder(id, orgID uint) error {
-	_, err := r.Exec(`DELETE FROM work_orders WHERE id = ? AND organization_id = ?`, id, orgID)
-	return err
+	result, err := r.Exec(`DELETE FROM work_orders WHERE id = ? AND organization_id = ?`, id, orgID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
 }
 
 func (r *Repository) CreateWorkOrderPart(wop *WorkOrderPart) error {
@@ -329,8 +486,11 @@ func (r *Repository) CreateWorkOrderPart(wop *WorkOrderPart) error {
 	return nil
 }
 
-func (r *Repository) GetWorkOrderParts(woID uint) ([]WorkOrderPart, error) {
-	rows, err := r.Query(`SELECT id, work_order_id, part_id, quantity, unit_price, total_price, created_at FROM work_order_parts WHERE work_order_id = ?`, woID)
+func (r *Repository) GetWorkOrderParts(woID, orgID uint) ([]WorkOrderPart, error) {
+	rows, err := r.Query(`SELECT wop.id, wop.work_order_id, wop.part_id, wop.quantity, wop.unit_price, wop.total_price, wop.created_at 
+		FROM work_order_parts wop 
+		JOIN work_orders wo ON wop.work_order_id = wo.id 
+		WHERE wop.work_order_id = ? AND wo.organization_id = ?`, woID, orgID)
 	if err != nil {
 		return nil, err
 	}
@@ -457,7 +617,7 @@ func (r *Repository) CreateDepreciation(depr *AssetDepreciation) error {
 		depr.OrganizationID, depr.AssetID, depr.Year, depr.DepreciationAmount)
 	if err != nil {
 		if strings.Contains(err.Error(), "UNIQUE constraint") {
-			_, err = r.Exec(`UPDATE asset_depreciation SET depreciation_amount = ? WHERE asset_id = ? AND year = ?`, depr.DepreciationAmount, depr.AssetID, depr.Year)
+			_, err = r.Exec(`UPDATE asset_depreciation SET depreciation_amount = ? WHERE asset_id = ? AND year = ? AND organization_id = ?`, depr.DepreciationAmount, depr.AssetID, depr.Year, depr.OrganizationID)
 			return err
 		}
 		return err
@@ -497,7 +657,7 @@ func (r *Repository) GetAllAssetCosts(orgID uint) ([]struct {
 	TotalCost float64
 }, error) {
 	rows, err := r.Query(`SELECT a.id, a.name, COALESCE(SUM(wo.total_cost), 0) as total_cost 
-		FROM assets a LEFT JOIN work_orders wo ON a.id = wo.asset_id 
+		FROM assets a LEFT JOIN work_orders wo ON a.id = wo.asset_id AND wo.organization_id = a.organization_id 
 		WHERE a.organization_id = ? AND a.deleted_at IS NULL 
 		GROUP BY a.id, a.name`, orgID)
 	if err != nil {
@@ -586,11 +746,14 @@ func (r *Repository) GetDashboardStats(orgID uint) (map[string]interface{}, erro
 	}
 	stats["asset_count"] = assetCount
 
-	today := time.Now().Format("2006-01-02")
+	cutoff, err := r.overdueCutoff(orgID)
+	if err != nil {
+		return nil, err
+	}
 	var overdueCount int
 	err = r.QueryRow(`SELECT COUNT(*) FROM maintenance_tasks mt 
 		JOIN maintenance_plans mp ON mt.maintenance_plan_id = mp.id 
-		WHERE mp.organization_id = ? AND mt.scheduled_date < ? AND mt.status IN ('pending', 'in_progress')`, orgID, today).Scan(&overdueCount)
+		WHERE mt.organization_id = ? AND mp.organization_id = mt.organization_id AND mt.scheduled_date < ? AND mt.status IN ('pending', 'in_progress')`, orgID, cutoff).Scan(&overdueCount)
 	if err != nil {
 		return nil, err
 	}
@@ -613,8 +776,16 @@ func (r *Repository) GetDashboardStats(orgID uint) (map[string]interface{}, erro
 	return stats, nil
 }
 
-func (r *Repository) GetTechnicians(orgID uint) ([]User, error) {
-	rows, err := r.Query(`SELECT id, organization_id, email, full_name, role, created_at, updated_at FROM users WHERE organization_id = ? AND role = 'technician'`, orgID)
+func (r *Repository) overdueCutoff(orgID uint) (string, error) {
+	cal, err := r.GetBusinessCalendar(orgID)
+	if err != nil {
+		return "", err
+	}
+	return cal.OverdueCutoff(time.Now()).Format(calendar.DateLayout), nil
+}
+
+func (r *Repository) ListUsersByRole(orgID uint, role string) ([]User, error) {
+	rows, err := r.Query(`SELECT id, organization_id, email, full_name, role, created_at, updated_at FROM users WHERE organization_id = ? AND role = ? ORDER BY id`, orgID, role)
 	if err != nil {
 		return nil, err
 	}
@@ -630,3 +801,28 @@ func (r *Repository) GetTechnicians(orgID uint) ([]User, error) {
 	}
 	return users, nil
 }
+
+type TechnicianLoad struct {
+	UserID         uint    `json:"user_id"`
+	OpenWorkOrders int     `json:"open_work_orders"`
+	OpenHours      float64 `json:"open_hours"`
+}
+
+func (r *Repository) GetOpenWorkOrderLoad(orgID uint) (map[uint]TechnicianLoad, error) {
+	rows, err := r.Query(`SELECT technician_id, COUNT(*), COALESCE(SUM(estimated_duration_hours), 0) FROM work_orders
+		WHERE organization_id = ? AND technician_id IS NOT NULL AND status IN ('pending', 'in_progress') GROUP BY technician_id`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	loads := make(map[uint]TechnicianLoad)
+	for rows.Next() {
+		var load TechnicianLoad
+		if err := rows.Scan(&load.UserID, &load.OpenWorkOrders, &load.OpenHours); err != nil {
+			return nil, err
+		}
+		loads[load.UserID] = load
+	}
+	return loads, rows.Err()
+}
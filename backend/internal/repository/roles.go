@@ -0,0 +1,140 @@
+package repository
+
+func (r *Repository) CreateRole(role *Role) error {
+	tx, err := r.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO roles (organization_id, name, description) VALUES (?, ?, ?)`,
+		role.OrganizationID, role.Name, role.Description)
+	if err != nil {
+		return err
+	}
+	id, _ := result.LastInsertId()
+
+	for _, permission := range role.Permissions {
+		if _, err := tx.Exec(`INSERT INTO role_permissions (role_id, permission) VALUES (?, ?)`, id, permission); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	role.ID = uint(id)
+	return nil
+}
+
+func (r *Repository) GetRole(id, orgID uint) (*Role, error) {
+	role := &Role{}
+	err := r.QueryRow(`SELECT id, organization_id, name, description, created_at, updated_at FROM roles WHERE id = ? AND organization_id = ?`, id, orgID).
+		Scan(&role.ID, &role.OrganizationID, &role.Name, &role.Description, &role.CreatedAt, &role.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	role.Permissions, err = r.getRolePermissions(role.ID)
+	return role, err
+}
+
+func (r *Repository) GetRoleByName(orgID uint, name string) (*Role, error) {
+	role := &Role{}
+	err := r.QueryRow(`SELECT id, organization_id, name, description, created_at, updated_at FROM roles WHERE organization_id = ? AND name = ?`, orgID, name).
+		Scan(&role.ID, &role.OrganizationID, &role.Name, &role.Description, &role.CreatedAt, &role.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	role.Permissions, err = r.getRolePermissions(role.ID)
+	return role, err
+}
+
+func (r *Repository) ListRoles(orgID uint) ([]Role, error) {
+	rows, err := r.Query(`SELECT id, organization_id, name, description, created_at, updated_at FROM roles WHERE organization_id = ? ORDER BY name ASC`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []Role
+	for rows.Next() {
+		var role Role
+		if err := rows.Scan(&role.ID, &role.OrganizationID, &role.Name, &role.Description, &role.CreatedAt, &role.UpdatedAt); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range roles {
+		if roles[i].Permissions, err = r.getRolePermissions(roles[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return roles, nil
+}
+
+func (r *Repository) UpdateRole(role *Role) error {
+	tx, err := r.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldName string
+	if err := tx.QueryRow(`SELECT name FROM roles WHERE id = ? AND organization_id = ?`, role.ID, role.OrganizationID).Scan(&oldName); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE roles SET name = ?, description = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND organization_id = ?`,
+		role.Name, role.Description, role.ID, role.OrganizationID); err != nil {
+		return err
+	}
+	if oldName != role.Name {
+		if _, err := tx.Exec(`UPDATE users SET role = ?, updated_at = CURRENT_TIMESTAMP WHERE organization_id = ? AND role = ?`,
+			role.Name, role.OrganizationID, oldName); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM role_permissions WHERE role_id = ?`, role.ID); err != nil {
+		return err
+	}
+	for _, permission := range role.Permissions {
+		if _, err := tx.Exec(`INSERT INTO role_permissions (role_id, permission) VALUES (?, ?)`, role.ID, permission); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) DeleteRole(id, orgID uint) error {
+	_, err := r.Exec(`DELETE FROM roles WHERE id = ? AND organization_id = ?`, id, orgID)
+	return err
+}
+
+func (r *Repository) CountUsersWithRole(orgID uint, role string) (int, error) {
+	var count int
+	err := r.QueryRow(`SELECT COUNT(*) FROM users WHERE organization_id = ? AND role = ?`, orgID, role).Scan(&count)
+	return count, err
+}
+
+func (r *Repository) getRolePermissions(roleID uint) ([]string, error) {
+	rows, err := r.Query(`SELECT permission FROM role_permissions WHERE role_id = ? ORDER BY permission ASC`, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []string{}
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, rows.Err()
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+)
+
+func (r *Repository) GetLoginThrottle(key string) (*LoginThrottle, error) {
+	throttle := &LoginThrottle{Key: key}
+	err := r.QueryRow(`SELECT failures, last_failure_at, locked_until FROM login_throttles WHERE key = ?`, key).
+		Scan(&throttle.Failures, &throttle.LastFailureAt, &throttle.LockedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return throttle, nil
+	}
+	return throttle, err
+}
+
+// CountLoginFailure saves throttle only if the stored failure count is still
+// previous, so concurrent attempts cannot both count the same failure.
+func (r *Repository) CountLoginFailure(throttle *LoginThrottle, previous int) (bool, error) {
+	result, err := r.Exec(`INSERT INTO login_throttles (key, failures, last_failure_at, locked_until) VALUES (?, ?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET failures = excluded.failures, last_failure_at = excluded.last_failure_at, locked_until = excluded.locked_until
+		WHERE login_throttles.failures = ?`,
+		throttle.Key, throttle.Failures, throttle.LastFailureAt, throttle.LockedUntil, previous)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+// ForgiveLoginFailure takes back one failure counted against key, lifting its
+// lock if that brings it below maxFailures.
+func (r *Repository) ForgiveLoginFailure(key string, maxFailures int) error {
+	_, err := r.Exec(`UPDATE login_throttles SET failures = MAX(failures - 1, 0),
+		locked_until = CASE WHEN failures - 1 < ? THEN NULL ELSE locked_until END WHERE key = ?`, maxFailures, key)
+	return err
+}
+
+func (r *Repository) DeleteLoginThrottle(key string) error {
+	_, err := r.Exec(`DELETE FROM login_throttles WHERE key = ?`, key)
+	return err
+}
+
+func (r *Repository) CreateSecurityEvent(event *SecurityEvent) error {
+	result, err := r.Exec(`INSERT INTO security_events (organization_id, user_id, email, event_type, ip_address, details) VALUES (?, ?, ?, ?, ?, ?)`,
+		event.OrganizationID, event.UserID, event.Email, event.EventType, event.IPAddress, event.Details)
+	if err != nil {
+		return err
+	}
+	id, _ := result.LastInsertId()
+	event.ID = uint(id)
+	return nil
+}
+
+func (r *Repository) ListSecurityEvents(orgID uint, page, pageSize int, eventType string) ([]SecurityEvent, int, error) {
+	offset := (page - 1) * pageSize
+
+	where := ` WHERE 1 = 1`
+	args := []interface{}{}
+	if orgID != 0 {
+		where += ` AND organization_id = ?`
+		args = append(args, orgID)
+	}
+	if eventType != "" {
+		where += ` AND event_type = ?`
+		args = append(args, eventType)
+	}
+
+	var count int
+	if err := r.QueryRow(`SELECT COUNT(*) FROM security_events`+where, args...).Scan(&count); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.Query(`SELECT id, organization_id, user_id, email, event_type, ip_address, details, created_at FROM security_events`+where+
+		` ORDER BY id DESC LIMIT ? OFFSET ?`, append(args, pageSize, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var events []SecurityEvent
+	for rows.Next() {
+		var event SecurityEvent
+		if err := rows.Scan(&event.ID, &event.OrganizationID, &event.UserID, &event.Email, &event.EventType, &event.IPAddress, &event.Details, &event.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		events = append(events, event)
+	}
+	return events, count, nil
+}
+
+func (r *Repository) HasSecurityEvent(userID uint, eventType, ip string) (bool, error) {
+	var exists bool
+	err := r.QueryRow(`SELECT EXISTS(SELECT 1 FROM security_events WHERE user_id = ? AND event_type = ? AND ip_address = ?)`, userID, eventType, ip).Scan(&exists)
+	return exists, err
+}
+
+func (r *Repository) CountSecurityEvents(userID uint, eventType string) (int, error) {
+	var count int
+	err := r.QueryRow(`SELECT COUNT(*) FROM security_events WHERE user_id = ? AND event_type = ?`, userID, eventType).Scan(&count)
+	return count, err
+}
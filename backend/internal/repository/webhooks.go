@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const webhookColumns = `id, organization_id, url, secret, event_types, description, active, created_at, updated_at`
+
+const webhookDeliveryColumns = `id, webhook_id, organization_id, event_type, payload, status, attempts, next_attempt_at, last_attempt_at,
+	response_code, error, created_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWebhook(row rowScanner) (*Webhook, error) {
+	webhook := &Webhook{}
+	var eventTypes *string
+	if err := row.Scan(&webhook.ID, &webhook.OrganizationID, &webhook.URL, &webhook.Secret, &eventTypes,
+		&webhook.Description, &webhook.Active, &webhook.CreatedAt, &webhook.UpdatedAt); err != nil {
+		return nil, err
+	}
+	webhook.EventTypes = []string{}
+	if eventTypes != nil {
+		json.Unmarshal([]byte(*eventTypes), &webhook.EventTypes)
+	}
+	return webhook, nil
+}
+
+func scanWebhookDelivery(row rowScanner) (*WebhookDelivery, error) {
+	delivery := &WebhookDelivery{}
+	var payload string
+	if err := row.Scan(&delivery.ID, &delivery.WebhookID, &delivery.OrganizationID, &delivery.EventType, &payload,
+		&delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastAttemptAt,
+		&delivery.ResponseCode, &delivery.Error, &delivery.CreatedAt); err != nil {
+		return nil, err
+	}
+	delivery.Payload = json.RawMessage(payload)
+	return delivery, nil
+}
+
+func (r *Repository) CreateWebhook(webhook *Webhook) error {
+	eventTypes, _ := json.Marshal(webhook.EventTypes)
+	now := time.Now().UTC()
+	result, err := r.Exec(`INSERT INTO webhooks (organization_id, url, secret, event_types, description, active, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		webhook.OrganizationID, webhook.URL, webhook.Secret, string(eventTypes), webhook.Description, webhook.Active, now, now)
+	if err != nil {
+		return err
+	}
+	id, _ := result.LastInsertId()
+	webhook.ID = uint(id)
+	webhook.CreatedAt = now
+	webhook.UpdatedAt = now
+	return nil
+}
+
+func (r *Repository) GetWebhook(id, orgID uint) (*Webhook, error) {
+	return scanWebhook(r.QueryRow(`SELECT `+webhookColumns+` FROM webhooks WHERE id = ? AND organization_id = ?`, id, orgID))
+}
+
+func (r *Repository) ListWebhooks(orgID uint) ([]Webhook, error) {
+	rows, err := r.Query(`SELECT `+webhookColumns+` FROM webhooks WHERE organization_id = ? ORDER BY id`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+	return webhooks, nil
+}
+
+func (r *Repository) ListActiveWebhooks(orgID uint) ([]Webhook, error) {
+	rows, err := r.Query(`SELECT `+webhookColumns+` FROM webhooks WHERE organization_id = ? AND active = 1`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+	return webhooks, nil
+}
+
+func (r *Repository) UpdateWebhook(webhook *Webhook) error {
+	eventTypes, _ := json.Marshal(webhook.EventTypes)
+	result, err := r.Exec(`UPDATE webhooks SET url = ?, event_types = ?, description = ?, active = ?, updated_at = ? WHERE id = ? AND organization_id = ?`,
+		webhook.URL, string(eventTypes), webhook.Description, webhook.Active, time.Now().UTC(), webhook.ID, webhook.OrganizationID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (r *Repository) DeleteWebhook(id, orgID uint) error {
+	result, err := r.Exec(`DELETE FROM webhooks WHERE id = ? AND organization_id = ?`, id, orgID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (r *Repository) CreateWebhookDelivery(delivery *WebhookDelivery) error {
+	now := time.Now().UTC().Truncate(time.Second)
+	delivery.Status = "pending"
+	delivery.NextAttemptAt = &now
+	result, err := r.Exec(`INSERT INTO webhook_deliveries (webhook_id, organization_id, event_type, payload, status, next_attempt_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		delivery.WebhookID, delivery.OrganizationID, delivery.EventType, string(delivery.Payload), delivery.Status, now, now)
+	if err != nil {
+		return err
+	}
+	id, _ := result.LastInsertId()
+	delivery.ID = uint(id)
+	delivery.CreatedAt = now
+	return nil
+}
+
+func (r *Repository) GetWebhookDelivery(id, orgID uint) (*WebhookDelivery, error) {
+	return scanWebhookDelivery(r.QueryRow(`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries WHERE id = ? AND organization_id = ?`, id, orgID))
+}
+
+func (r *Repository) ListWebhookDeliveries(webhookID, orgID uint, page, pageSize int) ([]WebhookDelivery, int, error) {
+	offset := (page - 1) * pageSize
+
+	var count int
+	if err := r.QueryRow(`SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = ? AND organization_id = ?`, webhookID, orgID).Scan(&count); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.Query(`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries WHERE webhook_id = ? AND organization_id = ? ORDER BY id DESC LIMIT ? OFFSET ?`,
+		webhookID, orgID, pageSize, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	return deliveries, count, nil
+}
+
+func (r *Repository) ListDueWebhookDeliveries(now time.Time, limit int) ([]WebhookDelivery, error) {
+	rows, err := r.Query(`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries WHERE status = 'pending' AND next_attempt_at <= ? ORDER BY next_attempt_at LIMIT ?`,
+		now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	return deliveries, nil
+}
+
+func (r *Repository) ClaimWebhookDelivery(id uint, now, leaseUntil time.Time) (bool, error) {
+	result, err := r.Exec(`UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ? AND status = 'pending' AND next_attempt_at <= ?`,
+		leaseUntil, id, now)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+func (r *Repository) RecordWebhookAttempt(delivery *WebhookDelivery) error {
+	_, err := r.Exec(`UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?, last_attempt_at = ?, response_code = ?, response_body = NULL, error = ? WHERE id = ?`,
+		delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.LastAttemptAt, delivery.ResponseCode, delivery.Error, delivery.ID)
+	return err
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"encoding/json"
+
+	"assetsentinel/internal/events"
+	"assetsentinel/internal/repository"
+)
+
+type AuditTrail struct {
+	repo *repository.Repository
+}
+
+func NewAuditTrail(repo *repository.Repository) *AuditTrail {
+	return &AuditTrail{repo: repo}
+}
+
+func (a *AuditTrail) Consume(event *events.Envelope) error {
+	values, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	newValues := string(values)
+	table, recordID := event.Data.Subject()
+	return a.repo.CreateAuditLog(&repository.AuditLog{
+		OrganizationID: event.OrganizationID,
+		UserID:         event.Actor.UserID,
+		TableName:      table,
+		RecordID:       recordID,
+		Action:         event.Type,
+		NewValues:      &newValues,
+	})
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"assetsentinel/internal/calendar"
+	"assetsentinel/internal/repository"
+)
+
+var ErrInvalidCalendar = errors.New("invalid business calendar")
+
+type CalendarService struct {
+	repo *repository.Repository
+}
+
+func NewCalendarService(repo *repository.Repository) *CalendarService {
+	return &CalendarService{repo: repo}
+}
+
+func (s *CalendarService) Get(orgID uint) (*repository.BusinessCalendar, error) {
+	return s.repo.GetBusinessCalendarSettings(orgID)
+}
+
+func (s *CalendarService) Update(orgID uint, workingDays []int, dailyHours *float64) (*repository.BusinessCalendar, error) {
+	if len(workingDays) == 0 {
+		return nil, fmt.Errorf("%w: at least one working day is required", ErrInvalidCalendar)
+	}
+	seen := make(map[int]bool)
+	days := make([]int, 0, len(workingDays))
+	for _, day := range workingDays {
+		if day < int(time.Sunday) || day > int(time.Saturday) {
+			return nil, fmt.Errorf("%w: working days must be between 0 (Sunday) and 6 (Saturday)", ErrInvalidCalendar)
+		}
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+	current, err := s.repo.GetBusinessCalendarSettings(orgID)
+	if err != nil {
+		return nil, err
+	}
+	hours := current.DailyHours
+	if dailyHours != nil {
+		if *dailyHours <= 0 || *dailyHours > 24 {
+			return nil, fmt.Errorf("%w: daily_hours must be more than 0 and at most 24", ErrInvalidCalendar)
+		}
+		hours = *dailyHours
+	}
+	if err := s.repo.SaveBusinessCalendar(orgID, days, hours); err != nil {
+		return nil, err
+	}
+	return s.repo.GetBusinessCalendarSettings(orgID)
+}
+
+func (s *CalendarService) Holidays(orgID uint) ([]repository.Holiday, error) {
+	return s.repo.ListHolidays(orgID)
+}
+
+func (s *CalendarService) AddHoliday(holiday *repository.Holiday) error {
+	date, err := time.Parse(calendar.DateLayout, holiday.Date)
+	if err != nil {
+		return fmt.Errorf("%w: date must be formatted as YYYY-MM-DD", ErrInvalidCalendar)
+	}
+	holiday.Date = date.Format(calendar.DateLayout)
+	return s.repo.SaveHoliday(holiday)
+}
+
+func (s *CalendarService) DeleteHoliday(id, orgID uint) error {
+	return s.repo.DeleteHoliday(id, orgID)
+}
@@ -0,0 +1,385 @@
+package services
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"assetsentinel/internal/calendar"
+	"assetsentinel/internal/ical"
+	"assetsentinel/internal/rbac"
+	"assetsentinel/internal/repository"
+)
+
+const (
+	FeedScopeUser         = "user"
+	FeedScopeOrganization = "organization"
+
+	// Feeds keep events from the last feedHistoryDays days, and the
+	// organization feed projects plan occurrences feedHorizonDays ahead.
+	feedHistoryDays = 30
+	feedHorizonDays = 90
+	feedRefresh     = time.Hour
+	feedProductID   = "-//AssetSentinel//Maintenance Calendar//EN"
+	feedUIDDomain   = "assetsentinel"
+)
+
+var (
+	ErrInvalidCalendarFeed   = errors.New("invalid calendar feed")
+	ErrCalendarFeedForbidden = errors.New("not permitted to subscribe to this calendar feed")
+)
+
+var feedPermissions = map[string][]string{
+	FeedScopeUser:         {rbac.WorkOrdersRead},
+	FeedScopeOrganization: {rbac.WorkOrdersRead, rbac.MaintenanceRead},
+}
+
+type CalendarFeedService struct {
+	repo  *repository.Repository
+	roles *RoleService
+}
+
+func NewCalendarFeedService(repo *repository.Repository, roles *RoleService) *CalendarFeedService {
+	return &CalendarFeedService{repo: repo, roles: roles}
+}
+
+func (s *CalendarFeedService) List(orgID, userID uint) ([]repository.CalendarFeed, error) {
+	return s.repo.ListCalendarFeeds(orgID, userID)
+}
+
+// Create issues a feed for the user and sets its Token, which is only stored
+// hashed and cannot be retrieved again.
+func (s *CalendarFeedService) Create(feed *repository.CalendarFeed, role string) error {
+	if feed.Scope == "" {
+		feed.Scope = FeedScopeUser
+	}
+	if _, ok := feedPermissions[feed.Scope]; !ok {
+		return fmt.Errorf("%w: scope must be user or organization", ErrInvalidCalendarFeed)
+	}
+	if err := s.authorize(feed.OrganizationID, role, feed.Scope); err != nil {
+		return err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	feed.Token = hex.EncodeToString(raw)
+	return s.repo.CreateCalendarFeed(feed, hashToken(feed.Token))
+}
+
+func (s *CalendarFeedService) Revoke(id, orgID, userID uint) error {
+	return s.repo.RevokeCalendarFeed(id, orgID, userID)
+}
+
+func (s *CalendarFeedService) authorize(orgID uint, role, scope string) error {
+	permissions, err := s.roles.ResolvePermissions(orgID, role)
+	if err != nil {
+		return ErrCalendarFeedForbidden
+	}
+	granted := map[string]bool{}
+	for _, permission := range permissions {
+		granted[permission] = true
+	}
+	for _, required := range feedPermissions[scope] {
+		if !granted[required] {
+			return ErrCalendarFeedForbidden
+		}
+	}
+	return nil
+}
+
+// Render builds the calendar behind a feed token. The owner's permissions are
+// checked on every request, so a feed stops working when they lose access.
+func (s *CalendarFeedService) Render(token string) (*ical.Calendar, error) {
+	feed, err := s.repo.GetCalendarFeedByTokenHash(hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	owner, err := s.repo.GetUserByID(feed.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if owner.OrganizationID != feed.OrganizationID {
+		return nil, sql.ErrNoRows
+	}
+	if err := s.authorize(feed.OrganizationID, owner.Role, feed.Scope); err != nil {
+		return nil, err
+	}
+	org, err := s.repo.GetOrganization(feed.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	cal, err := s.repo.GetBusinessCalendar(feed.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	since := cal.Today(now).AddDate(0, 0, -feedHistoryDays)
+	var technicianID, cancelledFor *uint
+	var role *string
+	name := org.Name + " maintenance"
+	if feed.Scope == FeedScopeUser {
+		technicianID, cancelledFor, role = &feed.UserID, &feed.UserID, &owner.Role
+		name = owner.FullName + " maintenance"
+	}
+
+	entries, err := s.repo.ListWorkOrderCalendarEntries(feed.OrganizationID, technicianID, since)
+	if err != nil {
+		return nil, err
+	}
+	// The user feed has the tasks and occurrences of the plans assigned to the
+	// owner's role until a work order takes them to someone.
+	tasks, err := s.repo.ListTaskCalendarEntries(feed.OrganizationID, role, since)
+	if err != nil {
+		return nil, err
+	}
+	projected, err := s.projectedEntries(cal, feed.OrganizationID, role, cal.Today(now).AddDate(0, 0, feedHorizonDays))
+	if err != nil {
+		return nil, err
+	}
+	// Projected occurrences share their UID with the task generated for
+	// them, so a task that already exists replaces its projection.
+	entries = append(append(entries, tasks...), projected...)
+
+	result := &ical.Calendar{Name: name, ProductID: feedProductID, RefreshRate: feedRefresh, Events: []ical.Event{}}
+	published := map[string]bool{}
+	for _, entry := range entries {
+		event := calendarEvent(entry)
+		if published[event.UID] {
+			continue
+		}
+		published[event.UID] = true
+		result.Events = append(result.Events, event)
+	}
+
+	cancellations, err := s.repo.ListCalendarCancellations(feed.OrganizationID, cancelledFor, now.UTC().AddDate(0, 0, -feedHistoryDays))
+	if err != nil {
+		return nil, err
+	}
+	for _, cancellation := range cancellations {
+		if published[cancellation.UID] {
+			continue
+		}
+		published[cancellation.UID] = true
+		result.Events = append(result.Events, ical.Event{
+			UID:          cancellation.UID,
+			Sequence:     cancellation.Sequence,
+			Summary:      cancellation.Summary,
+			Start:        cancellation.StartsAt,
+			End:          cancellation.EndsAt,
+			AllDay:       cancellation.AllDay,
+			Status:       ical.StatusCancelled,
+			LastModified: cancellation.CancelledAt,
+		})
+	}
+
+	if err := s.repo.TouchCalendarFeed(feed.ID, now.UTC()); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// projectedEntries returns the plan occurrences the scheduler has not turned
+// into tasks yet, up to through, or only those of plans assigned to role when
+// it is set.
+func (s *CalendarFeedService) projectedEntries(cal *calendar.Calendar, orgID uint, role *string, through time.Time) ([]repository.CalendarEntry, error) {
+	plans, err := s.repo.GetMaintenancePlansDue(orgID, through.AddDate(0, 0, calendar.MaxShiftDays))
+	if err != nil {
+		return nil, err
+	}
+	entries := []repository.CalendarEntry{}
+	for i := range plans {
+		if role != nil && (plans[i].AssignedRole == nil || *plans[i].AssignedRole != *role) {
+			continue
+		}
+		planEntries, err := planCalendarEntries(s.repo, cal, &plans[i], through)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, planEntries...)
+	}
+	return entries, nil
+}
+
+func planCalendarEntries(repo *repository.Repository, cal *calendar.Calendar, plan *repository.MaintenancePlan, through time.Time) ([]repository.CalendarEntry, error) {
+	asset, err := repo.GetAsset(plan.AssetID, plan.OrganizationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	dates, err := planOccurrencesBetween(cal, plan, cal.Shift(plan.NextMaintenanceDate, plan.NonWorkingDayShift), through)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]repository.CalendarEntry, 0, len(dates))
+	for _, date := range dates {
+		date := date
+		entries = append(entries, repository.CalendarEntry{
+			MaintenancePlanID:      &plan.ID,
+			AssetName:              asset.Name,
+			AssetLocation:          asset.Location,
+			Status:                 "scheduled",
+			ScheduledDate:          &date,
+			EstimatedDurationHours: plan.EstimatedDurationHours,
+			UpdatedAt:              plan.UpdatedAt,
+		})
+	}
+	return entries, nil
+}
+
+// calendarEvent turns a feed entry into its VEVENT. Work orders with a
+// scheduled start are timed events; tasks, projected occurrences and work
+// orders dated by their task are all-day events.
+func calendarEvent(entry repository.CalendarEntry) ical.Event {
+	event := ical.Event{Sequence: entry.Sequence, Status: ical.StatusConfirmed, LastModified: entry.UpdatedAt}
+	location := entry.AssetName
+	if entry.AssetLocation != nil && *entry.AssetLocation != "" {
+		location += " (" + *entry.AssetLocation + ")"
+	}
+	event.Location = location
+
+	var lines []string
+	if entry.WorkOrderID != nil {
+		event.UID = fmt.Sprintf("work-order-%d@%s", *entry.WorkOrderID, feedUIDDomain)
+		event.Summary = entry.Title
+		lines = append(lines, fmt.Sprintf("Work order #%d", *entry.WorkOrderID), "Status: "+entry.Status, "Priority: "+entry.Priority)
+	} else {
+		event.UID = fmt.Sprintf("maintenance-%d-%s@%s", *entry.MaintenancePlanID, entry.ScheduledDate.Format("20060102"), feedUIDDomain)
+		event.Summary = "Maintenance: " + entry.AssetName
+		if entry.MaintenanceTaskID != nil {
+			lines = append(lines, fmt.Sprintf("Maintenance task #%d", *entry.MaintenanceTaskID), "Status: "+entry.Status)
+		} else {
+			event.Status = ical.StatusTentative
+			lines = append(lines, fmt.Sprintf("Planned occurrence of maintenance plan #%d", *entry.MaintenancePlanID))
+		}
+	}
+	if entry.EstimatedDurationHours != nil {
+		lines = append(lines, "Estimated duration: "+strconv.FormatFloat(*entry.EstimatedDurationHours, 'f', -1, 64)+" h")
+	}
+	if entry.Description != nil && *entry.Description != "" {
+		lines = append(lines, "", *entry.Description)
+	}
+	event.Description = strings.Join(lines, "\n")
+
+	if entry.ScheduledStart != nil {
+		event.Start, event.End = workOrderWindow(*entry.ScheduledStart, entry.ScheduledEnd, entry.EstimatedDurationHours)
+		return event
+	}
+	event.AllDay = true
+	event.Start = calendar.Date(*entry.ScheduledDate)
+	event.End = event.Start.AddDate(0, 0, 1)
+	return event
+}
+
+// cancelCalendarEntry records the entry as cancelled in the organization
+// feeds when userIDs contains nil, and in the feeds of the other users given.
+func cancelCalendarEntry(tx *repository.Repository, orgID uint, entry repository.CalendarEntry, userIDs ...*uint) error {
+	event := calendarEvent(entry)
+	cancelled := map[uint]bool{}
+	for _, userID := range userIDs {
+		key := uint(0)
+		if userID != nil {
+			key = *userID
+		}
+		if cancelled[key] {
+			continue
+		}
+		cancelled[key] = true
+		err := tx.CreateCalendarCancellation(&repository.CalendarCancellation{
+			OrganizationID: orgID,
+			UserID:         userID,
+			UID:            event.UID,
+			Summary:        event.Summary,
+			StartsAt:       event.Start,
+			EndsAt:         event.End,
+			AllDay:         event.AllDay,
+			Sequence:       event.Sequence + 1,
+			CancelledAt:    time.Now().UTC(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cancelWorkOrderEvents compares a work order's feed entry before and after a
+// change (after is nil once it is deleted or no longer dated) and cancels it
+// in the feeds it drops out of.
+func cancelWorkOrderEvents(tx *repository.Repository, orgID uint, before, after *repository.CalendarEntry) error {
+	if before == nil {
+		return nil
+	}
+	if after == nil {
+		return cancelCalendarEntry(tx, orgID, *before, nil, before.TechnicianID)
+	}
+	if before.TechnicianID != nil && (after.TechnicianID == nil || *after.TechnicianID != *before.TechnicianID) {
+		return cancelCalendarEntry(tx, orgID, *before, before.TechnicianID)
+	}
+	return nil
+}
+
+func workOrderCalendarEntry(tx *repository.Repository, id, orgID uint) (*repository.CalendarEntry, error) {
+	entry, err := tx.GetWorkOrderCalendarEntry(id, orgID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return entry, err
+}
+
+// cancelPlanEvents cancels the open tasks and projected occurrences of a plan
+// that is about to be deleted.
+func cancelPlanEvents(tx *repository.Repository, id, orgID uint) error {
+	plan, err := tx.GetMaintenancePlan(id, orgID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cal, err := tx.GetBusinessCalendar(orgID)
+	if err != nil {
+		return err
+	}
+	today := cal.Today(time.Now())
+	entries, err := tx.ListPlanCalendarEntries(id, orgID, today.AddDate(0, 0, -feedHistoryDays))
+	if err != nil {
+		return err
+	}
+	projected, err := planCalendarEntries(tx, cal, plan, today.AddDate(0, 0, feedHorizonDays))
+	if err != nil {
+		return err
+	}
+	// Tasks and occurrences without a work order are also in the feeds of the
+	// users holding the plan's role.
+	var holders []*uint
+	if plan.AssignedRole != nil && *plan.AssignedRole != "" {
+		users, err := tx.ListUsersByRole(orgID, *plan.AssignedRole)
+		if err != nil {
+			return err
+		}
+		for i := range users {
+			holders = append(holders, &users[i].ID)
+		}
+	}
+	for _, entry := range append(entries, projected...) {
+		userIDs := []*uint{nil, entry.TechnicianID}
+		if entry.WorkOrderID == nil {
+			userIDs = append(userIDs, holders...)
+		}
+		if err := cancelCalendarEntry(tx, orgID, entry, userIDs...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
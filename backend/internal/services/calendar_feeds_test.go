@@ -0,0 +1,158 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"assetsentinel/internal/calendar"
+	"assetsentinel/internal/ical"
+	"assetsentinel/internal/rbac"
+	"assetsentinel/internal/repository"
+)
+
+type feedReader struct {
+	t     *testing.T
+	feeds *CalendarFeedService
+	token string
+}
+
+func (f *maintenanceFixture) feed(t *testing.T, user *repository.User, scope string) *feedReader {
+	t.Helper()
+	feeds := NewCalendarFeedService(f.repo, NewRoleService(f.repo))
+	feed := &repository.CalendarFeed{OrganizationID: f.org.ID, UserID: user.ID, Scope: scope}
+	if err := feeds.Create(feed, user.Role); err != nil {
+		t.Fatal(err)
+	}
+	return &feedReader{t: t, feeds: feeds, token: feed.Token}
+}
+
+// event returns the event with the UID, failing if the feed lists it more
+// than once, or nil.
+func (r *feedReader) event(uid string) *ical.Event {
+	r.t.Helper()
+	cal, err := r.feeds.Render(r.token)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	var found *ical.Event
+	for i := range cal.Events {
+		if cal.Events[i].UID != uid {
+			continue
+		}
+		if found != nil {
+			r.t.Fatalf("the feed lists %s twice", uid)
+		}
+		found = &cal.Events[i]
+	}
+	return found
+}
+
+func TestCalendarFeedEventsKeepTheirUID(t *testing.T) {
+	f := newMaintenanceFixture(t, "UTC")
+	alice := f.user(t, "alice", rbac.RoleTechnician)
+	manager := f.user(t, "mia", rbac.RoleMaintenanceManager)
+	aliceFeed, managerFeed := f.feed(t, alice, FeedScopeUser), f.feed(t, manager, FeedScopeUser)
+	orgFeed := f.feed(t, manager, FeedScopeOrganization)
+
+	due := calendar.Date(time.Now()).AddDate(0, 0, 3)
+	role := rbac.RoleTechnician
+	plan := &repository.MaintenancePlan{OrganizationID: f.org.ID, AssetID: f.asset.ID, FrequencyDays: 7, NextMaintenanceDate: due,
+		NonWorkingDayShift: calendar.ShiftNone, AssignedRole: &role, WorkOrderPriority: "medium", AssignmentStrategy: AssignRoundRobin}
+	if err := f.repo.CreateMaintenancePlan(plan); err != nil {
+		t.Fatal(err)
+	}
+	uid := fmt.Sprintf("maintenance-%d-%s@%s", plan.ID, due.Format("20060102"), feedUIDDomain)
+
+	for name, feed := range map[string]*feedReader{"organization": orgFeed, "technician": aliceFeed} {
+		if event := feed.event(uid); event == nil || event.Status != ical.StatusTentative || !event.AllDay {
+			t.Errorf("%s feed: planned occurrence = %+v, want a tentative all-day event", name, event)
+		}
+	}
+	if managerFeed.event(uid) != nil {
+		t.Error("the plan's occurrences are in the feed of a user without its role")
+	}
+
+	// The task generated for the occurrence replaces it under the same UID.
+	task := &repository.MaintenanceTask{OrganizationID: f.org.ID, MaintenancePlanID: plan.ID, AssetID: f.asset.ID, ScheduledDate: due, Status: "pending"}
+	if _, err := f.repo.CreateMaintenanceTaskOnce(task); err != nil {
+		t.Fatal(err)
+	}
+	for name, feed := range map[string]*feedReader{"organization": orgFeed, "technician": aliceFeed} {
+		if event := feed.event(uid); event == nil || event.Status != ical.StatusConfirmed {
+			t.Errorf("%s feed: task = %+v, want a confirmed event with the occurrence's UID", name, event)
+		}
+	}
+}
+
+func TestCalendarFeedSequenceAndCancellations(t *testing.T) {
+	f := newMaintenanceFixture(t, "UTC")
+	alice := f.user(t, "alice", rbac.RoleTechnician)
+	bob := f.user(t, "bob", rbac.RoleTechnician)
+	manager := f.user(t, "mia", rbac.RoleMaintenanceManager)
+	aliceFeed, bobFeed, orgFeed := f.feed(t, alice, FeedScopeUser), f.feed(t, bob, FeedScopeUser), f.feed(t, manager, FeedScopeOrganization)
+
+	start := calendar.Date(time.Now()).AddDate(0, 0, 1).Add(9 * time.Hour)
+	wo := &repository.WorkOrder{OrganizationID: f.org.ID, AssetID: f.asset.ID, TechnicianID: &alice.ID, Title: "Replace seal",
+		Status: "pending", Priority: "medium", ScheduledStart: &start}
+	if err := f.workOrders.Create(wo); err != nil {
+		t.Fatal(err)
+	}
+	uid := fmt.Sprintf("work-order-%d@%s", wo.ID, feedUIDDomain)
+	if event := aliceFeed.event(uid); event == nil || event.Sequence != 0 || !event.Start.Equal(start) || !event.End.Equal(start.Add(time.Hour)) {
+		t.Fatalf("new work order = %+v, want sequence 0 from %v for an hour", event, start)
+	}
+	if bobFeed.event(uid) != nil {
+		t.Error("another technician's work order is in bob's feed")
+	}
+
+	update := *wo
+	update.Title = "Replace seal and gasket"
+	if err := f.workOrders.Update(&update, wo.Status, manager.ID); err != nil {
+		t.Fatal(err)
+	}
+	if event := aliceFeed.event(uid); event == nil || event.Sequence != 1 || event.Summary != update.Title {
+		t.Errorf("updated work order = %+v, want sequence 1 with the new title", event)
+	}
+
+	update.TechnicianID = &bob.ID
+	if err := f.workOrders.Update(&update, wo.Status, manager.ID); err != nil {
+		t.Fatal(err)
+	}
+	if event := aliceFeed.event(uid); event == nil || event.Status != ical.StatusCancelled || event.Sequence != 2 {
+		t.Errorf("reassigned work order in alice's feed = %+v, want cancelled at sequence 2", event)
+	}
+	if event := bobFeed.event(uid); event == nil || event.Status != ical.StatusConfirmed || event.Sequence != 2 {
+		t.Errorf("reassigned work order in bob's feed = %+v, want confirmed at sequence 2", event)
+	}
+
+	if err := f.workOrders.Delete(wo.ID, f.org.ID); err != nil {
+		t.Fatal(err)
+	}
+	for name, feed := range map[string]*feedReader{"organization": orgFeed, "bob": bobFeed} {
+		if event := feed.event(uid); event == nil || event.Status != ical.StatusCancelled || event.Sequence != 3 {
+			t.Errorf("%s feed: deleted work order = %+v, want cancelled at sequence 3", name, event)
+		}
+	}
+}
+
+func TestCalendarFeedCancelsDeletedPlanForRoleHolders(t *testing.T) {
+	f := newMaintenanceFixture(t, "UTC")
+	alice := f.user(t, "alice", rbac.RoleTechnician)
+	aliceFeed := f.feed(t, alice, FeedScopeUser)
+
+	due := calendar.Date(time.Now()).AddDate(0, 0, 2)
+	role := rbac.RoleTechnician
+	plan := &repository.MaintenancePlan{OrganizationID: f.org.ID, AssetID: f.asset.ID, FrequencyDays: 30, NextMaintenanceDate: due,
+		NonWorkingDayShift: calendar.ShiftNone, AssignedRole: &role, WorkOrderPriority: "medium", AssignmentStrategy: AssignRoundRobin}
+	if err := f.repo.CreateMaintenancePlan(plan); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewMaintenanceService(f.repo).Delete(plan.ID, f.org.ID); err != nil {
+		t.Fatal(err)
+	}
+	uid := fmt.Sprintf("maintenance-%d-%s@%s", plan.ID, due.Format("20060102"), feedUIDDomain)
+	if event := aliceFeed.event(uid); event == nil || event.Status != ical.StatusCancelled {
+		t.Errorf("occurrence of the deleted plan = %+v, want it cancelled in alice's feed", event)
+	}
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"assetsentinel/internal/calendar"
+	"assetsentinel/internal/repository"
+)
+
+func TestCalendarServiceValidation(t *testing.T) {
+	repo := newTestRepository(t)
+	calendars := NewCalendarService(repo)
+	org := repository.Organization{Name: "Acme"}
+	if err := repo.CreateOrganization(&org); err != nil {
+		t.Fatal(err)
+	}
+
+	zero, tooMany := 0.0, 25.0
+	for _, tt := range []struct {
+		days  []int
+		hours *float64
+	}{
+		{nil, nil},
+		{[]int{1, 7}, nil},
+		{[]int{-1}, nil},
+		{[]int{1}, &zero},
+		{[]int{1}, &tooMany},
+	} {
+		if _, err := calendars.Update(org.ID, tt.days, tt.hours); !errors.Is(err, ErrInvalidCalendar) {
+			t.Errorf("Update(%v, %v) = %v, want ErrInvalidCalendar", tt.days, tt.hours, err)
+		}
+	}
+
+	hours := 10.0
+	settings, err := calendars.Update(org.ID, []int{1, 2, 3, 4, 4}, &hours)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(settings.WorkingDays) != 4 || settings.DailyHours != 10 {
+		t.Errorf("settings = %+v, want four ten-hour days", settings)
+	}
+
+	if err := calendars.AddHoliday(&repository.Holiday{OrganizationID: org.ID, Date: "25/12/2026", Name: "Christmas"}); !errors.Is(err, ErrInvalidCalendar) {
+		t.Errorf("AddHoliday with a malformed date = %v, want ErrInvalidCalendar", err)
+	}
+	if err := calendars.AddHoliday(&repository.Holiday{OrganizationID: org.ID, Date: "2026-12-25", Name: "Christmas"}); err != nil {
+		t.Fatal(err)
+	}
+	cal, err := repo.GetBusinessCalendar(org.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cal.IsWorkingDay(day(t, "2026-12-25")) || !cal.IsWorkingDay(day(t, "2026-12-24")) || cal.IsWorkingDay(day(t, "2026-12-18")) {
+		t.Error("the loaded calendar ignores the holiday or the working days")
+	}
+}
+
+func day(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(calendar.DateLayout, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
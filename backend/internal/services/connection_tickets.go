@@ -0,0 +1,57 @@
+package services
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"assetsentinel/internal/repository"
+)
+
+// connectionTicketTTL is how long a client has to open its real-time
+// connection with a ticket.
+const connectionTicketTTL = 30 * time.Second
+
+var ErrInvalidConnectionTicket = errors.New("invalid or expired connection ticket")
+
+type ConnectionTicketService struct {
+	repo *repository.Repository
+}
+
+func NewConnectionTicketService(repo *repository.Repository) *ConnectionTicketService {
+	return &ConnectionTicketService{repo: repo}
+}
+
+// Issue returns a single-use ticket that opens one real-time connection as the
+// user. Only its hash is stored.
+func (s *ConnectionTicketService) Issue(userID, orgID uint, role string) (string, time.Time, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(raw)
+
+	ticket := &repository.ConnectionTicket{
+		UserID:         userID,
+		OrganizationID: orgID,
+		Role:           role,
+		ExpiresAt:      time.Now().UTC().Add(connectionTicketTTL),
+	}
+	if err := s.repo.CreateConnectionTicket(ticket, hashToken(token)); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, ticket.ExpiresAt, nil
+}
+
+func (s *ConnectionTicketService) Redeem(token string) (userID, orgID uint, role string, err error) {
+	ticket, err := s.repo.RedeemConnectionTicket(hashToken(token), time.Now().UTC())
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, "", ErrInvalidConnectionTicket
+	}
+	if err != nil {
+		return 0, 0, "", err
+	}
+	return ticket.UserID, ticket.OrganizationID, ticket.Role, nil
+}
@@ -0,0 +1,552 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"assetsentinel/internal/calendar"
+	"assetsentinel/internal/rbac"
+	"assetsentinel/internal/repository"
+)
+
+const (
+	DefaultScheduleDays = 7
+	MaxScheduleDays     = 62
+
+	ProximitySameAsset     = "same_asset"
+	ProximitySameLocation  = "same_location"
+	ProximityUnknown       = "unknown"
+	ProximityOtherLocation = "other_location"
+
+	// Scheduled work within positionWindow of a requested window tells where a
+	// technician will be at that time.
+	positionWindow = 12 * time.Hour
+)
+
+var (
+	ErrIneligibleAssignee = errors.New("user cannot be assigned work orders")
+	ErrInvalidSchedule    = errors.New("invalid schedule")
+	ErrScheduleConflict   = errors.New("technician is already scheduled at that time")
+)
+
+// proximityRank orders suggestions: work on the same asset first, then at the
+// same location, and technicians known to be elsewhere last.
+var proximityRank = map[string]int{
+	ProximitySameAsset:     0,
+	ProximitySameLocation:  1,
+	ProximityUnknown:       2,
+	ProximityOtherLocation: 3,
+}
+
+type ScheduleConflict struct {
+	WorkOrderID uint      `json:"work_order_id"`
+	Title       string    `json:"title"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+}
+
+// ScheduleConflictError is returned when a work order would double-book its
+// technician. It wraps ErrScheduleConflict.
+type ScheduleConflictError struct {
+	TechnicianID uint
+	Conflicts    []ScheduleConflict
+}
+
+func (e *ScheduleConflictError) Error() string {
+	ids := make([]string, len(e.Conflicts))
+	for i, conflict := range e.Conflicts {
+		ids[i] = fmt.Sprintf("#%d", conflict.WorkOrderID)
+	}
+	return fmt.Sprintf("%v: overlaps work order %s", ErrScheduleConflict, strings.Join(ids, ", "))
+}
+
+func (e *ScheduleConflictError) Unwrap() error {
+	return ErrScheduleConflict
+}
+
+type ScheduleAssignment struct {
+	repository.ScheduledWorkOrder
+	WindowStart   time.Time `json:"window_start"`
+	WindowEnd     time.Time `json:"window_end"`
+	ConflictsWith []uint    `json:"conflicts_with"`
+}
+
+// TechnicianSchedule lists the work scheduled for a user in the range. Users
+// who may no longer be assigned work still appear while they have some, with
+// Eligible false.
+type TechnicianSchedule struct {
+	TechnicianID   uint                 `json:"technician_id"`
+	FullName       string               `json:"full_name"`
+	Email          string               `json:"email"`
+	Role           string               `json:"role"`
+	Eligible       bool                 `json:"eligible"`
+	ScheduledHours float64              `json:"scheduled_hours"`
+	CapacityHours  float64              `json:"capacity_hours"`
+	Conflicts      int                  `json:"conflicts"`
+	OpenWorkOrders int                  `json:"open_work_orders"`
+	OpenHours      float64              `json:"open_hours"`
+	Assignments    []ScheduleAssignment `json:"assignments"`
+}
+
+type Schedule struct {
+	From        time.Time            `json:"from"`
+	To          time.Time            `json:"to"`
+	Technicians []TechnicianSchedule `json:"technicians"`
+}
+
+type Suggestion struct {
+	TechnicianID      uint               `json:"technician_id"`
+	FullName          string             `json:"full_name"`
+	Email             string             `json:"email"`
+	Role              string             `json:"role"`
+	Available         bool               `json:"available"`
+	Conflicts         []ScheduleConflict `json:"conflicts"`
+	Proximity         string             `json:"proximity"`
+	NearbyWorkOrderID *uint              `json:"nearby_work_order_id"`
+	OpenWorkOrders    int                `json:"open_work_orders"`
+	OpenHours         float64            `json:"open_hours"`
+}
+
+// Suggestions ranks the eligible technicians for a work order. Best is the
+// first candidate when they are available, and nil when everyone is booked.
+type Suggestions struct {
+	AssetID     uint         `json:"asset_id"`
+	WindowStart time.Time    `json:"window_start"`
+	WindowEnd   time.Time    `json:"window_end"`
+	Best        *Suggestion  `json:"best"`
+	Candidates  []Suggestion `json:"candidates"`
+}
+
+type DispatchService struct {
+	repo  *repository.Repository
+	roles *RoleService
+}
+
+func NewDispatchService(repo *repository.Repository, roles *RoleService) *DispatchService {
+	return &DispatchService{repo: repo, roles: roles}
+}
+
+// eligible reports whether users holding role can be assigned work orders,
+// which takes permission to update them. Platform operators are never
+// dispatched.
+func (s *DispatchService) eligible(orgID uint, role string) (bool, error) {
+	if rbac.IsPlatformRole(role) {
+		return false, nil
+	}
+	permissions, err := s.roles.ResolvePermissions(orgID, role)
+	if errors.Is(err, ErrUnknownRole) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, permission := range permissions {
+		if permission == rbac.WorkOrdersUpdate {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ValidateAssignment checks the schedule of wo and that its technician belongs
+// to the organization and holds an eligible role. Updates are only checked
+// for what they change, so work assigned before a role change can still be
+// completed.
+func (s *DispatchService) ValidateAssignment(wo, existing *repository.WorkOrder) error {
+	if existing == nil || scheduleChanged(existing, wo) {
+		if err := validateSchedule(wo); err != nil {
+			return err
+		}
+	}
+	if wo.TechnicianID == nil || (existing != nil && sameUser(existing.TechnicianID, wo.TechnicianID)) {
+		return nil
+	}
+
+	user, err := s.repo.GetUser(*wo.TechnicianID, wo.OrganizationID)
+	if err != nil {
+		return ErrForeignReference
+	}
+	ok, err := s.eligible(wo.OrganizationID, user.Role)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: user %d has role %s", ErrIneligibleAssignee, user.ID, user.Role)
+	}
+	return nil
+}
+
+// available reports whether user can take wo: their role must be eligible and
+// they must have no other open work in its window. It runs in the transaction
+// that saves wo.
+func (s *DispatchService) available(tx *repository.Repository, wo *repository.WorkOrder, user *repository.User) (bool, error) {
+	ok, err := s.eligible(wo.OrganizationID, user.Role)
+	if err != nil || !ok || wo.ScheduledStart == nil {
+		return ok, err
+	}
+	start, end := workOrderWindow(*wo.ScheduledStart, wo.ScheduledEnd, wo.EstimatedDurationHours)
+	orders, err := tx.ListScheduledWorkOrders(wo.OrganizationID, &user.ID, start, end)
+	if err != nil {
+		return false, err
+	}
+	return len(conflictsWith(orders, wo.ID, start, end)) == 0, nil
+}
+
+func validateSchedule(wo *repository.WorkOrder) error {
+	if wo.ScheduledEnd != nil && wo.ScheduledStart == nil {
+		return fmt.Errorf("%w: scheduled_end requires scheduled_start", ErrInvalidSchedule)
+	}
+	if wo.ScheduledEnd != nil && !wo.ScheduledEnd.After(*wo.ScheduledStart) {
+		return fmt.Errorf("%w: scheduled_end must be after scheduled_start", ErrInvalidSchedule)
+	}
+	if wo.EstimatedDurationHours != nil && *wo.EstimatedDurationHours < 0 {
+		return fmt.Errorf("%w: estimated_duration_hours cannot be negative", ErrInvalidSchedule)
+	}
+	return nil
+}
+
+// CheckConflicts returns a *ScheduleConflictError when the technician of an
+// open, scheduled work order has other open work at the same time. Updates
+// are only checked when they change the technician or the window, or reopen
+// the work order. It runs in the transaction that saves wo.
+func (s *DispatchService) CheckConflicts(tx *repository.Repository, wo, existing *repository.WorkOrder) error {
+	if wo.TechnicianID == nil || wo.ScheduledStart == nil || workOrderDone(wo.Status) {
+		return nil
+	}
+	if existing != nil && sameUser(existing.TechnicianID, wo.TechnicianID) && !scheduleChanged(existing, wo) && !workOrderDone(existing.Status) {
+		return nil
+	}
+
+	start, end := workOrderWindow(*wo.ScheduledStart, wo.ScheduledEnd, wo.EstimatedDurationHours)
+	orders, err := tx.ListScheduledWorkOrders(wo.OrganizationID, wo.TechnicianID, start, end)
+	if err != nil {
+		return err
+	}
+	if conflicts := conflictsWith(orders, wo.ID, start, end); len(conflicts) > 0 {
+		return &ScheduleConflictError{TechnicianID: *wo.TechnicianID, Conflicts: conflicts}
+	}
+	return nil
+}
+
+// Schedule returns the work scheduled for each technician between from and to
+// (inclusive, in the organization's time zone), or for technicianID alone. A
+// nil from starts today and a nil to covers DefaultScheduleDays.
+func (s *DispatchService) Schedule(orgID uint, from, to *time.Time, technicianID *uint) (*Schedule, error) {
+	cal, err := s.repo.GetBusinessCalendar(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	first := cal.Today(time.Now())
+	if from != nil {
+		first = calendar.Date(*from)
+	}
+	last := first.AddDate(0, 0, DefaultScheduleDays-1)
+	if to != nil {
+		last = calendar.Date(*to)
+	}
+	if last.Before(first) {
+		return nil, fmt.Errorf("%w: to must not be before from", ErrInvalidSchedule)
+	}
+	if last.After(first.AddDate(0, 0, MaxScheduleDays-1)) {
+		return nil, fmt.Errorf("%w: the range cannot exceed %d days", ErrInvalidSchedule, MaxScheduleDays)
+	}
+	rangeStart := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, cal.Location)
+	rangeEnd := time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, cal.Location).AddDate(0, 0, 1)
+
+	var users []repository.User
+	if technicianID != nil {
+		user, err := s.repo.GetUser(*technicianID, orgID)
+		if err != nil {
+			return nil, err
+		}
+		users = []repository.User{*user}
+	} else if users, err = s.repo.ListUsers(orgID); err != nil {
+		return nil, err
+	}
+	orders, err := s.repo.ListScheduledWorkOrders(orgID, technicianID, rangeStart, rangeEnd)
+	if err != nil {
+		return nil, err
+	}
+	loads, err := s.repo.GetOpenWorkOrderLoad(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	byTechnician := map[uint][]repository.ScheduledWorkOrder{}
+	for _, order := range orders {
+		byTechnician[*order.TechnicianID] = append(byTechnician[*order.TechnicianID], order)
+	}
+	eligibleRoles := map[string]bool{}
+	capacity := cal.WorkingHours(first, last.AddDate(0, 0, 1))
+
+	schedule := &Schedule{From: first, To: last, Technicians: []TechnicianSchedule{}}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	for _, user := range users {
+		ok, seen := eligibleRoles[user.Role]
+		if !seen {
+			if ok, err = s.eligible(orgID, user.Role); err != nil {
+				return nil, err
+			}
+			eligibleRoles[user.Role] = ok
+		}
+		assignments := scheduleAssignments(byTechnician[user.ID], rangeStart, rangeEnd)
+		if !ok && len(assignments) == 0 && technicianID == nil {
+			continue
+		}
+
+		entry := TechnicianSchedule{
+			TechnicianID:   user.ID,
+			FullName:       user.FullName,
+			Email:          user.Email,
+			Role:           user.Role,
+			Eligible:       ok,
+			CapacityHours:  capacity,
+			OpenWorkOrders: loads[user.ID].OpenWorkOrders,
+			OpenHours:      loads[user.ID].OpenHours,
+			Assignments:    assignments,
+		}
+		for _, assignment := range assignments {
+			// Only the part of the window inside the range counts.
+			entry.ScheduledHours += minDate(assignment.WindowEnd, rangeEnd).Sub(maxDate(assignment.WindowStart, rangeStart)).Hours()
+			if len(assignment.ConflictsWith) > 0 {
+				entry.Conflicts++
+			}
+		}
+		schedule.Technicians = append(schedule.Technicians, entry)
+	}
+	return schedule, nil
+}
+
+// scheduleAssignments returns the work orders whose window overlaps the range,
+// with the other work orders of the list they overlap.
+func scheduleAssignments(orders []repository.ScheduledWorkOrder, rangeStart, rangeEnd time.Time) []ScheduleAssignment {
+	assignments := []ScheduleAssignment{}
+	for _, order := range orders {
+		start, end := workOrderWindow(*order.ScheduledStart, order.ScheduledEnd, order.EstimatedDurationHours)
+		if !overlaps(start, end, rangeStart, rangeEnd) {
+			continue
+		}
+		conflicts := []uint{}
+		for _, conflict := range conflictsWith(orders, order.ID, start, end) {
+			conflicts = append(conflicts, conflict.WorkOrderID)
+		}
+		assignments = append(assignments, ScheduleAssignment{ScheduledWorkOrder: order, WindowStart: start, WindowEnd: end, ConflictsWith: conflicts})
+	}
+	return assignments
+}
+
+// Suggest ranks the eligible technicians, or those holding role when it is
+// set, for work on wo.AssetID in the window of wo. Available technicians come
+// first; then technicians working close to the asset around that time, and
+// then those with the least open work. When wo.ID is set, the work order
+// supplies what wo leaves empty and is ignored when looking for conflicts.
+func (s *DispatchService) Suggest(wo *repository.WorkOrder, role string) (*Suggestions, error) {
+	if wo.ID != 0 {
+		existing, err := s.repo.GetWorkOrder(wo.ID, wo.OrganizationID)
+		if err != nil {
+			return nil, err
+		}
+		if wo.AssetID == 0 {
+			wo.AssetID = existing.AssetID
+		}
+		if wo.ScheduledStart == nil {
+			wo.ScheduledStart, wo.ScheduledEnd = existing.ScheduledStart, existing.ScheduledEnd
+		}
+		if wo.EstimatedDurationHours == nil {
+			wo.EstimatedDurationHours = existing.EstimatedDurationHours
+		}
+	}
+	if wo.ScheduledStart == nil {
+		return nil, fmt.Errorf("%w: scheduled_start is required", ErrInvalidSchedule)
+	}
+	if err := validateSchedule(wo); err != nil {
+		return nil, err
+	}
+	asset, err := s.repo.GetAsset(wo.AssetID, wo.OrganizationID)
+	if err != nil {
+		return nil, ErrForeignReference
+	}
+
+	var users []repository.User
+	if role != "" {
+		users, err = s.repo.ListUsersByRole(wo.OrganizationID, role)
+	} else {
+		users, err = s.repo.ListUsers(wo.OrganizationID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	start, end := workOrderWindow(*wo.ScheduledStart, wo.ScheduledEnd, wo.EstimatedDurationHours)
+	orders, err := s.repo.ListScheduledWorkOrders(wo.OrganizationID, nil, start.Add(-positionWindow), end.Add(positionWindow))
+	if err != nil {
+		return nil, err
+	}
+	loads, err := s.repo.GetOpenWorkOrderLoad(wo.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	byTechnician := map[uint][]repository.ScheduledWorkOrder{}
+	for _, order := range orders {
+		if order.ID != wo.ID {
+			byTechnician[*order.TechnicianID] = append(byTechnician[*order.TechnicianID], order)
+		}
+	}
+	eligibleRoles := map[string]bool{}
+
+	suggestions := &Suggestions{AssetID: asset.ID, WindowStart: start, WindowEnd: end, Candidates: []Suggestion{}}
+	for _, user := range users {
+		ok, seen := eligibleRoles[user.Role]
+		if !seen {
+			if ok, err = s.eligible(wo.OrganizationID, user.Role); err != nil {
+				return nil, err
+			}
+			eligibleRoles[user.Role] = ok
+		}
+		if !ok {
+			continue
+		}
+
+		conflicts := conflictsWith(byTechnician[user.ID], wo.ID, start, end)
+		candidate := Suggestion{
+			TechnicianID:   user.ID,
+			FullName:       user.FullName,
+			Email:          user.Email,
+			Role:           user.Role,
+			Available:      len(conflicts) == 0,
+			Conflicts:      conflicts,
+			Proximity:      ProximityUnknown,
+			OpenWorkOrders: loads[user.ID].OpenWorkOrders,
+			OpenHours:      loads[user.ID].OpenHours,
+		}
+		if nearby := nearestWorkOrder(byTechnician[user.ID], start, end); nearby != nil {
+			candidate.Proximity = proximity(asset, nearby)
+			candidate.NearbyWorkOrderID = &nearby.ID
+		}
+		suggestions.Candidates = append(suggestions.Candidates, candidate)
+	}
+
+	sort.SliceStable(suggestions.Candidates, func(i, j int) bool {
+		a, b := suggestions.Candidates[i], suggestions.Candidates[j]
+		if a.Available != b.Available {
+			return a.Available
+		}
+		if proximityRank[a.Proximity] != proximityRank[b.Proximity] {
+			return proximityRank[a.Proximity] < proximityRank[b.Proximity]
+		}
+		if a.OpenHours != b.OpenHours {
+			return a.OpenHours < b.OpenHours
+		}
+		if a.OpenWorkOrders != b.OpenWorkOrders {
+			return a.OpenWorkOrders < b.OpenWorkOrders
+		}
+		return a.TechnicianID < b.TechnicianID
+	})
+	if len(suggestions.Candidates) > 0 && suggestions.Candidates[0].Available {
+		suggestions.Best = &suggestions.Candidates[0]
+	}
+	return suggestions, nil
+}
+
+// nearestWorkOrder returns the work order closest in time to the window,
+// within positionWindow of it.
+func nearestWorkOrder(orders []repository.ScheduledWorkOrder, start, end time.Time) *repository.ScheduledWorkOrder {
+	var nearest *repository.ScheduledWorkOrder
+	nearestGap := positionWindow
+	for i := range orders {
+		orderStart, orderEnd := workOrderWindow(*orders[i].ScheduledStart, orders[i].ScheduledEnd, orders[i].EstimatedDurationHours)
+		gap := time.Duration(0)
+		if orderEnd.Before(start) {
+			gap = start.Sub(orderEnd)
+		} else if orderStart.After(end) {
+			gap = orderStart.Sub(end)
+		}
+		if gap <= nearestGap {
+			nearest, nearestGap = &orders[i], gap
+		}
+	}
+	return nearest
+}
+
+// proximity compares the asset of nearby work with the asset to work on.
+// Locations are free text, so they only match when they are equal ignoring
+// case and surrounding space.
+func proximity(asset *repository.Asset, nearby *repository.ScheduledWorkOrder) string {
+	if nearby.AssetID == asset.ID {
+		return ProximitySameAsset
+	}
+	if asset.Location == nil || nearby.AssetLocation == nil {
+		return ProximityUnknown
+	}
+	here, there := strings.TrimSpace(*asset.Location), strings.TrimSpace(*nearby.AssetLocation)
+	if here == "" || there == "" {
+		return ProximityUnknown
+	}
+	if strings.EqualFold(here, there) {
+		return ProximitySameLocation
+	}
+	return ProximityOtherLocation
+}
+
+// conflictsWith returns the work orders of the list, other than excludeID,
+// whose window overlaps start to end.
+func conflictsWith(orders []repository.ScheduledWorkOrder, excludeID uint, start, end time.Time) []ScheduleConflict {
+	conflicts := []ScheduleConflict{}
+	for _, order := range orders {
+		if order.ID == excludeID {
+			continue
+		}
+		orderStart, orderEnd := workOrderWindow(*order.ScheduledStart, order.ScheduledEnd, order.EstimatedDurationHours)
+		if overlaps(start, end, orderStart, orderEnd) {
+			conflicts = append(conflicts, ScheduleConflict{WorkOrderID: order.ID, Title: order.Title, Start: orderStart, End: orderEnd})
+		}
+	}
+	return conflicts
+}
+
+// workOrderWindow is the time a scheduled work order takes: up to its
+// scheduled end, or for its estimated duration, or an hour.
+func workOrderWindow(start time.Time, scheduledEnd *time.Time, estimatedHours *float64) (time.Time, time.Time) {
+	end := start.Add(time.Hour)
+	if estimatedHours != nil && *estimatedHours > 0 {
+		end = start.Add(time.Duration(*estimatedHours * float64(time.Hour)))
+	}
+	if scheduledEnd != nil && scheduledEnd.After(start) {
+		end = *scheduledEnd
+	}
+	return start, end
+}
+
+func overlaps(aStart, aEnd, bStart, bEnd time.Time) bool {
+	return aStart.Before(bEnd) && bStart.Before(aEnd)
+}
+
+func scheduleChanged(existing, wo *repository.WorkOrder) bool {
+	return !sameTime(existing.ScheduledStart, wo.ScheduledStart) || !sameTime(existing.ScheduledEnd, wo.ScheduledEnd) ||
+		!sameHours(existing.EstimatedDurationHours, wo.EstimatedDurationHours)
+}
+
+func sameUser(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func sameHours(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
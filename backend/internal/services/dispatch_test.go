@@ -0,0 +1,274 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"assetsentinel/internal/rbac"
+	"assetsentinel/internal/repository"
+)
+
+func at(hour, minute int) *time.Time {
+	t := time.Date(2026, 3, 30, hour, minute, 0, 0, time.UTC)
+	return &t
+}
+
+// scheduled stores a work order without the dispatch checks.
+func (f *maintenanceFixture) scheduled(t *testing.T, technician *repository.User, asset *repository.Asset, start, end *time.Time, hours *float64) *repository.WorkOrder {
+	t.Helper()
+	wo := &repository.WorkOrder{OrganizationID: f.org.ID, AssetID: asset.ID, TechnicianID: &technician.ID, Title: "Work for " + technician.FullName,
+		Status: "pending", Priority: "medium", ScheduledStart: start, ScheduledEnd: end, EstimatedDurationHours: hours}
+	if err := f.repo.CreateWorkOrder(wo); err != nil {
+		t.Fatal(err)
+	}
+	return wo
+}
+
+func (f *maintenanceFixture) customRole(t *testing.T, name string, permissions ...string) {
+	t.Helper()
+	if err := f.repo.CreateRole(&repository.Role{OrganizationID: f.org.ID, Name: name, Permissions: permissions}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (f *maintenanceFixture) assetAt(t *testing.T, name, location string) *repository.Asset {
+	t.Helper()
+	asset := &repository.Asset{OrganizationID: f.org.ID, Name: name, Category: "pump", Status: "active", Location: &location}
+	if err := f.repo.CreateAsset(asset); err != nil {
+		t.Fatal(err)
+	}
+	return asset
+}
+
+func TestValidateAssignment(t *testing.T) {
+	f := newMaintenanceFixture(t, "UTC")
+	dispatch := NewDispatchService(f.repo, NewRoleService(f.repo))
+	f.customRole(t, "contractor", rbac.WorkOrdersRead, rbac.WorkOrdersUpdate)
+	f.customRole(t, "auditor", rbac.WorkOrdersRead)
+
+	globex := repository.Organization{Name: "Globex"}
+	if err := f.repo.CreateOrganization(&globex); err != nil {
+		t.Fatal(err)
+	}
+	outsider := &repository.User{OrganizationID: globex.ID, Email: "olga@globex.test", PasswordHash: "x", FullName: "olga", Role: rbac.RoleTechnician}
+	if err := f.repo.CreateUser(outsider); err != nil {
+		t.Fatal(err)
+	}
+	vic := f.user(t, "vic", rbac.RoleViewer)
+	for _, tt := range []struct {
+		user *repository.User
+		want error
+	}{
+		{f.user(t, "alice", rbac.RoleTechnician), nil},
+		{f.user(t, "carl", "contractor"), nil},
+		{f.user(t, "mia", rbac.RoleMaintenanceManager), nil},
+		{f.user(t, "ada", "auditor"), ErrIneligibleAssignee},
+		{vic, ErrIneligibleAssignee},
+		{f.user(t, "root", rbac.RoleSuperAdmin), ErrIneligibleAssignee},
+		{outsider, ErrForeignReference},
+	} {
+		wo := &repository.WorkOrder{OrganizationID: f.org.ID, AssetID: f.asset.ID, TechnicianID: &tt.user.ID}
+		if err := dispatch.ValidateAssignment(wo, nil); !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+			t.Errorf("assigning %s (%s) = %v, want %v", tt.user.FullName, tt.user.Role, err, tt.want)
+		}
+	}
+
+	// Work assigned before a role change can still be updated.
+	existing := &repository.WorkOrder{OrganizationID: f.org.ID, AssetID: f.asset.ID, TechnicianID: &vic.ID, Title: "Old"}
+	update := *existing
+	update.Title = "Renamed"
+	if err := dispatch.ValidateAssignment(&update, existing); err != nil {
+		t.Errorf("updating work already assigned to vic = %v", err)
+	}
+
+	negative := -1.0
+	for name, wo := range map[string]*repository.WorkOrder{
+		"end without start": {ScheduledEnd: at(10, 0)},
+		"end before start":  {ScheduledStart: at(10, 0), ScheduledEnd: at(9, 0)},
+		"empty window":      {ScheduledStart: at(10, 0), ScheduledEnd: at(10, 0)},
+		"negative estimate": {ScheduledStart: at(10, 0), EstimatedDurationHours: &negative},
+	} {
+		wo.OrganizationID = f.org.ID
+		if err := dispatch.ValidateAssignment(wo, nil); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("%s = %v, want ErrInvalidSchedule", name, err)
+		}
+	}
+}
+
+func TestCheckConflicts(t *testing.T) {
+	f := newMaintenanceFixture(t, "UTC")
+	alice := f.user(t, "alice", rbac.RoleTechnician)
+	bob := f.user(t, "bob", rbac.RoleTechnician)
+
+	// 11:00 to 13:00 in UTC+2 is 09:00 to 11:00 UTC.
+	plusTwo := time.FixedZone("", 2*60*60)
+	start, end := time.Date(2026, 3, 30, 11, 0, 0, 0, plusTwo), time.Date(2026, 3, 30, 13, 0, 0, 0, plusTwo)
+	first := &repository.WorkOrder{OrganizationID: f.org.ID, AssetID: f.asset.ID, TechnicianID: &alice.ID, Title: "Inspection",
+		Status: "pending", Priority: "medium", ScheduledStart: &start, ScheduledEnd: &end}
+	if err := f.workOrders.Create(first); err != nil {
+		t.Fatal(err)
+	}
+
+	one := 1.0
+	overlapping := &repository.WorkOrder{OrganizationID: f.org.ID, AssetID: f.asset.ID, TechnicianID: &alice.ID, Title: "Repair",
+		Status: "pending", Priority: "medium", ScheduledStart: at(9, 30), EstimatedDurationHours: &one}
+	err := f.workOrders.Create(overlapping)
+	var conflict *ScheduleConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrScheduleConflict) {
+		t.Fatalf("overlapping work order = %v, want a ScheduleConflictError", err)
+	}
+	if conflict.TechnicianID != alice.ID || len(conflict.Conflicts) != 1 || conflict.Conflicts[0].WorkOrderID != first.ID ||
+		!conflict.Conflicts[0].Start.Equal(*at(9, 0)) || !conflict.Conflicts[0].End.Equal(*at(11, 0)) {
+		t.Errorf("conflict = %+v, want work order %d from 09:00 to 11:00 UTC", conflict, first.ID)
+	}
+
+	for name, wo := range map[string]*repository.WorkOrder{
+		"right after":  {TechnicianID: &alice.ID, ScheduledStart: at(11, 0)},
+		"right before": {TechnicianID: &alice.ID, ScheduledStart: at(8, 0)},
+		"someone else": {TechnicianID: &bob.ID, ScheduledStart: at(10, 0)},
+		"unscheduled":  {TechnicianID: &alice.ID},
+	} {
+		wo.OrganizationID, wo.AssetID, wo.Title, wo.Status, wo.Priority = f.org.ID, f.asset.ID, name, "pending", "medium"
+		if err := f.workOrders.Create(wo); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	// Completed work no longer blocks the technician.
+	done := *first
+	done.Status = "completed"
+	if err := f.workOrders.Update(&done, first.Status, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.workOrders.Create(overlapping); err != nil {
+		t.Errorf("work overlapping a completed work order = %v", err)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	f := newMaintenanceFixture(t, "UTC")
+	dispatch := NewDispatchService(f.repo, NewRoleService(f.repo))
+	f.customRole(t, "contractor", rbac.WorkOrdersRead, rbac.WorkOrdersUpdate)
+	target := f.assetAt(t, "Pump", "Hall 1")
+	neighbour := f.assetAt(t, "Valve", " hall 1")
+	elsewhere := f.assetAt(t, "Boiler", "Hall 2")
+
+	alice := f.user(t, "alice", rbac.RoleTechnician)
+	bob := f.user(t, "bob", rbac.RoleTechnician)
+	carol := f.user(t, "carol", rbac.RoleTechnician)
+	dave := f.user(t, "dave", rbac.RoleTechnician)
+	erin := f.user(t, "erin", "contractor")
+	f.user(t, "vic", rbac.RoleViewer)
+
+	five := 5.0
+	f.scheduled(t, alice, elsewhere, at(11, 0), at(12, 0), nil)
+	f.scheduled(t, bob, neighbour, at(8, 0), at(9, 0), nil)
+	f.scheduled(t, carol, target, at(13, 0), nil, nil)
+	f.scheduled(t, dave, elsewhere, nil, nil, &five)
+
+	two := 2.0
+	suggestions, err := dispatch.Suggest(&repository.WorkOrder{OrganizationID: f.org.ID, AssetID: target.ID, ScheduledStart: at(10, 0), EstimatedDurationHours: &two}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		user      *repository.User
+		available bool
+		proximity string
+	}{
+		{carol, true, ProximitySameAsset},
+		{bob, true, ProximitySameLocation},
+		{erin, true, ProximityUnknown},
+		{dave, true, ProximityUnknown},
+		{alice, false, ProximityOtherLocation},
+	}
+	if len(suggestions.Candidates) != len(want) {
+		t.Fatalf("got %d candidates, want %d: %+v", len(suggestions.Candidates), len(want), suggestions.Candidates)
+	}
+	for i, w := range want {
+		got := suggestions.Candidates[i]
+		if got.TechnicianID != w.user.ID || got.Available != w.available || got.Proximity != w.proximity {
+			t.Errorf("candidate %d = %s (available %v, %s), want %s (available %v, %s)",
+				i+1, got.FullName, got.Available, got.Proximity, w.user.FullName, w.available, w.proximity)
+		}
+	}
+	if suggestions.Best == nil || suggestions.Best.TechnicianID != carol.ID {
+		t.Errorf("best = %+v, want carol", suggestions.Best)
+	}
+	if dave := suggestions.Candidates[3]; dave.OpenHours != 5 || dave.OpenWorkOrders != 1 {
+		t.Errorf("dave's load = %v hours in %d work orders, want 5 in 1", dave.OpenHours, dave.OpenWorkOrders)
+	}
+	if conflicts := suggestions.Candidates[4].Conflicts; len(conflicts) != 1 {
+		t.Errorf("alice's conflicts = %+v, want one", conflicts)
+	}
+
+	// Only holders of the role are suggested, and nobody is best when they
+	// are all booked.
+	suggestions, err = dispatch.Suggest(&repository.WorkOrder{OrganizationID: f.org.ID, AssetID: target.ID, ScheduledStart: at(11, 30)}, rbac.RoleTechnician)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, candidate := range suggestions.Candidates {
+		if candidate.TechnicianID == erin.ID {
+			t.Error("a contractor was suggested for the technician role")
+		}
+	}
+	f.scheduled(t, erin, elsewhere, at(10, 0), at(12, 0), nil)
+	if suggestions, err = dispatch.Suggest(&repository.WorkOrder{OrganizationID: f.org.ID, AssetID: target.ID, ScheduledStart: at(11, 0)}, "contractor"); err != nil {
+		t.Fatal(err)
+	}
+	if suggestions.Best != nil || len(suggestions.Candidates) != 1 || suggestions.Candidates[0].Available {
+		t.Errorf("suggestions = %+v, want erin unavailable and no best candidate", suggestions)
+	}
+
+	if _, err := dispatch.Suggest(&repository.WorkOrder{OrganizationID: f.org.ID, AssetID: target.ID}, ""); !errors.Is(err, ErrInvalidSchedule) {
+		t.Errorf("Suggest without a start = %v, want ErrInvalidSchedule", err)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	f := newMaintenanceFixture(t, "UTC")
+	dispatch := NewDispatchService(f.repo, NewRoleService(f.repo))
+	alice := f.user(t, "alice", rbac.RoleTechnician)
+	bob := f.user(t, "bob", rbac.RoleTechnician)
+	vic := f.user(t, "vic", rbac.RoleViewer)
+	f.user(t, "val", rbac.RoleViewer)
+
+	// Alice is double-booked, and her night shift runs past the end of the
+	// range.
+	f.scheduled(t, alice, &f.asset, at(9, 0), at(11, 0), nil)
+	f.scheduled(t, alice, &f.asset, at(10, 0), at(12, 0), nil)
+	f.scheduled(t, alice, &f.asset, at(22, 0), at(26, 0), nil)
+	f.scheduled(t, vic, &f.asset, at(14, 0), nil, nil)
+
+	day := time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC)
+	schedule, err := dispatch.Schedule(f.org.ID, &day, &day, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedule.Technicians) != 3 {
+		t.Fatalf("got %d technicians, want alice, bob and vic", len(schedule.Technicians))
+	}
+	byID := map[uint]TechnicianSchedule{}
+	for _, technician := range schedule.Technicians {
+		byID[technician.TechnicianID] = technician
+	}
+	if a := byID[alice.ID]; len(a.Assignments) != 3 || a.Conflicts != 2 || a.ScheduledHours != 6 || a.CapacityHours != 8 || !a.Eligible {
+		t.Errorf("alice = %d assignments, %d conflicts, %v of %v hours; want 3, 2, 6 of 8", len(a.Assignments), a.Conflicts, a.ScheduledHours, a.CapacityHours)
+	}
+	if b := byID[bob.ID]; len(b.Assignments) != 0 || b.ScheduledHours != 0 {
+		t.Errorf("bob = %+v, want an empty day", b)
+	}
+	if v := byID[vic.ID]; v.Eligible || len(v.Assignments) != 1 {
+		t.Errorf("vic = %+v, want an ineligible user listed with their work", v)
+	}
+
+	before := day.AddDate(0, 0, -1)
+	tooLong := day.AddDate(0, 0, MaxScheduleDays)
+	for _, to := range []time.Time{before, tooLong} {
+		if _, err := dispatch.Schedule(f.org.ID, &day, &to, nil); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("Schedule to %v = %v, want ErrInvalidSchedule", to, err)
+		}
+	}
+}
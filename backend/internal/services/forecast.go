@@ -0,0 +1,239 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"assetsentinel/internal/calendar"
+	"assetsentinel/internal/rbac"
+	"assetsentinel/internal/repository"
+)
+
+const (
+	DefaultForecastDays = 90
+	MaxForecastDays     = 366
+
+	// UnassignedRole groups the work of plans without an assigned role.
+	UnassignedRole = "unassigned"
+
+	maxForecastOccurrences = 1000
+)
+
+var ErrInvalidForecastRange = errors.New("invalid forecast range")
+
+type ForecastRole struct {
+	Role          string  `json:"role"`
+	Occurrences   int     `json:"occurrences"`
+	Hours         float64 `json:"hours"`
+	Technicians   int     `json:"technicians"`
+	CapacityHours float64 `json:"capacity_hours"`
+	OverAllocated bool    `json:"over_allocated"`
+}
+
+type ForecastWeek struct {
+	WeekStart     time.Time      `json:"week_start"`
+	WeekEnd       time.Time      `json:"week_end"`
+	Occurrences   int            `json:"occurrences"`
+	Hours         float64        `json:"hours"`
+	CapacityHours float64        `json:"capacity_hours"`
+	OverAllocated bool           `json:"over_allocated"`
+	Roles         []ForecastRole `json:"roles"`
+}
+
+// Forecast is the labor projected for the maintenance plans of an organization
+// over a date range. Occurrences without an estimate count towards Occurrences
+// but add no hours; they are reported in Unestimated.
+type Forecast struct {
+	From          time.Time      `json:"from"`
+	To            time.Time      `json:"to"`
+	Occurrences   int            `json:"occurrences"`
+	Unestimated   int            `json:"unestimated"`
+	Hours         float64        `json:"hours"`
+	CapacityHours float64        `json:"capacity_hours"`
+	OverAllocated bool           `json:"over_allocated"`
+	Roles         []ForecastRole `json:"roles"`
+	Weeks         []ForecastWeek `json:"weeks"`
+}
+
+// Forecast projects every occurrence of the organization's plans scheduled
+// between from and to (inclusive) and compares the estimated hours per week
+// and per role with the working hours of the users holding each role. Plans
+// without an assigned role are expected to be picked up by technicians, so
+// capacity always includes the technician role. A nil from starts today and a
+// nil to covers DefaultForecastDays.
+func (s *MaintenanceService) Forecast(orgID uint, from, to *time.Time) (*Forecast, error) {
+	cal, err := s.repo.GetBusinessCalendar(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	start := cal.Today(time.Now())
+	if from != nil {
+		start = calendar.Date(*from)
+	}
+	end := start.AddDate(0, 0, DefaultForecastDays-1)
+	if to != nil {
+		end = calendar.Date(*to)
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("%w: to must not be before from", ErrInvalidForecastRange)
+	}
+	if end.After(start.AddDate(0, 0, MaxForecastDays-1)) {
+		return nil, fmt.Errorf("%w: the range cannot exceed %d days", ErrInvalidForecastRange, MaxForecastDays)
+	}
+
+	plans, err := s.repo.GetMaintenancePlansDue(orgID, end.AddDate(0, 0, calendar.MaxShiftDays))
+	if err != nil {
+		return nil, err
+	}
+
+	forecast := &Forecast{From: start, To: end, Roles: []ForecastRole{}, Weeks: []ForecastWeek{}}
+	hours := map[time.Time]map[string]*ForecastRole{}
+	roles := map[string]bool{rbac.RoleTechnician: true}
+	for i := range plans {
+		plan := &plans[i]
+		role := UnassignedRole
+		if plan.AssignedRole != nil && *plan.AssignedRole != "" {
+			role = *plan.AssignedRole
+			roles[role] = true
+		}
+		dates, err := planOccurrencesBetween(cal, plan, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("projecting plan %d: %w", plan.ID, err)
+		}
+		for _, scheduled := range dates {
+			weekStart := startOfWeek(scheduled)
+			if hours[weekStart] == nil {
+				hours[weekStart] = map[string]*ForecastRole{}
+			}
+			entry := hours[weekStart][role]
+			if entry == nil {
+				entry = &ForecastRole{Role: role}
+				hours[weekStart][role] = entry
+			}
+			entry.Occurrences++
+			forecast.Occurrences++
+			if plan.EstimatedDurationHours == nil {
+				forecast.Unestimated++
+				continue
+			}
+			entry.Hours += *plan.EstimatedDurationHours
+		}
+	}
+
+	headcount := map[string]int{}
+	for role := range roles {
+		users, err := s.repo.ListUsersByRole(orgID, role)
+		if err != nil {
+			return nil, err
+		}
+		headcount[role] = len(users)
+	}
+	names := make([]string, 0, len(roles)+1)
+	for role := range roles {
+		names = append(names, role)
+	}
+	sort.Strings(names)
+	names = append(names, UnassignedRole)
+
+	totals := map[string]*ForecastRole{}
+	for _, role := range names {
+		totals[role] = &ForecastRole{Role: role, Technicians: headcount[role]}
+	}
+	for weekStart := startOfWeek(start); !weekStart.After(end); weekStart = weekStart.AddDate(0, 0, 7) {
+		week := ForecastWeek{WeekStart: weekStart, WeekEnd: weekStart.AddDate(0, 0, 6), Roles: []ForecastRole{}}
+		// Capacity only counts the days of the week inside the range.
+		first, last := maxDate(weekStart, start), minDate(week.WeekEnd, end)
+		personHours := cal.WorkingHours(first, last.AddDate(0, 0, 1))
+
+		for _, role := range names {
+			entry := ForecastRole{Role: role, Technicians: headcount[role], CapacityHours: personHours * float64(headcount[role])}
+			if planned := hours[weekStart][role]; planned != nil {
+				entry.Occurrences, entry.Hours = planned.Occurrences, planned.Hours
+			}
+			if role == UnassignedRole && entry.Occurrences == 0 {
+				continue
+			}
+			// Unassigned work has no capacity of its own and only counts
+			// against the week's total.
+			entry.OverAllocated = role != UnassignedRole && entry.Hours > entry.CapacityHours
+			week.Roles = append(week.Roles, entry)
+
+			week.Occurrences += entry.Occurrences
+			week.Hours += entry.Hours
+			week.CapacityHours += entry.CapacityHours
+
+			total := totals[role]
+			total.Occurrences += entry.Occurrences
+			total.Hours += entry.Hours
+			total.CapacityHours += entry.CapacityHours
+		}
+		week.OverAllocated = week.Hours > week.CapacityHours
+		for _, entry := range week.Roles {
+			week.OverAllocated = week.OverAllocated || entry.OverAllocated
+		}
+		forecast.OverAllocated = forecast.OverAllocated || week.OverAllocated
+		forecast.Hours += week.Hours
+		forecast.CapacityHours += week.CapacityHours
+		forecast.Weeks = append(forecast.Weeks, week)
+	}
+
+	for _, role := range names {
+		total := totals[role]
+		if role == UnassignedRole && total.Occurrences == 0 {
+			continue
+		}
+		total.OverAllocated = role != UnassignedRole && total.Hours > total.CapacityHours
+		forecast.Roles = append(forecast.Roles, *total)
+	}
+	return forecast, nil
+}
+
+// planOccurrencesBetween returns the scheduled dates, after the business
+// calendar shift, of the plan's occurrences that fall between start and end.
+func planOccurrencesBetween(cal *calendar.Calendar, plan *repository.MaintenancePlan, start, end time.Time) ([]time.Time, error) {
+	recurrence, err := PlanRecurrence(plan)
+	if err != nil {
+		return nil, err
+	}
+
+	// A shift can move an occurrence by up to MaxShiftDays in either
+	// direction, so look that far past the end of the range.
+	limit := end.AddDate(0, 0, calendar.MaxShiftDays)
+	dates := []time.Time{}
+	date, ok := calendar.Date(plan.NextMaintenanceDate), true
+	if earliest := start.AddDate(0, 0, -calendar.MaxShiftDays); date.Before(earliest) {
+		// Occurrences this early cannot be shifted into the range.
+		date, ok = recurrence.From(earliest)
+	}
+	for i := 0; ok && !date.After(limit) && i < maxForecastOccurrences; i++ {
+		scheduled := cal.Shift(date, plan.NonWorkingDayShift)
+		if !scheduled.Before(start) && !scheduled.After(end) {
+			dates = append(dates, scheduled)
+		}
+		date, ok = recurrence.After(date)
+	}
+	return dates, nil
+}
+
+func startOfWeek(day time.Time) time.Time {
+	day = calendar.Date(day)
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+func minDate(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxDate(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"assetsentinel/internal/calendar"
+	"assetsentinel/internal/rbac"
+	"assetsentinel/internal/repository"
+)
+
+func (f *maintenanceFixture) forecastPlan(t *testing.T, role string, days int, next string, shift string, hours *float64) {
+	t.Helper()
+	plan := &repository.MaintenancePlan{OrganizationID: f.org.ID, AssetID: f.asset.ID, FrequencyDays: days, NextMaintenanceDate: day(t, next),
+		NonWorkingDayShift: shift, EstimatedDurationHours: hours, WorkOrderPriority: "medium", AssignmentStrategy: AssignRoundRobin}
+	if role != "" {
+		plan.AssignedRole = &role
+	}
+	if err := f.repo.CreateMaintenancePlan(plan); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func forecastRole(roles []ForecastRole, name string) *ForecastRole {
+	for i := range roles {
+		if roles[i].Role == name {
+			return &roles[i]
+		}
+	}
+	return nil
+}
+
+func TestForecast(t *testing.T) {
+	f := newMaintenanceFixture(t, "UTC")
+	f.user(t, "alice", rbac.RoleTechnician)
+	if err := NewCalendarService(f.repo).AddHoliday(&repository.Holiday{OrganizationID: f.org.ID, Date: "2026-03-03", Name: "Founders' Day"}); err != nil {
+		t.Fatal(err)
+	}
+	// Six hours of technician work every day, a weekly Saturday check that is
+	// moved to Monday without an estimate, and a manager review nobody can do.
+	f.forecastPlan(t, rbac.RoleTechnician, 1, "2026-03-02", calendar.ShiftNone, float(6))
+	f.forecastPlan(t, "", 7, "2026-02-28", calendar.ShiftNext, nil)
+	f.forecastPlan(t, rbac.RoleMaintenanceManager, 14, "2026-03-10", calendar.ShiftNone, float(3))
+
+	from, to := day(t, "2026-03-02"), day(t, "2026-03-15")
+	forecast, err := NewMaintenanceService(f.repo).Forecast(f.org.ID, &from, &to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forecast.Occurrences != 17 || forecast.Unestimated != 2 || forecast.Hours != 87 || forecast.CapacityHours != 72 || !forecast.OverAllocated {
+		t.Errorf("forecast = %d occurrences (%d unestimated), %v of %v hours, over allocated %v; want 17 (2), 87 of 72, true",
+			forecast.Occurrences, forecast.Unestimated, forecast.Hours, forecast.CapacityHours, forecast.OverAllocated)
+	}
+	if len(forecast.Roles) != 3 || forecast.Roles[0].Role != rbac.RoleMaintenanceManager || forecast.Roles[2].Role != UnassignedRole {
+		t.Fatalf("roles = %+v, want maintenance_manager, technician and unassigned", forecast.Roles)
+	}
+	if tech := forecastRole(forecast.Roles, rbac.RoleTechnician); tech.Occurrences != 14 || tech.Hours != 84 || tech.CapacityHours != 72 || tech.Technicians != 1 || !tech.OverAllocated {
+		t.Errorf("technician = %+v", tech)
+	}
+	if manager := forecastRole(forecast.Roles, rbac.RoleMaintenanceManager); manager.Occurrences != 1 || manager.CapacityHours != 0 || !manager.OverAllocated {
+		t.Errorf("maintenance_manager = %+v", manager)
+	}
+	if unassigned := forecastRole(forecast.Roles, UnassignedRole); unassigned.Occurrences != 2 || unassigned.OverAllocated {
+		t.Errorf("unassigned = %+v", unassigned)
+	}
+
+	if len(forecast.Weeks) != 2 {
+		t.Fatalf("got %d weeks, want 2", len(forecast.Weeks))
+	}
+	first, second := forecast.Weeks[0], forecast.Weeks[1]
+	if !first.WeekStart.Equal(from) || first.CapacityHours != 32 || first.Occurrences != 8 || first.Hours != 42 {
+		t.Errorf("first week = %+v, want 8 occurrences and 42 hours against 32 hours around the holiday", first)
+	}
+	if second.CapacityHours != 40 || second.Occurrences != 9 || second.Hours != 45 || !second.OverAllocated {
+		t.Errorf("second week = %+v, want 9 occurrences and 45 hours against 40", second)
+	}
+	if forecastRole(first.Roles, UnassignedRole) == nil || forecastRole(second.Roles, UnassignedRole) == nil {
+		t.Error("the shifted Saturday checks are missing from a week")
+	}
+
+	// Capacity only counts the days of a week that are inside the range.
+	from, to = day(t, "2026-03-11"), day(t, "2026-03-12")
+	if forecast, err = NewMaintenanceService(f.repo).Forecast(f.org.ID, &from, &to); err != nil {
+		t.Fatal(err)
+	}
+	if len(forecast.Weeks) != 1 || forecast.CapacityHours != 16 || forecast.Occurrences != 2 {
+		t.Errorf("partial week = %+v, want 2 occurrences against 16 hours", forecast)
+	}
+}
+
+func TestForecastRange(t *testing.T) {
+	f := newMaintenanceFixture(t, "UTC")
+	maintenance := NewMaintenanceService(f.repo)
+	from := day(t, "2026-03-02")
+	for _, to := range []time.Time{from.AddDate(0, 0, -1), from.AddDate(0, 0, MaxForecastDays)} {
+		if _, err := maintenance.Forecast(f.org.ID, &from, &to); !errors.Is(err, ErrInvalidForecastRange) {
+			t.Errorf("Forecast to %s = %v, want ErrInvalidForecastRange", to.Format(calendar.DateLayout), err)
+		}
+	}
+	to := from.AddDate(0, 0, MaxForecastDays-1)
+	if _, err := maintenance.Forecast(f.org.ID, &from, &to); err != nil {
+		t.Errorf("Forecast over %d days = %v", MaxForecastDays, err)
+	}
+	forecast, err := maintenance.Forecast(f.org.ID, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if days := int(forecast.To.Sub(forecast.From).Hours()/24) + 1; days != DefaultForecastDays {
+		t.Errorf("default range covers %d days, want %d", days, DefaultForecastDays)
+	}
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"assetsentinel/internal/repository"
+
+	"github.com/robfig/cron/v3"
+)
+
+var ErrInvalidJobSchedule = errors.New("invalid job schedule")
+
+func NextJobRun(schedule string, after time.Time) (time.Time, error) {
+	parsed, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrInvalidJobSchedule, err)
+	}
+	return parsed.Next(after.UTC()).Truncate(time.Second), nil
+}
+
+type JobService struct {
+	repo *repository.Repository
+}
+
+func NewJobService(repo *repository.Repository) *JobService {
+	return &JobService{repo: repo}
+}
+
+func (s *JobService) List() ([]repository.Job, error) {
+	return s.repo.ListJobs()
+}
+
+func (s *JobService) Get(name string) (*repository.Job, error) {
+	return s.repo.GetJob(name)
+}
+
+func (s *JobService) Runs(name string, page, pageSize int) ([]repository.JobRun, int, error) {
+	if _, err := s.repo.GetJob(name); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.ListJobRuns(name, page, pageSize)
+}
+
+func (s *JobService) Update(name string, schedule *string, enabled *bool) (*repository.Job, error) {
+	job, err := s.repo.GetJob(name)
+	if err != nil {
+		return nil, err
+	}
+	if schedule != nil {
+		job.Schedule = *schedule
+	}
+	if enabled != nil {
+		job.Enabled = *enabled
+	}
+
+	job.NextRunAt, err = NextJobRun(job.Schedule, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateJobSchedule(job); err != nil {
+		return nil, err
+	}
+	return s.repo.GetJob(name)
+}
+
+func (s *JobService) Trigger(name string) (*repository.Job, error) {
+	if err := s.repo.RequestJobRun(name, time.Now()); err != nil {
+		return nil, err
+	}
+	return s.repo.GetJob(name)
+}
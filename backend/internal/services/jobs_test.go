@@ -0,0 +1,78 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNextJobRun(t *testing.T) {
+	after := time.Date(2026, 3, 14, 10, 30, 15, 0, time.UTC)
+	tests := []struct {
+		schedule string
+		want     time.Time
+	}{
+		{"0 * * * *", time.Date(2026, 3, 14, 11, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 3, 14, 10, 45, 0, 0, time.UTC)},
+		{"0 6 * * 1", time.Date(2026, 3, 16, 6, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := NextJobRun(tt.schedule, after)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("NextJobRun(%q) = %v, %v, want %v", tt.schedule, got, err, tt.want)
+		}
+	}
+
+	local := time.Date(2026, 3, 14, 11, 30, 0, 0, time.FixedZone("CET", 3600))
+	if got, _ := NextJobRun("0 * * * *", local); !got.Equal(time.Date(2026, 3, 14, 11, 0, 0, 0, time.UTC)) || got.Location() != time.UTC {
+		t.Errorf("schedules are not evaluated in UTC: got %v", got)
+	}
+
+	for _, schedule := range []string{"", "every hour", "61 * * * *", "* * * *"} {
+		if _, err := NextJobRun(schedule, after); !errors.Is(err, ErrInvalidJobSchedule) {
+			t.Errorf("NextJobRun(%q) = %v, want ErrInvalidJobSchedule", schedule, err)
+		}
+	}
+}
+
+func TestJobServiceUpdate(t *testing.T) {
+	repo := newTestRepository(t)
+	jobs := NewJobService(repo)
+	if err := repo.RegisterJob("maintenance_due", "0 * * * *", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := "every hour"
+	if _, err := jobs.Update("maintenance_due", &invalid, nil); !errors.Is(err, ErrInvalidJobSchedule) {
+		t.Errorf("invalid schedule: %v, want ErrInvalidJobSchedule", err)
+	}
+	if _, err := jobs.Update("missing", nil, nil); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("unknown job: %v, want sql.ErrNoRows", err)
+	}
+
+	daily := "0 6 * * *"
+	job, err := jobs.Update("maintenance_due", &daily, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, _ := NextJobRun(daily, time.Now())
+	if job.Schedule != daily || !job.NextRunAt.Equal(next) {
+		t.Errorf("job = %+v, want the daily schedule next at %v", job, next)
+	}
+
+	job, err = jobs.Trigger("maintenance_due")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !job.RunRequested || job.NextRunAt.After(time.Now()) {
+		t.Errorf("triggered job = %+v, want it requested and due now", job)
+	}
+	if _, err := jobs.Update("maintenance_due", &daily, nil); err != nil {
+		t.Fatal(err)
+	}
+	if job, _ := jobs.Get("maintenance_due"); job.NextRunAt.After(time.Now()) {
+		t.Error("updating the schedule postponed a requested run")
+	}
+}
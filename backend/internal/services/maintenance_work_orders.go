@@ -0,0 +1,253 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"assetsentinel/internal/calendar"
+	"assetsentinel/internal/events"
+	"assetsentinel/internal/repository"
+)
+
+const (
+	AssignRoundRobin  = "round_robin"
+	AssignLeastLoaded = "least_loaded"
+)
+
+var ErrInvalidWorkOrderSettings = errors.New("invalid work order settings")
+
+func validPriority(priority string) bool {
+	switch priority {
+	case "low", "medium", "high", "critical":
+		return true
+	}
+	return false
+}
+
+func workOrderDone(status string) bool {
+	return status == "completed" || status == "closed"
+}
+
+func normalizeWorkOrderSettings(plan *repository.MaintenancePlan) error {
+	if plan.WorkOrderPriority == "" {
+		plan.WorkOrderPriority = "medium"
+	}
+	if !validPriority(plan.WorkOrderPriority) {
+		return fmt.Errorf("%w: work_order_priority must be low, medium, high or critical", ErrInvalidWorkOrderSettings)
+	}
+	if plan.AssignmentStrategy == "" {
+		plan.AssignmentStrategy = AssignRoundRobin
+	}
+	if plan.AssignmentStrategy != AssignRoundRobin && plan.AssignmentStrategy != AssignLeastLoaded {
+		return fmt.Errorf("%w: assignment_strategy must be round_robin or least_loaded", ErrInvalidWorkOrderSettings)
+	}
+	if plan.EstimatedDurationHours != nil && *plan.EstimatedDurationHours < 0 {
+		return fmt.Errorf("%w: estimated_duration_hours cannot be negative", ErrInvalidWorkOrderSettings)
+	}
+	return nil
+}
+
+// CreateForTask opens the work order for a task generated from plan. It is
+// scheduled for the working hours of the task's date, or for the plan's
+// estimated duration from the start of them, and assigned to a technician
+// free at that time. It runs inside the caller's transaction so the task and
+// its work order are created together; the caller sends the assignment
+// notification once it commits.
+func (s *WorkOrderService) CreateForTask(tx *repository.Repository, plan *repository.MaintenancePlan, task *repository.MaintenanceTask) (*repository.WorkOrder, error) {
+	asset, err := tx.GetAsset(plan.AssetID, plan.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	cal, err := tx.GetBusinessCalendar(plan.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	title := fmt.Sprintf("Preventive maintenance: %s (%s)", asset.Name, task.ScheduledDate.Format(calendar.DateLayout))
+	if plan.WorkOrderTitle != nil && *plan.WorkOrderTitle != "" {
+		title = *plan.WorkOrderTitle
+	}
+	start, end := cal.WorkdayWindow(task.ScheduledDate)
+	if plan.EstimatedDurationHours != nil && *plan.EstimatedDurationHours > 0 {
+		end = start.Add(time.Duration(*plan.EstimatedDurationHours * float64(time.Hour)))
+	}
+	start, end = start.UTC(), end.UTC()
+	taskID := task.ID
+	wo := &repository.WorkOrder{
+		OrganizationID:         plan.OrganizationID,
+		AssetID:                plan.AssetID,
+		MaintenanceTaskID:      &taskID,
+		ProcedureID:            plan.ProcedureID,
+		Title:                  title,
+		Description:            plan.WorkOrderDescription,
+		Status:                 "pending",
+		Priority:               plan.WorkOrderPriority,
+		EstimatedDurationHours: plan.EstimatedDurationHours,
+		ScheduledStart:         &start,
+		ScheduledEnd:           &end,
+	}
+
+	assignee, err := s.pickAssignee(tx, plan, wo)
+	if err != nil {
+		return nil, err
+	}
+	if assignee != nil {
+		wo.TechnicianID = &assignee.ID
+	}
+	if err := s.dispatch.ValidateAssignment(wo, nil); err != nil {
+		return nil, err
+	}
+	if err := s.dispatch.CheckConflicts(tx, wo, nil); err != nil {
+		return nil, err
+	}
+	if assignee != nil {
+		if err := tx.SetMaintenancePlanLastAssignee(plan.ID, plan.OrganizationID, assignee.ID); err != nil {
+			return nil, err
+		}
+		plan.LastAssigneeID = &assignee.ID
+	}
+
+	if err := tx.CreateWorkOrder(wo); err != nil {
+		return nil, err
+	}
+	if err := events.Enqueue(tx, wo.OrganizationID, events.System(), events.WorkOrderCreated{WorkOrder: events.NewWorkOrder(wo)}); err != nil {
+		return nil, err
+	}
+	return wo, nil
+}
+
+func (s *WorkOrderService) NotifyAssigned(wo *repository.WorkOrder) {
+	s.notifyAssignment(wo, nil)
+}
+
+// pickAssignee chooses a user holding the plan's assigned role who can be
+// dispatched to wo and is free in its window. Candidates are taken in rotation
+// after the plan's previous assignee, so round robin takes the first of them
+// and least loaded breaks ties the same way. The work order is left
+// unassigned when nobody is free.
+func (s *WorkOrderService) pickAssignee(tx *repository.Repository, plan *repository.MaintenancePlan, wo *repository.WorkOrder) (*repository.User, error) {
+	if plan.AssignedRole == nil || *plan.AssignedRole == "" {
+		return nil, nil
+	}
+	users, err := tx.ListUsersByRole(plan.OrganizationID, *plan.AssignedRole)
+	if err != nil || len(users) == 0 {
+		return nil, err
+	}
+
+	start := 0
+	if plan.LastAssigneeID != nil {
+		for i, user := range users {
+			if user.ID > *plan.LastAssigneeID {
+				start = i
+				break
+			}
+		}
+	}
+	rotation := []repository.User{}
+	for _, user := range append(append([]repository.User{}, users[start:]...), users[:start]...) {
+		ok, err := s.dispatch.available(tx, wo, &user)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			rotation = append(rotation, user)
+		}
+	}
+	if len(rotation) == 0 {
+		return nil, nil
+	}
+	if plan.AssignmentStrategy != AssignLeastLoaded {
+		return &rotation[0], nil
+	}
+
+	loads, err := tx.GetOpenWorkOrderLoad(plan.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	best := 0
+	for i := 1; i < len(rotation); i++ {
+		candidate, current := loads[rotation[i].ID], loads[rotation[best].ID]
+		if candidate.OpenHours < current.OpenHours ||
+			(candidate.OpenHours == current.OpenHours && candidate.OpenWorkOrders < current.OpenWorkOrders) {
+			best = i
+		}
+	}
+	return &rotation[best], nil
+}
+
+func (s *MaintenanceService) GetTask(id, orgID uint) (*repository.MaintenanceTask, error) {
+	return s.repo.GetMaintenanceTask(id, orgID)
+}
+
+// CompleteTask marks a task completed and completes its open work order in the
+// same transaction. Completing an already completed task is a no-op.
+func (s *MaintenanceService) CompleteTask(id, orgID, actorID uint, notes *string) (*repository.MaintenanceTask, error) {
+	task, err := s.repo.GetMaintenanceTask(id, orgID)
+	if err != nil {
+		return nil, err
+	}
+	cal, err := s.repo.GetBusinessCalendar(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	task.Notes = notes
+	err = s.repo.WithTx(func(tx *repository.Repository) error {
+		completed, err := tx.CompleteMaintenanceTask(task, cal.Today(time.Now()))
+		if err != nil || !completed {
+			return err
+		}
+		return completeTaskWorkOrder(tx, task, actorID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetMaintenanceTask(id, orgID)
+}
+
+func completeTaskWorkOrder(tx *repository.Repository, task *repository.MaintenanceTask, actorID uint) error {
+	wo, err := tx.GetOpenWorkOrderForTask(task.ID, task.OrganizationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	oldStatus := wo.Status
+	wo.Status = "completed"
+	if err := requireProcedureResults(tx, wo, nil); err != nil {
+		return err
+	}
+	if wo.ActualEnd == nil {
+		now := time.Now().UTC()
+		wo.ActualEnd = &now
+	}
+	if err := tx.UpdateWorkOrder(wo); err != nil {
+		return err
+	}
+	return events.Enqueue(tx, wo.OrganizationID, events.User(actorID), events.WorkOrderStatusChanged{
+		WorkOrder: events.NewWorkOrder(wo),
+		OldStatus: oldStatus,
+		NewStatus: wo.Status,
+	})
+}
+
+func completeWorkOrderTask(tx *repository.Repository, wo *repository.WorkOrder) error {
+	task, err := tx.GetMaintenanceTask(*wo.MaintenanceTaskID, wo.OrganizationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cal, err := tx.GetBusinessCalendar(wo.OrganizationID)
+	if err != nil {
+		return err
+	}
+	task.Notes = nil
+	_, err = tx.CompleteMaintenanceTask(task, cal.Today(time.Now()))
+	return err
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"assetsentinel/internal/calendar"
+	"assetsentinel/internal/rbac"
+	"assetsentinel/internal/repository"
+)
+
+type maintenanceFixture struct {
+	repo       *repository.Repository
+	workOrders *WorkOrderService
+	org        repository.Organization
+	asset      repository.Asset
+}
+
+func newMaintenanceFixture(t *testing.T, timeZone string) *maintenanceFixture {
+	t.Helper()
+	repo := newTestRepository(t)
+	f := &maintenanceFixture{
+		repo:       repo,
+		workOrders: NewWorkOrderService(repo, nil, NewDispatchService(repo, NewRoleService(repo))),
+		org:        repository.Organization{Name: "Acme", TimeZone: timeZone},
+	}
+	if err := repo.CreateOrganization(&f.org); err != nil {
+		t.Fatal(err)
+	}
+	f.asset = repository.Asset{OrganizationID: f.org.ID, Name: "Pump", Category: "pump", Status: "active"}
+	if err := repo.CreateAsset(&f.asset); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func (f *maintenanceFixture) user(t *testing.T, name, role string) *repository.User {
+	t.Helper()
+	user := &repository.User{OrganizationID: f.org.ID, Email: name + "@acme.test", PasswordHash: "x", FullName: name, Role: role}
+	if err := f.repo.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func (f *maintenanceFixture) plan(t *testing.T, role string, hours *float64) *repository.MaintenancePlan {
+	t.Helper()
+	plan := &repository.MaintenancePlan{OrganizationID: f.org.ID, AssetID: f.asset.ID, FrequencyDays: 7, NextMaintenanceDate: day(t, "2026-03-30"),
+		NonWorkingDayShift: calendar.ShiftNone, AutoWorkOrder: true, AssignedRole: &role, EstimatedDurationHours: hours,
+		WorkOrderPriority: "medium", AssignmentStrategy: AssignRoundRobin}
+	if err := f.repo.CreateMaintenancePlan(plan); err != nil {
+		t.Fatal(err)
+	}
+	return plan
+}
+
+func (f *maintenanceFixture) createForTask(t *testing.T, plan *repository.MaintenancePlan, date string) *repository.WorkOrder {
+	t.Helper()
+	task := &repository.MaintenanceTask{OrganizationID: f.org.ID, MaintenancePlanID: plan.ID, AssetID: f.asset.ID, ScheduledDate: day(t, date), Status: "pending"}
+	var wo *repository.WorkOrder
+	err := f.repo.WithTx(func(tx *repository.Repository) error {
+		if _, err := tx.CreateMaintenanceTaskOnce(task); err != nil {
+			return err
+		}
+		var err error
+		wo, err = f.workOrders.CreateForTask(tx, plan, task)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wo
+}
+
+func assignee(wo *repository.WorkOrder) string {
+	if wo.TechnicianID == nil {
+		return "nobody"
+	}
+	return fmt.Sprint(*wo.TechnicianID)
+}
+
+func TestCreateForTaskSchedulesTheWorkingDay(t *testing.T) {
+	f := newMaintenanceFixture(t, "Europe/Berlin")
+	f.user(t, "alice", rbac.RoleTechnician)
+	two := 2.0
+
+	// 2026-03-30 is the first Monday of summer time in Berlin (UTC+2).
+	wo := f.createForTask(t, f.plan(t, rbac.RoleTechnician, &two), "2026-03-30")
+	if want := time.Date(2026, 3, 30, 6, 0, 0, 0, time.UTC); wo.ScheduledStart == nil || !wo.ScheduledStart.Equal(want) {
+		t.Errorf("scheduled_start = %v, want %v", wo.ScheduledStart, want)
+	}
+	if want := time.Date(2026, 3, 30, 8, 0, 0, 0, time.UTC); wo.ScheduledEnd == nil || !wo.ScheduledEnd.Equal(want) {
+		t.Errorf("scheduled_end = %v, want %v", wo.ScheduledEnd, want)
+	}
+
+	// Without an estimate the work order takes the whole working day.
+	wo = f.createForTask(t, f.plan(t, rbac.RoleTechnician, nil), "2026-03-20")
+	if want := time.Date(2026, 3, 20, 15, 0, 0, 0, time.UTC); wo.ScheduledEnd == nil || !wo.ScheduledEnd.Equal(want) {
+		t.Errorf("scheduled_end = %v, want %v", wo.ScheduledEnd, want)
+	}
+}
+
+func TestCreateForTaskAssignsAFreeEligibleTechnician(t *testing.T) {
+	f := newMaintenanceFixture(t, "UTC")
+	alice := f.user(t, "alice", rbac.RoleTechnician)
+	bob := f.user(t, "bob", rbac.RoleTechnician)
+	two := 2.0
+
+	busyStart, busyEnd := time.Date(2026, 3, 30, 9, 0, 0, 0, time.UTC), time.Date(2026, 3, 30, 11, 0, 0, 0, time.UTC)
+	busy := &repository.WorkOrder{OrganizationID: f.org.ID, AssetID: f.asset.ID, TechnicianID: &alice.ID, Title: "Inspection",
+		Status: "in_progress", Priority: "medium", ScheduledStart: &busyStart, ScheduledEnd: &busyEnd}
+	if err := f.repo.CreateWorkOrder(busy); err != nil {
+		t.Fatal(err)
+	}
+
+	plan := f.plan(t, rbac.RoleTechnician, &two)
+	if wo := f.createForTask(t, plan, "2026-03-30"); assignee(wo) != fmt.Sprint(bob.ID) {
+		t.Errorf("work order assigned to %s, want bob (%d) since alice is busy", assignee(wo), bob.ID)
+	}
+	if wo := f.createForTask(t, plan, "2026-03-31"); assignee(wo) != fmt.Sprint(alice.ID) {
+		t.Errorf("work order assigned to %s, want alice (%d) in rotation after bob", assignee(wo), alice.ID)
+	}
+	// Both technicians now have work from 08:00 to 10:00 on 2026-03-30.
+	if wo := f.createForTask(t, f.plan(t, rbac.RoleTechnician, &two), "2026-03-30"); wo.TechnicianID != nil {
+		t.Errorf("work order assigned to %s although everyone is booked", assignee(wo))
+	}
+}
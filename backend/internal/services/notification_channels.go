@@ -0,0 +1,107 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"assetsentinel/internal/mailer"
+	"assetsentinel/internal/repository"
+)
+
+const (
+	ChannelInApp = "in_app"
+	ChannelEmail = "email"
+	ChannelChat  = "chat"
+
+	ChatFormatGeneric = "generic"
+	ChatFormatSlack   = "slack"
+	ChatFormatTeams   = "teams"
+	ChatFormatDiscord = "discord"
+)
+
+var chatFormats = []string{ChatFormatGeneric, ChatFormatSlack, ChatFormatTeams, ChatFormatDiscord}
+
+var ErrNoChatWebhook = errors.New("no chat webhook URL configured")
+
+type NotificationRecipient struct {
+	User     *repository.User
+	Settings *repository.NotificationSettings
+}
+
+type NotificationChannel interface {
+	Send(recipient NotificationRecipient, title, body string) error
+}
+
+type EmailChannel struct {
+	mailer mailer.Mailer
+}
+
+func NewEmailChannel(m mailer.Mailer) *EmailChannel {
+	return &EmailChannel{mailer: m}
+}
+
+func (c *EmailChannel) Send(recipient NotificationRecipient, title, body string) error {
+	return c.mailer.Send(mailer.Message{
+		To:      []string{recipient.User.Email},
+		Subject: title,
+		Body:    body,
+	})
+}
+
+type ChatChannel struct {
+	client *http.Client
+}
+
+func NewChatChannel() *ChatChannel {
+	return &ChatChannel{client: newOutboundClient(10 * time.Second)}
+}
+
+func (c *ChatChannel) Send(recipient NotificationRecipient, title, body string) error {
+	settings := recipient.Settings
+	if settings.ChatWebhookURL == nil || *settings.ChatWebhookURL == "" {
+		return ErrNoChatWebhook
+	}
+	format := ChatFormatGeneric
+	if settings.ChatFormat != nil && *settings.ChatFormat != "" {
+		format = *settings.ChatFormat
+	}
+
+	payload, err := json.Marshal(chatPayload(format, title, body))
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Post(*settings.ChatWebhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("chat webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func chatPayload(format, title, body string) map[string]interface{} {
+	switch format {
+	case ChatFormatSlack:
+		return map[string]interface{}{"text": "*" + title + "*\n" + body}
+	case ChatFormatTeams:
+		return map[string]interface{}{
+			"@type":    "MessageCard",
+			"@context": "https://schema.org/extensions",
+			"summary":  title,
+			"title":    title,
+			"text":     body,
+		}
+	case ChatFormatDiscord:
+		return map[string]interface{}{"content": "**" + title + "**\n" + body}
+	}
+	return map[string]interface{}{"title": title, "message": body}
+}
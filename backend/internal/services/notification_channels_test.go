@@ -0,0 +1,31 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"assetsentinel/internal/repository"
+)
+
+func TestChatChannelRefusesPrivateAddress(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer server.Close()
+
+	url := server.URL
+	err := NewChatChannel().Send(NotificationRecipient{
+		User:     &repository.User{Email: "tech@acme.test"},
+		Settings: &repository.NotificationSettings{ChatWebhookURL: &url},
+	}, "Work order assigned", "Replace the pump seal")
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("got %v, want ErrPrivateAddress", err)
+	}
+	if hits.Load() != 0 {
+		t.Errorf("the private endpoint was called %d times", hits.Load())
+	}
+}
@@ -0,0 +1,517 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"assetsentinel/internal/events"
+	"assetsentinel/internal/rbac"
+	"assetsentinel/internal/repository"
+)
+
+const (
+	NotificationWorkOrderAssigned  = "work_order_assigned"
+	NotificationMaintenanceOverdue = "maintenance_overdue"
+	NotificationLowInventory       = "low_inventory"
+
+	PreferenceSourceUser         = "user"
+	PreferenceSourceOrganization = "organization"
+	PreferenceSourceDefault      = "default"
+
+	notificationMaxAttempts = 5
+	notificationLease       = 2 * time.Minute
+)
+
+var notificationDefaults = map[string]repository.NotificationPreference{
+	NotificationWorkOrderAssigned:  {Channels: []string{ChannelInApp, ChannelEmail}},
+	NotificationMaintenanceOverdue: {Channels: []string{ChannelInApp, ChannelEmail}},
+	NotificationLowInventory:       {Channels: []string{ChannelInApp, ChannelEmail}, Digest: true},
+}
+
+var ErrInvalidNotificationPreference = errors.New("invalid notification preference")
+
+type EffectivePreference struct {
+	repository.NotificationPreference
+	Source string `json:"source"`
+}
+
+type NotificationPreferences struct {
+	Settings    *repository.NotificationSettings `json:"settings"`
+	Preferences []EffectivePreference            `json:"preferences"`
+	Channels    []string                         `json:"channels"`
+	ChatFormats []string                         `json:"chat_formats"`
+}
+
+type NotificationService struct {
+	repo *repository.Repository
+	hub  interface {
+		SendToUser(orgID, userID uint, message map[string]interface{})
+	}
+	channels   map[string]NotificationChannel
+	digestHour int
+}
+
+func NewNotificationService(repo *repository.Repository, hub interface {
+	SendToUser(orgID, userID uint, message map[string]interface{})
+}, channels map[string]NotificationChannel, digestHour int) *NotificationService {
+	return &NotificationService{repo: repo, hub: hub, channels: channels, digestHour: digestHour}
+}
+
+func (s *NotificationService) Notify(orgID, userID uint, notificationType, title, message string, data interface{}) error {
+	preference, err := s.effectivePreference(orgID, userID, notificationType)
+	if err != nil {
+		return err
+	}
+	settings, err := s.effectiveSettings(orgID, userID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, channel := range preference.Channels {
+		if channel == ChannelInApp {
+			if err := s.notifyInApp(orgID, userID, notificationType, title, message, data); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, ok := s.channels[channel]; !ok {
+			continue
+		}
+
+		delivery := &repository.NotificationDelivery{
+			OrganizationID:   orgID,
+			UserID:           userID,
+			Channel:          channel,
+			NotificationType: notificationType,
+			Title:            title,
+			Digest:           preference.Digest,
+			DeliverAfter:     s.deliverAfter(settings, preference.Digest, now),
+		}
+		if message != "" {
+			delivery.Message = &message
+		}
+		if err := s.repo.CreateNotificationDelivery(delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *NotificationService) NotifyRoles(orgID uint, roles []string, notificationType, title, message string, data interface{}) error {
+	users, err := s.repo.ListUsersWithRoles(orgID, roles)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if err := s.Notify(orgID, user.ID, notificationType, title, message, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *NotificationService) Consume(event *events.Envelope) error {
+	low, ok := event.Data.(events.LowInventory)
+	if !ok {
+		return nil
+	}
+	part := low.Part
+	return s.NotifyRoles(event.OrganizationID, []string{rbac.RoleAdmin, rbac.RoleMaintenanceManager}, NotificationLowInventory,
+		"Low inventory: "+part.Name, fmt.Sprintf("%s (%s) is down to %d units; the reorder threshold is %d.", part.Name, part.SKU, part.Quantity, part.MinThreshold),
+		map[string]interface{}{"part_id": part.ID})
+}
+
+func (s *NotificationService) notifyInApp(orgID, userID uint, notificationType, title, message string, data interface{}) error {
+	n := &repository.Notification{
+		OrganizationID: orgID,
+		UserID:         userID,
+		Type:           notificationType,
+		Title:          title,
+	}
+	if message != "" {
+		n.Message = &message
+	}
+	if data != nil {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		n.Data = encoded
+	}
+
+	if err := s.repo.CreateNotification(n); err != nil {
+		return err
+	}
+
+	if s.hub != nil {
+		unread, err := s.repo.CountUnreadNotifications(userID, orgID)
+		if err != nil {
+			log.Printf("Error counting unread notifications: %v", err)
+		}
+		s.hub.SendToUser(orgID, userID, map[string]interface{}{
+			"type":         "notification",
+			"notification": n,
+			"unread_count": unread,
+		})
+	}
+	return nil
+}
+
+func (s *NotificationService) List(userID, orgID uint, page, pageSize int, unreadOnly bool) ([]repository.Notification, int, error) {
+	return s.repo.ListNotifications(userID, orgID, page, pageSize, unreadOnly)
+}
+
+func (s *NotificationService) UnreadCount(userID, orgID uint) (int, error) {
+	return s.repo.CountUnreadNotifications(userID, orgID)
+}
+
+func (s *NotificationService) MarkRead(id, userID, orgID uint) error {
+	return s.repo.MarkNotificationRead(id, userID, orgID)
+}
+
+func (s *NotificationService) MarkAllRead(userID, orgID uint) (int64, error) {
+	return s.repo.MarkAllNotificationsRead(userID, orgID)
+}
+
+func (s *NotificationService) Preferences(orgID, userID uint) (*NotificationPreferences, error) {
+	settings, err := s.repo.GetNotificationSettings(orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &NotificationPreferences{
+		Settings:    settings,
+		Preferences: []EffectivePreference{},
+		Channels:    s.channelNames(),
+		ChatFormats: chatFormats,
+	}
+	for _, notificationType := range sortedKeys(notificationDefaults) {
+		preference, err := s.effectivePreference(orgID, userID, notificationType)
+		if err != nil {
+			return nil, err
+		}
+		result.Preferences = append(result.Preferences, *preference)
+	}
+	return result, nil
+}
+
+func (s *NotificationService) UpdatePreferences(settings *repository.NotificationSettings, preferences []repository.NotificationPreference) error {
+	if err := s.validateSettings(settings); err != nil {
+		return err
+	}
+	for _, preference := range preferences {
+		if err := s.validatePreference(preference); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.WithTx(func(tx *repository.Repository) error {
+		if err := tx.SaveNotificationSettings(settings); err != nil {
+			return err
+		}
+		return tx.ReplaceNotificationPreferences(settings.OrganizationID, settings.UserID, preferences)
+	})
+}
+
+func (s *NotificationService) DeliverDue(limit int) int {
+	now := time.Now().UTC().Truncate(time.Second)
+	deliveries, err := s.repo.ListDueNotificationDeliveries(now, limit)
+	if err != nil {
+		log.Printf("Error loading due notifications: %v", err)
+		return 0
+	}
+
+	var digests [][]repository.NotificationDelivery
+	for _, delivery := range deliveries {
+		claimed, err := s.repo.ClaimNotificationDelivery(delivery.ID, now, now.Add(notificationLease))
+		if err != nil {
+			log.Printf("Error claiming notification %d: %v", delivery.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		if !delivery.Digest {
+			s.send([]repository.NotificationDelivery{delivery})
+			continue
+		}
+
+		last := len(digests) - 1
+		if last >= 0 && digests[last][0].UserID == delivery.UserID && digests[last][0].Channel == delivery.Channel {
+			digests[last] = append(digests[last], delivery)
+		} else {
+			digests = append(digests, []repository.NotificationDelivery{delivery})
+		}
+	}
+	for _, digest := range digests {
+		s.send(digest)
+	}
+	return len(deliveries)
+}
+
+func (s *NotificationService) send(batch []repository.NotificationDelivery) {
+	first := batch[0]
+	var err error
+	defer func() {
+		s.recordDelivery(batch, err)
+	}()
+
+	channel, ok := s.channels[first.Channel]
+	if !ok {
+		err = fmt.Errorf("channel %q is not configured", first.Channel)
+		return
+	}
+	user, err := s.repo.GetUser(first.UserID, first.OrganizationID)
+	if err != nil {
+		return
+	}
+	settings, err := s.effectiveSettings(first.OrganizationID, first.UserID)
+	if err != nil {
+		return
+	}
+
+	title, body := first.Title, ""
+	if first.Message != nil {
+		body = *first.Message
+	}
+	if first.Digest {
+		title, body = digestMessage(batch)
+	}
+	err = channel.Send(NotificationRecipient{User: user, Settings: settings}, title, body)
+}
+
+func (s *NotificationService) recordDelivery(batch []repository.NotificationDelivery, sendErr error) {
+	now := time.Now().UTC()
+	for i := range batch {
+		delivery := &batch[i]
+		delivery.Attempts++
+		if sendErr == nil {
+			delivery.Status = "sent"
+			delivery.SentAt = &now
+			delivery.Error = nil
+		} else {
+			message := sendErr.Error()
+			delivery.Error = &message
+			if delivery.Attempts >= notificationMaxAttempts || errors.Is(sendErr, ErrNoChatWebhook) {
+				delivery.Status = "failed"
+			} else {
+				delivery.DeliverAfter = now.Add(time.Duration(delivery.Attempts) * 5 * time.Minute).Truncate(time.Second)
+			}
+		}
+		if err := s.repo.RecordNotificationDelivery(delivery); err != nil {
+			log.Printf("Error recording notification delivery %d: %v", delivery.ID, err)
+		}
+	}
+	if sendErr != nil {
+		log.Printf("Error sending %s notification to user %d: %v", batch[0].Channel, batch[0].UserID, sendErr)
+	}
+}
+
+func digestMessage(batch []repository.NotificationDelivery) (string, string) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d notifications since your last digest:\n\n", len(batch))
+	for _, delivery := range batch {
+		fmt.Fprintf(&b, "- %s", delivery.Title)
+		if delivery.Message != nil {
+			fmt.Fprintf(&b, ": %s", *delivery.Message)
+		}
+		b.WriteString("\n")
+	}
+	return fmt.Sprintf("AssetSentinel daily digest (%d)", len(batch)), b.String()
+}
+
+func (s *NotificationService) effectivePreference(orgID, userID uint, notificationType string) (*EffectivePreference, error) {
+	type scope struct {
+		userID uint
+		source string
+	}
+	scopes := []scope{{0, PreferenceSourceOrganization}}
+	if userID != 0 {
+		scopes = append([]scope{{userID, PreferenceSourceUser}}, scopes...)
+	}
+
+	for _, scope := range scopes {
+		preferences, err := s.repo.ListNotificationPreferences(orgID, scope.userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, preference := range preferences {
+			if preference.NotificationType == notificationType {
+				return &EffectivePreference{NotificationPreference: preference, Source: scope.source}, nil
+			}
+		}
+	}
+
+	preference, ok := notificationDefaults[notificationType]
+	if !ok {
+		preference = repository.NotificationPreference{Channels: []string{ChannelInApp}}
+	}
+	preference.NotificationType = notificationType
+	return &EffectivePreference{NotificationPreference: preference, Source: PreferenceSourceDefault}, nil
+}
+
+func (s *NotificationService) effectiveSettings(orgID, userID uint) (*repository.NotificationSettings, error) {
+	org, err := s.repo.GetNotificationSettings(orgID, 0)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.repo.GetNotificationSettings(orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.QuietHoursStart == nil && user.QuietHoursEnd == nil {
+		user.QuietHoursStart, user.QuietHoursEnd = org.QuietHoursStart, org.QuietHoursEnd
+	}
+	for _, field := range []struct{ user, org **string }{
+		{&user.TimeZone, &org.TimeZone},
+		{&user.ChatWebhookURL, &org.ChatWebhookURL},
+		{&user.ChatFormat, &org.ChatFormat},
+	} {
+		if *field.user == nil || **field.user == "" {
+			*field.user = *field.org
+		}
+	}
+	if user.TimeZone == nil || *user.TimeZone == "" {
+		if organization, err := s.repo.GetOrganization(orgID); err == nil {
+			user.TimeZone = &organization.TimeZone
+		}
+	}
+	return user, nil
+}
+
+func (s *NotificationService) deliverAfter(settings *repository.NotificationSettings, digest bool, now time.Time) time.Time {
+	at := now
+	if digest {
+		local := now.In(settingsLocation(settings))
+		at = time.Date(local.Year(), local.Month(), local.Day(), s.digestHour, 0, 0, 0, local.Location())
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+	}
+	if end, quiet := quietHoursEnd(settings, at); quiet {
+		at = end
+	}
+	return at.UTC()
+}
+
+func quietHoursEnd(settings *repository.NotificationSettings, at time.Time) (time.Time, bool) {
+	if settings.QuietHoursStart == nil || settings.QuietHoursEnd == nil {
+		return at, false
+	}
+	start, err := parseClock(*settings.QuietHoursStart)
+	if err != nil {
+		return at, false
+	}
+	end, err := parseClock(*settings.QuietHoursEnd)
+	if err != nil || start == end {
+		return at, false
+	}
+
+	local := at.In(settingsLocation(settings))
+	minute := local.Hour()*60 + local.Minute()
+	inside := minute >= start && minute < end
+	if start > end {
+		inside = minute >= start || minute < end
+	}
+	if !inside {
+		return at, false
+	}
+
+	until := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, local.Location())
+	if !until.After(local) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until, true
+}
+
+func settingsLocation(settings *repository.NotificationSettings) *time.Location {
+	if settings.TimeZone != nil {
+		if loc, err := time.LoadLocation(*settings.TimeZone); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (s *NotificationService) validateSettings(settings *repository.NotificationSettings) error {
+	if (settings.QuietHoursStart == nil) != (settings.QuietHoursEnd == nil) {
+		return fmt.Errorf("%w: quiet_hours_start and quiet_hours_end must be set together", ErrInvalidNotificationPreference)
+	}
+	for _, clock := range []*string{settings.QuietHoursStart, settings.QuietHoursEnd} {
+		if clock == nil {
+			continue
+		}
+		if _, err := parseClock(*clock); err != nil {
+			return fmt.Errorf("%w: quiet hours must be HH:MM", ErrInvalidNotificationPreference)
+		}
+	}
+	if settings.TimeZone != nil && *settings.TimeZone != "" {
+		if _, err := time.LoadLocation(*settings.TimeZone); err != nil {
+			return fmt.Errorf("%w: unknown time zone %q", ErrInvalidNotificationPreference, *settings.TimeZone)
+		}
+	}
+	if settings.ChatWebhookURL != nil && *settings.ChatWebhookURL != "" {
+		parsed, err := url.Parse(*settings.ChatWebhookURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("%w: chat_webhook_url must be an absolute http or https URL", ErrInvalidNotificationPreference)
+		}
+		if err := checkPublicHost(parsed.Hostname()); err != nil {
+			return fmt.Errorf("%w: chat_webhook_url: %v", ErrInvalidNotificationPreference, err)
+		}
+	}
+	if settings.ChatFormat != nil && *settings.ChatFormat != "" && !containsString(chatFormats, *settings.ChatFormat) {
+		return fmt.Errorf("%w: chat_format must be one of %s", ErrInvalidNotificationPreference, strings.Join(chatFormats, ", "))
+	}
+	return nil
+}
+
+func (s *NotificationService) validatePreference(preference repository.NotificationPreference) error {
+	if _, ok := notificationDefaults[preference.NotificationType]; !ok {
+		return fmt.Errorf("%w: unknown notification type %q", ErrInvalidNotificationPreference, preference.NotificationType)
+	}
+	channels := s.channelNames()
+	for _, channel := range preference.Channels {
+		if !containsString(channels, channel) {
+			return fmt.Errorf("%w: unknown channel %q", ErrInvalidNotificationPreference, channel)
+		}
+	}
+	return nil
+}
+
+func (s *NotificationService) channelNames() []string {
+	return append([]string{ChannelInApp}, sortedKeys(s.channels)...)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
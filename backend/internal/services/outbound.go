@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+var ErrPrivateAddress = errors.New("URL must resolve to a public internet address")
+
+// blockedNetworks are address ranges outbound webhooks may not reach beyond
+// those the net.IP predicates cover.
+var blockedNetworks = func() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",     // "this" network
+		"100.64.0.0/10", // carrier-grade NAT
+		"192.0.0.0/24",  // IETF protocol assignments
+		"198.18.0.0/15", // benchmarking
+		"240.0.0.0/4",   // reserved
+		"64:ff9b::/96",  // NAT64, which can embed any IPv4 address
+		"2002::/16",     // 6to4, which can embed any IPv4 address
+	} {
+		_, network, _ := net.ParseCIDR(cidr)
+		networks = append(networks, network)
+	}
+	return networks
+}()
+
+// publicAddress reports whether ip is routable on the public internet, so it
+// excludes loopback, private, link-local (including the 169.254.169.254
+// metadata endpoint) and other special-purpose addresses.
+func publicAddress(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsMulticast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkPublicHost resolves host and fails unless every address is public.
+// Delivery checks each connection again, since DNS can change in between.
+func checkPublicHost(host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if !publicAddress(ip) {
+			return ErrPrivateAddress
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("%w: %s does not resolve", ErrPrivateAddress, host)
+	}
+	for _, addr := range addrs {
+		if !publicAddress(addr.IP) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
+
+// dialPublicOnly is a net.Dialer Control function that refuses connections to
+// non-public addresses. It runs after name resolution, so it also covers
+// redirects and DNS rebinding.
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicAddress(ip) {
+		return fmt.Errorf("%w: refusing to connect to %s", ErrPrivateAddress, host)
+	}
+	return nil
+}
+
+// newOutboundClient returns an HTTP client for calling user-supplied URLs.
+// It connects directly, without an environment proxy, so the address check
+// applies to the destination.
+func newOutboundClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: dialPublicOnly}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
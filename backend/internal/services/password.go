@@ -0,0 +1,215 @@
+package services
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"assetsentinel/internal/mailer"
+	"assetsentinel/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrWeakPassword      = errors.New("password does not meet policy")
+	ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+)
+
+type PasswordPolicy struct {
+	MinLength   int
+	HistorySize int
+	blocklist   map[string]bool
+}
+
+func NewPasswordPolicy(minLength, historySize int, blocklistFile string) (*PasswordPolicy, error) {
+	policy := &PasswordPolicy{
+		MinLength:   minLength,
+		HistorySize: historySize,
+		blocklist:   make(map[string]bool),
+	}
+	if blocklistFile == "" {
+		return policy, nil
+	}
+
+	f, err := os.Open(blocklistFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open password blocklist: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if entry := strings.TrimSpace(scanner.Text()); entry != "" && !strings.HasPrefix(entry, "#") {
+			policy.blocklist[strings.ToLower(entry)] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read password blocklist: %w", err)
+	}
+	return policy, nil
+}
+
+func (p *PasswordPolicy) Validate(password string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrWeakPassword, p.MinLength)
+	}
+	if p.blocklist[strings.ToLower(password)] {
+		return fmt.Errorf("%w: password appears in a list of breached passwords", ErrWeakPassword)
+	}
+	return nil
+}
+
+func (p *PasswordPolicy) checkHistory(repo *repository.Repository, user *repository.User, password string) error {
+	if p.HistorySize <= 0 {
+		return nil
+	}
+	hashes, err := repo.GetPasswordHistory(user.ID, p.HistorySize)
+	if err != nil {
+		return err
+	}
+	for _, hash := range append(hashes, user.PasswordHash) {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return fmt.Errorf("%w: must not reuse any of the last %d passwords", ErrWeakPassword, p.HistorySize)
+		}
+	}
+	return nil
+}
+
+type PasswordResetService struct {
+	repo     *repository.Repository
+	policy   *PasswordPolicy
+	mailer   mailer.Mailer
+	guard    *LoginGuard
+	baseURL  string
+	tokenTTL time.Duration
+}
+
+func NewPasswordResetService(repo *repository.Repository, policy *PasswordPolicy, m mailer.Mailer, guard *LoginGuard, baseURL string, tokenTTL time.Duration) *PasswordResetService {
+	return &PasswordResetService{
+		repo:     repo,
+		policy:   policy,
+		mailer:   m,
+		guard:    guard,
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		tokenTTL: tokenTTL,
+	}
+}
+
+// RequestReset throttles reset requests per client IP and sends the reset
+// mail in the background, so the response does not reveal whether the account
+// exists.
+func (s *PasswordResetService) RequestReset(email, ip string) error {
+	if err := s.guard.AttemptReset(ip); err != nil {
+		return err
+	}
+	go func() {
+		if err := s.sendReset(email, ip); err != nil {
+			log.Printf("Error requesting password reset: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (s *PasswordResetService) sendReset(email, ip string) error {
+	user, err := s.repo.GetUserByEmail(email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(raw)
+
+	if err := s.repo.CreatePasswordResetToken(&repository.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().UTC().Add(s.tokenTTL),
+	}); err != nil {
+		return err
+	}
+
+	s.guard.logEvent(&repository.SecurityEvent{
+		OrganizationID: &user.OrganizationID,
+		UserID:         &user.ID,
+		Email:          &user.Email,
+		EventType:      SecurityEventPasswordResetRequested,
+		IPAddress:      &ip,
+	})
+
+	return s.mailer.Send(mailer.Message{
+		To:      []string{user.Email},
+		Subject: "Reset your AssetSentinel password",
+		Body: fmt.Sprintf("Hello %s,\n\nA password reset was requested for your account. "+
+			"Use the link below within %d minutes to choose a new password:\n\n%s/reset-password?token=%s\n\n"+
+			"If you did not request this, you can ignore this email.\n",
+			user.FullName, int(s.tokenTTL.Minutes()), s.baseURL, token),
+	})
+}
+
+func (s *PasswordResetService) Reset(token, password, ip string) error {
+	reset, err := s.repo.GetPasswordResetToken(hashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+	if reset.UsedAt != nil || time.Now().UTC().After(reset.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	user, err := s.repo.GetUserByID(reset.UserID)
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+
+	if err := s.policy.Validate(password); err != nil {
+		return err
+	}
+	if err := s.policy.checkHistory(s.repo, user, password); err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.ResetPasswordWithToken(reset.ID, user.ID, string(hash)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	if err := s.repo.DeleteLoginThrottle(accountKey(user.Email)); err != nil {
+		log.Printf("Error clearing login throttle: %v", err)
+	}
+	s.guard.logEvent(&repository.SecurityEvent{
+		OrganizationID: &user.OrganizationID,
+		UserID:         &user.ID,
+		Email:          &user.Email,
+		EventType:      SecurityEventPasswordReset,
+		IPAddress:      &ip,
+	})
+	return nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"assetsentinel/internal/mailer"
+)
+
+type chanMailer chan mailer.Message
+
+func (m chanMailer) Send(msg mailer.Message) error {
+	m <- msg
+	return nil
+}
+
+func TestPasswordPolicyAllowsAccountName(t *testing.T) {
+	policy, err := NewPasswordPolicy(8, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.Validate("tech-at-acme-2024"); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+	if err := policy.Validate("short"); !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("got %v for a short password, want ErrWeakPassword", err)
+	}
+}
+
+func TestRequestResetMailsInBackground(t *testing.T) {
+	repo := newTestRepository(t)
+	user := seedLoginUser(t, repo, "tech@acme.test", "correct horse battery")
+	mail := make(chanMailer, 1)
+	guard := NewLoginGuard(repo, 5, 2, 20, 15*time.Minute)
+	resets := NewPasswordResetService(repo, nil, mail, guard, "https://app.test/", time.Hour)
+
+	if err := resets.RequestReset("nobody@acme.test", "203.0.113.7"); err != nil {
+		t.Fatalf("unknown account: %v", err)
+	}
+	if err := resets.RequestReset(user.Email, "203.0.113.8"); err != nil {
+		t.Fatalf("known account: %v", err)
+	}
+
+	select {
+	case msg := <-mail:
+		if len(msg.To) != 1 || msg.To[0] != user.Email || !strings.Contains(msg.Body, "https://app.test/reset-password?token=") {
+			t.Errorf("unexpected reset mail %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reset mail was sent")
+	}
+	select {
+	case msg := <-mail:
+		t.Errorf("mail sent for an unknown account: %+v", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestRequestResetIsThrottledPerIP(t *testing.T) {
+	repo := newTestRepository(t)
+	guard := NewLoginGuard(repo, 5, 2, 4, 15*time.Minute)
+	resets := NewPasswordResetService(repo, nil, make(chanMailer, 10), guard, "https://app.test", time.Hour)
+
+	for i := 0; i < 3; i++ {
+		if err := resets.RequestReset("nobody@acme.test", "203.0.113.7"); err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+	}
+	err := resets.RequestReset("nobody@acme.test", "203.0.113.7")
+	var throttled *ThrottleError
+	if !errors.As(err, &throttled) || !strings.Contains(err.Error(), "password reset requests") {
+		t.Fatalf("got %v, want the IP throttled", err)
+	}
+	if err := resets.RequestReset("nobody@acme.test", "203.0.113.9"); err != nil {
+		t.Errorf("another IP: %v", err)
+	}
+}
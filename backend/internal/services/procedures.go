@@ -0,0 +1,358 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"assetsentinel/internal/events"
+	"assetsentinel/internal/repository"
+)
+
+const (
+	StepCheckbox = "checkbox"
+	StepNumeric  = "numeric"
+	StepPassFail = "pass_fail"
+	StepText     = "text"
+	StepPhoto    = "photo"
+)
+
+var (
+	ErrInvalidProcedure   = errors.New("invalid procedure")
+	ErrInvalidStepResults = errors.New("invalid step results")
+	ErrWorkOrderCompleted = errors.New("work order is already completed")
+
+	ErrProcedureResultsRequired = errors.New("the work order's procedure results must be submitted to complete it")
+)
+
+type StepSubmission struct {
+	StepID   uint     `json:"step_id" binding:"required"`
+	Checked  *bool    `json:"checked"`
+	Reading  *float64 `json:"reading"`
+	Passed   *bool    `json:"passed"`
+	Text     *string  `json:"text"`
+	PhotoURL *string  `json:"photo_url"`
+}
+
+type ProcedureOutcome struct {
+	WorkOrder *repository.WorkOrder   `json:"work_order"`
+	Results   []repository.StepResult `json:"results"`
+	FollowUp  *repository.WorkOrder   `json:"follow_up_work_order"`
+}
+
+type ProcedureService struct {
+	repo *repository.Repository
+}
+
+func NewProcedureService(repo *repository.Repository) *ProcedureService {
+	return &ProcedureService{repo: repo}
+}
+
+func (s *ProcedureService) List(orgID uint) ([]repository.Procedure, error) {
+	return s.repo.ListProcedures(orgID)
+}
+
+func (s *ProcedureService) Get(id, orgID uint) (*repository.Procedure, error) {
+	return s.repo.GetProcedure(id, orgID)
+}
+
+func (s *ProcedureService) Create(procedure *repository.Procedure) error {
+	if err := validateProcedure(procedure); err != nil {
+		return err
+	}
+	return s.repo.CreateProcedure(procedure)
+}
+
+// Update saves a procedure. Steps keep their identity when they are sent back
+// with their id, so work orders already using the procedure can still submit
+// results for them.
+func (s *ProcedureService) Update(procedure *repository.Procedure) error {
+	if err := validateProcedure(procedure); err != nil {
+		return err
+	}
+	existing, err := s.repo.GetProcedure(procedure.ID, procedure.OrganizationID)
+	if err != nil {
+		return err
+	}
+	steps := map[uint]bool{}
+	for _, step := range existing.Steps {
+		steps[step.ID] = true
+	}
+	for i, step := range procedure.Steps {
+		if step.ID == 0 {
+			continue
+		}
+		if !steps[step.ID] {
+			return fmt.Errorf("%w: step %d (id %d) is not part of the procedure or is listed twice", ErrInvalidProcedure, i+1, step.ID)
+		}
+		delete(steps, step.ID)
+	}
+	return s.repo.UpdateProcedure(procedure)
+}
+
+func (s *ProcedureService) Delete(id, orgID uint) error {
+	return s.repo.DeleteProcedure(id, orgID)
+}
+
+func validateProcedure(procedure *repository.Procedure) error {
+	if strings.TrimSpace(procedure.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidProcedure)
+	}
+	if len(procedure.Steps) == 0 {
+		return fmt.Errorf("%w: at least one step is required", ErrInvalidProcedure)
+	}
+	for i, step := range procedure.Steps {
+		if strings.TrimSpace(step.Instruction) == "" {
+			return fmt.Errorf("%w: step %d needs an instruction", ErrInvalidProcedure, i+1)
+		}
+		switch step.Type {
+		case StepNumeric:
+			if step.MinValue != nil && step.MaxValue != nil && *step.MinValue > *step.MaxValue {
+				return fmt.Errorf("%w: step %d has min_value above max_value", ErrInvalidProcedure, i+1)
+			}
+		case StepCheckbox, StepPassFail, StepText, StepPhoto:
+			if step.MinValue != nil || step.MaxValue != nil {
+				return fmt.Errorf("%w: step %d: only numeric steps have limits", ErrInvalidProcedure, i+1)
+			}
+		default:
+			return fmt.Errorf("%w: step %d type must be checkbox, numeric, pass_fail, text or photo", ErrInvalidProcedure, i+1)
+		}
+	}
+	return nil
+}
+
+func requireProcedure(repo *repository.Repository, procedureID *uint, orgID uint) error {
+	if procedureID == nil {
+		return nil
+	}
+	if _, err := repo.GetProcedure(*procedureID, orgID); err != nil {
+		return ErrForeignReference
+	}
+	return nil
+}
+
+// WorkOrderProcedure returns the procedure attached to a work order together
+// with any step results already submitted for it.
+func (s *ProcedureService) WorkOrderProcedure(workOrderID, orgID uint) (*repository.Procedure, []repository.StepResult, error) {
+	wo, err := s.repo.GetWorkOrder(workOrderID, orgID)
+	if err != nil {
+		return nil, nil, err
+	}
+	results, err := s.repo.ListStepResults(wo.ID, orgID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if wo.ProcedureID == nil {
+		return nil, results, nil
+	}
+	procedure, err := s.repo.GetProcedure(*wo.ProcedureID, orgID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return procedure, results, nil
+}
+
+// SubmitResults records the outcome of each step of a work order's procedure
+// and completes the work order (and its maintenance task). Numeric readings
+// outside their limits raise a single corrective follow-up work order.
+func (s *ProcedureService) SubmitResults(workOrderID, orgID, actorID uint, submissions []StepSubmission) (*ProcedureOutcome, error) {
+	wo, err := s.repo.GetWorkOrder(workOrderID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if workOrderDone(wo.Status) {
+		return nil, ErrWorkOrderCompleted
+	}
+	if wo.ProcedureID == nil {
+		return nil, fmt.Errorf("%w: work order has no procedure", ErrInvalidStepResults)
+	}
+	procedure, err := s.repo.GetProcedure(*wo.ProcedureID, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := buildStepResults(wo.ID, actorID, procedure.Steps, submissions)
+	if err != nil {
+		return nil, err
+	}
+
+	outcome := &ProcedureOutcome{Results: results}
+	oldStatus := wo.Status
+	err = s.repo.WithTx(func(tx *repository.Repository) error {
+		// Completing the work order is the first write, so a concurrent
+		// submission waits for this one and then finds it completed.
+		completed, err := tx.CompleteWorkOrder(wo.ID, orgID, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		if !completed {
+			return ErrWorkOrderCompleted
+		}
+		if wo, err = tx.GetWorkOrder(wo.ID, orgID); err != nil {
+			return err
+		}
+		outcome.WorkOrder = wo
+
+		var outOfLimit []repository.StepResult
+		for i := range outcome.Results {
+			if err := tx.CreateStepResult(&outcome.Results[i]); err != nil {
+				return err
+			}
+			if outcome.Results[i].OutOfLimit {
+				outOfLimit = append(outOfLimit, outcome.Results[i])
+			}
+		}
+
+		if wo.MaintenanceTaskID != nil {
+			if err := completeWorkOrderTask(tx, wo); err != nil {
+				return err
+			}
+		}
+		if err := events.Enqueue(tx, orgID, events.User(actorID), events.WorkOrderStatusChanged{
+			WorkOrder: events.NewWorkOrder(wo),
+			OldStatus: oldStatus,
+			NewStatus: wo.Status,
+		}); err != nil {
+			return err
+		}
+
+		if len(outOfLimit) == 0 {
+			return nil
+		}
+		outcome.FollowUp = correctiveWorkOrder(wo, actorID, outOfLimit)
+		if err := tx.CreateWorkOrder(outcome.FollowUp); err != nil {
+			return err
+		}
+		return events.Enqueue(tx, orgID, events.User(actorID), events.WorkOrderCreated{WorkOrder: events.NewWorkOrder(outcome.FollowUp)})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return outcome, nil
+}
+
+// requireProcedureResults refuses to complete a work order that has, or had,
+// a procedure before its results are submitted; SubmitResults completes
+// those. existing is the work order before the change, if any.
+func requireProcedureResults(tx *repository.Repository, wo, existing *repository.WorkOrder) error {
+	if !workOrderDone(wo.Status) || (existing != nil && workOrderDone(existing.Status)) {
+		return nil
+	}
+	if wo.ProcedureID == nil && (existing == nil || existing.ProcedureID == nil) {
+		return nil
+	}
+	if wo.ID == 0 {
+		return ErrProcedureResultsRequired
+	}
+	count, err := tx.CountStepResults(wo.ID)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrProcedureResultsRequired
+	}
+	return nil
+}
+
+func buildStepResults(workOrderID, actorID uint, steps []repository.ProcedureStep, submissions []StepSubmission) ([]repository.StepResult, error) {
+	byStep := make(map[uint]StepSubmission, len(submissions))
+	for _, submission := range submissions {
+		if _, dup := byStep[submission.StepID]; dup {
+			return nil, fmt.Errorf("%w: step %d submitted more than once", ErrInvalidStepResults, submission.StepID)
+		}
+		byStep[submission.StepID] = submission
+	}
+
+	results := make([]repository.StepResult, 0, len(steps))
+	for _, step := range steps {
+		submission, ok := byStep[step.ID]
+		delete(byStep, step.ID)
+		if !ok {
+			if step.Optional {
+				continue
+			}
+			return nil, fmt.Errorf("%w: step %d (%s) is required", ErrInvalidStepResults, step.Position, step.Instruction)
+		}
+
+		stepID := step.ID
+		result := repository.StepResult{
+			WorkOrderID: workOrderID,
+			StepID:      &stepID,
+			Position:    step.Position,
+			Type:        step.Type,
+			Instruction: step.Instruction,
+			MinValue:    step.MinValue,
+			MaxValue:    step.MaxValue,
+			Unit:        step.Unit,
+			RecordedBy:  &actorID,
+		}
+		missing := false
+		switch step.Type {
+		case StepCheckbox:
+			result.Checked = submission.Checked
+			missing = submission.Checked == nil || (!*submission.Checked && !step.Optional)
+		case StepNumeric:
+			result.Reading = submission.Reading
+			missing = submission.Reading == nil
+			if !missing {
+				result.OutOfLimit = (step.MinValue != nil && *submission.Reading < *step.MinValue) ||
+					(step.MaxValue != nil && *submission.Reading > *step.MaxValue)
+			}
+		case StepPassFail:
+			result.Passed = submission.Passed
+			missing = submission.Passed == nil
+		case StepText:
+			result.Text = submission.Text
+			missing = submission.Text == nil || strings.TrimSpace(*submission.Text) == ""
+		case StepPhoto:
+			result.PhotoURL = submission.PhotoURL
+			missing = submission.PhotoURL == nil || strings.TrimSpace(*submission.PhotoURL) == ""
+		}
+		if missing {
+			return nil, fmt.Errorf("%w: step %d (%s) needs a %s result", ErrInvalidStepResults, step.Position, step.Instruction, step.Type)
+		}
+		results = append(results, result)
+	}
+	for stepID := range byStep {
+		return nil, fmt.Errorf("%w: step %d is not part of the procedure", ErrInvalidStepResults, stepID)
+	}
+	return results, nil
+}
+
+func correctiveWorkOrder(source *repository.WorkOrder, actorID uint, readings []repository.StepResult) *repository.WorkOrder {
+	lines := []string{fmt.Sprintf("Readings outside their limits during work order %d:", source.ID)}
+	for _, reading := range readings {
+		unit := ""
+		if reading.Unit != nil {
+			unit = " " + *reading.Unit
+		}
+		lines = append(lines, fmt.Sprintf("- Step %d (%s): %s%s, expected %s", reading.Position, reading.Instruction,
+			strconv.FormatFloat(*reading.Reading, 'f', -1, 64), unit, limitRange(reading.MinValue, reading.MaxValue, unit)))
+	}
+	description := strings.Join(lines, "\n")
+	sourceID := source.ID
+	return &repository.WorkOrder{
+		OrganizationID:    source.OrganizationID,
+		AssetID:           source.AssetID,
+		SourceWorkOrderID: &sourceID,
+		Title:             "Corrective action: " + source.Title,
+		Description:       &description,
+		Status:            "pending",
+		Priority:          "high",
+		CreatedBy:         &actorID,
+	}
+}
+
+func limitRange(min, max *float64, unit string) string {
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) + unit }
+	switch {
+	case min != nil && max != nil:
+		return fmt.Sprintf("%s to %s", format(*min), format(*max))
+	case min != nil:
+		return "at least " + format(*min)
+	default:
+		return "at most " + format(*max)
+	}
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"assetsentinel/internal/rbac"
+	"assetsentinel/internal/repository"
+)
+
+func float(v float64) *float64 { return &v }
+func boolean(v bool) *bool     { return &v }
+func text(v string) *string    { return &v }
+
+func (f *maintenanceFixture) procedure(t *testing.T) *repository.Procedure {
+	t.Helper()
+	unit := "bar"
+	procedure := &repository.Procedure{OrganizationID: f.org.ID, Name: "Pump check", Steps: []repository.ProcedureStep{
+		{Type: StepCheckbox, Instruction: "Isolate the pump"},
+		{Type: StepNumeric, Instruction: "Read the pressure", MinValue: float(2), MaxValue: float(4), Unit: &unit},
+		{Type: StepPassFail, Instruction: "Seal test", Optional: true},
+		{Type: StepText, Instruction: "Remarks"},
+	}}
+	if err := NewProcedureService(f.repo).Create(procedure); err != nil {
+		t.Fatal(err)
+	}
+	return procedure
+}
+
+func (f *maintenanceFixture) procedureWorkOrder(t *testing.T, procedure *repository.Procedure) *repository.WorkOrder {
+	t.Helper()
+	wo := &repository.WorkOrder{OrganizationID: f.org.ID, AssetID: f.asset.ID, ProcedureID: &procedure.ID, Title: "Pump check", Status: "in_progress", Priority: "medium"}
+	if err := f.workOrders.Create(wo); err != nil {
+		t.Fatal(err)
+	}
+	return wo
+}
+
+func submissions(procedure *repository.Procedure, pressure float64) []StepSubmission {
+	steps := procedure.Steps
+	return []StepSubmission{
+		{StepID: steps[0].ID, Checked: boolean(true)},
+		{StepID: steps[1].ID, Reading: &pressure},
+		{StepID: steps[3].ID, Text: text("Noisy bearing")},
+	}
+}
+
+func TestSubmitResultsValidation(t *testing.T) {
+	f := newMaintenanceFixture(t, "UTC")
+	actor := f.user(t, "alice", rbac.RoleTechnician)
+	procedure := f.procedure(t)
+	wo := f.procedureWorkOrder(t, procedure)
+	procedures := NewProcedureService(f.repo)
+	steps := procedure.Steps
+
+	valid := submissions(procedure, 3)
+	for name, results := range map[string][]StepSubmission{
+		"missing required step": valid[1:],
+		"unchecked checkbox":    append([]StepSubmission{{StepID: steps[0].ID, Checked: boolean(false)}}, valid[1:]...),
+		"numeric without value": append([]StepSubmission{valid[0], {StepID: steps[1].ID}}, valid[2]),
+		"blank text":            append(valid[:2:2], StepSubmission{StepID: steps[3].ID, Text: text("  ")}),
+		"duplicate step":        append(valid[:3:3], valid[0]),
+		"unknown step":          append(valid[:3:3], StepSubmission{StepID: 9999, Checked: boolean(true)}),
+	} {
+		if _, err := procedures.SubmitResults(wo.ID, f.org.ID, actor.ID, results); !errors.Is(err, ErrInvalidStepResults) {
+			t.Errorf("%s: SubmitResults = %v, want ErrInvalidStepResults", name, err)
+		}
+	}
+
+	outcome, err := procedures.SubmitResults(wo.ID, f.org.ID, actor.ID, valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outcome.WorkOrder.Status != "completed" || outcome.WorkOrder.ActualEnd == nil {
+		t.Errorf("work order = %+v, want it completed", outcome.WorkOrder)
+	}
+	if len(outcome.Results) != 3 || outcome.FollowUp != nil {
+		t.Errorf("outcome = %d results and follow-up %v, want 3 results within limits", len(outcome.Results), outcome.FollowUp)
+	}
+	if _, err := procedures.SubmitResults(wo.ID, f.org.ID, actor.ID, valid); !errors.Is(err, ErrWorkOrderCompleted) {
+		t.Errorf("resubmitting = %v, want ErrWorkOrderCompleted", err)
+	}
+}
+
+func TestOutOfLimitReadingRaisesFollowUp(t *testing.T) {
+	f := newMaintenanceFixture(t, "UTC")
+	actor := f.user(t, "alice", rbac.RoleTechnician)
+	procedure := f.procedure(t)
+	wo := f.procedureWorkOrder(t, procedure)
+
+	outcome, err := NewProcedureService(f.repo).SubmitResults(wo.ID, f.org.ID, actor.ID, submissions(procedure, 5.5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !outcome.Results[1].OutOfLimit || outcome.Results[0].OutOfLimit {
+		t.Errorf("out_of_limit = %v, %v; want only the pressure reading flagged", outcome.Results[0].OutOfLimit, outcome.Results[1].OutOfLimit)
+	}
+	followUp := outcome.FollowUp
+	if followUp == nil {
+		t.Fatal("no corrective work order was raised")
+	}
+	stored, err := f.repo.GetWorkOrder(followUp.ID, f.org.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Priority != "high" || stored.Status != "pending" || stored.AssetID != f.asset.ID ||
+		stored.SourceWorkOrderID == nil || *stored.SourceWorkOrderID != wo.ID {
+		t.Errorf("follow-up = %+v, want a pending high priority work order on the asset from work order %d", stored, wo.ID)
+	}
+	if stored.Description == nil || !strings.Contains(*stored.Description, "Read the pressure): 5.5 bar, expected 2 bar to 4 bar") {
+		t.Errorf("follow-up description = %v", stored.Description)
+	}
+}
+
+func TestCompletionRequiresProcedureResults(t *testing.T) {
+	f := newMaintenanceFixture(t, "UTC")
+	actor := f.user(t, "alice", rbac.RoleTechnician)
+	procedure := f.procedure(t)
+
+	wo := f.procedureWorkOrder(t, procedure)
+	update := *wo
+	update.Status = "completed"
+	if err := f.workOrders.Update(&update, wo.Status, actor.ID); !errors.Is(err, ErrProcedureResultsRequired) {
+		t.Errorf("completing without results = %v, want ErrProcedureResultsRequired", err)
+	}
+	update.ProcedureID = nil
+	if err := f.workOrders.Update(&update, wo.Status, actor.ID); !errors.Is(err, ErrProcedureResultsRequired) {
+		t.Errorf("completing while removing the procedure = %v, want ErrProcedureResultsRequired", err)
+	}
+	created := &repository.WorkOrder{OrganizationID: f.org.ID, AssetID: f.asset.ID, ProcedureID: &procedure.ID, Title: "Done", Status: "closed", Priority: "low"}
+	if err := f.workOrders.Create(created); !errors.Is(err, ErrProcedureResultsRequired) {
+		t.Errorf("creating a closed work order = %v, want ErrProcedureResultsRequired", err)
+	}
+
+	two := 2.0
+	plan := f.plan(t, rbac.RoleTechnician, &two)
+	plan.ProcedureID = &procedure.ID
+	generated := f.createForTask(t, plan, "2026-03-30")
+	maintenance := NewMaintenanceService(f.repo)
+	if _, err := maintenance.CompleteTask(*generated.MaintenanceTaskID, f.org.ID, actor.ID, nil); !errors.Is(err, ErrProcedureResultsRequired) {
+		t.Errorf("completing the task = %v, want ErrProcedureResultsRequired", err)
+	}
+	task, err := maintenance.GetTask(*generated.MaintenanceTaskID, f.org.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Status == "completed" {
+		t.Error("the task was completed although its work order was not")
+	}
+
+	if _, err := NewProcedureService(f.repo).SubmitResults(generated.ID, f.org.ID, actor.ID, submissions(procedure, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if task, err = maintenance.GetTask(task.ID, f.org.ID); err != nil || task.Status != "completed" {
+		t.Errorf("task = %+v, %v; want it completed with its work order", task, err)
+	}
+}
+
+func TestConcurrentSubmissionsCompleteOnce(t *testing.T) {
+	f := newMaintenanceFixture(t, "UTC")
+	actor := f.user(t, "alice", rbac.RoleTechnician)
+	procedure := f.procedure(t)
+	wo := f.procedureWorkOrder(t, procedure)
+	procedures := NewProcedureService(f.repo)
+
+	const submitters = 8
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for i := 0; i < submitters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := procedures.SubmitResults(wo.ID, f.org.ID, actor.ID, submissions(procedure, 9))
+			if err != nil && !errors.Is(err, ErrWorkOrderCompleted) {
+				t.Errorf("SubmitResults = %v", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				succeeded++
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 1 {
+		t.Errorf("%d submissions succeeded, want 1", succeeded)
+	}
+	if results, err := f.repo.CountStepResults(wo.ID); err != nil || results != 3 {
+		t.Errorf("%d step results recorded (%v), want 3", results, err)
+	}
+	var followUps int
+	if err := f.repo.DB.QueryRow(`SELECT COUNT(*) FROM work_orders WHERE source_work_order_id = ?`, wo.ID).Scan(&followUps); err != nil {
+		t.Fatal(err)
+	}
+	if followUps != 1 {
+		t.Errorf("%d corrective work orders raised, want 1", followUps)
+	}
+}
+
+func TestUpdateProcedureKeepsStepIDs(t *testing.T) {
+	f := newMaintenanceFixture(t, "UTC")
+	actor := f.user(t, "alice", rbac.RoleTechnician)
+	procedure := f.procedure(t)
+	wo := f.procedureWorkOrder(t, procedure)
+	procedures := NewProcedureService(f.repo)
+	original := append([]repository.ProcedureStep{}, procedure.Steps...)
+
+	// Move the remarks first, reword the pressure reading, drop the seal test
+	// and add a photo.
+	remarks, pressure, isolate := original[3], original[1], original[0]
+	pressure.Instruction = "Read the outlet pressure"
+	update := &repository.Procedure{ID: procedure.ID, OrganizationID: f.org.ID, Name: "Pump check", Steps: []repository.ProcedureStep{
+		remarks, isolate, pressure, {Type: StepPhoto, Instruction: "Photograph the gauge", Optional: true},
+	}}
+	if err := procedures.Update(update); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := procedures.Get(procedure.ID, f.org.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Steps) != 4 {
+		t.Fatalf("procedure has %d steps, want 4", len(stored.Steps))
+	}
+	for i, id := range []uint{remarks.ID, isolate.ID, pressure.ID} {
+		if stored.Steps[i].ID != id || stored.Steps[i].Position != i+1 {
+			t.Errorf("step %d = id %d at %d, want id %d", i+1, stored.Steps[i].ID, stored.Steps[i].Position, id)
+		}
+	}
+	if stored.Steps[2].Instruction != "Read the outlet pressure" || stored.Steps[3].Type != StepPhoto {
+		t.Errorf("steps = %+v", stored.Steps)
+	}
+
+	// A work order opened before the edit can still submit by step ID.
+	if _, err := procedures.SubmitResults(wo.ID, f.org.ID, actor.ID, submissions(procedure, 3)); err != nil {
+		t.Errorf("submitting results after the edit: %v", err)
+	}
+
+	other := f.procedure(t)
+	update.Steps = append(update.Steps[:3:3], other.Steps[0])
+	if err := procedures.Update(update); !errors.Is(err, ErrInvalidProcedure) {
+		t.Errorf("adopting another procedure's step = %v, want ErrInvalidProcedure", err)
+	}
+	update.Steps = []repository.ProcedureStep{remarks, remarks}
+	if err := procedures.Update(update); !errors.Is(err, ErrInvalidProcedure) {
+		t.Errorf("listing a step twice = %v, want ErrInvalidProcedure", err)
+	}
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"assetsentinel/internal/calendar"
+	"assetsentinel/internal/repository"
+)
+
+const MaxOccurrencePreview = 100
+
+type Occurrence struct {
+	Date          time.Time `json:"date"`
+	ScheduledDate time.Time `json:"scheduled_date"`
+	Shifted       bool      `json:"shifted"`
+}
+
+func PlanRecurrence(plan *repository.MaintenancePlan) (*calendar.Recurrence, error) {
+	if plan.RecurrenceRule == nil {
+		return calendar.IntervalRecurrence(plan.FrequencyDays, plan.NextMaintenanceDate)
+	}
+	start := plan.NextMaintenanceDate
+	if plan.RecurrenceStart != nil {
+		start = *plan.RecurrenceStart
+	}
+	return calendar.ParseRecurrence(*plan.RecurrenceRule, start)
+}
+
+func normalizeRecurrence(plan, existing *repository.MaintenancePlan) error {
+	plan.NextMaintenanceDate = calendar.Date(plan.NextMaintenanceDate)
+	if plan.RecurrenceRule != nil && strings.TrimSpace(*plan.RecurrenceRule) == "" {
+		plan.RecurrenceRule = nil
+	}
+	if plan.RecurrenceRule == nil {
+		plan.RecurrenceStart = nil
+		_, err := calendar.IntervalRecurrence(plan.FrequencyDays, plan.NextMaintenanceDate)
+		return err
+	}
+	if plan.NextMaintenanceDate.IsZero() {
+		return fmt.Errorf("%w: next_maintenance_date is required as the start of the recurrence", calendar.ErrInvalidRecurrence)
+	}
+
+	recurrence, err := calendar.ParseRecurrence(*plan.RecurrenceRule, plan.NextMaintenanceDate)
+	if err != nil {
+		return err
+	}
+	rule := recurrence.String()
+	start := plan.NextMaintenanceDate
+	if existing != nil && existing.RecurrenceRule != nil && existing.RecurrenceStart != nil &&
+		*existing.RecurrenceRule == rule && calendar.Date(existing.NextMaintenanceDate).Equal(plan.NextMaintenanceDate) {
+		start = calendar.Date(*existing.RecurrenceStart)
+		if recurrence, err = calendar.ParseRecurrence(rule, start); err != nil {
+			return err
+		}
+	}
+
+	first, ok := recurrence.From(plan.NextMaintenanceDate)
+	if !ok {
+		return fmt.Errorf("%w: the rule has no occurrences on or after next_maintenance_date", calendar.ErrInvalidRecurrence)
+	}
+	plan.RecurrenceRule = &rule
+	plan.RecurrenceStart = &start
+	plan.NextMaintenanceDate = first
+	return nil
+}
+
+func (s *MaintenanceService) Occurrences(id, orgID uint, count int) ([]Occurrence, error) {
+	plan, err := s.repo.GetMaintenancePlan(id, orgID)
+	if err != nil {
+		return nil, err
+	}
+	return s.occurrences(plan, count)
+}
+
+func (s *MaintenanceService) Preview(plan *repository.MaintenancePlan, count int) ([]Occurrence, error) {
+	if plan.NextMaintenanceDate.IsZero() {
+		cal, err := s.repo.GetBusinessCalendar(plan.OrganizationID)
+		if err != nil {
+			return nil, err
+		}
+		plan.NextMaintenanceDate = cal.Today(time.Now())
+	}
+	if err := normalizeShift(plan); err != nil {
+		return nil, err
+	}
+	if err := normalizeRecurrence(plan, nil); err != nil {
+		return nil, err
+	}
+	return s.occurrences(plan, count)
+}
+
+func (s *MaintenanceService) occurrences(plan *repository.MaintenancePlan, count int) ([]Occurrence, error) {
+	cal, err := s.repo.GetBusinessCalendar(plan.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	recurrence, err := PlanRecurrence(plan)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences := []Occurrence{}
+	for _, date := range recurrence.Next(plan.NextMaintenanceDate, count) {
+		scheduled := cal.Shift(date, plan.NonWorkingDayShift)
+		occurrences = append(occurrences, Occurrence{Date: date, ScheduledDate: scheduled, Shifted: !scheduled.Equal(date)})
+	}
+	return occurrences, nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"assetsentinel/internal/calendar"
+	"assetsentinel/internal/repository"
+)
+
+func TestNormalizeRecurrence(t *testing.T) {
+	rule := "RRULE:FREQ=MONTHLY;BYDAY=1MO"
+	plan := &repository.MaintenancePlan{RecurrenceRule: &rule, NextMaintenanceDate: day(t, "2026-01-10")}
+	if err := normalizeRecurrence(plan, nil); err != nil {
+		t.Fatal(err)
+	}
+	if *plan.RecurrenceRule != "FREQ=MONTHLY;BYDAY=+1MO" {
+		t.Errorf("rule = %q, want the canonical form without the RRULE: prefix", *plan.RecurrenceRule)
+	}
+	if !plan.NextMaintenanceDate.Equal(day(t, "2026-02-02")) || !plan.RecurrenceStart.Equal(day(t, "2026-01-10")) {
+		t.Errorf("next = %v, start = %v; want the first occurrence on 2026-02-02 anchored at 2026-01-10", plan.NextMaintenanceDate, plan.RecurrenceStart)
+	}
+
+	// Saving the plan unchanged keeps the original anchor, so an INTERVAL
+	// rule does not drift each time the next date moves forward.
+	every := "FREQ=WEEKLY;INTERVAL=3"
+	existing := &repository.MaintenancePlan{RecurrenceRule: &every, NextMaintenanceDate: day(t, "2026-01-05")}
+	if err := normalizeRecurrence(existing, nil); err != nil {
+		t.Fatal(err)
+	}
+	existing.NextMaintenanceDate = day(t, "2026-01-26")
+	again := every
+	update := &repository.MaintenancePlan{RecurrenceRule: &again, NextMaintenanceDate: day(t, "2026-01-26")}
+	if err := normalizeRecurrence(update, existing); err != nil {
+		t.Fatal(err)
+	}
+	if !update.RecurrenceStart.Equal(day(t, "2026-01-05")) {
+		t.Errorf("start = %v, want the existing anchor 2026-01-05", update.RecurrenceStart)
+	}
+
+	blank := "  "
+	reset := &repository.MaintenancePlan{RecurrenceRule: &blank, RecurrenceStart: update.RecurrenceStart, FrequencyDays: 30, NextMaintenanceDate: day(t, "2026-01-05")}
+	if err := normalizeRecurrence(reset, existing); err != nil {
+		t.Fatal(err)
+	}
+	if reset.RecurrenceRule != nil || reset.RecurrenceStart != nil {
+		t.Error("a blank rule does not fall back to frequency_days")
+	}
+
+	finished := "FREQ=DAILY;UNTIL=20251231T000000Z"
+	for _, plan := range []*repository.MaintenancePlan{
+		{RecurrenceRule: &finished, NextMaintenanceDate: day(t, "2026-01-05")},
+		{RecurrenceRule: &every},
+		{FrequencyDays: 0, NextMaintenanceDate: day(t, "2026-01-05")},
+	} {
+		if err := normalizeRecurrence(plan, nil); !errors.Is(err, calendar.ErrInvalidRecurrence) {
+			t.Errorf("normalizeRecurrence(%+v) = %v, want ErrInvalidRecurrence", plan, err)
+		}
+	}
+}
+
+func TestPreviewShiftsOccurrencesOffHolidays(t *testing.T) {
+	repo := newTestRepository(t)
+	org := repository.Organization{Name: "Acme"}
+	if err := repo.CreateOrganization(&org); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewCalendarService(repo).AddHoliday(&repository.Holiday{OrganizationID: org.ID, Date: "2026-01-01", Name: "New Year"}); err != nil {
+		t.Fatal(err)
+	}
+
+	rule := "FREQ=MONTHLY;BYMONTHDAY=1"
+	plan := &repository.MaintenancePlan{
+		OrganizationID:      org.ID,
+		RecurrenceRule:      &rule,
+		NextMaintenanceDate: day(t, "2026-01-01"),
+		NonWorkingDayShift:  calendar.ShiftNext,
+	}
+	occurrences, err := NewMaintenanceService(repo).Preview(plan, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		date, scheduled string
+		shifted         bool
+	}{
+		{"2026-01-01", "2026-01-02", true},
+		{"2026-02-01", "2026-02-02", true},
+		{"2026-03-01", "2026-03-02", true},
+	}
+	if len(occurrences) != len(want) {
+		t.Fatalf("got %d occurrences, want %d", len(occurrences), len(want))
+	}
+	for i, w := range want {
+		got := occurrences[i]
+		if got.Date.Format(calendar.DateLayout) != w.date || got.ScheduledDate.Format(calendar.DateLayout) != w.scheduled || got.Shifted != w.shifted {
+			t.Errorf("occurrence %d = %+v, want %s scheduled on %s", i, got, w.date, w.scheduled)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"assetsentinel/internal/rbac"
+	"assetsentinel/internal/repository"
+)
+
+var (
+	ErrUnknownRole       = errors.New("unknown role")
+	ErrInvalidPermission = errors.New("invalid permission")
+	ErrReservedRoleName  = errors.New("role name is reserved for a built-in role")
+	ErrRoleInUse         = errors.New("role is assigned to one or more users")
+)
+
+type RoleService struct {
+	repo *repository.Repository
+}
+
+func NewRoleService(repo *repository.Repository) *RoleService {
+	return &RoleService{repo: repo}
+}
+
+func (s *RoleService) ResolvePermissions(orgID uint, role string) ([]string, error) {
+	if rbac.IsBuiltinRole(role) {
+		return rbac.BuiltinPermissions(role), nil
+	}
+
+	custom, err := s.repo.GetRoleByName(orgID, role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUnknownRole
+		}
+		return nil, err
+	}
+	return custom.Permissions, nil
+}
+
+func (s *RoleService) ValidateRole(orgID uint, role string) error {
+	return validateRole(s.repo, orgID, role)
+}
+
+func (s *RoleService) List(orgID uint) ([]repository.Role, error) {
+	return s.repo.ListRoles(orgID)
+}
+
+func (s *RoleService) Get(id, orgID uint) (*repository.Role, error) {
+	return s.repo.GetRole(id, orgID)
+}
+
+func (s *RoleService) Create(role *repository.Role) error {
+	if err := validateCustomRole(role); err != nil {
+		return err
+	}
+	return s.repo.CreateRole(role)
+}
+
+func (s *RoleService) Update(role *repository.Role) error {
+	if err := validateCustomRole(role); err != nil {
+		return err
+	}
+	return s.repo.UpdateRole(role)
+}
+
+func (s *RoleService) Delete(id, orgID uint) error {
+	role, err := s.repo.GetRole(id, orgID)
+	if err != nil {
+		return err
+	}
+
+	count, err := s.repo.CountUsersWithRole(orgID, role.Name)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrRoleInUse
+	}
+
+	return s.repo.DeleteRole(id, orgID)
+}
+
+func validateCustomRole(role *repository.Role) error {
+	if rbac.IsBuiltinRole(role.Name) {
+		return ErrReservedRoleName
+	}
+	for _, permission := range role.Permissions {
+		if !rbac.IsPermission(permission) {
+			return fmt.Errorf("%w: %s", ErrInvalidPermission, permission)
+		}
+	}
+	return nil
+}
+
+func validateRole(repo *repository.Repository, orgID uint, role string) error {
+	if rbac.IsBuiltinRole(role) {
+		return nil
+	}
+	if _, err := repo.GetRoleByName(orgID, role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", ErrUnknownRole, role)
+		}
+		return err
+	}
+	return nil
+}
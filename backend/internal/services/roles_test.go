@@ -0,0 +1,99 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"assetsentinel/internal/rbac"
+	"assetsentinel/internal/repository"
+)
+
+func TestResolvePermissions(t *testing.T) {
+	repo := newTestRepository(t)
+	roles := NewRoleService(repo)
+	acme := repository.Organization{Name: "Acme"}
+	globex := repository.Organization{Name: "Globex"}
+	for _, org := range []*repository.Organization{&acme, &globex} {
+		if err := repo.CreateOrganization(org); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inspector := &repository.Role{OrganizationID: acme.ID, Name: "inspector", Permissions: []string{rbac.AssetsRead, rbac.WorkOrdersRead}}
+	if err := roles.Create(inspector); err != nil {
+		t.Fatal(err)
+	}
+
+	permissions, err := roles.ResolvePermissions(acme.ID, "inspector")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(permissions) != 2 || permissions[0] != rbac.AssetsRead || permissions[1] != rbac.WorkOrdersRead {
+		t.Errorf("inspector permissions = %v", permissions)
+	}
+	if permissions, err := roles.ResolvePermissions(globex.ID, rbac.RoleTechnician); err != nil || len(permissions) != len(rbac.BuiltinPermissions(rbac.RoleTechnician)) {
+		t.Errorf("technician permissions = %v, %v", permissions, err)
+	}
+	if _, err := roles.ResolvePermissions(globex.ID, "inspector"); !errors.Is(err, ErrUnknownRole) {
+		t.Errorf("another organization's role resolved: %v", err)
+	}
+	if err := roles.ValidateRole(globex.ID, "inspector"); !errors.Is(err, ErrUnknownRole) {
+		t.Errorf("ValidateRole accepted another organization's role: %v", err)
+	}
+}
+
+func TestCustomRoleValidation(t *testing.T) {
+	repo := newTestRepository(t)
+	roles := NewRoleService(repo)
+	org := repository.Organization{Name: "Acme"}
+	if err := repo.CreateOrganization(&org); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		role *repository.Role
+		want error
+	}{
+		{&repository.Role{OrganizationID: org.ID, Name: rbac.RoleAdmin}, ErrReservedRoleName},
+		{&repository.Role{OrganizationID: org.ID, Name: "operator", Permissions: []string{rbac.PlatformManage}}, ErrInvalidPermission},
+		{&repository.Role{OrganizationID: org.ID, Name: "operator", Permissions: []string{"assets:approve"}}, ErrInvalidPermission},
+	}
+	for _, tt := range tests {
+		if err := roles.Create(tt.role); !errors.Is(err, tt.want) {
+			t.Errorf("Create(%s, %v) = %v, want %v", tt.role.Name, tt.role.Permissions, err, tt.want)
+		}
+	}
+}
+
+func TestRenamingAndDeletingRoles(t *testing.T) {
+	repo := newTestRepository(t)
+	roles := NewRoleService(repo)
+	user := seedLoginUser(t, repo, "inspector@acme.test", "correct horse battery")
+	role := &repository.Role{OrganizationID: user.OrganizationID, Name: "inspector", Permissions: []string{rbac.AssetsRead}}
+	if err := roles.Create(role); err != nil {
+		t.Fatal(err)
+	}
+	user.Role = "inspector"
+	if err := repo.UpdateUser(user); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := roles.Delete(role.ID, user.OrganizationID); !errors.Is(err, ErrRoleInUse) {
+		t.Fatalf("deleting an assigned role: %v, want ErrRoleInUse", err)
+	}
+
+	role.Name = "auditor"
+	role.Permissions = []string{rbac.AssetsRead, rbac.AuditRead}
+	if err := roles.Update(role); err != nil {
+		t.Fatal(err)
+	}
+	renamed, err := repo.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renamed.Role != "auditor" {
+		t.Errorf("user role = %s after renaming, want auditor", renamed.Role)
+	}
+	if permissions, err := roles.ResolvePermissions(user.OrganizationID, "auditor"); err != nil || len(permissions) != 2 {
+		t.Errorf("auditor permissions = %v, %v", permissions, err)
+	}
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"assetsentinel/internal/repository"
+)
+
+const (
+	SecurityEventLoginFailed     = "login_failed"
+	SecurityEventLoginSuccess    = "login_success"
+	SecurityEventLoginNewIP      = "login_new_ip"
+	SecurityEventAccountLocked   = "account_locked"
+	SecurityEventAccountUnlocked = "account_unlocked"
+	SecurityEventIPLocked        = "ip_locked"
+
+	SecurityEventPasswordResetRequested = "password_reset_requested"
+	SecurityEventPasswordReset          = "password_reset"
+)
+
+type ThrottleError struct {
+	RetryAfter time.Duration
+	Locked     bool
+	attempts   string
+}
+
+func (e *ThrottleError) Error() string {
+	attempts := e.attempts
+	if attempts == "" {
+		attempts = "failed login attempts"
+	}
+	seconds := int(math.Ceil(e.RetryAfter.Seconds()))
+	if e.Locked {
+		return fmt.Sprintf("too many %s; temporarily locked, retry in %d seconds", attempts, seconds)
+	}
+	return fmt.Sprintf("too many %s; retry in %d seconds", attempts, seconds)
+}
+
+// loginAttemptRetries bounds how often Attempt retries counting a failure that
+// a concurrent attempt counted first.
+const loginAttemptRetries = 5
+
+type LoginGuard struct {
+	repo                *repository.Repository
+	maxAccountFailures  int
+	accountFreeFailures int
+	maxIPFailures       int
+	lockout             time.Duration
+	baseDelay           time.Duration
+}
+
+func NewLoginGuard(repo *repository.Repository, maxAccountFailures, accountFreeFailures, maxIPFailures int, lockout time.Duration) *LoginGuard {
+	return &LoginGuard{
+		repo:                repo,
+		maxAccountFailures:  maxAccountFailures,
+		accountFreeFailures: accountFreeFailures,
+		maxIPFailures:       maxIPFailures,
+		lockout:             lockout,
+		baseDelay:           time.Second,
+	}
+}
+
+// LoginAttempt is a login attempt counted by Attempt, to be settled with
+// RecordFailure or RecordSuccess once the password has been checked.
+type LoginAttempt struct {
+	Email         string
+	IP            string
+	accountLocked bool
+	ipLocked      bool
+}
+
+// Attempt counts a login attempt as a failure before the password is checked,
+// so parallel guesses cannot all get past the throttle.
+func (g *LoginGuard) Attempt(email, ip string) (*LoginAttempt, error) {
+	attempt := &LoginAttempt{Email: email, IP: ip}
+	var err error
+	if attempt.ipLocked, err = g.count(ipKey(ip), g.maxIPFailures/2, g.maxIPFailures); err != nil {
+		return nil, err
+	}
+	if attempt.accountLocked, err = g.count(accountKey(email), g.accountFreeFailures, g.maxAccountFailures); err != nil {
+		if err := g.repo.ForgiveLoginFailure(ipKey(ip), g.maxIPFailures); err != nil {
+			log.Printf("Error clearing login throttle: %v", err)
+		}
+		return nil, err
+	}
+	return attempt, nil
+}
+
+// AttemptReset counts a password reset request against the client IP, with
+// the same limits as failed logins.
+func (g *LoginGuard) AttemptReset(ip string) error {
+	_, err := g.count(resetKey(ip), g.maxIPFailures/2, g.maxIPFailures)
+	var throttled *ThrottleError
+	if errors.As(err, &throttled) {
+		throttled.attempts = "password reset requests"
+	}
+	return err
+}
+
+func (g *LoginGuard) RecordFailure(attempt *LoginAttempt, user *repository.User) {
+	event := &repository.SecurityEvent{
+		EventType: SecurityEventLoginFailed,
+		Email:     &attempt.Email,
+		IPAddress: &attempt.IP,
+	}
+	if user != nil {
+		event.OrganizationID = &user.OrganizationID
+		event.UserID = &user.ID
+	}
+	g.logEvent(event)
+
+	if attempt.accountLocked {
+		locked := *event
+		locked.EventType = SecurityEventAccountLocked
+		locked.Details = stringPtr(fmt.Sprintf("locked for %s after %d failed attempts", g.lockout, g.maxAccountFailures))
+		g.logEvent(&locked)
+	}
+	if attempt.ipLocked {
+		locked := *event
+		locked.EventType = SecurityEventIPLocked
+		locked.Details = stringPtr(fmt.Sprintf("locked for %s after %d failed attempts", g.lockout, g.maxIPFailures))
+		g.logEvent(&locked)
+	}
+}
+
+func (g *LoginGuard) RecordSuccess(attempt *LoginAttempt, user *repository.User) {
+	ip := attempt.IP
+	if err := g.repo.DeleteLoginThrottle(accountKey(user.Email)); err != nil {
+		log.Printf("Error clearing login throttle: %v", err)
+	}
+	if err := g.repo.ForgiveLoginFailure(ipKey(ip), g.maxIPFailures); err != nil {
+		log.Printf("Error clearing login throttle: %v", err)
+	}
+
+	seen, err := g.repo.HasSecurityEvent(user.ID, SecurityEventLoginSuccess, ip)
+	if err != nil {
+		log.Printf("Error checking login history: %v", err)
+	}
+	previous, err := g.repo.CountSecurityEvents(user.ID, SecurityEventLoginSuccess)
+	if err != nil {
+		log.Printf("Error checking login history: %v", err)
+	}
+
+	event := &repository.SecurityEvent{
+		OrganizationID: &user.OrganizationID,
+		UserID:         &user.ID,
+		Email:          &user.Email,
+		EventType:      SecurityEventLoginSuccess,
+		IPAddress:      &ip,
+	}
+	g.logEvent(event)
+
+	if !seen && previous > 0 {
+		newIP := *event
+		newIP.EventType = SecurityEventLoginNewIP
+		g.logEvent(&newIP)
+	}
+}
+
+func (g *LoginGuard) Unlock(user *repository.User, actorID uint) error {
+	if err := g.repo.DeleteLoginThrottle(accountKey(user.Email)); err != nil {
+		return err
+	}
+
+	g.logEvent(&repository.SecurityEvent{
+		OrganizationID: &user.OrganizationID,
+		UserID:         &user.ID,
+		Email:          &user.Email,
+		EventType:      SecurityEventAccountUnlocked,
+		Details:        stringPtr(fmt.Sprintf("unlocked by user %d", actorID)),
+	})
+	return nil
+}
+
+func (g *LoginGuard) ListEvents(orgID uint, page, pageSize int, eventType string) ([]repository.SecurityEvent, int, error) {
+	return g.repo.ListSecurityEvents(orgID, page, pageSize, eventType)
+}
+
+func (g *LoginGuard) waitFor(throttle *repository.LoginThrottle, freeFailures int, now time.Time) (time.Duration, bool) {
+	if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+		return throttle.LockedUntil.Sub(now), true
+	}
+	if throttle.LastFailureAt == nil || throttle.Failures <= freeFailures {
+		return 0, false
+	}
+
+	delay := g.baseDelay * time.Duration(1<<uint(min(throttle.Failures-freeFailures-1, 16)))
+	if delay > g.lockout {
+		delay = g.lockout
+	}
+	if ready := throttle.LastFailureAt.Add(delay); now.Before(ready) {
+		return ready.Sub(now), false
+	}
+	return 0, false
+}
+
+// count counts a failure against key unless it is throttled, and reports
+// whether the failure locked it.
+func (g *LoginGuard) count(key string, freeFailures, maxFailures int) (bool, error) {
+	for i := 0; i < loginAttemptRetries; i++ {
+		throttle, err := g.repo.GetLoginThrottle(key)
+		if err != nil {
+			return false, err
+		}
+		now := time.Now().UTC()
+		if wait, locked := g.waitFor(throttle, freeFailures, now); wait > 0 {
+			return false, &ThrottleError{RetryAfter: wait, Locked: locked}
+		}
+
+		previous := throttle.Failures
+		if throttle.LockedUntil != nil || (throttle.LastFailureAt != nil && now.Sub(*throttle.LastFailureAt) > g.lockout) {
+			throttle.Failures = 0
+			throttle.LockedUntil = nil
+		}
+		throttle.Failures++
+		throttle.LastFailureAt = &now
+
+		locked := false
+		if throttle.Failures >= maxFailures {
+			until := now.Add(g.lockout)
+			throttle.LockedUntil = &until
+			locked = true
+		}
+
+		counted, err := g.repo.CountLoginFailure(throttle, previous)
+		if err != nil {
+			return false, err
+		}
+		if counted {
+			return locked, nil
+		}
+	}
+	return false, &ThrottleError{RetryAfter: g.baseDelay}
+}
+
+func (g *LoginGuard) logEvent(event *repository.SecurityEvent) {
+	if err := g.repo.CreateSecurityEvent(event); err != nil {
+		log.Printf("Error recording security event: %v", err)
+	}
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func resetKey(ip string) string {
+	return "reset:" + ip
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
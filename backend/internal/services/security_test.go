@@ -0,0 +1,145 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"assetsentinel/internal/jwtkeys"
+	"assetsentinel/internal/rbac"
+	"assetsentinel/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestRepository(t *testing.T) *repository.Repository {
+	t.Helper()
+	db, err := repository.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := repository.RunMigrations(db); err != nil {
+		t.Fatal(err)
+	}
+	return repository.NewRepository(db)
+}
+
+func seedLoginUser(t *testing.T, repo *repository.Repository, email, password string) *repository.User {
+	t.Helper()
+	org := repository.Organization{Name: "Acme"}
+	if err := repo.CreateOrganization(&org); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &repository.User{OrganizationID: org.ID, Email: email, PasswordHash: string(hash), FullName: "Test User", Role: rbac.RoleTechnician}
+	if err := repo.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestParallelLoginFailuresCannotBypassThrottle(t *testing.T) {
+	repo := newTestRepository(t)
+	user := seedLoginUser(t, repo, "tech@acme.test", "correct horse battery")
+	guard := NewLoginGuard(repo, 5, 2, 100, 15*time.Minute)
+
+	const attempts = 20
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		checked int
+	)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			attempt, err := guard.Attempt(user.Email, "203.0.113.7")
+			if err != nil {
+				var throttled *ThrottleError
+				if !errors.As(err, &throttled) {
+					t.Errorf("Attempt: %v", err)
+				}
+				return
+			}
+			guard.RecordFailure(attempt, user)
+			mu.Lock()
+			checked++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	// The free failures and the first delayed one get through; every other
+	// guess arrives before the delay has passed.
+	if checked == 0 || checked > 3 {
+		t.Fatalf("%d of %d parallel guesses were checked, want 1 to 3", checked, attempts)
+	}
+	throttle, err := repo.GetLoginThrottle(accountKey(user.Email))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if throttle.Failures != checked {
+		t.Errorf("counted %d failures, want %d", throttle.Failures, checked)
+	}
+}
+
+func TestLoginGuardLocksAccountAtMaxFailures(t *testing.T) {
+	repo := newTestRepository(t)
+	user := seedLoginUser(t, repo, "tech@acme.test", "correct horse battery")
+	guard := NewLoginGuard(repo, 3, 3, 100, 15*time.Minute)
+	auth := NewAuthService(repo, jwtkeys.NewHMAC("test-secret"), guard, nil)
+
+	for i := 0; i < 3; i++ {
+		if _, _, err := auth.Login(user.Email, "wrong password", "203.0.113.7"); err == nil || errors.As(err, new(*ThrottleError)) {
+			t.Fatalf("attempt %d: got %v, want invalid credentials", i+1, err)
+		}
+	}
+
+	_, _, err := auth.Login(user.Email, "correct horse battery", "203.0.113.7")
+	var throttled *ThrottleError
+	if !errors.As(err, &throttled) || !throttled.Locked {
+		t.Fatalf("got %v after 3 failures, want a lockout", err)
+	}
+	locked, err := repo.CountSecurityEvents(user.ID, SecurityEventAccountLocked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locked != 1 {
+		t.Errorf("logged %d account_locked events, want 1", locked)
+	}
+
+	if err := guard.Unlock(user, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := auth.Login(user.Email, "correct horse battery", "203.0.113.7"); err != nil {
+		t.Fatalf("login after unlock: %v", err)
+	}
+}
+
+func TestAccountFreeFailuresAreConfigurable(t *testing.T) {
+	repo := newTestRepository(t)
+	user := seedLoginUser(t, repo, "tech@acme.test", "correct horse battery")
+	guard := NewLoginGuard(repo, 10, 4, 100, 15*time.Minute)
+
+	for i := 0; i < 4; i++ {
+		attempt, err := guard.Attempt(user.Email, "203.0.113.7")
+		if err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+		guard.RecordFailure(attempt, user)
+	}
+	if _, err := guard.Attempt(user.Email, "203.0.113.7"); err != nil {
+		t.Fatalf("attempt 5: %v, want it allowed after 4 free failures", err)
+	}
+	_, err := guard.Attempt(user.Email, "203.0.113.7")
+	var throttled *ThrottleError
+	if !errors.As(err, &throttled) || throttled.Locked {
+		t.Fatalf("attempt 6: got %v, want a delay", err)
+	}
+}
@@ -2,20 +2,35 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"log"
 	"time"
 
+	"assetsentinel/internal/calendar"
+	"assetsentinel/internal/events"
+	"assetsentinel/internal/jwtkeys"
+	"assetsentinel/internal/rbac"
 	"assetsentinel/internal/repository"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrRoleNotAssignable   = errors.New("role cannot be assigned")
+	ErrUnknownOrganization = errors.New("unknown organization")
+	ErrRegistrationClosed  = errors.New("organization already has users; ask an administrator for an account")
+	ErrForeignReference    = errors.New("referenced record does not belong to this organization")
+)
+
 type AuthService struct {
-	repo      *repository.Repository
-	jwtSecret string
+	repo   *repository.Repository
+	keys   *jwtkeys.KeySet
+	guard  *LoginGuard
+	policy *PasswordPolicy
 }
 
-func NewAuthService(repo *repository.Repository, jwtSecret string) *AuthService {
-	return &AuthService{repo: repo, jwtSecret: jwtSecret}
+func NewAuthService(repo *repository.Repository, keys *jwtkeys.KeySet, guard *LoginGuard, policy *PasswordPolicy) *AuthService {
+	return &AuthService{repo: repo, keys: keys, guard: guard, policy: policy}
 }
 
 type Claims struct {
@@ -26,36 +41,74 @@ type Claims struct {
 }
 
 func (s *AuthService) Register(email, password, fullName, role string, orgID uint) (*repository.User, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if rbac.IsPlatformRole(role) {
+		return nil, ErrRoleNotAssignable
+	}
+	if err := validateRole(s.repo, orgID, role); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repo.GetOrganization(orgID); err != nil {
+		return nil, ErrUnknownOrganization
+	}
+	count, err := s.repo.CountUsers(orgID)
 	if err != nil {
 		return nil, err
 	}
+	if count > 0 {
+		return nil, ErrRegistrationClosed
+	}
 
 	user := &repository.User{
 		OrganizationID: orgID,
 		Email:          email,
-		PasswordHash:   string(hash),
 		FullName:       fullName,
 		Role:           role,
 	}
 
-	if err := s.repo.CreateUser(user); err != nil {
+	if err := s.CreateUser(user, password); err != nil {
 		return nil, err
 	}
 
 	return user, nil
 }
 
-func (s *AuthService) Login(email, password string) (string, *repository.User, error) {
+func (s *AuthService) CreateUser(user *repository.User, password string) error {
+	if err := s.policy.Validate(password); err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = string(hash)
+
+	if err := s.repo.CreateUser(user); err != nil {
+		return err
+	}
+	return s.repo.AddPasswordHistory(user.ID, user.PasswordHash)
+}
+
+func (s *AuthService) Login(email, password, ip string) (string, *repository.User, error) {
+	attempt, err := s.guard.Attempt(email, ip)
+	if err != nil {
+		return "", nil, err
+	}
+
 	user, err := s.repo.GetUserByEmail(email)
 	if err != nil {
+		s.guard.RecordFailure(attempt, nil)
 		return "", nil, errors.New("invalid credentials")
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		s.guard.RecordFailure(attempt, user)
 		return "", nil, errors.New("invalid credentials")
 	}
 
+	s.guard.RecordSuccess(attempt, user)
+
 	claims := &Claims{
 		UserID:         user.ID,
 		OrganizationID: user.OrganizationID,
@@ -66,8 +119,7 @@ func (s *AuthService) Login(email, password string) (string, *repository.User, e
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(s.jwtSecret))
+	tokenString, err := s.keys.Sign(claims)
 	if err != nil {
 		return "", nil, err
 	}
@@ -105,18 +157,28 @@ func (s *AssetService) Delete(id, orgID uint) error {
 
 type MaintenanceService struct {
 	repo *repository.Repository
-	hub  interface {
-		BroadcastToOrg(orgID uint, message map[string]interface{})
-	}
 }
 
-func NewMaintenanceService(repo *repository.Repository, hub interface {
-	BroadcastToOrg(orgID uint, message map[string]interface{})
-}) *MaintenanceService {
-	return &MaintenanceService{repo: repo, hub: hub}
+func NewMaintenanceService(repo *repository.Repository) *MaintenanceService {
+	return &MaintenanceService{repo: repo}
 }
 
 func (s *MaintenanceService) Create(plan *repository.MaintenancePlan) error {
+	if err := requireAsset(s.repo, plan.AssetID, plan.OrganizationID); err != nil {
+		return err
+	}
+	if err := normalizeShift(plan); err != nil {
+		return err
+	}
+	if err := normalizeWorkOrderSettings(plan); err != nil {
+		return err
+	}
+	if err := requireProcedure(s.repo, plan.ProcedureID, plan.OrganizationID); err != nil {
+		return err
+	}
+	if err := normalizeRecurrence(plan, nil); err != nil {
+		return err
+	}
 	return s.repo.CreateMaintenancePlan(plan)
 }
 
@@ -129,36 +191,85 @@ func (s *MaintenanceService) List(orgID uint, page, pageSize int) ([]repository.
 }
 
 func (s *MaintenanceService) Update(plan *repository.MaintenancePlan) error {
+	if err := requireAsset(s.repo, plan.AssetID, plan.OrganizationID); err != nil {
+		return err
+	}
+	if err := normalizeShift(plan); err != nil {
+		return err
+	}
+	if err := normalizeWorkOrderSettings(plan); err != nil {
+		return err
+	}
+	if err := requireProcedure(s.repo, plan.ProcedureID, plan.OrganizationID); err != nil {
+		return err
+	}
+	existing, err := s.repo.GetMaintenancePlan(plan.ID, plan.OrganizationID)
+	if err != nil {
+		return err
+	}
+	if err := normalizeRecurrence(plan, existing); err != nil {
+		return err
+	}
+	plan.LastAssigneeID = existing.LastAssigneeID
 	return s.repo.UpdateMaintenancePlan(plan)
 }
 
 func (s *MaintenanceService) Delete(id, orgID uint) error {
-	return s.repo.DeleteMaintenancePlan(id, orgID)
+	return s.repo.WithTx(func(tx *repository.Repository) error {
+		if err := cancelPlanEvents(tx, id, orgID); err != nil {
+			return err
+		}
+		return tx.DeleteMaintenancePlan(id, orgID)
+	})
 }
 
-type WorkOrderService struct {
-	repo *repository.Repository
-	hub  interface {
-		BroadcastToOrg(orgID uint, message map[string]interface{})
+func normalizeShift(plan *repository.MaintenancePlan) error {
+	if plan.NonWorkingDayShift == "" {
+		plan.NonWorkingDayShift = calendar.ShiftNone
+	}
+	if !calendar.ValidShift(plan.NonWorkingDayShift) {
+		return fmt.Errorf("%w: non_working_day_shift must be none, next or previous", ErrInvalidCalendar)
 	}
+	return nil
+}
+
+type WorkOrderService struct {
+	repo     *repository.Repository
+	notifier *NotificationService
+	dispatch *DispatchService
 }
 
-func NewWorkOrderService(repo *repository.Repository, hub interface {
-	BroadcastToOrg(orgID uint, message map[string]interface{})
-}) *WorkOrderService {
-	return &WorkOrderService{repo: repo, hub: hub}
+func NewWorkOrderService(repo *repository.Repository, notifier *NotificationService, dispatch *DispatchService) *WorkOrderService {
+	return &WorkOrderService{repo: repo, notifier: notifier, dispatch: dispatch}
 }
 
 func (s *WorkOrderService) Create(wo *repository.WorkOrder) error {
-	if err := s.repo.CreateWorkOrder(wo); err != nil {
+	if err := s.validateReferences(wo); err != nil {
 		return err
 	}
-	if s.hub != nil {
-		s.hub.BroadcastToOrg(wo.OrganizationID, map[string]interface{}{
-			"type":       "work_order_created",
-			"work_order": wo,
-		})
+	if err := s.dispatch.ValidateAssignment(wo, nil); err != nil {
+		return err
+	}
+	actor := events.System()
+	if wo.CreatedBy != nil {
+		actor = events.User(*wo.CreatedBy)
+	}
+	err := s.repo.WithTx(func(tx *repository.Repository) error {
+		if err := s.dispatch.CheckConflicts(tx, wo, nil); err != nil {
+			return err
+		}
+		if err := requireProcedureResults(tx, wo, nil); err != nil {
+			return err
+		}
+		if err := tx.CreateWorkOrder(wo); err != nil {
+			return err
+		}
+		return events.Enqueue(tx, wo.OrganizationID, actor, events.WorkOrderCreated{WorkOrder: events.NewWorkOrder(wo)})
+	})
+	if err != nil {
+		return err
 	}
+	s.notifyAssignment(wo, nil)
 	return nil
 }
 
@@ -170,36 +281,120 @@ func (s *WorkOrderService) List(orgID uint, page, pageSize int, status string) (
 	return s.repo.ListWorkOrders(orgID, page, pageSize, status)
 }
 
-func (s *WorkOrderService) Update(wo *repository.WorkOrder, oldStatus string) error {
-	if err := s.repo.UpdateWorkOrder(wo); err != nil {
+func (s *WorkOrderService) Update(wo *repository.WorkOrder, oldStatus string, actorID uint) error {
+	var previousTechnician *uint
+	wo.MaintenanceTaskID, wo.SourceWorkOrderID = nil, nil
+	existing, err := s.repo.GetWorkOrder(wo.ID, wo.OrganizationID)
+	if err == nil {
+		previousTechnician = existing.TechnicianID
+		wo.MaintenanceTaskID = existing.MaintenanceTaskID
+		wo.SourceWorkOrderID = existing.SourceWorkOrderID
+	}
+	if err := s.validateReferences(wo); err != nil {
+		return err
+	}
+	if err := s.dispatch.ValidateAssignment(wo, existing); err != nil {
 		return err
 	}
-	if s.hub != nil && oldStatus != wo.Status {
-		s.hub.BroadcastToOrg(wo.OrganizationID, map[string]interface{}{
-			"type":       "work_order_status_change",
-			"work_order": wo,
-			"old_status": oldStatus,
-			"new_status": wo.Status,
+	err = s.repo.WithTx(func(tx *repository.Repository) error {
+		if err := s.dispatch.CheckConflicts(tx, wo, existing); err != nil {
+			return err
+		}
+		if err := requireProcedureResults(tx, wo, existing); err != nil {
+			return err
+		}
+		before, err := workOrderCalendarEntry(tx, wo.ID, wo.OrganizationID)
+		if err != nil {
+			return err
+		}
+		if err := tx.UpdateWorkOrder(wo); err != nil {
+			return err
+		}
+		after, err := workOrderCalendarEntry(tx, wo.ID, wo.OrganizationID)
+		if err != nil {
+			return err
+		}
+		if err := cancelWorkOrderEvents(tx, wo.OrganizationID, before, after); err != nil {
+			return err
+		}
+		if oldStatus == wo.Status {
+			return nil
+		}
+		if wo.MaintenanceTaskID != nil && workOrderDone(wo.Status) && !workOrderDone(oldStatus) {
+			if err := completeWorkOrderTask(tx, wo); err != nil {
+				return err
+			}
+		}
+		return events.Enqueue(tx, wo.OrganizationID, events.User(actorID), events.WorkOrderStatusChanged{
+			WorkOrder: events.NewWorkOrder(wo),
+			OldStatus: oldStatus,
+			NewStatus: wo.Status,
 		})
+	})
+	if err != nil {
+		return err
 	}
+	s.notifyAssignment(wo, previousTechnician)
 	return nil
 }
 
 func (s *WorkOrderService) Delete(id, orgID uint) error {
-	return s.repo.DeleteWorkOrder(id, orgID)
+	return s.repo.WithTx(func(tx *repository.Repository) error {
+		entry, err := workOrderCalendarEntry(tx, id, orgID)
+		if err != nil {
+			return err
+		}
+		if err := cancelWorkOrderEvents(tx, orgID, entry, nil); err != nil {
+			return err
+		}
+		return tx.DeleteWorkOrder(id, orgID)
+	})
+}
+
+func (s *WorkOrderService) notifyAssignment(wo *repository.WorkOrder, previousTechnician *uint) {
+	if s.notifier == nil || wo.TechnicianID == nil {
+		return
+	}
+	if previousTechnician != nil && *previousTechnician == *wo.TechnicianID {
+		return
+	}
+
+	err := s.notifier.Notify(wo.OrganizationID, *wo.TechnicianID, NotificationWorkOrderAssigned,
+		"Work order assigned: "+wo.Title, "You have been assigned a "+wo.Priority+" priority work order.",
+		map[string]interface{}{"work_order_id": wo.ID, "asset_id": wo.AssetID})
+	if err != nil {
+		log.Printf("Error sending assignment notification: %v", err)
+	}
+}
+
+func (s *WorkOrderService) validateReferences(wo *repository.WorkOrder) error {
+	if err := requireAsset(s.repo, wo.AssetID, wo.OrganizationID); err != nil {
+		return err
+	}
+	if wo.TechnicianID != nil {
+		if _, err := s.repo.GetUser(*wo.TechnicianID, wo.OrganizationID); err != nil {
+			return ErrForeignReference
+		}
+	}
+	if wo.MaintenanceTaskID != nil {
+		if _, err := s.repo.GetMaintenanceTask(*wo.MaintenanceTaskID, wo.OrganizationID); err != nil {
+			return ErrForeignReference
+		}
+	}
+	if wo.SourceWorkOrderID != nil {
+		if _, err := s.repo.GetWorkOrder(*wo.SourceWorkOrderID, wo.OrganizationID); err != nil {
+			return ErrForeignReference
+		}
+	}
+	return requireProcedure(s.repo, wo.ProcedureID, wo.OrganizationID)
 }
 
 type InventoryService struct {
 	repo *repository.Repository
-	hub  interface {
-		BroadcastToOrg(orgID uint, message map[string]interface{})
-	}
 }
 
-func NewInventoryService(repo *repository.Repository, hub interface {
-	BroadcastToOrg(orgID uint, message map[string]interface{})
-}) *InventoryService {
-	return &InventoryService{repo: repo, hub: hub}
+func NewInventoryService(repo *repository.Repository) *InventoryService {
+	return &InventoryService{repo: repo}
 }
 
 func (s *InventoryService) Create(part *repository.InventoryPart) error {
@@ -218,23 +413,21 @@ func (s *InventoryService) GetLowStock(orgID uint) ([]repository.InventoryPart,
 	return s.repo.GetLowStockParts(orgID)
 }
 
-func (s *InventoryService) Update(part *repository.InventoryPart) error {
+func (s *InventoryService) Update(part *repository.InventoryPart, actorID uint) error {
 	oldPart, err := s.repo.GetInventoryPart(part.ID, part.OrganizationID)
 	if err != nil {
 		return err
 	}
 
-	if err := s.repo.UpdateInventoryPart(part); err != nil {
-		return err
-	}
-
-	if s.hub != nil && oldPart.Quantity > part.MinThreshold && part.Quantity <= part.MinThreshold {
-		s.hub.BroadcastToOrg(part.OrganizationID, map[string]interface{}{
-			"type": "low_inventory",
-			"part": part,
-		})
-	}
-	return nil
+	return s.repo.WithTx(func(tx *repository.Repository) error {
+		if err := tx.UpdateInventoryPart(part); err != nil {
+			return err
+		}
+		if oldPart.Quantity <= part.MinThreshold || part.Quantity > part.MinThreshold {
+			return nil
+		}
+		return events.Enqueue(tx, part.OrganizationID, events.User(actorID), events.LowInventory{Part: events.NewInventoryPart(part)})
+	})
 }
 
 func (s *InventoryService) Deduct(partID, orgID, quantity int) (int, error) {
@@ -288,3 +481,10 @@ func (s *DepreciationService) GetAllCosts(orgID uint) ([]struct {
 }, error) {
 	return s.repo.GetAllAssetCosts(orgID)
 }
+
+func requireAsset(repo *repository.Repository, assetID, orgID uint) error {
+	if _, err := repo.GetAsset(assetID, orgID); err != nil {
+		return ErrForeignReference
+	}
+	return nil
+}
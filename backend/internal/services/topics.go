@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"assetsentinel/internal/events"
+	"assetsentinel/internal/rbac"
+	"assetsentinel/internal/repository"
+)
+
+const (
+	TopicAsset     = "asset"
+	TopicWorkOrder = "work_order"
+	TopicEvent     = "event"
+	TopicLocation  = "location"
+)
+
+var (
+	ErrInvalidTopic   = errors.New("invalid topic")
+	ErrTopicForbidden = errors.New("not permitted to subscribe to this topic")
+)
+
+var eventPermissions = map[string]string{
+	events.TypeWorkOrderCreated:       rbac.WorkOrdersRead,
+	events.TypeWorkOrderStatusChanged: rbac.WorkOrdersRead,
+	events.TypeMaintenanceDue:         rbac.MaintenanceRead,
+	events.TypeMaintenanceOverdue:     rbac.MaintenanceRead,
+	events.TypeLowInventory:           rbac.InventoryRead,
+}
+
+type TopicService struct {
+	repo  *repository.Repository
+	roles *RoleService
+}
+
+func NewTopicService(repo *repository.Repository, roles *RoleService) *TopicService {
+	return &TopicService{repo: repo, roles: roles}
+}
+
+func (s *TopicService) AuthorizeTopic(orgID, userID uint, role, topic string) error {
+	kind, value, ok := strings.Cut(topic, ":")
+	if !ok || value == "" {
+		return ErrInvalidTopic
+	}
+
+	var required string
+	switch kind {
+	case TopicAsset:
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return ErrInvalidTopic
+		}
+		if _, err := s.repo.GetAsset(uint(id), orgID); err != nil {
+			return ErrTopicForbidden
+		}
+		required = rbac.AssetsRead
+	case TopicWorkOrder:
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return ErrInvalidTopic
+		}
+		if _, err := s.repo.GetWorkOrder(uint(id), orgID); err != nil {
+			return ErrTopicForbidden
+		}
+		required = rbac.WorkOrdersRead
+	case TopicEvent:
+		permission, known := eventPermissions[value]
+		if !known {
+			return ErrInvalidTopic
+		}
+		required = permission
+	case TopicLocation:
+		required = rbac.AssetsRead
+	default:
+		return ErrInvalidTopic
+	}
+
+	permissions, err := s.roles.ResolvePermissions(orgID, role)
+	if err != nil {
+		return ErrTopicForbidden
+	}
+	for _, permission := range permissions {
+		if permission == required {
+			return nil
+		}
+	}
+	return ErrTopicForbidden
+}
+
+func (s *TopicService) MessageTopics(orgID uint, message map[string]interface{}) []string {
+	var topics []string
+	if eventType, ok := message["type"].(string); ok {
+		topics = append(topics, TopicEvent+":"+eventType)
+	}
+
+	var assetID uint
+	switch data := message["data"].(type) {
+	case events.WorkOrderCreated:
+		topics = append(topics, formatTopic(TopicWorkOrder, data.WorkOrder.ID))
+		assetID = data.WorkOrder.AssetID
+	case events.WorkOrderStatusChanged:
+		topics = append(topics, formatTopic(TopicWorkOrder, data.WorkOrder.ID))
+		assetID = data.WorkOrder.AssetID
+	case events.LowInventory:
+		if data.Part.Location != nil && *data.Part.Location != "" {
+			topics = append(topics, TopicLocation+":"+*data.Part.Location)
+		}
+	case events.MaintenanceDue:
+		assetID = data.AssetID
+	case events.MaintenanceOverdue:
+		assetID = data.AssetID
+	}
+
+	if assetID != 0 {
+		topics = append(topics, formatTopic(TopicAsset, assetID))
+		if asset, err := s.repo.GetAsset(assetID, orgID); err == nil && asset.Location != nil && *asset.Location != "" {
+			topics = append(topics, TopicLocation+":"+*asset.Location)
+		}
+	}
+	return topics
+}
+
+func formatTopic(kind string, id uint) string {
+	return kind + ":" + strconv.FormatUint(uint64(id), 10)
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"assetsentinel/internal/events"
+	"assetsentinel/internal/rbac"
+	"assetsentinel/internal/repository"
+)
+
+func TestAuthorizeTopic(t *testing.T) {
+	repo := newTestRepository(t)
+	topics := NewTopicService(repo, NewRoleService(repo))
+	acme, globex := repository.Organization{Name: "Acme"}, repository.Organization{Name: "Globex"}
+	for _, org := range []*repository.Organization{&acme, &globex} {
+		if err := repo.CreateOrganization(org); err != nil {
+			t.Fatal(err)
+		}
+	}
+	asset := repository.Asset{OrganizationID: acme.ID, Name: "Pump", Category: "pump", Status: "active"}
+	if err := repo.CreateAsset(&asset); err != nil {
+		t.Fatal(err)
+	}
+	workOrder := repository.WorkOrder{OrganizationID: acme.ID, AssetID: asset.ID, Title: "Repair", Status: "pending", Priority: "medium"}
+	if err := repo.CreateWorkOrder(&workOrder); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CreateRole(&repository.Role{OrganizationID: acme.ID, Name: "storekeeper", Permissions: []string{rbac.InventoryRead}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		org   uint
+		role  string
+		topic string
+		want  error
+	}{
+		{acme.ID, rbac.RoleViewer, fmt.Sprintf("asset:%d", asset.ID), nil},
+		{acme.ID, rbac.RoleTechnician, fmt.Sprintf("work_order:%d", workOrder.ID), nil},
+		{acme.ID, rbac.RoleViewer, "event:low_inventory", nil},
+		{acme.ID, rbac.RoleViewer, "location:Building A", nil},
+		{acme.ID, "storekeeper", "event:low_inventory", nil},
+		{acme.ID, "storekeeper", "event:work_order_created", ErrTopicForbidden},
+		{acme.ID, "storekeeper", fmt.Sprintf("asset:%d", asset.ID), ErrTopicForbidden},
+		{globex.ID, rbac.RoleAdmin, fmt.Sprintf("asset:%d", asset.ID), ErrTopicForbidden},
+		{globex.ID, rbac.RoleAdmin, fmt.Sprintf("work_order:%d", workOrder.ID), ErrTopicForbidden},
+		{acme.ID, "unknown", "event:low_inventory", ErrTopicForbidden},
+		{acme.ID, rbac.RoleAdmin, "asset:pump", ErrInvalidTopic},
+		{acme.ID, rbac.RoleAdmin, "asset:", ErrInvalidTopic},
+		{acme.ID, rbac.RoleAdmin, "event:asset_deleted", ErrInvalidTopic},
+		{acme.ID, rbac.RoleAdmin, "organization:1", ErrInvalidTopic},
+		{acme.ID, rbac.RoleAdmin, "everything", ErrInvalidTopic},
+	}
+	for _, tt := range tests {
+		if err := topics.AuthorizeTopic(tt.org, 1, tt.role, tt.topic); !errors.Is(err, tt.want) {
+			t.Errorf("%s subscribing to %q in org %d: %v, want %v", tt.role, tt.topic, tt.org, err, tt.want)
+		}
+	}
+}
+
+func TestMessageTopics(t *testing.T) {
+	repo := newTestRepository(t)
+	topics := NewTopicService(repo, NewRoleService(repo))
+	org := repository.Organization{Name: "Acme"}
+	if err := repo.CreateOrganization(&org); err != nil {
+		t.Fatal(err)
+	}
+	location := "Building A"
+	asset := repository.Asset{OrganizationID: org.ID, Name: "Pump", Category: "pump", Status: "active", Location: &location}
+	if err := repo.CreateAsset(&asset); err != nil {
+		t.Fatal(err)
+	}
+	shelf := "Store 2"
+
+	tests := []struct {
+		data events.Payload
+		want []string
+	}{
+		{events.WorkOrderCreated{WorkOrder: events.WorkOrder{ID: 7, AssetID: asset.ID}},
+			[]string{"event:work_order_created", "work_order:7", fmt.Sprintf("asset:%d", asset.ID), "location:Building A"}},
+		{events.MaintenanceOverdue{AssetID: asset.ID},
+			[]string{"event:maintenance_overdue", fmt.Sprintf("asset:%d", asset.ID), "location:Building A"}},
+		{events.LowInventory{Part: events.InventoryPart{ID: 3, Location: &shelf}},
+			[]string{"event:low_inventory", "location:Store 2"}},
+		{events.LowInventory{Part: events.InventoryPart{ID: 3}},
+			[]string{"event:low_inventory"}},
+	}
+	for _, tt := range tests {
+		message := map[string]interface{}{"type": tt.data.EventType(), "data": tt.data}
+		if got := topics.MessageTopics(org.ID, message); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s topics = %v, want %v", tt.data.EventType(), got, tt.want)
+		}
+	}
+}
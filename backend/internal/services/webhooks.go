@@ -0,0 +1,259 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"assetsentinel/internal/events"
+	"assetsentinel/internal/repository"
+)
+
+const (
+	webhookMaxAttempts  = 8
+	webhookBaseBackoff  = 30 * time.Second
+	webhookMaxBackoff   = 6 * time.Hour
+	webhookLease        = 2 * time.Minute
+	webhookTimeout      = 10 * time.Second
+	webhookResponseSize = 2048
+)
+
+var (
+	ErrInvalidWebhookURL = errors.New("webhook URL must be an absolute http or https URL")
+	ErrUnknownEventType  = errors.New("unknown event type")
+)
+
+type WebhookService struct {
+	repo   *repository.Repository
+	client *http.Client
+}
+
+func NewWebhookService(repo *repository.Repository) *WebhookService {
+	return &WebhookService{repo: repo, client: newOutboundClient(webhookTimeout)}
+}
+
+func (s *WebhookService) List(orgID uint) ([]repository.Webhook, error) {
+	return s.repo.ListWebhooks(orgID)
+}
+
+func (s *WebhookService) Get(id, orgID uint) (*repository.Webhook, error) {
+	return s.repo.GetWebhook(id, orgID)
+}
+
+func (s *WebhookService) Create(webhook *repository.Webhook) error {
+	if err := validateWebhook(webhook); err != nil {
+		return err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	webhook.Secret = "whsec_" + hex.EncodeToString(secret)
+	return s.repo.CreateWebhook(webhook)
+}
+
+func (s *WebhookService) Update(webhook *repository.Webhook) error {
+	if err := validateWebhook(webhook); err != nil {
+		return err
+	}
+	return s.repo.UpdateWebhook(webhook)
+}
+
+func (s *WebhookService) Delete(id, orgID uint) error {
+	return s.repo.DeleteWebhook(id, orgID)
+}
+
+func (s *WebhookService) Deliveries(webhookID, orgID uint, page, pageSize int) ([]repository.WebhookDelivery, int, error) {
+	if _, err := s.repo.GetWebhook(webhookID, orgID); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.ListWebhookDeliveries(webhookID, orgID, page, pageSize)
+}
+
+func (s *WebhookService) Redeliver(deliveryID, orgID uint) (*repository.WebhookDelivery, error) {
+	original, err := s.repo.GetWebhookDelivery(deliveryID, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := &repository.WebhookDelivery{
+		WebhookID:      original.WebhookID,
+		OrganizationID: original.OrganizationID,
+		EventType:      original.EventType,
+		Payload:        original.Payload,
+	}
+	if err := s.repo.CreateWebhookDelivery(delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+func (s *WebhookService) Consume(event *events.Envelope) error {
+	webhooks, err := s.repo.ListActiveWebhooks(event.OrganizationID)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.WithTx(func(tx *repository.Repository) error {
+		for _, webhook := range webhooks {
+			if !subscribesTo(webhook, event.Type) {
+				continue
+			}
+			err := tx.CreateWebhookDelivery(&repository.WebhookDelivery{
+				WebhookID:      webhook.ID,
+				OrganizationID: event.OrganizationID,
+				EventType:      event.Type,
+				Payload:        payload,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *WebhookService) DeliverDue(limit int) int {
+	now := time.Now().UTC().Truncate(time.Second)
+	deliveries, err := s.repo.ListDueWebhookDeliveries(now, limit)
+	if err != nil {
+		log.Printf("Error loading due webhook deliveries: %v", err)
+		return 0
+	}
+
+	delivered := 0
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		claimed, err := s.repo.ClaimWebhookDelivery(delivery.ID, now, now.Add(webhookLease))
+		if err != nil || !claimed {
+			continue
+		}
+		s.deliver(delivery)
+		delivered++
+	}
+	return delivered
+}
+
+func (s *WebhookService) deliver(delivery *repository.WebhookDelivery) {
+	now := time.Now().UTC().Truncate(time.Second)
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseCode = nil
+	delivery.Error = nil
+
+	webhook, err := s.repo.GetWebhook(delivery.WebhookID, delivery.OrganizationID)
+	if err == nil {
+		err = s.send(webhook, delivery)
+	}
+
+	switch {
+	case err == nil:
+		delivery.Status = "succeeded"
+		delivery.NextAttemptAt = nil
+	case delivery.Attempts >= webhookMaxAttempts:
+		delivery.Status = "failed"
+		delivery.NextAttemptAt = nil
+		delivery.Error = stringPtr(err.Error())
+	default:
+		next := now.Add(webhookBackoff(delivery.Attempts))
+		delivery.Status = "pending"
+		delivery.NextAttemptAt = &next
+		delivery.Error = stringPtr(err.Error())
+	}
+
+	if err := s.repo.RecordWebhookAttempt(delivery); err != nil {
+		log.Printf("Error recording webhook delivery %d: %v", delivery.ID, err)
+	}
+}
+
+func (s *WebhookService) send(webhook *repository.Webhook, delivery *repository.WebhookDelivery) error {
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "AssetSentinel-Webhooks/1.0")
+	req.Header.Set("X-AssetSentinel-Event", delivery.EventType)
+	req.Header.Set("X-AssetSentinel-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-AssetSentinel-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-AssetSentinel-Signature", SignWebhookPayload(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, webhookResponseSize))
+	code := resp.StatusCode
+	delivery.ResponseCode = &code
+
+	if code < 200 || code >= 300 {
+		return fmt.Errorf("endpoint responded with status %d", code)
+	}
+	return nil
+}
+
+func SignWebhookPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookBaseBackoff << uint(min(attempts-1, 20))
+	if backoff > webhookMaxBackoff {
+		backoff = webhookMaxBackoff
+	}
+	return backoff
+}
+
+func subscribesTo(webhook repository.Webhook, eventType string) bool {
+	if len(webhook.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range webhook.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func validateWebhook(webhook *repository.Webhook) error {
+	parsed, err := url.Parse(webhook.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ErrInvalidWebhookURL
+	}
+	if err := checkPublicHost(parsed.Hostname()); err != nil {
+		return err
+	}
+	for _, eventType := range webhook.EventTypes {
+		if !events.IsType(eventType) {
+			return fmt.Errorf("%w: %s", ErrUnknownEventType, eventType)
+		}
+	}
+	if webhook.EventTypes == nil {
+		webhook.EventTypes = []string{}
+	}
+	return nil
+}
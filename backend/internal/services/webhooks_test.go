@@ -0,0 +1,89 @@
+package services
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"assetsentinel/internal/repository"
+)
+
+func TestPublicAddress(t *testing.T) {
+	for address, public := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:2800:220::": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fe80::1":         false,
+		"fd00:ec2::254":   false,
+		"::ffff:10.0.0.1": false,
+		"64:ff9b::a00:1":  false,
+	} {
+		if got := publicAddress(net.ParseIP(address)); got != public {
+			t.Errorf("publicAddress(%s) = %v, want %v", address, got, public)
+		}
+	}
+}
+
+func TestValidateWebhookRejectsPrivateAddresses(t *testing.T) {
+	for _, url := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://10.0.0.5/hook",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[::1]/hook",
+		"https://[fd00:ec2::254]/hook",
+	} {
+		if err := validateWebhook(&repository.Webhook{URL: url}); !errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("%s: got %v, want ErrPrivateAddress", url, err)
+		}
+	}
+	if err := validateWebhook(&repository.Webhook{URL: "https://93.184.216.34/hook"}); err != nil {
+		t.Errorf("public address: %v", err)
+	}
+}
+
+func TestWebhookDeliveryRefusesPrivateAddress(t *testing.T) {
+	repo := newTestRepository(t)
+	org := repository.Organization{Name: "Acme"}
+	if err := repo.CreateOrganization(&org); err != nil {
+		t.Fatal(err)
+	}
+
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer server.Close()
+
+	// Saved directly, as if the host had resolved to a public address when
+	// the webhook was created and was later rebound.
+	webhook := &repository.Webhook{OrganizationID: org.ID, URL: server.URL, Secret: "whsec_test", EventTypes: []string{}, Active: true}
+	if err := repo.CreateWebhook(webhook); err != nil {
+		t.Fatal(err)
+	}
+	delivery := &repository.WebhookDelivery{WebhookID: webhook.ID, OrganizationID: org.ID, EventType: "work_order_created", Payload: []byte(`{}`)}
+	if err := repo.CreateWebhookDelivery(delivery); err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewWebhookService(repo)
+	service.deliver(delivery)
+
+	if hits.Load() != 0 {
+		t.Fatalf("the private endpoint was called %d times", hits.Load())
+	}
+	if delivery.Status != "pending" || delivery.Error == nil || !strings.Contains(*delivery.Error, "refusing to connect") {
+		t.Errorf("got status %s and error %v, want a refused connection to be retried", delivery.Status, delivery.Error)
+	}
+}
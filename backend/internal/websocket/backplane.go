@@ -0,0 +1,211 @@
+package websocket
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const dedupWindow = 4096
+
+type Backplane interface {
+	Publish(payload []byte) error
+	Subscribe(handler func(payload []byte)) error
+	Close() error
+}
+
+type envelope struct {
+	ID     string          `json:"id"`
+	Origin string          `json:"origin"`
+	OrgID  uint            `json:"org_id"`
+	UserID uint            `json:"user_id,omitempty"`
+	Roles  []string        `json:"roles,omitempty"`
+	Topics []string        `json:"topics,omitempty"`
+	Seq    uint64          `json:"seq"`
+	Data   json.RawMessage `json:"data"`
+}
+
+type dedup struct {
+	seen  map[string]bool
+	order []string
+	next  int
+	mu    sync.Mutex
+}
+
+func newDedup(size int) *dedup {
+	return &dedup{seen: make(map[string]bool, size), order: make([]string, size)}
+}
+
+func (d *dedup) firstSeen(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.seen[id] {
+		return false
+	}
+	if old := d.order[d.next]; old != "" {
+		delete(d.seen, old)
+	}
+	d.order[d.next] = id
+	d.next = (d.next + 1) % len(d.order)
+	d.seen[id] = true
+	return true
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (h *Hub) startBackplane() {
+	if h.options.Backplane == nil {
+		return
+	}
+
+	go func() {
+		for payload := range h.outbound {
+			if err := h.options.Backplane.Publish(payload); err != nil {
+				log.Printf("Error publishing to backplane: %v", err)
+			}
+		}
+	}()
+
+	err := h.options.Backplane.Subscribe(func(payload []byte) {
+		var env envelope
+		if err := json.Unmarshal(payload, &env); err != nil {
+			log.Printf("Error decoding backplane message: %v", err)
+			return
+		}
+		if env.Origin == h.instanceID || !h.dedup.firstSeen(env.ID) {
+			return
+		}
+		message := BroadcastMessage{
+			OrgID:  env.OrgID,
+			UserID: env.UserID,
+			Roles:  env.Roles,
+			Topics: env.Topics,
+			seq:    env.Seq,
+			data:   env.Data,
+		}
+		select {
+		case h.deliver <- message:
+		case <-h.done:
+		}
+	})
+	if err != nil {
+		log.Printf("Error subscribing to backplane: %v", err)
+	}
+}
+
+func (h *Hub) publish(message BroadcastMessage, seq uint64, data []byte) {
+	if h.options.Backplane == nil {
+		return
+	}
+
+	id := randomID()
+	h.dedup.firstSeen(id)
+	payload, _ := json.Marshal(envelope{
+		ID:     id,
+		Origin: h.instanceID,
+		OrgID:  message.OrgID,
+		UserID: message.UserID,
+		Roles:  message.Roles,
+		Topics: message.Topics,
+		Seq:    seq,
+		Data:   data,
+	})
+
+	select {
+	case h.outbound <- payload:
+	default:
+		log.Printf("Backplane publish queue full; dropping event for org %d", message.OrgID)
+	}
+}
+
+// MemoryBackplane connects hubs in the same process. A hub ignores its own
+// messages, so it is only useful for tests that run several hubs.
+type MemoryBackplane struct {
+	handlers []func(payload []byte)
+	mu       sync.RWMutex
+}
+
+func NewMemoryBackplane() *MemoryBackplane {
+	return &MemoryBackplane{}
+}
+
+func (b *MemoryBackplane) Publish(payload []byte) error {
+	b.mu.RLock()
+	handlers := append([]func(payload []byte){}, b.handlers...)
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(payload)
+	}
+	return nil
+}
+
+func (b *MemoryBackplane) Subscribe(handler func(payload []byte)) error {
+	b.mu.Lock()
+	b.handlers = append(b.handlers, handler)
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *MemoryBackplane) Close() error {
+	return nil
+}
+
+type RedisBackplane struct {
+	client  *redis.Client
+	channel string
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func NewRedisBackplane(addr, password, channel string) (*RedisBackplane, error) {
+	client := redis.NewClient(&redis.Options{Addr: addr, Password: password})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	return &RedisBackplane{client: client, channel: channel, ctx: ctx, cancel: cancel}, nil
+}
+
+func (b *RedisBackplane) Publish(payload []byte) error {
+	ctx, cancel := context.WithTimeout(b.ctx, 5*time.Second)
+	defer cancel()
+	return b.client.Publish(ctx, b.channel, payload).Err()
+}
+
+func (b *RedisBackplane) Subscribe(handler func(payload []byte)) error {
+	pubsub := b.client.Subscribe(b.ctx, b.channel)
+	if _, err := pubsub.Receive(b.ctx); err != nil {
+		pubsub.Close()
+		return err
+	}
+
+	go func() {
+		defer pubsub.Close()
+		for message := range pubsub.Channel() {
+			handler([]byte(message.Payload))
+		}
+	}()
+	return nil
+}
+
+func (b *RedisBackplane) Close() error {
+	b.cancel()
+	return b.client.Close()
+}
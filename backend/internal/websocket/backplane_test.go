@@ -0,0 +1,88 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// testBackplaneFanOut connects a client to each of two hubs sharing
+// backplanes and checks that events reach both exactly once, with the same
+// event ids.
+func testBackplaneFanOut(t *testing.T, a, b *Hub) {
+	t.Helper()
+	_, connA := connect(a, 1, 10, "admin")
+	_, connB := connect(b, 1, 11, "admin")
+	_, other := connect(b, 2, 20, "admin")
+
+	for i, hub := range []*Hub{a, b} {
+		from := string(rune('a' + i))
+		hub.BroadcastToOrg(1, map[string]interface{}{"type": "work_order_created", "from": from})
+		for name, conn := range map[string]*fakeConn{"a": connA, "b": connB} {
+			message := conn.next(t)
+			if message["from"] != from || message["event_id"] != float64(i+1) {
+				t.Errorf("client on hub %s got %v, want the event from hub %s with event_id %d", name, message, from, i+1)
+			}
+		}
+	}
+	connA.expectNone(t)
+	connB.expectNone(t)
+	other.expectNone(t)
+}
+
+// testBackplaneDedup publishes one envelope twice, and one that claims to
+// come from hub b, and checks that b delivers only the first copy.
+func testBackplaneDedup(t *testing.T, backplane Backplane, b *Hub) {
+	t.Helper()
+	_, conn := connect(b, 1, 11, "admin")
+
+	duplicate, _ := json.Marshal(envelope{ID: "event-1", Origin: "elsewhere", OrgID: 1, Seq: 7, Data: json.RawMessage(`{"type":"low_inventory"}`)})
+	own, _ := json.Marshal(envelope{ID: "event-2", Origin: b.instanceID, OrgID: 1, Seq: 8, Data: json.RawMessage(`{"type":"low_inventory"}`)})
+	for _, payload := range [][]byte{duplicate, duplicate, own} {
+		if err := backplane.Publish(payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if message := conn.next(t); message["type"] != "low_inventory" {
+		t.Errorf("got %v, want the low_inventory event", message)
+	}
+	conn.expectNone(t)
+}
+
+func TestMemoryBackplaneFanOut(t *testing.T) {
+	backplane := NewMemoryBackplane()
+	a, _ := startHub(t, HubOptions{Backplane: backplane})
+	b, _ := startHub(t, HubOptions{Backplane: backplane})
+	testBackplaneFanOut(t, a, b)
+}
+
+func TestMemoryBackplaneDedup(t *testing.T) {
+	backplane := NewMemoryBackplane()
+	b, _ := startHub(t, HubOptions{Backplane: backplane})
+	testBackplaneDedup(t, backplane, b)
+}
+
+func newRedisBackplane(t *testing.T, addr string) *RedisBackplane {
+	t.Helper()
+	backplane, err := NewRedisBackplane(addr, "", "assetsentinel:test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { backplane.Close() })
+	return backplane
+}
+
+func TestRedisBackplaneFanOut(t *testing.T) {
+	server := miniredis.RunT(t)
+	a, _ := startHub(t, HubOptions{Backplane: newRedisBackplane(t, server.Addr())})
+	b, _ := startHub(t, HubOptions{Backplane: newRedisBackplane(t, server.Addr())})
+	testBackplaneFanOut(t, a, b)
+}
+
+func TestRedisBackplaneDedup(t *testing.T) {
+	server := miniredis.RunT(t)
+	b, _ := startHub(t, HubOptions{Backplane: newRedisBackplane(t, server.Addr())})
+	testBackplaneDedup(t, newRedisBackplane(t, server.Addr()), b)
+}
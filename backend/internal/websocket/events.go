@@ -0,0 +1,148 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+
+	"assetsentinel/internal/repository"
+)
+
+type EventLog interface {
+	LatestEventSeq(orgID uint) (uint64, error)
+	OldestEventSeq(orgID uint) (uint64, error)
+	AppendEvent(event *repository.Event, retain int) error
+	ListEventsSince(orgID uint, seq uint64, limit int) ([]repository.Event, error)
+}
+
+func (h *Hub) currentSeq(orgID uint) uint64 {
+	if h.events == nil {
+		return h.lastSeq(orgID)
+	}
+	latest, err := h.events.LatestEventSeq(orgID)
+	if err != nil {
+		log.Printf("Error loading event sequence for org %d: %v", orgID, err)
+		return h.lastSeq(orgID)
+	}
+	return latest
+}
+
+func (h *Hub) lastSeq(orgID uint) uint64 {
+	h.seqMu.Lock()
+	defer h.seqMu.Unlock()
+	return h.sequences[orgID]
+}
+
+func (h *Hub) noteSeq(orgID uint, seq uint64) {
+	h.seqMu.Lock()
+	defer h.seqMu.Unlock()
+	if seq > h.sequences[orgID] {
+		h.sequences[orgID] = seq
+	}
+}
+
+// recordLoop records new events one at a time and hands them to Run, so each
+// hub delivers its own events in sequence order.
+func (h *Hub) recordLoop() {
+	defer close(h.outbound)
+
+	for {
+		select {
+		case message := <-h.broadcast:
+			message.seq, message.data = h.record(message)
+			h.publish(message, message.seq, message.data)
+			select {
+			case h.deliver <- message:
+			case <-h.done:
+				return
+			}
+		case <-h.done:
+			return
+		}
+	}
+}
+
+func (h *Hub) record(message BroadcastMessage) (uint64, []byte) {
+	payload, _ := json.Marshal(message.Message)
+
+	var seq uint64
+	if h.events != nil {
+		event := &repository.Event{
+			OrganizationID: message.OrgID,
+			Roles:          message.Roles,
+			Topics:         message.Topics,
+			Payload:        payload,
+		}
+		if message.UserID != 0 {
+			event.UserID = &message.UserID
+		}
+		if err := h.events.AppendEvent(event, h.options.EventRetention); err != nil {
+			log.Printf("Error appending event for org %d: %v", message.OrgID, err)
+		}
+		seq = event.Seq
+	}
+	if seq == 0 {
+		seq = h.lastSeq(message.OrgID) + 1
+	}
+	h.noteSeq(message.OrgID, seq)
+
+	return seq, withEventID(payload, seq)
+}
+
+func withEventID(payload []byte, seq uint64) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return payload
+	}
+	fields["event_id"], _ = json.Marshal(seq)
+	data, _ := json.Marshal(fields)
+	return data
+}
+
+func (h *Hub) replay(client *Client, lastEventID uint64) {
+	if h.events == nil {
+		return
+	}
+
+	latest := h.currentSeq(client.orgID)
+	client.replayedThrough = latest
+	if lastEventID >= latest {
+		if lastEventID > latest {
+			h.requireResync(client, latest)
+		}
+		return
+	}
+
+	oldest, err := h.events.OldestEventSeq(client.orgID)
+	if err != nil || oldest == 0 || oldest > lastEventID+1 {
+		h.requireResync(client, latest)
+		return
+	}
+
+	limit := cap(client.send) - len(client.send) - 1
+	events, err := h.events.ListEventsSince(client.orgID, lastEventID, limit+1)
+	if err != nil || len(events) > limit {
+		h.requireResync(client, latest)
+		return
+	}
+
+	for _, event := range events {
+		message := BroadcastMessage{OrgID: event.OrganizationID, Roles: event.Roles, Topics: event.Topics}
+		if event.UserID != nil {
+			message.UserID = *event.UserID
+		}
+		if message.matches(client) {
+			client.send <- withEventID(event.Payload, event.Seq)
+		}
+	}
+}
+
+func (h *Hub) requireResync(client *Client, latest uint64) {
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":            "resync_required",
+		"latest_event_id": latest,
+	})
+	select {
+	case client.send <- data:
+	default:
+	}
+}
@@ -0,0 +1,134 @@
+package websocket
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"assetsentinel/internal/repository"
+)
+
+// memoryEventLog is an in-memory EventLog. When gate is set, AppendEvent
+// stores the event and then waits on it before returning.
+type memoryEventLog struct {
+	mu       sync.Mutex
+	events   []repository.Event
+	appended chan uint64
+	gate     chan struct{}
+}
+
+func (l *memoryEventLog) LatestEventSeq(orgID uint) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var latest uint64
+	for _, event := range l.events {
+		if event.OrganizationID == orgID {
+			latest = event.Seq
+		}
+	}
+	return latest, nil
+}
+
+func (l *memoryEventLog) OldestEventSeq(orgID uint) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, event := range l.events {
+		if event.OrganizationID == orgID {
+			return event.Seq, nil
+		}
+	}
+	return 0, nil
+}
+
+func (l *memoryEventLog) AppendEvent(event *repository.Event, retain int) error {
+	latest, _ := l.LatestEventSeq(event.OrganizationID)
+	l.mu.Lock()
+	event.Seq = latest + 1
+	l.events = append(l.events, *event)
+	l.mu.Unlock()
+
+	if l.appended != nil {
+		l.appended <- event.Seq
+	}
+	if l.gate != nil {
+		<-l.gate
+	}
+	return nil
+}
+
+func (l *memoryEventLog) ListEventsSince(orgID uint, seq uint64, limit int) ([]repository.Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var events []repository.Event
+	for _, event := range l.events {
+		if event.OrganizationID == orgID && event.Seq > seq && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func TestHubDeliversEventsInSequenceOrder(t *testing.T) {
+	hub, _ := startHubWithLog(t, &memoryEventLog{}, HubOptions{})
+	_, conn := connect(hub, 1, 10, "admin")
+
+	const count = 100
+	for i := 1; i <= count; i++ {
+		hub.BroadcastToOrg(1, map[string]interface{}{"type": "work_order_created", "n": i})
+	}
+	for i := 1; i <= count; i++ {
+		if message := conn.next(t); message["n"] != float64(i) || message["event_id"] != float64(i) {
+			t.Fatalf("message %d = %v, want n and event_id %d", i, message, i)
+		}
+	}
+}
+
+func TestSlowEventLogDoesNotBlockDelivery(t *testing.T) {
+	log := &memoryEventLog{appended: make(chan uint64, 1), gate: make(chan struct{})}
+	backplane := NewMemoryBackplane()
+	hub, _ := startHubWithLog(t, log, HubOptions{Backplane: backplane})
+	_, conn := connect(hub, 1, 10, "admin")
+
+	hub.BroadcastToOrg(1, map[string]interface{}{"type": "work_order_created"})
+	<-log.appended
+
+	remote, _ := json.Marshal(envelope{ID: "remote-1", Origin: "elsewhere", OrgID: 1, Seq: 7, Data: json.RawMessage(`{"type":"low_inventory","event_id":7}`)})
+	if err := backplane.Publish(remote); err != nil {
+		t.Fatal(err)
+	}
+	if message := conn.next(t); message["type"] != "low_inventory" {
+		t.Fatalf("got %v while the event log was blocked, want the remote event", message)
+	}
+	_, late := connect(hub, 1, 11, "admin")
+	late.reads <- []byte(`{"action": "subscribe", "topics": ["asset:7"]}`)
+	if reply := late.next(t); reply["type"] != "subscribed" {
+		t.Fatalf("got %v while the event log was blocked, want a subscription reply", reply)
+	}
+
+	close(log.gate)
+	if message := conn.next(t); message["type"] != "work_order_created" || message["event_id"] != float64(1) {
+		t.Errorf("got %v, want the recorded event once the log caught up", message)
+	}
+}
+
+func TestReplayAndDeliveryDoNotDuplicate(t *testing.T) {
+	log := &memoryEventLog{appended: make(chan uint64, 1), gate: make(chan struct{})}
+	hub, _ := startHubWithLog(t, log, HubOptions{})
+
+	// The event is in the log but not yet delivered when the client resumes,
+	// so replay sends it and the live delivery must not.
+	hub.BroadcastToOrg(1, map[string]interface{}{"type": "work_order_created"})
+	<-log.appended
+	lastSeen := uint64(0)
+	_, conn := resume(hub, 1, 10, "admin", &lastSeen)
+	if message := conn.next(t); message["event_id"] != float64(1) {
+		t.Fatalf("got %v, want event 1 replayed", message)
+	}
+
+	close(log.gate)
+	hub.BroadcastToOrg(1, map[string]interface{}{"type": "low_inventory"})
+	if message := conn.next(t); message["type"] != "low_inventory" || message["event_id"] != float64(2) {
+		t.Errorf("got %v, want event 2 and no second copy of event 1", message)
+	}
+	conn.expectNone(t)
+}
@@ -1,14 +1,34 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"sort"
+	"strconv"
 	"sync"
+	"time"
+
+	"assetsentinel/internal/events"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
 
+const (
+	SlowConsumerCoalesce   = "coalesce"
+	SlowConsumerDisconnect = "disconnect"
+
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = (pongWait * 9) / 10
+	maxMessageSize = 4096
+	coalesceWindow = 30 * time.Second
+)
+
+var ErrHubClosed = errors.New("real-time hub is shut down")
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -20,6 +40,7 @@ var upgrader = websocket.Upgrader{
 func (h *Hub) HandleWebSocket(c *gin.Context) {
 	orgID := uint(c.GetFloat64("organization_id"))
 	userID := uint(c.GetFloat64("user_id"))
+	role := c.GetString("role")
 
 	if orgID == 0 {
 		c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -31,38 +52,125 @@ func (h *Hub) HandleWebSocket(c *gin.Context) {
 		return
 	}
 
-	client := NewClient(h, conn, orgID, userID)
+	client := NewClient(h, conn, orgID, userID, role)
+	if lastEventID, err := strconv.ParseUint(c.Query("last_event_id"), 10, 64); err == nil {
+		client.lastEventID = &lastEventID
+	}
 	h.Register(client)
 
 	go client.WritePump()
 	go client.ReadPump()
 }
 
+type TopicPolicy interface {
+	AuthorizeTopic(orgID, userID uint, role, topic string) error
+	MessageTopics(orgID uint, message map[string]interface{}) []string
+}
+
 type Hub struct {
-	clients    map[uint]map[*Client]bool
-	broadcast  chan BroadcastMessage
-	register   chan *Client
-	unregister chan *Client
-	mu         sync.RWMutex
+	clients       map[uint]map[*Client]bool
+	broadcast     chan BroadcastMessage
+	deliver       chan BroadcastMessage
+	register      chan *Client
+	unregister    chan *Client
+	subscriptions chan subscriptionChange
+	topics        TopicPolicy
+	events        EventLog
+	options       HubOptions
+	sequences     map[uint]uint64
+	seqMu         sync.Mutex
+	metrics       hubMetrics
+	instanceID    string
+	outbound      chan []byte
+	dedup         *dedup
+	done          chan struct{}
+	mu            sync.RWMutex
+}
+
+type HubOptions struct {
+	EventRetention     int
+	SlowConsumerPolicy string
+	Backplane          Backplane
 }
 
 type BroadcastMessage struct {
 	OrgID   uint
+	UserID  uint
+	Roles   []string
+	Topics  []string
 	Message map[string]interface{}
+
+	seq  uint64
+	data []byte
+}
+
+type subscriptionChange struct {
+	client    *Client
+	topics    []string
+	rejected  map[string]string
+	subscribe bool
+	err       string
 }
 
-func NewHub() *Hub {
+func (m BroadcastMessage) matches(client *Client) bool {
+	if m.UserID != 0 {
+		return client.userID == m.UserID
+	}
+	if len(m.Roles) > 0 && !containsString(m.Roles, client.role) {
+		return false
+	}
+	if len(client.subscriptions) == 0 {
+		return true
+	}
+	for _, topic := range m.Topics {
+		if client.subscriptions[topic] {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func NewHub(topics TopicPolicy, events EventLog, options HubOptions) *Hub {
 	return &Hub{
-		clients:    make(map[uint]map[*Client]bool),
-		broadcast:  make(chan BroadcastMessage, 256),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
+		clients:       make(map[uint]map[*Client]bool),
+		broadcast:     make(chan BroadcastMessage, 256),
+		deliver:       make(chan BroadcastMessage, 256),
+		register:      make(chan *Client),
+		unregister:    make(chan *Client),
+		subscriptions: make(chan subscriptionChange),
+		topics:        topics,
+		events:        events,
+		options:       options,
+		sequences:     make(map[uint]uint64),
+		instanceID:    randomID(),
+		outbound:      make(chan []byte, 256),
+		dedup:         newDedup(dedupWindow),
+		done:          make(chan struct{}),
 	}
 }
 
-func (h *Hub) Run() {
+// Run owns the client registry. New events are recorded by a separate
+// goroutine, so a slow event log delays them without blocking delivery of
+// recorded and remote events, registration or subscriptions.
+func (h *Hub) Run(ctx context.Context) {
+	h.startBackplane()
+	go h.recordLoop()
+
 	for {
 		select {
+		case <-ctx.Done():
+			h.shutdown()
+			return
+
 		case client := <-h.register:
 			h.mu.Lock()
 			if h.clients[client.orgID] == nil {
@@ -70,60 +178,210 @@ func (h *Hub) Run() {
 			}
 			h.clients[client.orgID][client] = true
 			h.mu.Unlock()
+			h.metrics.connections.Add(1)
+
+			if client.lastEventID != nil {
+				h.replay(client, *client.lastEventID)
+			}
 
 		case client := <-h.unregister:
-			h.mu.Lock()
-			if clients, ok := h.clients[client.orgID]; ok {
-				if _, ok := clients[client]; ok {
-					delete(clients, client)
-					close(client.send)
-					if len(clients) == 0 {
-						delete(h.clients, client.orgID)
+			h.removeClient(client)
+
+		case change := <-h.subscriptions:
+			h.mu.RLock()
+			_, registered := h.clients[change.client.orgID][change.client]
+			h.mu.RUnlock()
+			if !registered {
+				continue
+			}
+
+			var reply map[string]interface{}
+			if change.err != "" {
+				reply = map[string]interface{}{"type": "error", "error": change.err}
+			} else {
+				for _, topic := range change.topics {
+					if change.subscribe {
+						change.client.subscriptions[topic] = true
+					} else {
+						delete(change.client.subscriptions, topic)
 					}
 				}
+				reply = map[string]interface{}{
+					"type":          "subscribed",
+					"topics":        change.topics,
+					"subscriptions": change.client.subscriptionList(),
+				}
+				if !change.subscribe {
+					reply["type"] = "unsubscribed"
+				}
+				if len(change.rejected) > 0 {
+					reply["rejected"] = change.rejected
+				}
+			}
+			data, _ := json.Marshal(reply)
+			select {
+			case change.client.send <- data:
+			default:
 			}
-			h.mu.Unlock()
 
-		case message := <-h.broadcast:
+		case message := <-h.deliver:
 			h.mu.RLock()
 			clients := h.clients[message.OrgID]
 			h.mu.RUnlock()
+			h.noteSeq(message.OrgID, message.seq)
 
-			data, _ := json.Marshal(message.Message)
+			var slow []*Client
 			for client := range clients {
+				if !message.matches(client) || message.seq <= client.replayedThrough {
+					continue
+				}
 				select {
-				case client.send <- data:
+				case client.send <- message.data:
 				default:
-					close(client.send)
-					delete(clients, client)
+					if !h.coalesce(client, message.OrgID) {
+						slow = append(slow, client)
+					}
 				}
 			}
+			for _, client := range slow {
+				h.metrics.slowConsumerDisconnects.Add(1)
+				h.removeClient(client)
+			}
 		}
 	}
 }
 
+func (h *Hub) shutdown() {
+	close(h.done)
+
+	h.mu.RLock()
+	var clients []*Client
+	for _, orgClients := range h.clients {
+		for client := range orgClients {
+			clients = append(clients, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		h.removeClient(client)
+	}
+
+	timeout := time.After(writeWait)
+	for _, client := range clients {
+		select {
+		case <-client.closed:
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func (h *Hub) closed() bool {
+	select {
+	case <-h.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (h *Hub) send(message BroadcastMessage) bool {
+	select {
+	case h.broadcast <- message:
+		return true
+	case <-h.done:
+		return false
+	}
+}
+
+func (h *Hub) removeClient(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	clients, ok := h.clients[client.orgID]
+	if !ok {
+		return
+	}
+	if _, ok := clients[client]; !ok {
+		return
+	}
+	delete(clients, client)
+	close(client.send)
+	if len(clients) == 0 {
+		delete(h.clients, client.orgID)
+	}
+}
+
+func (h *Hub) coalesce(client *Client, orgID uint) bool {
+	if h.options.SlowConsumerPolicy != SlowConsumerCoalesce {
+		return false
+	}
+	now := time.Now()
+	if !client.lastCoalesced.IsZero() && now.Sub(client.lastCoalesced) < coalesceWindow {
+		return false
+	}
+	client.lastCoalesced = now
+
+	dropped := 0
+	for len(client.send) > 0 {
+		select {
+		case <-client.send:
+			dropped++
+		default:
+		}
+	}
+	h.metrics.messagesCoalesced.Add(int64(dropped + 1))
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":            "resync_required",
+		"latest_event_id": h.currentSeq(orgID),
+		"dropped":         dropped + 1,
+	})
+	client.send <- data
+	return true
+}
+
 func (h *Hub) Register(client *Client) {
-	h.register <- client
+	select {
+	case h.register <- client:
+	case <-h.done:
+		close(client.send)
+	}
 }
 
 func (h *Hub) Unregister(client *Client) {
-	h.unregister <- client
+	select {
+	case h.unregister <- client:
+	case <-h.done:
+	}
 }
 
 func (h *Hub) BroadcastToOrg(orgID uint, message map[string]interface{}) {
-	h.broadcast <- BroadcastMessage{OrgID: orgID, Message: message}
+	h.send(BroadcastMessage{OrgID: orgID, Topics: h.messageTopics(orgID, message), Message: message})
 }
 
-type Client struct {
-	hub  *Hub
-	conn interface {
-		ReadMessage() (messageType int, p []byte, err error)
-		WriteMessage(messageType int, p []byte) error
-		Close() error
+func (h *Hub) Consume(event *events.Envelope) error {
+	message := event.Message()
+	if !h.send(BroadcastMessage{OrgID: event.OrganizationID, Topics: h.messageTopics(event.OrganizationID, message), Message: message}) {
+		return ErrHubClosed
 	}
-	send   chan []byte
-	orgID  uint
-	userID uint
+	return nil
+}
+
+func (h *Hub) SendToUser(orgID, userID uint, message map[string]interface{}) {
+	h.send(BroadcastMessage{OrgID: orgID, UserID: userID, Message: message})
+}
+
+func (h *Hub) SendToRoles(orgID uint, roles []string, message map[string]interface{}) {
+	h.send(BroadcastMessage{OrgID: orgID, Roles: roles, Topics: h.messageTopics(orgID, message), Message: message})
+}
+
+func (h *Hub) messageTopics(orgID uint, message map[string]interface{}) []string {
+	if h.topics == nil {
+		return nil
+	}
+	return h.topics.MessageTopics(orgID, message)
 }
 
 type ClientConn interface {
@@ -132,13 +390,41 @@ type ClientConn interface {
 	Close() error
 }
 
-func NewClient(hub *Hub, conn ClientConn, orgID, userID uint) *Client {
+type Client struct {
+	hub           *Hub
+	conn          ClientConn
+	send          chan []byte
+	closed        chan struct{}
+	orgID         uint
+	userID        uint
+	role          string
+	subscriptions map[string]bool
+	lastEventID   *uint64
+	lastCoalesced time.Time
+	pingPeriod    time.Duration
+
+	// replayedThrough is the latest sequence when the client was registered.
+	// Replay covers the events up to it, so they are not delivered again.
+	replayedThrough uint64
+}
+
+type clientFrame struct {
+	Action string   `json:"action"`
+	Topics []string `json:"topics"`
+}
+
+func NewClient(hub *Hub, conn ClientConn, orgID, userID uint, role string) *Client {
 	return &Client{
 		hub:    hub,
 		conn:   conn,
 		send:   make(chan []byte, 256),
+		closed: make(chan struct{}),
 		orgID:  orgID,
 		userID: userID,
+		role:   role,
+
+		subscriptions: make(map[string]bool),
+		pingPeriod:    pingPeriod,
 	}
 }
 
@@ -147,22 +433,116 @@ func (c *Client) ReadPump() {
 		c.hub.Unregister(c)
 		c.conn.Close()
 	}()
+
+	if conn, ok := c.conn.(interface {
+		SetReadLimit(limit int64)
+		SetReadDeadline(t time.Time) error
+		SetPongHandler(h func(appData string) error)
+	}); ok {
+		conn.SetReadLimit(maxMessageSize)
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(pongWait))
+		})
+	}
+
 	for {
-		_, _, err := c.conn.ReadMessage()
+		_, data, err := c.conn.ReadMessage()
 		if err != nil {
 			break
 		}
+		c.handleFrame(data)
 	}
 }
 
+func (c *Client) handleFrame(data []byte) {
+	var frame clientFrame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		c.request(subscriptionChange{client: c, err: "invalid frame"})
+		return
+	}
+
+	switch frame.Action {
+	case "subscribe":
+		change := subscriptionChange{client: c, subscribe: true, rejected: make(map[string]string)}
+		for _, topic := range frame.Topics {
+			if err := c.authorize(topic); err != nil {
+				change.rejected[topic] = err.Error()
+				continue
+			}
+			change.topics = append(change.topics, topic)
+		}
+		c.request(change)
+	case "unsubscribe":
+		c.request(subscriptionChange{client: c, topics: frame.Topics})
+	default:
+		c.request(subscriptionChange{client: c, err: "unknown action"})
+	}
+}
+
+func (c *Client) request(change subscriptionChange) {
+	select {
+	case c.hub.subscriptions <- change:
+	case <-c.hub.done:
+	}
+}
+
+func (c *Client) authorize(topic string) error {
+	if c.hub.topics == nil {
+		return errors.New("subscriptions are not supported")
+	}
+	return c.hub.topics.AuthorizeTopic(c.orgID, c.userID, c.role, topic)
+}
+
+func (c *Client) subscriptionList() []string {
+	topics := make([]string, 0, len(c.subscriptions))
+	for topic := range c.subscriptions {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
 func (c *Client) WritePump() {
-	defer c.conn.Close()
+	ticker := time.NewTicker(c.pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+		close(c.closed)
+	}()
+
 	for {
-		message, ok := <-c.send
-		if !ok {
-			c.conn.WriteMessage(1, []byte{})
-			break
+		select {
+		case message, ok := <-c.send:
+			c.setWriteDeadline()
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, c.closeMessage())
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+			c.hub.metrics.messagesSent.Add(1)
+		case <-ticker.C:
+			c.setWriteDeadline()
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
 		}
-		c.conn.WriteMessage(1, message)
+	}
+}
+
+func (c *Client) closeMessage() []byte {
+	if c.hub.closed() {
+		return websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	}
+	return []byte{}
+}
+
+func (c *Client) setWriteDeadline() {
+	if conn, ok := c.conn.(interface {
+		SetWriteDeadline(t time.Time) error
+	}); ok {
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
 	}
 }
@@ -0,0 +1,433 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeConn is an in-memory ClientConn. Frames sent on reads are returned by
+// ReadMessage; written frames are collected on writes.
+type fakeConn struct {
+	reads     chan []byte
+	writes    chan fakeFrame
+	blocked   chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	mu           sync.Mutex
+	readLimit    int64
+	readDeadline time.Time
+	pong         func(appData string) error
+}
+
+type fakeFrame struct {
+	messageType int
+	data        []byte
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{
+		reads:  make(chan []byte),
+		writes: make(chan fakeFrame, 1024),
+		closed: make(chan struct{}),
+	}
+}
+
+func (c *fakeConn) ReadMessage() (int, []byte, error) {
+	select {
+	case data := <-c.reads:
+		return websocket.TextMessage, data, nil
+	case <-c.closed:
+		return 0, nil, errors.New("connection closed")
+	}
+}
+
+func (c *fakeConn) WriteMessage(messageType int, data []byte) error {
+	if c.blocked != nil {
+		select {
+		case <-c.blocked:
+		case <-c.closed:
+		}
+	}
+	select {
+	case <-c.closed:
+		return errors.New("connection closed")
+	default:
+	}
+	c.writes <- fakeFrame{messageType: messageType, data: data}
+	return nil
+}
+
+func (c *fakeConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return nil
+}
+
+func (c *fakeConn) SetReadLimit(limit int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readLimit = limit
+}
+
+func (c *fakeConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	return nil
+}
+
+func (c *fakeConn) SetPongHandler(h func(appData string) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pong = h
+}
+
+func (c *fakeConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// next returns the next text frame written to the connection, skipping pings.
+func (c *fakeConn) next(t *testing.T) map[string]interface{} {
+	t.Helper()
+	for {
+		select {
+		case frame := <-c.writes:
+			if frame.messageType != websocket.TextMessage {
+				continue
+			}
+			var message map[string]interface{}
+			if err := json.Unmarshal(frame.data, &message); err != nil {
+				t.Fatalf("invalid frame %q: %v", frame.data, err)
+			}
+			return message
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a frame")
+			return nil
+		}
+	}
+}
+
+func (c *fakeConn) expectNone(t *testing.T) {
+	t.Helper()
+	select {
+	case frame := <-c.writes:
+		if frame.messageType == websocket.TextMessage {
+			t.Errorf("unexpected frame %s", frame.data)
+		}
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+type allowTopics struct{}
+
+func (allowTopics) AuthorizeTopic(orgID, userID uint, role, topic string) error {
+	if strings.HasPrefix(topic, "asset:") {
+		return nil
+	}
+	return errors.New("unknown topic")
+}
+
+func (allowTopics) MessageTopics(orgID uint, message map[string]interface{}) []string {
+	if id, ok := message["asset_id"]; ok {
+		return []string{fmt.Sprintf("asset:%v", id)}
+	}
+	return nil
+}
+
+func startHub(t *testing.T, options HubOptions) (*Hub, context.CancelFunc) {
+	t.Helper()
+	return startHubWithLog(t, nil, options)
+}
+
+func startHubWithLog(t *testing.T, log EventLog, options HubOptions) (*Hub, context.CancelFunc) {
+	t.Helper()
+	hub := NewHub(allowTopics{}, log, options)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+	return hub, cancel
+}
+
+func connect(hub *Hub, orgID, userID uint, role string) (*Client, *fakeConn) {
+	return resume(hub, orgID, userID, role, nil)
+}
+
+// resume connects a client that last saw lastEventID, if it is set.
+func resume(hub *Hub, orgID, userID uint, role string, lastEventID *uint64) (*Client, *fakeConn) {
+	conn := newFakeConn()
+	client := NewClient(hub, conn, orgID, userID, role)
+	client.lastEventID = lastEventID
+	hub.Register(client)
+	go client.WritePump()
+	go client.ReadPump()
+	return client, conn
+}
+
+func eventually(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func waitClosed(t *testing.T, client *Client) {
+	t.Helper()
+	select {
+	case <-client.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("client was not closed")
+	}
+}
+
+func TestHubDeliversToOrganizationAndUser(t *testing.T) {
+	hub, _ := startHub(t, HubOptions{})
+	_, acmeAdmin := connect(hub, 1, 10, "admin")
+	_, acmeTech := connect(hub, 1, 11, "technician")
+	_, globex := connect(hub, 2, 20, "admin")
+
+	hub.BroadcastToOrg(1, map[string]interface{}{"type": "work_order_created"})
+	for _, conn := range []*fakeConn{acmeAdmin, acmeTech} {
+		if message := conn.next(t); message["type"] != "work_order_created" || message["event_id"] != float64(1) {
+			t.Errorf("got %v, want work_order_created with event_id 1", message)
+		}
+	}
+
+	hub.SendToUser(1, 11, map[string]interface{}{"type": "notification"})
+	if message := acmeTech.next(t); message["type"] != "notification" {
+		t.Errorf("got %v, want the notification", message)
+	}
+
+	hub.SendToRoles(1, []string{"admin"}, map[string]interface{}{"type": "low_inventory"})
+	if message := acmeAdmin.next(t); message["type"] != "low_inventory" {
+		t.Errorf("got %v, want low_inventory", message)
+	}
+
+	acmeAdmin.expectNone(t)
+	acmeTech.expectNone(t)
+	globex.expectNone(t)
+}
+
+func TestHubSubscriptionsFilterMessages(t *testing.T) {
+	hub, _ := startHub(t, HubOptions{})
+	_, conn := connect(hub, 1, 10, "admin")
+
+	conn.reads <- []byte(`{"action": "subscribe", "topics": ["asset:7", "secret:1"]}`)
+	reply := conn.next(t)
+	if reply["type"] != "subscribed" || fmt.Sprint(reply["subscriptions"]) != "[asset:7]" {
+		t.Fatalf("got %v, want a subscription to asset:7", reply)
+	}
+	if rejected, _ := reply["rejected"].(map[string]interface{}); rejected["secret:1"] == nil {
+		t.Errorf("got %v, want secret:1 rejected", reply)
+	}
+
+	hub.BroadcastToOrg(1, map[string]interface{}{"type": "work_order_created", "asset_id": 8})
+	hub.BroadcastToOrg(1, map[string]interface{}{"type": "work_order_created", "asset_id": 7})
+	if message := conn.next(t); message["asset_id"] != float64(7) {
+		t.Errorf("got %v, want only the asset 7 message", message)
+	}
+
+	conn.reads <- []byte(`not json`)
+	if reply := conn.next(t); reply["type"] != "error" {
+		t.Errorf("got %v, want an error frame", reply)
+	}
+}
+
+func TestHubConcurrentRegisterUnregisterBroadcast(t *testing.T) {
+	hub, _ := startHub(t, HubOptions{SlowConsumerPolicy: SlowConsumerCoalesce})
+
+	const clients = 50
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			client, conn := connect(hub, uint(i%3+1), uint(i+1), "admin")
+			time.Sleep(time.Duration(i%5) * time.Millisecond)
+			conn.Close()
+			select {
+			case <-client.closed:
+			case <-time.After(5 * time.Second):
+				t.Errorf("client %d was not closed", i)
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				hub.BroadcastToOrg(uint(i%3+1), map[string]interface{}{"type": "work_order_created", "n": j})
+				hub.SendToUser(uint(i%3+1), uint(i+1), map[string]interface{}{"type": "notification"})
+				_ = hub.Metrics()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	eventually(t, "every client to be unregistered", func() bool { return hub.Metrics().ConnectedClients == 0 })
+	if total := hub.Metrics().TotalConnections; total != clients {
+		t.Errorf("counted %d connections, want %d", total, clients)
+	}
+}
+
+func TestHubDisconnectsSlowConsumer(t *testing.T) {
+	hub, _ := startHub(t, HubOptions{SlowConsumerPolicy: SlowConsumerDisconnect})
+	conn := newFakeConn()
+	conn.blocked = make(chan struct{})
+	client := NewClient(hub, conn, 1, 10, "admin")
+	client.send = make(chan []byte, 4)
+	hub.Register(client)
+	go client.WritePump()
+	_, fast := connect(hub, 1, 11, "admin")
+
+	// The write pump holds one message while blocked, so the buffer
+	// overflows on the sixth.
+	for i := 0; i < cap(client.send)+2; i++ {
+		hub.BroadcastToOrg(1, map[string]interface{}{"type": "work_order_created", "n": i})
+	}
+
+	eventually(t, "the slow consumer to be disconnected", func() bool { return hub.Metrics().SlowConsumerDisconnects == 1 })
+	if metrics := hub.Metrics(); metrics.ConnectedClients != 1 {
+		t.Errorf("%d clients connected, want only the fast one", metrics.ConnectedClients)
+	}
+	close(conn.blocked)
+	waitClosed(t, client)
+
+	for i := 0; i < cap(client.send)+2; i++ {
+		if message := fast.next(t); message["n"] != float64(i) {
+			t.Fatalf("fast client got %v, want message %d", message, i)
+		}
+	}
+}
+
+func TestHubCoalescesSlowConsumer(t *testing.T) {
+	hub, _ := startHub(t, HubOptions{SlowConsumerPolicy: SlowConsumerCoalesce})
+	conn := newFakeConn()
+	client := NewClient(hub, conn, 1, 10, "admin")
+	hub.Register(client)
+
+	// Without a write pump nothing drains the send buffer. The first
+	// overflow replaces the backlog with a resync frame.
+	buffer := cap(client.send)
+	for i := 0; i < buffer+1; i++ {
+		hub.BroadcastToOrg(1, map[string]interface{}{"type": "work_order_created", "n": i})
+	}
+	eventually(t, "the backlog to be coalesced", func() bool { return hub.Metrics().MessagesCoalesced > 0 })
+
+	var resync map[string]interface{}
+	json.Unmarshal(<-client.send, &resync)
+	if resync["type"] != "resync_required" || resync["dropped"] != float64(buffer+1) || resync["latest_event_id"] != float64(buffer+1) {
+		t.Fatalf("got %v, want a resync after %d dropped messages", resync, buffer+1)
+	}
+	if metrics := hub.Metrics(); metrics.ConnectedClients != 1 || metrics.SlowConsumerDisconnects != 0 {
+		t.Fatalf("got %+v, want the client kept", metrics)
+	}
+
+	// A second overflow within the coalesce window disconnects the client.
+	for i := 0; i < buffer+1; i++ {
+		hub.BroadcastToOrg(1, map[string]interface{}{"type": "work_order_created"})
+	}
+	eventually(t, "the slow consumer to be disconnected", func() bool { return hub.Metrics().SlowConsumerDisconnects == 1 })
+}
+
+func TestClientPingAndPong(t *testing.T) {
+	hub, _ := startHub(t, HubOptions{})
+	conn := newFakeConn()
+	client := NewClient(hub, conn, 1, 10, "admin")
+	client.pingPeriod = 10 * time.Millisecond
+	hub.Register(client)
+	go client.WritePump()
+	go client.ReadPump()
+
+	select {
+	case frame := <-conn.writes:
+		if frame.messageType != websocket.PingMessage {
+			t.Fatalf("got frame type %d, want a ping", frame.messageType)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no ping was sent")
+	}
+
+	var pong func(string) error
+	eventually(t, "the pong handler", func() bool {
+		conn.mu.Lock()
+		defer conn.mu.Unlock()
+		pong = conn.pong
+		return pong != nil
+	})
+	conn.mu.Lock()
+	conn.readDeadline = time.Time{}
+	limit := conn.readLimit
+	conn.mu.Unlock()
+	if limit != maxMessageSize {
+		t.Errorf("read limit %d, want %d", limit, maxMessageSize)
+	}
+
+	if err := pong(""); err != nil {
+		t.Fatal(err)
+	}
+	conn.mu.Lock()
+	deadline := conn.readDeadline
+	conn.mu.Unlock()
+	if time.Until(deadline) < pongWait-time.Second {
+		t.Errorf("pong moved the read deadline to %s, want about %s ahead", deadline, pongWait)
+	}
+}
+
+func TestClientDisconnectUnregisters(t *testing.T) {
+	hub, _ := startHub(t, HubOptions{})
+	client, conn := connect(hub, 1, 10, "admin")
+	eventually(t, "the client to register", func() bool { return hub.Metrics().ConnectedClients == 1 })
+
+	conn.Close()
+	waitClosed(t, client)
+	eventually(t, "the client to unregister", func() bool { return hub.Metrics().ConnectedClients == 0 })
+
+	hub.BroadcastToOrg(1, map[string]interface{}{"type": "work_order_created"})
+	conn.expectNone(t)
+}
+
+func TestHubShutdownSendsGoingAway(t *testing.T) {
+	hub, cancel := startHub(t, HubOptions{})
+	client, conn := connect(hub, 1, 10, "admin")
+	eventually(t, "the client to register", func() bool { return hub.Metrics().ConnectedClients == 1 })
+
+	cancel()
+	waitClosed(t, client)
+
+	var closeFrame *fakeFrame
+	for len(conn.writes) > 0 {
+		frame := <-conn.writes
+		if frame.messageType == websocket.CloseMessage {
+			closeFrame = &frame
+		}
+	}
+	if closeFrame == nil {
+		t.Fatal("no close frame was sent")
+	}
+	if want := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"); string(closeFrame.data) != string(want) {
+		t.Errorf("close frame %q, want %q", closeFrame.data, want)
+	}
+
+	late := NewClient(hub, newFakeConn(), 1, 11, "admin")
+	hub.Register(late)
+	if _, ok := <-late.send; ok {
+		t.Error("a client registered after shutdown was not closed")
+	}
+}
@@ -0,0 +1,41 @@
+package websocket
+
+import "sync/atomic"
+
+type hubMetrics struct {
+	connections             atomic.Int64
+	messagesSent            atomic.Int64
+	messagesCoalesced       atomic.Int64
+	slowConsumerDisconnects atomic.Int64
+}
+
+type Metrics struct {
+	ConnectedClients        int          `json:"connected_clients"`
+	ClientsByOrganization   map[uint]int `json:"clients_by_organization"`
+	TotalConnections        int64        `json:"total_connections"`
+	MessagesSent            int64        `json:"messages_sent"`
+	MessagesCoalesced       int64        `json:"messages_coalesced"`
+	SlowConsumerDisconnects int64        `json:"slow_consumer_disconnects"`
+	SlowConsumerPolicy      string       `json:"slow_consumer_policy"`
+}
+
+func (h *Hub) Metrics() Metrics {
+	h.mu.RLock()
+	byOrg := make(map[uint]int, len(h.clients))
+	total := 0
+	for orgID, clients := range h.clients {
+		byOrg[orgID] = len(clients)
+		total += len(clients)
+	}
+	h.mu.RUnlock()
+
+	return Metrics{
+		ConnectedClients:        total,
+		ClientsByOrganization:   byOrg,
+		TotalConnections:        h.metrics.connections.Load(),
+		MessagesSent:            h.metrics.messagesSent.Load(),
+		MessagesCoalesced:       h.metrics.messagesCoalesced.Load(),
+		SlowConsumerDisconnects: h.metrics.slowConsumerDisconnects.Load(),
+		SlowConsumerPolicy:      h.options.SlowConsumerPolicy,
+	}
+}
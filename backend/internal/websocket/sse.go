@@ -0,0 +1,145 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const sseHeartbeatInterval = 15 * time.Second
+
+var errStreamClosed = errors.New("event stream closed")
+
+type sseConn struct {
+	writer  gin.ResponseWriter
+	request <-chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	mu      sync.Mutex
+}
+
+func newSSEConn(writer gin.ResponseWriter, request <-chan struct{}) *sseConn {
+	return &sseConn{writer: writer, request: request, done: make(chan struct{})}
+}
+
+func (s *sseConn) ReadMessage() (int, []byte, error) {
+	select {
+	case <-s.request:
+	case <-s.done:
+	}
+	return 0, nil, io.EOF
+}
+
+func (s *sseConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != websocket.TextMessage || len(data) == 0 {
+		return nil
+	}
+
+	var event struct {
+		EventID uint64 `json:"event_id"`
+	}
+	json.Unmarshal(data, &event)
+
+	var frame strings.Builder
+	if event.EventID > 0 {
+		fmt.Fprintf(&frame, "id: %d\n", event.EventID)
+	}
+	fmt.Fprintf(&frame, "data: %s\n\n", data)
+	return s.write(frame.String())
+}
+
+func (s *sseConn) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return nil
+}
+
+func (s *sseConn) write(frame string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return errStreamClosed
+	default:
+	}
+	if _, err := io.WriteString(s.writer, frame); err != nil {
+		return err
+	}
+	s.writer.Flush()
+	return nil
+}
+
+func (s *sseConn) heartbeat(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.write(": heartbeat\n\n"); err != nil {
+				return
+			}
+		case <-s.done:
+			return
+		case <-s.request:
+			return
+		}
+	}
+}
+
+func (h *Hub) HandleSSE(c *gin.Context) {
+	orgID := uint(c.GetFloat64("organization_id"))
+	userID := uint(c.GetFloat64("user_id"))
+	role := c.GetString("role")
+
+	if orgID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	conn := newSSEConn(c.Writer, c.Request.Context().Done())
+	client := NewClient(h, conn, orgID, userID, role)
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	if id, err := strconv.ParseUint(lastEventID, 10, 64); err == nil {
+		client.lastEventID = &id
+	}
+
+	for _, topic := range strings.Split(c.Query("topics"), ",") {
+		if topic = strings.TrimSpace(topic); topic == "" {
+			continue
+		}
+		if err := client.authorize(topic); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "topic": topic})
+			return
+		}
+		client.subscriptions[topic] = true
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	if err := conn.write("retry: 5000\n\n"); err != nil {
+		return
+	}
+
+	h.Register(client)
+
+	go client.ReadPump()
+	go conn.heartbeat(sseHeartbeatInterval)
+	client.WritePump()
+}
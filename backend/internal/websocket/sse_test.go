@@ -0,0 +1,139 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newSSEServer(t *testing.T, hub *Hub) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/events", func(c *gin.Context) {
+		c.Set("organization_id", float64(1))
+		c.Set("user_id", float64(10))
+		c.Set("role", "admin")
+		hub.HandleSSE(c)
+	})
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return server
+}
+
+type sseStream struct {
+	response *http.Response
+	lines    chan string
+	cancel   context.CancelFunc
+}
+
+func openStream(t *testing.T, url string, header http.Header) *sseStream {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	stream := &sseStream{response: response, lines: make(chan string, 64), cancel: cancel}
+	t.Cleanup(func() {
+		cancel()
+		response.Body.Close()
+	})
+	go func() {
+		defer close(stream.lines)
+		scanner := bufio.NewScanner(response.Body)
+		for scanner.Scan() {
+			stream.lines <- scanner.Text()
+		}
+	}()
+	return stream
+}
+
+// frame returns the next event frame, with its fields joined by newlines.
+func (s *sseStream) frame(t *testing.T) string {
+	t.Helper()
+	var fields []string
+	for {
+		select {
+		case line, ok := <-s.lines:
+			if !ok {
+				t.Fatal("stream ended")
+			}
+			if line == "" {
+				if len(fields) > 0 {
+					return strings.Join(fields, "\n")
+				}
+				continue
+			}
+			fields = append(fields, line)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for an event")
+		}
+	}
+}
+
+func TestSSEStreamsEvents(t *testing.T) {
+	hub, _ := startHub(t, HubOptions{})
+	server := newSSEServer(t, hub)
+	stream := openStream(t, server.URL+"/events?topics=asset:7", nil)
+
+	if got := stream.response.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", got)
+	}
+	if frame := stream.frame(t); frame != "retry: 5000" {
+		t.Fatalf("first frame = %q, want the retry interval", frame)
+	}
+
+	eventually(t, "the stream to register", func() bool { return hub.Metrics().ConnectedClients == 1 })
+	hub.BroadcastToOrg(1, map[string]interface{}{"type": "work_order_created", "asset_id": 8})
+	hub.BroadcastToOrg(1, map[string]interface{}{"type": "work_order_created", "asset_id": 7})
+	if frame := stream.frame(t); frame != `id: 2`+"\n"+`data: {"asset_id":7,"event_id":2,"type":"work_order_created"}` {
+		t.Errorf("frame = %q, want only the asset 7 event", frame)
+	}
+
+	stream.cancel()
+	eventually(t, "the stream to unregister", func() bool { return hub.Metrics().ConnectedClients == 0 })
+}
+
+func TestSSERejectsForbiddenTopics(t *testing.T) {
+	hub, _ := startHub(t, HubOptions{})
+	server := newSSEServer(t, hub)
+
+	response, err := http.Get(server.URL + "/events?topics=asset:7,secret:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", response.StatusCode)
+	}
+}
+
+func TestSSEResumesFromLastEventID(t *testing.T) {
+	hub, _ := startHubWithLog(t, &memoryEventLog{}, HubOptions{})
+	server := newSSEServer(t, hub)
+	_, conn := connect(hub, 1, 11, "admin")
+	for _, kind := range []string{"work_order_created", "low_inventory"} {
+		hub.BroadcastToOrg(1, map[string]interface{}{"type": kind})
+	}
+	// Both events are recorded once another client has received them.
+	conn.next(t)
+	conn.next(t)
+
+	stream := openStream(t, server.URL+"/events", http.Header{"Last-Event-Id": {"1"}})
+	stream.frame(t)
+	if frame := stream.frame(t); frame != `id: 2`+"\n"+`data: {"event_id":2,"type":"low_inventory"}` {
+		t.Errorf("frame = %q, want event 2 replayed", frame)
+	}
+}
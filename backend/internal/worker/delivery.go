@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const deliveryBatchSize = 50
+
+type DeliveryWorker struct {
+	service interface {
+		DeliverDue(limit int) int
+	}
+	interval time.Duration
+	running  bool
+	mu       sync.Mutex
+}
+
+func NewDeliveryWorker(service interface {
+	DeliverDue(limit int) int
+}, interval time.Duration) *DeliveryWorker {
+	return &DeliveryWorker{
+		service:  service,
+		interval: interval,
+	}
+}
+
+func (d *DeliveryWorker) Start(ctx context.Context) {
+	d.mu.Lock()
+	if d.running {
+		d.mu.Unlock()
+		return
+	}
+	d.running = true
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		d.running = false
+		d.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.drain(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (d *DeliveryWorker) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		if d.service.DeliverDue(deliveryBatchSize) < deliveryBatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"assetsentinel/internal/events"
+	"assetsentinel/internal/repository"
+)
+
+const (
+	outboxBatchSize   = 100
+	outboxLease       = time.Minute
+	outboxMaxAttempts = 20
+	outboxMaxBackoff  = 5 * time.Minute
+	outboxRetention   = 24 * time.Hour
+)
+
+type OutboxRelay struct {
+	repo      *repository.Repository
+	publisher interface {
+		Dispatch(event *events.Envelope, deliveries events.DeliveryLog) error
+	}
+	interval time.Duration
+	running  bool
+	mu       sync.Mutex
+}
+
+func NewOutboxRelay(repo *repository.Repository, publisher interface {
+	Dispatch(event *events.Envelope, deliveries events.DeliveryLog) error
+}, interval time.Duration) *OutboxRelay {
+	return &OutboxRelay{
+		repo:      repo,
+		publisher: publisher,
+		interval:  interval,
+	}
+}
+
+func (r *OutboxRelay) Start(ctx context.Context) {
+	r.mu.Lock()
+	if r.running {
+		r.mu.Unlock()
+		return
+	}
+	r.running = true
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.running = false
+		r.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.drain(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *OutboxRelay) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		if r.relayDue(ctx) < outboxBatchSize {
+			break
+		}
+	}
+	if err := r.repo.DeleteDeliveredOutboxEvents(time.Now().UTC().Add(-outboxRetention)); err != nil {
+		log.Printf("Error purging outbox: %v", err)
+	}
+}
+
+func (r *OutboxRelay) relayDue(ctx context.Context) int {
+	now := time.Now().UTC().Truncate(time.Second)
+	pending, err := r.repo.ListDueOutboxEvents(now, outboxBatchSize)
+	if err != nil {
+		log.Printf("Error loading outbox events: %v", err)
+		return 0
+	}
+
+	for i := range pending {
+		if ctx.Err() != nil {
+			break
+		}
+		claimed, err := r.repo.ClaimOutboxEvent(pending[i].ID, now, now.Add(outboxLease))
+		if err != nil {
+			log.Printf("Error claiming outbox event %d: %v", pending[i].ID, err)
+			continue
+		}
+		if claimed {
+			r.relay(&pending[i])
+		}
+	}
+	return len(pending)
+}
+
+func (r *OutboxRelay) relay(entry *repository.OutboxEvent) {
+	event, err := events.Decode(entry.Payload)
+	if err != nil {
+		r.fail(entry, err, true)
+		return
+	}
+	delivered, err := r.repo.ListOutboxDeliveries(entry.ID)
+	if err != nil {
+		r.fail(entry, err, false)
+		return
+	}
+	deliveries := &outboxDeliveries{repo: r.repo, entryID: entry.ID, delivered: make(map[string]bool)}
+	for _, consumer := range delivered {
+		deliveries.delivered[consumer] = true
+	}
+	if err := r.publisher.Dispatch(event, deliveries); err != nil {
+		r.fail(entry, err, false)
+		return
+	}
+	if err := r.repo.MarkOutboxEventDelivered(entry.ID); err != nil {
+		log.Printf("Error marking outbox event %d delivered: %v", entry.ID, err)
+	}
+}
+
+type outboxDeliveries struct {
+	repo      *repository.Repository
+	entryID   uint
+	delivered map[string]bool
+}
+
+func (d *outboxDeliveries) Delivered(consumer string) bool {
+	return d.delivered[consumer]
+}
+
+func (d *outboxDeliveries) RecordDelivery(consumer string) error {
+	return d.repo.RecordOutboxDelivery(d.entryID, consumer)
+}
+
+func (r *OutboxRelay) fail(entry *repository.OutboxEvent, cause error, permanent bool) {
+	message := cause.Error()
+	entry.Attempts++
+	entry.LastError = &message
+	if permanent || entry.Attempts >= outboxMaxAttempts {
+		entry.Status = "failed"
+		log.Printf("Giving up on outbox event %s after %d attempts: %v", entry.EventID, entry.Attempts, cause)
+	} else {
+		backoff := time.Second << uint(min(entry.Attempts-1, 20))
+		if backoff > outboxMaxBackoff {
+			backoff = outboxMaxBackoff
+		}
+		next := time.Now().UTC().Add(backoff).Truncate(time.Second)
+		entry.NextAttemptAt = &next
+	}
+	if err := r.repo.RecordOutboxFailure(entry); err != nil {
+		log.Printf("Error recording outbox failure for event %d: %v", entry.ID, err)
+	}
+}
@@ -0,0 +1,78 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"assetsentinel/internal/events"
+	"assetsentinel/internal/repository"
+)
+
+type recordingConsumer struct {
+	seen []string
+	fail int
+}
+
+func (c *recordingConsumer) Consume(event *events.Envelope) error {
+	if c.fail > 0 {
+		c.fail--
+		return errors.New("consumer unavailable")
+	}
+	c.seen = append(c.seen, event.ID)
+	return nil
+}
+
+func TestOutboxRelayDoesNotRedeliverAfterOneConsumerFails(t *testing.T) {
+	repo := openRepository(t, filepath.Join(t.TempDir(), "test.db"))
+	if err := repository.RunMigrations(repo.DB); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.WithTx(func(tx *repository.Repository) error {
+		return events.Enqueue(tx, 1, events.System(), events.MaintenanceDue{MaintenancePlanID: 1, AssetID: 2})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	realtime, webhooks, notifications, audit := &recordingConsumer{}, &recordingConsumer{fail: 2}, &recordingConsumer{}, &recordingConsumer{}
+	relay := NewOutboxRelay(repo, events.NewPublisher(
+		events.Subscription{Name: "realtime", Consumer: realtime},
+		events.Subscription{Name: "webhooks", Consumer: webhooks},
+		events.Subscription{Name: "notifications", Consumer: notifications},
+		events.Subscription{Name: "audit", Consumer: audit},
+	), time.Second)
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		if _, err := repo.DB.Exec(`UPDATE outbox_events SET next_attempt_at = ?`, time.Now().UTC().Add(-time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		relay.relayDue(context.Background())
+	}
+
+	for name, consumer := range map[string]*recordingConsumer{"realtime": realtime, "webhooks": webhooks, "notifications": notifications, "audit": audit} {
+		if len(consumer.seen) != 1 {
+			t.Errorf("%s received the event %d times, want once", name, len(consumer.seen))
+		}
+	}
+	var status string
+	var attempts int
+	if err := repo.DB.QueryRow(`SELECT status, attempts FROM outbox_events`).Scan(&status, &attempts); err != nil {
+		t.Fatal(err)
+	}
+	if status != "delivered" || attempts != 3 {
+		t.Errorf("outbox entry is %s after %d attempts, want delivered after 3", status, attempts)
+	}
+
+	if err := repo.DeleteDeliveredOutboxEvents(time.Now().UTC().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	var remaining int
+	if err := repo.DB.QueryRow(`SELECT COUNT(*) FROM outbox_deliveries`).Scan(&remaining); err != nil {
+		t.Fatal(err)
+	}
+	if remaining != 0 {
+		t.Errorf("%d delivery records left after purging the event", remaining)
+	}
+}
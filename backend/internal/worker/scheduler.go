@@ -1,30 +1,71 @@
 package worker
 
 import (
-	"assetsentinel/internal/repository"
-	"assetsentinel/internal/websocket"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
+	"os"
 	"sync"
 	"time"
+
+	"assetsentinel/internal/calendar"
+	"assetsentinel/internal/events"
+	"assetsentinel/internal/rbac"
+	"assetsentinel/internal/repository"
+	"assetsentinel/internal/services"
+)
+
+const (
+	jobPollInterval = 15 * time.Second
+	jobLease        = 2 * time.Minute
+	jobMaxAttempts  = 5
+	jobRetryBackoff = 30 * time.Second
+
+	maxOccurrencesPerRun = 100
 )
 
+type Job struct {
+	Name     string
+	Schedule string
+	Run      func(ctx context.Context) error
+}
+
 type Scheduler struct {
-	repo    *repository.Repository
-	hub     *websocket.Hub
-	stop    chan bool
-	running bool
-	mu      sync.Mutex
+	repo       *repository.Repository
+	notifier   *services.NotificationService
+	workOrders *services.WorkOrderService
+	owner      string
+	jobs       []Job
+	running    bool
+	mu         sync.Mutex
 }
 
-func NewScheduler(repo *repository.Repository, hub *websocket.Hub) *Scheduler {
-	return &Scheduler{
-		repo: repo,
-		hub:  hub,
-		stop: make(chan bool),
+func NewScheduler(repo *repository.Repository, notifier *services.NotificationService, workOrders *services.WorkOrderService) *Scheduler {
+	s := &Scheduler{
+		repo:       repo,
+		notifier:   notifier,
+		workOrders: workOrders,
+		owner:      leaseOwner(),
+	}
+	s.jobs = []Job{
+		{Name: "maintenance_due", Schedule: "0 * * * *", Run: s.checkMaintenanceDue},
+		{Name: "maintenance_overdue", Schedule: "0 * * * *", Run: s.checkOverdueTasks},
 	}
+	return s
 }
 
-func (s *Scheduler) Start() {
+func leaseOwner() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+func (s *Scheduler) Start(ctx context.Context) {
 	s.mu.Lock()
 	if s.running {
 		s.mu.Unlock()
@@ -32,93 +73,294 @@ func (s *Scheduler) Start() {
 	}
 	s.running = true
 	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
 
-	ticker := time.NewTicker(1 * time.Hour)
+	for _, job := range s.jobs {
+		next, err := services.NextJobRun(job.Schedule, time.Now())
+		if err != nil {
+			log.Printf("Error scheduling job %s: %v", job.Name, err)
+			continue
+		}
+		if err := s.repo.RegisterJob(job.Name, job.Schedule, next); err != nil {
+			log.Printf("Error registering job %s: %v", job.Name, err)
+		}
+	}
+
+	ticker := time.NewTicker(jobPollInterval)
 	defer ticker.Stop()
 
 	for {
+		s.runDue(ctx)
 		select {
 		case <-ticker.C:
-			s.checkMaintenanceDue()
-			s.checkOverdueTasks()
-		case <-s.stop:
+		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-func (s *Scheduler) Stop() {
-	s.stop <- true
-	s.mu.Lock()
-	s.running = false
-	s.mu.Unlock()
+func (s *Scheduler) runDue(ctx context.Context) {
+	for _, job := range s.jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		s.runJob(ctx, job)
+	}
 }
 
-func (s *Scheduler) checkMaintenanceDue() {
-	orgs, err := s.repo.ListOrganizations()
+func (s *Scheduler) runJob(ctx context.Context, job Job) {
+	now := time.Now().UTC().Truncate(time.Second)
+	state, err := s.repo.ClaimJob(job.Name, s.owner, now, now.Add(jobLease))
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
 	if err != nil {
-		log.Printf("Error fetching organizations: %v", err)
+		log.Printf("Error claiming job %s: %v", job.Name, err)
 		return
 	}
 
+	run := &repository.JobRun{JobName: job.Name, Trigger: "schedule", Attempt: state.Attempts + 1, Owner: s.owner}
+	switch {
+	case state.RunRequested:
+		run.Trigger = "manual"
+	case state.Attempts > 0:
+		run.Trigger = "retry"
+	}
+	abandoned, err := s.repo.AbandonJobRuns(job.Name, now)
+	if err != nil {
+		log.Printf("Error recovering runs of job %s: %v", job.Name, err)
+	}
+	if abandoned > 0 {
+		run.Trigger = "recovery"
+	}
+	if err := s.repo.CreateJobRun(run); err != nil {
+		log.Printf("Error recording run of job %s: %v", job.Name, err)
+	}
+
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	go s.holdLease(runCtx, cancel, job.Name)
+	runErr := job.Run(runCtx)
+	cancel()
+
+	s.finish(state, run, runErr)
+}
+
+func (s *Scheduler) holdLease(ctx context.Context, cancel context.CancelFunc, name string) {
+	ticker := time.NewTicker(jobLease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			renewed, err := s.repo.RenewJobLease(name, s.owner, time.Now().UTC().Truncate(time.Second).Add(jobLease))
+			if err != nil {
+				log.Printf("Error renewing lease of job %s: %v", name, err)
+				continue
+			}
+			if !renewed {
+				log.Printf("Lost lease of job %s, cancelling run", name)
+				cancel()
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Scheduler) finish(job *repository.Job, run *repository.JobRun, runErr error) {
+	finished := time.Now().UTC()
+	duration := finished.Sub(run.StartedAt).Milliseconds()
+	run.FinishedAt = &finished
+	run.DurationMS = &duration
+	run.Status = "succeeded"
+	if runErr != nil {
+		message := runErr.Error()
+		run.Status = "failed"
+		run.Error = &message
+		log.Printf("Job %s failed on attempt %d: %v", job.Name, run.Attempt, runErr)
+	}
+	if run.ID != 0 {
+		if err := s.repo.FinishJobRun(run); err != nil {
+			log.Printf("Error recording run of job %s: %v", job.Name, err)
+		}
+	}
+
+	next, err := services.NextJobRun(job.Schedule, finished)
+	if err != nil {
+		log.Printf("Error scheduling job %s: %v", job.Name, err)
+		next = finished.Add(time.Hour)
+	}
+	job.Attempts = 0
+	if runErr != nil && run.Attempt < jobMaxAttempts {
+		job.Attempts = run.Attempt
+		if retry := finished.Add(jobRetryBackoff << (run.Attempt - 1)); retry.Before(next) {
+			next = retry
+		}
+	}
+	job.NextRunAt = next
+	job.LastRunAt = &run.StartedAt
+	job.LastStatus = &run.Status
+	job.LastError = run.Error
+
+	if err := s.repo.ReleaseJob(job, s.owner); err != nil {
+		log.Printf("Error releasing job %s: %v", job.Name, err)
+	}
+}
+
+func (s *Scheduler) checkMaintenanceDue(ctx context.Context) error {
+	orgs, err := s.repo.ListOrganizations()
+	if err != nil {
+		return fmt.Errorf("fetching organizations: %w", err)
+	}
+
+	var errs []error
 	for _, org := range orgs {
-		plans, err := s.repo.GetMaintenancePlansDue(org.ID)
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		cal, err := s.repo.GetBusinessCalendar(org.ID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("loading business calendar for org %d: %w", org.ID, err))
+			continue
+		}
+		today := cal.Today(time.Now())
+		plans, err := s.repo.GetMaintenancePlansDue(org.ID, today.AddDate(0, 0, calendar.MaxShiftDays))
 		if err != nil {
-			log.Printf("Error fetching maintenance plans for org %d: %v", org.ID, err)
+			errs = append(errs, fmt.Errorf("fetching maintenance plans for org %d: %w", org.ID, err))
 			continue
 		}
 
 		for _, plan := range plans {
-			task := &repository.MaintenanceTask{
-				OrganizationID:    plan.OrganizationID,
-				MaintenancePlanID: plan.ID,
-				AssetID:           plan.AssetID,
-				ScheduledDate:     plan.NextMaintenanceDate,
-				Status:            "pending",
+			if err := ctx.Err(); err != nil {
+				return errors.Join(append(errs, err)...)
 			}
-
-			if err := s.repo.CreateMaintenanceTask(task); err != nil {
-				log.Printf("Error creating maintenance task: %v", err)
-				continue
+			if err := s.generatePlanTasks(ctx, cal, today, &plan); err != nil {
+				errs = append(errs, fmt.Errorf("creating maintenance tasks for plan %d: %w", plan.ID, err))
 			}
+		}
+	}
+	return errors.Join(errs...)
+}
 
-			s.hub.BroadcastToOrg(org.ID, map[string]interface{}{
-				"type":           "maintenance_due",
-				"maintenance_id": plan.ID,
-				"asset_id":       plan.AssetID,
-				"scheduled_date": plan.NextMaintenanceDate,
-			})
+func (s *Scheduler) generatePlanTasks(ctx context.Context, cal *calendar.Calendar, today time.Time, plan *repository.MaintenancePlan) error {
+	recurrence, err := services.PlanRecurrence(plan)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < maxOccurrencesPerRun; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		scheduled := cal.Shift(plan.NextMaintenanceDate, plan.NonWorkingDayShift)
+		if scheduled.After(today) {
+			return nil
+		}
+		next, more := recurrence.After(plan.NextMaintenanceDate)
+		task := &repository.MaintenanceTask{
+			OrganizationID:    plan.OrganizationID,
+			MaintenancePlanID: plan.ID,
+			AssetID:           plan.AssetID,
+			ScheduledDate:     scheduled,
+			Status:            "pending",
+		}
+
+		advanced := false
+		var workOrder *repository.WorkOrder
+		err := s.repo.WithTx(func(tx *repository.Repository) error {
+			created, err := tx.CreateMaintenanceTaskOnce(task)
+			if err != nil {
+				return err
+			}
+			if created {
+				if err := events.Enqueue(tx, plan.OrganizationID, events.System(), events.MaintenanceDue{
+					MaintenancePlanID: plan.ID,
+					MaintenanceTaskID: task.ID,
+					AssetID:           plan.AssetID,
+					ScheduledDate:     scheduled,
+				}); err != nil {
+					return err
+				}
+				if plan.AutoWorkOrder {
+					if workOrder, err = s.workOrders.CreateForTask(tx, plan, task); err != nil {
+						return err
+					}
+				}
+			}
+			if !more {
+				return nil
+			}
+			advanced, err = tx.AdvanceMaintenancePlan(plan, next)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if workOrder != nil {
+			s.workOrders.NotifyAssigned(workOrder)
+		}
+		if !advanced {
+			return nil
 		}
 	}
+	return nil
 }
 
-func (s *Scheduler) checkOverdueTasks() {
+func (s *Scheduler) checkOverdueTasks(ctx context.Context) error {
 	orgs, err := s.repo.ListOrganizations()
 	if err != nil {
-		log.Printf("Error fetching organizations: %v", err)
-		return
+		return fmt.Errorf("fetching organizations: %w", err)
 	}
 
+	var errs []error
 	for _, org := range orgs {
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
 		tasks, err := s.repo.GetOverdueMaintenanceTasks(org.ID)
 		if err != nil {
-			log.Printf("Error fetching overdue tasks for org %d: %v", org.ID, err)
+			errs = append(errs, fmt.Errorf("fetching overdue tasks for org %d: %w", org.ID, err))
 			continue
 		}
 
 		for _, task := range tasks {
-			task.Status = "overdue"
-			if err := s.repo.UpdateMaintenanceTask(&task); err != nil {
-				log.Printf("Error updating overdue task: %v", err)
+			if err := ctx.Err(); err != nil {
+				return errors.Join(append(errs, err)...)
+			}
+			marked := false
+			err := s.repo.WithTx(func(tx *repository.Repository) error {
+				var err error
+				marked, err = tx.MarkMaintenanceTaskOverdue(&task)
+				if err != nil || !marked {
+					return err
+				}
+				return events.Enqueue(tx, org.ID, events.System(), events.MaintenanceOverdue{
+					MaintenancePlanID: task.MaintenancePlanID,
+					MaintenanceTaskID: task.ID,
+					AssetID:           task.AssetID,
+					ScheduledDate:     task.ScheduledDate,
+				})
+			})
+			if err != nil {
+				errs = append(errs, fmt.Errorf("updating overdue task %d: %w", task.ID, err))
+				continue
+			}
+			if !marked {
 				continue
 			}
 
-			s.hub.BroadcastToOrg(org.ID, map[string]interface{}{
-				"type":           "maintenance_overdue",
-				"maintenance_id": task.ID,
-				"asset_id":       task.AssetID,
-				"scheduled_date": task.ScheduledDate,
-			})
+			err = s.notifier.NotifyRoles(org.ID, []string{rbac.RoleAdmin, rbac.RoleMaintenanceManager}, services.NotificationMaintenanceOverdue,
+				"Maintenance overdue", fmt.Sprintf("Maintenance task %d scheduled for %s is overdue.", task.ID, task.ScheduledDate.Format("2006-01-02")),
+				map[string]interface{}{"maintenance_task_id": task.ID, "maintenance_plan_id": task.MaintenancePlanID, "asset_id": task.AssetID})
+			if err != nil {
+				errs = append(errs, fmt.Errorf("sending overdue notifications for task %d: %w", task.ID, err))
+			}
 		}
 	}
+	return errors.Join(errs...)
 }
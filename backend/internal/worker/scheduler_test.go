@@ -0,0 +1,269 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"assetsentinel/internal/calendar"
+	"assetsentinel/internal/repository"
+	"assetsentinel/internal/services"
+)
+
+func openRepository(t *testing.T, path string) *repository.Repository {
+	t.Helper()
+	db, err := repository.NewDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return repository.NewRepository(db)
+}
+
+// TestSchedulersShareJobs runs several schedulers against one database file,
+// as separate server instances would, and checks that each due job runs once
+// and each plan occurrence gets exactly one task.
+func TestSchedulersShareJobs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	repo := openRepository(t, path)
+	if err := repository.RunMigrations(repo.DB); err != nil {
+		t.Fatal(err)
+	}
+
+	org := repository.Organization{Name: "Acme"}
+	if err := repo.CreateOrganization(&org); err != nil {
+		t.Fatal(err)
+	}
+	asset := repository.Asset{OrganizationID: org.ID, Name: "Pump", Category: "pump", Status: "active"}
+	if err := repo.CreateAsset(&asset); err != nil {
+		t.Fatal(err)
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	plan := repository.MaintenancePlan{OrganizationID: org.ID, AssetID: asset.ID, FrequencyDays: 7,
+		NextMaintenanceDate: today.AddDate(0, 0, -21), NonWorkingDayShift: calendar.ShiftNone, WorkOrderPriority: "medium", AssignmentStrategy: "round_robin"}
+	if err := repo.CreateMaintenancePlan(&plan); err != nil {
+		t.Fatal(err)
+	}
+
+	due := time.Now().UTC().Add(-time.Minute)
+	for _, name := range []string{"maintenance_due", "maintenance_overdue"} {
+		if err := repo.RegisterJob(name, "0 * * * *", due); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const instances = 4
+	var wg sync.WaitGroup
+	for i := 0; i < instances; i++ {
+		instance := openRepository(t, path)
+		notifier := services.NewNotificationService(instance, nil, nil, 8)
+		scheduler := NewScheduler(instance, notifier, services.NewWorkOrderService(instance, notifier, services.NewDispatchService(instance, services.NewRoleService(instance))))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scheduler.runDue(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	for _, name := range []string{"maintenance_due", "maintenance_overdue"} {
+		runs, total, err := repo.ListJobRuns(name, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 1 {
+			t.Fatalf("%s ran %d times, want once", name, total)
+		}
+		if runs[0].Status != "succeeded" || runs[0].Trigger != "schedule" || runs[0].Attempt != 1 {
+			t.Errorf("%s run = %+v, want a first scheduled attempt that succeeded", name, runs[0])
+		}
+	}
+
+	rows, err := repo.DB.Query(`SELECT scheduled_date, COUNT(*) FROM maintenance_tasks WHERE maintenance_plan_id = ? GROUP BY scheduled_date`, plan.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	dates := 0
+	for rows.Next() {
+		var date string
+		var count int
+		if err := rows.Scan(&date, &count); err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Errorf("%d tasks scheduled for %s, want 1", count, date)
+		}
+		dates++
+	}
+	if dates != 4 {
+		t.Errorf("tasks were created for %d dates, want 4", dates)
+	}
+}
+
+// TestClaimedJobStateIsCurrent checks that a run sees the job as it was when
+// claimed rather than as an earlier read left it.
+func TestClaimedJobStateIsCurrent(t *testing.T) {
+	repo := openRepository(t, filepath.Join(t.TempDir(), "test.db"))
+	if err := repository.RunMigrations(repo.DB); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := repo.RegisterJob("maintenance_due", "0 * * * *", now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RequestJobRun("maintenance_due", now); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := repo.ClaimJob("maintenance_due", "a", now, now.Add(jobLease))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !job.RunRequested || job.LeaseOwner == nil || *job.LeaseOwner != "a" {
+		t.Errorf("claimed job = %+v, want the manual request and the new lease", job)
+	}
+	if _, err := repo.ClaimJob("maintenance_due", "b", now, now.Add(jobLease)); err == nil {
+		t.Error("a second owner claimed a leased job")
+	}
+	stored, err := repo.GetJob("maintenance_due")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.RunRequested {
+		t.Error("claiming the job left the manual request pending")
+	}
+}
+
+// newJobScheduler returns a scheduler running only a yearly job named "test"
+// that is due now.
+func newJobScheduler(t *testing.T, run func(ctx context.Context) error) (*repository.Repository, *Scheduler) {
+	t.Helper()
+	repo := openRepository(t, filepath.Join(t.TempDir(), "test.db"))
+	if err := repository.RunMigrations(repo.DB); err != nil {
+		t.Fatal(err)
+	}
+	scheduler := NewScheduler(repo, nil, nil)
+	scheduler.jobs = []Job{{Name: "test", Schedule: "0 0 1 1 *", Run: run}}
+	if err := repo.RegisterJob("test", "0 0 1 1 *", time.Now().UTC().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	return repo, scheduler
+}
+
+func makeDue(t *testing.T, repo *repository.Repository) {
+	t.Helper()
+	if _, err := repo.DB.Exec(`UPDATE jobs SET next_run_at = ? WHERE name = 'test'`, time.Now().UTC().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFailedJobIsRetriedWithBackoff(t *testing.T) {
+	repo, scheduler := newJobScheduler(t, func(ctx context.Context) error { return errors.New("upstream unavailable") })
+
+	for attempt := 1; attempt <= jobMaxAttempts; attempt++ {
+		makeDue(t, repo)
+		before := time.Now().UTC()
+		scheduler.runDue(context.Background())
+
+		job, err := repo.GetJob("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.LastStatus == nil || *job.LastStatus != "failed" || job.LeaseOwner != nil {
+			t.Fatalf("attempt %d left the job %+v, want it failed and released", attempt, job)
+		}
+		if attempt < jobMaxAttempts {
+			backoff := jobRetryBackoff << (attempt - 1)
+			if job.Attempts != attempt || job.NextRunAt.Before(before.Add(backoff).Truncate(time.Second)) || job.NextRunAt.After(time.Now().Add(backoff)) {
+				t.Errorf("attempt %d: attempts %d, next run %v, want a retry in %v", attempt, job.Attempts, job.NextRunAt, backoff)
+			}
+			continue
+		}
+		next, _ := services.NextJobRun("0 0 1 1 *", time.Now())
+		if job.Attempts != 0 || !job.NextRunAt.Equal(next) {
+			t.Errorf("after %d attempts: attempts %d, next run %v, want the next scheduled run %v", attempt, job.Attempts, job.NextRunAt, next)
+		}
+	}
+
+	runs, _, err := repo.ListJobRuns("test", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != jobMaxAttempts || runs[len(runs)-1].Trigger != "schedule" || runs[0].Trigger != "retry" || runs[0].Attempt != jobMaxAttempts {
+		t.Errorf("runs = %+v, want a scheduled run followed by retries", runs)
+	}
+}
+
+func TestManualTriggerRunsDisabledJob(t *testing.T) {
+	runs := 0
+	repo, scheduler := newJobScheduler(t, func(ctx context.Context) error { runs++; return nil })
+	jobs := services.NewJobService(repo)
+	disabled := false
+	if _, err := jobs.Update("test", nil, &disabled); err != nil {
+		t.Fatal(err)
+	}
+	makeDue(t, repo)
+
+	scheduler.runDue(context.Background())
+	if runs != 0 {
+		t.Fatal("a disabled job ran on schedule")
+	}
+
+	if _, err := jobs.Trigger("test"); err != nil {
+		t.Fatal(err)
+	}
+	scheduler.runDue(context.Background())
+	if runs != 1 {
+		t.Fatalf("job ran %d times after a manual trigger, want once", runs)
+	}
+	history, _, err := repo.ListJobRuns("test", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Trigger != "manual" || history[0].Status != "succeeded" {
+		t.Errorf("runs = %+v, want one successful manual run", history)
+	}
+	job, err := repo.GetJob("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.RunRequested || job.Enabled {
+		t.Errorf("job = %+v, want the request cleared and the job still disabled", job)
+	}
+
+	scheduler.runDue(context.Background())
+	if runs != 1 {
+		t.Error("a manual trigger ran more than once")
+	}
+}
+
+func TestExpiredLeaseIsRecovered(t *testing.T) {
+	repo, scheduler := newJobScheduler(t, func(ctx context.Context) error { return nil })
+	expired := time.Now().UTC().Add(-time.Minute)
+	if _, err := repo.DB.Exec(`UPDATE jobs SET lease_owner = 'crashed', lease_until = ? WHERE name = 'test'`, expired); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CreateJobRun(&repository.JobRun{JobName: "test", Trigger: "schedule", Attempt: 1, Owner: "crashed"}); err != nil {
+		t.Fatal(err)
+	}
+
+	scheduler.runDue(context.Background())
+
+	runs, _, err := repo.ListJobRuns("test", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("got %d runs, want the abandoned run and its recovery", len(runs))
+	}
+	if runs[1].Status != "failed" || runs[1].Error == nil {
+		t.Errorf("abandoned run = %+v, want it failed", runs[1])
+	}
+	if runs[0].Trigger != "recovery" || runs[0].Status != "succeeded" || runs[0].Owner != scheduler.owner {
+		t.Errorf("new run = %+v, want a successful recovery by this scheduler", runs[0])
+	}
+}
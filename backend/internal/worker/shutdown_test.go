@@ -0,0 +1,121 @@
+package worker
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"assetsentinel/internal/events"
+)
+
+type countingDeliveries struct {
+	calls atomic.Int64
+}
+
+func (d *countingDeliveries) DeliverDue(limit int) int {
+	d.calls.Add(1)
+	return 0
+}
+
+type noopPublisher struct{}
+
+func (noopPublisher) Dispatch(event *events.Envelope, deliveries events.DeliveryLog) error {
+	return nil
+}
+
+func stopsOnCancel(t *testing.T, name string, start func(ctx context.Context)) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		start(ctx)
+		close(stopped)
+	}()
+	time.Sleep(30 * time.Millisecond)
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Errorf("%s did not stop after its context was cancelled", name)
+	}
+}
+
+func TestWorkersStopOnCancel(t *testing.T) {
+	deliveries := &countingDeliveries{}
+	stopsOnCancel(t, "delivery worker", NewDeliveryWorker(deliveries, 10*time.Millisecond).Start)
+	if deliveries.calls.Load() == 0 {
+		t.Error("delivery worker never polled")
+	}
+
+	repo, scheduler := newJobScheduler(t, func(ctx context.Context) error { return nil })
+	stopsOnCancel(t, "outbox relay", NewOutboxRelay(repo, noopPublisher{}, 10*time.Millisecond).Start)
+	stopsOnCancel(t, "scheduler", scheduler.Start)
+}
+
+func TestSchedulerFinishesRunningJobOnShutdown(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	repo, scheduler := newJobScheduler(t, func(ctx context.Context) error {
+		close(started)
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		scheduler.Start(ctx)
+		close(stopped)
+	}()
+	<-started
+	cancel()
+
+	select {
+	case <-stopped:
+		t.Fatal("scheduler stopped before its running job finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("scheduler did not stop once the job finished")
+	}
+
+	runs, _, err := repo.ListJobRuns("test", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Status != "succeeded" {
+		t.Errorf("runs = %+v, want the interrupted run to finish and succeed", runs)
+	}
+	job, err := repo.GetJob("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.LeaseOwner != nil {
+		t.Errorf("job is still leased to %s after shutdown", *job.LeaseOwner)
+	}
+}
+
+func TestDeliveryWorkerDrainsFullBatches(t *testing.T) {
+	var calls atomic.Int64
+	worker := NewDeliveryWorker(deliverFunc(func(limit int) int {
+		if calls.Add(1) <= 3 {
+			return limit
+		}
+		return 0
+	}), time.Hour)
+	worker.drain(context.Background())
+	if calls.Load() != 4 {
+		t.Errorf("drained %d batches, want 4", calls.Load())
+	}
+}
+
+type deliverFunc func(limit int) int
+
+func (f deliverFunc) DeliverDue(limit int) int { return f(limit) }
@@ -2,6 +2,8 @@ import axios from 'axios'
 
 const API_URL = import.meta.env.VITE_API_URL || 'http://localhost:8080/api'
 const WS_URL = import.meta.env.VITE_WS_URL || 'ws://localhost:8080/ws'
+const SSE_URL = import.meta.env.VITE_SSE_URL || 'http://localhost:8080/events'
+const REALTIME_TRANSPORT = import.meta.env.VITE_REALTIME_TRANSPORT || 'websocket'
 
 const api = axios.create({
   baseURL: API_URL,
@@ -16,7 +18,15 @@ api.interceptors.request.use(config => {
 
 export const auth = {
   login: (email, password) => api.post('/auth/login', { email, password }),
-  register: (data) => api.post('/auth/register', data)
+  register: (data) => api.post('/auth/register', data),
+  permissions: () => api.get('/me/permissions'),
+  forgotPassword: (email) => api.post('/auth/password/forgot', { email }),
+  resetPassword: (token, password) => api.post('/auth/password/reset', { token, password })
+}
+
+export const can = (permission) => {
+  const permissions = JSON.parse(localStorage.getItem('permissions') || '[]')
+  return permissions.includes(permission)
 }
 
 export const assets = {
@@ -32,7 +42,16 @@ export const maintenance = {
   get: (id) => api.get(`/maintenance-plans/${id}`),
   create: (data) => api.post('/maintenance-plans', data),
   update: (id, data) => api.put(`/maintenance-plans/${id}`, data),
-  delete: (id) => api.delete(`/maintenance-plans/${id}`)
+  delete: (id) => api.delete(`/maintenance-plans/${id}`),
+  occurrences: (id, params) => api.get(`/maintenance-plans/${id}/occurrences`, { params }),
+  preview: (data, params) => api.post('/maintenance-plans/preview', data, { params }),
+  forecast: (params) => api.get('/maintenance-plans/forecast', { params }),
+  exportForecast: (params) => api.get('/maintenance-plans/forecast', { params: { ...params, format: 'csv' }, responseType: 'blob' })
+}
+
+export const maintenanceTasks = {
+  get: (id) => api.get(`/maintenance-tasks/${id}`),
+  complete: (id, data) => api.post(`/maintenance-tasks/${id}/complete`, data)
 }
 
 export const workOrders = {
@@ -40,7 +59,22 @@ export const workOrders = {
   get: (id) => api.get(`/work-orders/${id}`),
   create: (data) => api.post('/work-orders', data),
   update: (id, data) => api.put(`/work-orders/${id}`, data),
-  delete: (id) => api.delete(`/work-orders/${id}`)
+  delete: (id) => api.delete(`/work-orders/${id}`),
+  procedure: (id) => api.get(`/work-orders/${id}/procedure`),
+  submitResults: (id, results) => api.post(`/work-orders/${id}/procedure/results`, { results })
+}
+
+export const dispatch = {
+  schedule: (params) => api.get('/dispatch/schedule', { params }),
+  suggestions: (data) => api.post('/dispatch/suggestions', data)
+}
+
+export const procedures = {
+  list: () => api.get('/procedures'),
+  get: (id) => api.get(`/procedures/${id}`),
+  create: (data) => api.post('/procedures', data),
+  update: (id, data) => api.put(`/procedures/${id}`, data),
+  delete: (id) => api.delete(`/procedures/${id}`)
 }
 
 export const inventory = {
@@ -65,27 +99,124 @@ export const audit = {
   list: (params) => api.get('/audit', { params })
 }
 
+export const users = {
+  unlock: (id) => api.post(`/users/${id}/unlock`)
+}
+
+export const security = {
+  events: (params) => api.get('/security/events', { params })
+}
+
+export const roles = {
+  list: () => api.get('/roles'),
+  get: (id) => api.get(`/roles/${id}`),
+  create: (data) => api.post('/roles', data),
+  update: (id, data) => api.put(`/roles/${id}`, data),
+  delete: (id) => api.delete(`/roles/${id}`),
+  permissions: () => api.get('/permissions')
+}
+
+export const notifications = {
+  list: (params) => api.get('/notifications', { params }),
+  unreadCount: () => api.get('/notifications/unread-count'),
+  markRead: (id) => api.post(`/notifications/${id}/read`),
+  markAllRead: () => api.post('/notifications/read-all'),
+  preferences: () => api.get('/notifications/preferences'),
+  updatePreferences: (data) => api.put('/notifications/preferences', data),
+  organizationPreferences: () => api.get('/notifications/preferences/organization'),
+  updateOrganizationPreferences: (data) => api.put('/notifications/preferences/organization', data)
+}
+
+export const webhooks = {
+  list: () => api.get('/webhooks'),
+  get: (id) => api.get(`/webhooks/${id}`),
+  create: (data) => api.post('/webhooks', data),
+  update: (id, data) => api.put(`/webhooks/${id}`, data),
+  delete: (id) => api.delete(`/webhooks/${id}`),
+  deliveries: (id, params) => api.get(`/webhooks/${id}/deliveries`, { params }),
+  redeliver: (deliveryId) => api.post(`/webhooks/deliveries/${deliveryId}/redeliver`)
+}
+
+export const jobs = {
+  list: () => api.get('/jobs'),
+  get: (name) => api.get(`/jobs/${name}`),
+  update: (name, data) => api.put(`/jobs/${name}`, data),
+  runs: (name, params) => api.get(`/jobs/${name}/runs`, { params }),
+  run: (name) => api.post(`/jobs/${name}/run`)
+}
+
+export const businessCalendar = {
+  get: () => api.get('/calendar'),
+  update: (data) => api.put('/calendar', data),
+  holidays: () => api.get('/calendar/holidays'),
+  addHoliday: (data) => api.post('/calendar/holidays', data),
+  deleteHoliday: (id) => api.delete(`/calendar/holidays/${id}`)
+}
+
+export const calendarFeeds = {
+  list: () => api.get('/calendar-feeds'),
+  create: (data) => api.post('/calendar-feeds', data),
+  revoke: (id) => api.delete(`/calendar-feeds/${id}`)
+}
+
 class WebSocketService {
   constructor() {
     this.ws = null
     this.listeners = {}
+    this.lastEventId = null
   }
 
-  connect() {
-    const token = localStorage.getItem('token')
-    this.ws = new WebSocket(`${WS_URL}?token=${token}`)
-    
-    this.ws.onmessage = (event) => {
-      const data = JSON.parse(event.data)
-      const type = data.type
-      if (this.listeners[type]) {
-        this.listeners[type].forEach(cb => cb(data))
+  async connect() {
+    let ticket
+    try {
+      ticket = (await api.post('/realtime/tickets')).data.ticket
+    } catch {
+      setTimeout(() => this.connect(), 5000)
+      return
+    }
+    const resume = this.lastEventId !== null ? `&last_event_id=${this.lastEventId}` : ''
+
+    if (REALTIME_TRANSPORT === 'sse') {
+      // Tickets are single-use, so reconnect with a new one instead of
+      // letting EventSource retry the same URL.
+      this.ws = new EventSource(`${SSE_URL}?ticket=${ticket}${resume}`)
+      this.ws.onmessage = (event) => this.dispatch(event)
+      this.ws.onerror = () => {
+        this.ws.close()
+        setTimeout(() => this.connect(), 5000)
       }
+      return
     }
 
+    this.ws = new WebSocket(`${WS_URL}?ticket=${ticket}${resume}`)
+    this.ws.onmessage = (event) => this.dispatch(event)
     this.ws.onclose = () => setTimeout(() => this.connect(), 5000)
   }
 
+  dispatch(event) {
+    const data = JSON.parse(event.data)
+    const type = data.type
+    if (data.event_id) this.lastEventId = data.event_id
+    if (type === 'resync_required') this.lastEventId = data.latest_event_id
+    if (this.listeners[type]) {
+      this.listeners[type].forEach(cb => cb(data))
+    }
+  }
+
+  subscribe(topics) {
+    this.send({ action: 'subscribe', topics })
+  }
+
+  unsubscribe(topics) {
+    this.send({ action: 'unsubscribe', topics })
+  }
+
+  send(frame) {
+    if (this.ws instanceof WebSocket && this.ws.readyState === WebSocket.OPEN) {
+      this.ws.send(JSON.stringify(frame))
+    }
+  }
+
   on(event, callback) {
     if (!this.listeners[event]) this.listeners[event] = []
     this.listeners[event].push(callback)